@@ -9,41 +9,64 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/4hel/paper/gameserver/internal/protocol"
 	"github.com/4hel/paper/gameserver/internal/types"
 )
 
+// clientVersion is reported to the server in the hello handshake
+const clientVersion = "dev"
+
 func main() {
 	// Parse command line arguments
 	var name = flag.String("name", "", "Player name (required)")
 	var server = flag.String("server", "localhost:8080", "Server address")
 	var forceHTTP = flag.Bool("http", false, "Force HTTP instead of HTTPS for production servers")
+	var wire = flag.String("codec", "json", "Wire codec: json or msgpack")
+	var roomSize = flag.Int("room-size", 0, "Players per game, 3 or more for a free-for-all")
+	var teams = flag.Bool("teams", false, "Play 2v2 in teams")
+	var teammate = flag.String("teammate", "", "Name of the player to team up with, implies -teams")
+	var game = flag.String("game", "", "Game type, e.g. matching_pennies, prisoners_dilemma, tic_tac_toe or connect_four (default rps)")
+	var playerKey = flag.String("player-key", "", "Key of your correspondence matches, as printed when it was issued")
 	flag.Parse()
 
 	if *name == "" {
-		fmt.Println("Usage: go run cmd/client/main.go -name <player_name> [-server localhost:8080]")
+		fmt.Println("Usage: go run cmd/client/main.go -name <player_name> [-server localhost:8080] [-codec json|msgpack] [-game rps]")
 		fmt.Println("\nDeveloper Client - prints raw JSON protocol messages")
 		fmt.Println("Commands during gameplay:")
-		fmt.Println("  1, 2, 3     - Rock, Paper, Scissors choices")
+		fmt.Println("  1, 2, 3     - Rock, Paper, Scissors choices, or the game's actions in order")
+		fmt.Println("  <row> <col> - Move in a turn-based game, only the column where pieces drop")
 		fmt.Println("  play        - Play again after game ends")
 		fmt.Println("  quit        - Disconnect from server")
 		os.Exit(1)
 	}
 
 	// Connect to WebSocket server
-	protocol := "ws"
+	scheme := "ws"
 	if !*forceHTTP && strings.Contains(*server, ".") && !strings.HasPrefix(*server, "localhost") && !strings.HasPrefix(*server, "127.0.0.1") {
-		protocol = "wss"
+		scheme = "wss"
 	}
-	url := fmt.Sprintf("%s://%s/ws", protocol, *server)
+	url := fmt.Sprintf("%s://%s/ws", scheme, *server)
 	fmt.Printf("[DEV CLIENT] Connecting to %s as '%s'\n", url, *name)
 
+	var codec protocol.WireCodec
+	switch *wire {
+	case "json":
+		codec = protocol.JSONCodec{}
+	case "msgpack":
+		codec = protocol.MsgpackCodec{}
+	default:
+		log.Fatalf("Unknown codec '%s', use json or msgpack", *wire)
+	}
+
 	// Configure dialer for production servers
-	dialer := websocket.DefaultDialer
-	if protocol == "wss" {
+	dialer := *websocket.DefaultDialer
+	dialer.Subprotocols = []string{codec.Subprotocol()}
+	if scheme == "wss" {
 		dialer.TLSClientConfig = &tls.Config{
 			ServerName: strings.Split(*server, ":")[0], // Extract hostname for SNI
 		}
@@ -55,21 +78,44 @@ func main() {
 	}
 	defer conn.Close()
 
+	if conn.Subprotocol() != codec.Subprotocol() {
+		log.Fatalf("Server does not support the %s codec", *wire)
+	}
+
+	// send encodes an event with the negotiated codec
+	send := func(event types.BaseGameEvent) error {
+		data, err := codec.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(codec.FrameType(), data)
+	}
+
 	fmt.Printf("[DEV CLIENT] Connected! WebSocket established\n")
 	fmt.Printf("[DEV CLIENT] Commands: 1=rock, 2=paper, 3=scissors, play, quit\n")
 	fmt.Printf("[DEV CLIENT] ------- PROTOCOL MESSAGES -------\n")
 
-	// Send join_lobby message
-	joinData, _ := json.Marshal(types.JoinLobbyMessage{Name: *name})
-	joinEvent := types.BaseGameEvent{
-		Type: "join_lobby",
-		Data: joinData,
+	// Send hello handshake
+	helloEvent, _ := protocol.Encode(types.HelloMessage{
+		ProtocolVersion: protocol.Version,
+		ClientName:      "dev-client",
+		ClientVersion:   clientVersion,
+	})
+
+	jsonOut, _ := json.MarshalIndent(helloEvent, "", "  ")
+	fmt.Printf("[SEND] %s\n", string(jsonOut))
+
+	if err := send(helloEvent); err != nil {
+		log.Fatal("Failed to send hello:", err)
 	}
 
-	jsonOut, _ := json.MarshalIndent(joinEvent, "", "  ")
+	// Send join_lobby message
+	joinEvent, _ := protocol.Encode(types.JoinLobbyMessage{Name: *name, RoomSize: *roomSize, Teams: *teams || *teammate != "", Teammate: *teammate, Game: *game})
+
+	jsonOut, _ = json.MarshalIndent(joinEvent, "", "  ")
 	fmt.Printf("[SEND] %s\n", string(jsonOut))
 
-	if err := conn.WriteJSON(joinEvent); err != nil {
+	if err := send(joinEvent); err != nil {
 		log.Fatal("Failed to send join_lobby:", err)
 	}
 
@@ -90,40 +136,81 @@ func main() {
 	// Track game state for input validation
 	var inGame bool = false
 	var waitingForChoice bool = false
+	var waitingForMove bool = false
+	var actions = []string{"rock", "paper", "scissors"}
 
 	// Read messages from server
 	go func() {
 		defer close(done)
 		for {
-			var event types.BaseGameEvent
-			err := conn.ReadJSON(&event)
+			_, data, err := conn.ReadMessage()
 			if err != nil {
 				fmt.Printf("[ERROR] Connection closed: %v\n", err)
 				return
 			}
 
+			var event types.BaseGameEvent
+			if err := codec.Unmarshal(data, &event); err != nil {
+				fmt.Printf("[ERROR] Malformed message: %v\n", err)
+				continue
+			}
+
 			// Print raw JSON received
 			jsonIn, _ := json.MarshalIndent(event, "", "  ")
 			fmt.Printf("[RECV] %s\n", string(jsonIn))
 
 			// Basic state tracking for input validation
 			switch event.Type {
-			case "player_waiting":
+			case protocol.TypePlayerWaiting:
 				inGame = false
 				waitingForChoice = false
 				fmt.Printf("[DEV CLIENT] Waiting for opponent...\n")
-			case "game_starting":
+			case protocol.TypeGameStarting, protocol.TypeGameResumed:
 				inGame = true
+				// Free-for-all and team games are Rock Paper Scissors
+				actions = []string{"rock", "paper", "scissors"}
+				if starting, err := protocol.Decode[types.GameStartingMessage](event); err == nil && len(starting.Actions) > 0 {
+					actions = starting.Actions
+				} else if resumed, err := protocol.Decode[types.GameResumedMessage](event); err == nil && len(resumed.Actions) > 0 {
+					actions = resumed.Actions
+				}
 				// Don't change waitingForChoice here - round_start will set it
-			case "round_start":
+			case protocol.TypeRoundStart:
 				inGame = true // Ensure we're in game when round starts
 				waitingForChoice = true
-				fmt.Printf("[DEV CLIENT] Enter your choice: 1=rock, 2=paper, 3=scissors\n")
-			case "round_result":
+				if start, err := protocol.Decode[types.RoundStartMessage](event); err == nil {
+					if start.SuddenDeath {
+						fmt.Printf("[DEV CLIENT] Sudden death! The next round won decides the game\n")
+					}
+					if len(start.YourHand) > 0 {
+						fmt.Printf("[DEV CLIENT] Your hand: %s, opponent's hand: %s\n", handSummary(start.YourHand), handSummary(start.OpponentHand))
+					}
+				}
+				fmt.Printf("[DEV CLIENT] Enter your choice: %s\n", choiceMenu(actions))
+			case protocol.TypeBoardUpdate:
+				update, _ := protocol.Decode[types.BoardUpdateMessage](event)
+				for _, row := range update.Board {
+					fmt.Printf("    %s\n", row)
+				}
+				waitingForMove = update.YourTurn
+				if waitingForMove {
+					fmt.Printf("[DEV CLIENT] Your move: <row> <column>, or <column> where pieces drop\n")
+				}
+			case protocol.TypeMatchList:
+				if list, err := protocol.Decode[types.MatchListMessage](event); err == nil && list.PlayerKey != "" {
+					fmt.Printf("[DEV CLIENT] Your player key is %s, keep it secret and pass -player-key to get back to your matches\n", list.PlayerKey)
+				}
+			case protocol.TypeYourTurn:
+				turn, _ := protocol.Decode[types.YourTurnMessage](event)
+				fmt.Printf("[DEV CLIENT] Your turn against %s in %s. Enter: move %s <row> <column>\n", turn.Opponent, turn.MatchID, turn.MatchID)
+			case protocol.TypeTeamVote:
+				fmt.Printf("[DEV CLIENT] Your teammate voted, you can change your vote until everyone voted\n")
+			case protocol.TypeRoundResult:
 				waitingForChoice = false
-			case "game_ended":
+			case protocol.TypeGameEnded:
 				inGame = false
 				waitingForChoice = false
+				waitingForMove = false
 				fmt.Printf("[DEV CLIENT] Game ended. Enter: play (to play again) or quit (to disconnect)\n")
 			}
 		}
@@ -140,16 +227,12 @@ func main() {
 			fmt.Printf("\n[DEV CLIENT] Interrupt received, disconnecting...\n")
 			
 			// Send disconnect message
-			disconnectData, _ := json.Marshal(types.DisconnectMessage{})
-			disconnectEvent := types.BaseGameEvent{
-				Type: "disconnect",
-				Data: disconnectData,
-			}
+			disconnectEvent, _ := protocol.Encode(types.DisconnectMessage{})
 			
 			jsonOut, _ := json.MarshalIndent(disconnectEvent, "", "  ")
 			fmt.Printf("[SEND] %s\n", string(jsonOut))
 			
-			conn.WriteJSON(disconnectEvent)
+			send(disconnectEvent)
 			
 			// Close connection gracefully
 			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
@@ -160,47 +243,71 @@ func main() {
 			
 			if waitingForChoice {
 				// Handle game choice input
-				var choice string
-				switch input {
-				case "1":
-					choice = "rock"
-				case "2":
-					choice = "paper"
-				case "3":
-					choice = "scissors"
-				default:
-					fmt.Printf("[DEV CLIENT] Invalid choice '%s'. Use: 1=rock, 2=paper, 3=scissors\n", input)
+				n, err := strconv.Atoi(input)
+				if err != nil || n < 1 || n > len(actions) {
+					fmt.Printf("[DEV CLIENT] Invalid choice '%s'. Use: %s\n", input, choiceMenu(actions))
 					continue
 				}
+				choice := actions[n-1]
 
 				// Send make_choice message
-				choiceData, _ := json.Marshal(types.MakeChoiceMessage{Choice: choice})
-				eventToSend = &types.BaseGameEvent{
-					Type: "make_choice",
-					Data: choiceData,
+				choiceEvent, _ := protocol.Encode(types.MakeChoiceMessage{Choice: choice})
+				eventToSend = &choiceEvent
+
+			} else if waitingForMove {
+				// Handle a move, the row may be left out
+				move, ok := parseMove(input)
+				if !ok {
+					fmt.Printf("[DEV CLIENT] Invalid move '%s'. Use: <row> <column>, or <column>\n", input)
+					continue
 				}
+				moveEvent, _ := protocol.Encode(move)
+				eventToSend = &moveEvent
 
 			} else if !inGame {
 				// Handle post-game or lobby input
 				switch strings.ToLower(input) {
 				case "play":
 					// Send play_again message
-					playAgainData, _ := json.Marshal(types.PlayAgainMessage{})
-					eventToSend = &types.BaseGameEvent{
-						Type: "play_again",
-						Data: playAgainData,
-					}
+					playAgainEvent, _ := protocol.Encode(types.PlayAgainMessage{})
+					eventToSend = &playAgainEvent
 
 				case "quit", "exit", "q":
 					// Send disconnect message
-					disconnectData, _ := json.Marshal(types.DisconnectMessage{})
-					eventToSend = &types.BaseGameEvent{
-						Type: "disconnect",
-						Data: disconnectData,
-					}
+					disconnectEvent, _ := protocol.Encode(types.DisconnectMessage{})
+					eventToSend = &disconnectEvent
+
+				case "matches":
+					// List the correspondence matches of the player key
+					listEvent, _ := protocol.Encode(types.ListMatchesMessage{PlayerKey: *playerKey})
+					eventToSend = &listEvent
 
 				default:
-					fmt.Printf("[DEV CLIENT] Unknown command '%s'. Available: play, quit\n", input)
+					// Open a correspondence match, or move in one
+					if game, ok := strings.CutPrefix(input, "match "); ok {
+						createEvent, _ := protocol.Encode(types.CreateMatchMessage{Game: strings.TrimSpace(game), PlayerKey: *playerKey})
+						eventToSend = &createEvent
+						break
+					}
+					if args, ok := strings.CutPrefix(input, "move "); ok {
+						matchID, cells, _ := strings.Cut(strings.TrimSpace(args), " ")
+						move, ok := parseMove(cells)
+						if !ok {
+							fmt.Printf("[DEV CLIENT] Invalid move '%s'. Use: move <match_id> <row> <column>, or move <match_id> <column>\n", input)
+							continue
+						}
+						move.MatchID = matchID
+						moveEvent, _ := protocol.Encode(move)
+						eventToSend = &moveEvent
+						break
+					}
+					// Register for a tournament or league under the lobby name
+					if id, ok := strings.CutPrefix(input, "tournament "); ok {
+						joinEvent, _ := protocol.Encode(types.JoinTournamentMessage{TournamentID: strings.TrimSpace(id)})
+						eventToSend = &joinEvent
+						break
+					}
+					fmt.Printf("[DEV CLIENT] Unknown command '%s'. Available: play, tournament <id>, match <game>, matches, move <match_id> <row> <column>, quit\n", input)
 					continue
 				}
 			} else {
@@ -213,16 +320,58 @@ func main() {
 				jsonOut, _ := json.MarshalIndent(*eventToSend, "", "  ")
 				fmt.Printf("[SEND] %s\n", string(jsonOut))
 
-				if err := conn.WriteJSON(*eventToSend); err != nil {
+				if err := send(*eventToSend); err != nil {
 					fmt.Printf("[ERROR] Failed to send message: %v\n", err)
 				}
 
 				// If disconnect, exit
-				if eventToSend.Type == "disconnect" {
+				if eventToSend.Type == protocol.TypeDisconnect {
 					time.Sleep(100 * time.Millisecond) // Brief delay for message to send
 					return
 				}
 			}
 		}
 	}
+}
+
+// parseMove reads "<row> <column>", or "<column>" for games where pieces
+// drop
+func parseMove(input string) (types.MakeMoveMessage, bool) {
+	var cells []int
+	for _, field := range strings.Fields(input) {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return types.MakeMoveMessage{}, false
+		}
+		cells = append(cells, n)
+	}
+	var move types.MakeMoveMessage
+	switch len(cells) {
+	case 1:
+		move.Column = cells[0]
+	case 2:
+		move.Row, move.Column = cells[0], cells[1]
+	default:
+		return move, false
+	}
+	return move, true
+}
+
+// handSummary lists how often each action can still be played, e.g.
+// "rock x2, paper x3"
+func handSummary(hand []types.HandCount) string {
+	var counts []string
+	for _, card := range hand {
+		counts = append(counts, fmt.Sprintf("%s x%d", card.Action, card.Count))
+	}
+	return strings.Join(counts, ", ")
+}
+
+// choiceMenu lists the numbers to type for actions, e.g. "1=rock, 2=paper"
+func choiceMenu(actions []string) string {
+	var menu []string
+	for i, action := range actions {
+		menu = append(menu, fmt.Sprintf("%d=%s", i+1, action))
+	}
+	return strings.Join(menu, ", ")
 }
\ No newline at end of file
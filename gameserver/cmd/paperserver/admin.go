@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/lobby"
+	"github.com/4hel/paper/gameserver/internal/tournament"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// requireAdmin only lets requests with the admin bearer token through
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + s.adminToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleDrain starts draining the WebSocket handler. The HTTP server keeps
+// running so health checks can report the drain.
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	log.Printf("Drain requested from %s", r.RemoteAddr)
+	go s.wsHandler.Drain(context.Background())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]bool{"draining": true})
+}
+
+// maintenanceStatus is the body of /admin/maintenance responses
+type maintenanceStatus struct {
+	Enabled bool `json:"enabled"`
+	lobby.Maintenance
+}
+
+// handleMaintenance shows (GET), enables (PUT with a lobby.Maintenance
+// body) or disables (DELETE) maintenance mode
+func (s *Server) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var m lobby.Maintenance
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			http.Error(w, "Invalid maintenance settings: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Maintenance mode enabled from %s", r.RemoteAddr)
+		s.wsHandler.SetMaintenance(m)
+	case http.MethodDelete:
+		log.Printf("Maintenance mode disabled from %s", r.RemoteAddr)
+		s.wsHandler.EndMaintenance()
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	m, enabled := s.wsHandler.Maintenance()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(maintenanceStatus{Enabled: enabled, Maintenance: m})
+}
+
+// tournamentRequest is the body of requests creating a tournament or
+// registering a player
+type tournamentRequest struct {
+	Name string `json:"name"`
+}
+
+// handleTournaments lists (GET) or creates (POST with a tournamentRequest
+// body) tournaments
+func (s *Server) handleTournaments(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		brackets := []types.TournamentUpdateMessage{}
+		for _, t := range s.wsHandler.Tournaments().List() {
+			brackets = append(brackets, t.Bracket())
+		}
+		writeJSON(w, http.StatusOK, brackets)
+	case http.MethodPost:
+		var req tournamentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid tournament: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		t := s.wsHandler.Tournaments().Create(req.Name)
+		log.Printf("Tournament %s created from %s", t.ID, r.RemoteAddr)
+		writeJSON(w, http.StatusCreated, t.Bracket())
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTournament shows a tournament's bracket (GET), registers a player
+// (POST .../players with a tournamentRequest body) or starts it (POST
+// .../start)
+func (s *Server) handleTournament(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.wsHandler.Tournaments().Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Tournament not found", http.StatusNotFound)
+		return
+	}
+	serveCompetition(w, r, t, func() any { return t.Bracket() })
+}
+
+// competition is a tournament or a league
+type competition interface {
+	Register(name, clientID string) error
+	Start() error
+}
+
+// serveCompetition handles the requests handleTournament and handleLeague
+// share, view returns what is sent back
+func serveCompetition(w http.ResponseWriter, r *http.Request, c competition, view func() any) {
+	action := r.PathValue("action")
+	allowed := http.MethodPost
+	if action == "" {
+		allowed = http.MethodGet
+	}
+	if r.Method != allowed {
+		w.Header().Set("Allow", allowed)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var err error
+	switch action {
+	case "":
+	case "players":
+		var req tournamentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid player: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// The first client that joins under the name plays for it
+		err = c.Register(req.Name, "")
+	case "start":
+		log.Printf("%s start requested from %s", r.PathValue("id"), r.RemoteAddr)
+		err = c.Start()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case errors.Is(err, tournament.ErrInvalidName):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeJSON(w, http.StatusOK, view())
+	}
+}
+
+// leagueRequest is the body of requests creating a league
+type leagueRequest struct {
+	Name          string `json:"name"`
+	Format        string `json:"format"`         // "round_robin" or "swiss"
+	Rounds        int    `json:"rounds"`         // Swiss only, zero for the default
+	RoundInterval string `json:"round_interval"` // e.g. "24h", empty to play rounds back to back
+}
+
+// handleLeagues lists (GET) or creates (POST with a leagueRequest body)
+// leagues
+func (s *Server) handleLeagues(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tables := []types.LeagueUpdateMessage{}
+		for _, l := range s.wsHandler.Tournaments().Leagues() {
+			tables = append(tables, l.Table())
+		}
+		writeJSON(w, http.StatusOK, tables)
+	case http.MethodPost:
+		var req leagueRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid league: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		cfg := tournament.LeagueConfig{Name: req.Name, Format: req.Format, Rounds: req.Rounds}
+		if req.RoundInterval != "" {
+			interval, err := time.ParseDuration(req.RoundInterval)
+			if err != nil {
+				http.Error(w, "Invalid round interval: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			cfg.RoundInterval = interval
+		}
+		l, err := s.wsHandler.Tournaments().CreateLeague(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("League %s created from %s", l.ID, r.RemoteAddr)
+		writeJSON(w, http.StatusCreated, l.Table())
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleLeague shows a league's table (GET), registers a player (POST
+// .../players with a tournamentRequest body) or starts it (POST .../start)
+func (s *Server) handleLeague(w http.ResponseWriter, r *http.Request) {
+	l, ok := s.wsHandler.Tournaments().GetLeague(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "League not found", http.StatusNotFound)
+		return
+	}
+	serveCompetition(w, r, l, func() any { return l.Table() })
+}
+
+// handleStandings serves a league's standings and current pairings to
+// anyone, without the admin token
+func (s *Server) handleStandings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	l, ok := s.wsHandler.Tournaments().GetLeague(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "League not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, l.Table())
+}
+
+// writeJSON sends v as the JSON response body
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+func TestAdminDrainRequiresToken(t *testing.T) {
+	server := NewServer(":0", WithAdminToken("secret"))
+	defer server.wsHandler.Close()
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		auth   string
+		status int
+	}{
+		{"no token", http.MethodPost, "", http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, "Bearer nope", http.StatusUnauthorized},
+		{"wrong method", http.MethodGet, "Bearer secret", http.StatusMethodNotAllowed},
+		{"authorized", http.MethodPost, "Bearer secret", http.StatusAccepted},
+	} {
+		req := httptest.NewRequest(tc.method, "/admin/drain", nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rec := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.status, rec.Code)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := server.wsHandler.Drain(ctx); err != nil {
+		t.Errorf("Expected the drain started by the admin endpoint to finish, got %v", err)
+	}
+}
+
+func TestAdminEndpointsDisabledWithoutToken(t *testing.T) {
+	server := NewServer(":0")
+	defer server.wsHandler.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/drain", nil)
+	rec := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected admin endpoints to be disabled, got status %d", rec.Code)
+	}
+	if server.wsHandler.Draining() {
+		t.Error("Server should not drain")
+	}
+}
+
+func TestAdminMaintenanceIsReportedByHealth(t *testing.T) {
+	server := NewServer(":0", WithAdminToken("secret"))
+	defer server.wsHandler.Close()
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+	health := func() healthStatus {
+		var status healthStatus
+		json.NewDecoder(serve(http.MethodGet, "/health", "").Body).Decode(&status)
+		return status
+	}
+
+	if mode := health().Mode; mode != "normal" {
+		t.Errorf("Expected normal mode, got %s", mode)
+	}
+
+	rec := serve(http.MethodPut, "/admin/maintenance", `{"message":"Migrating","eta":"2030-01-01T12:00:00Z"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected maintenance to be enabled, got status %d: %s", rec.Code, rec.Body)
+	}
+
+	status := health()
+	if status.Mode != "maintenance" || status.Maintenance == nil || status.Maintenance.Message != "Migrating" {
+		t.Errorf("Expected health to report maintenance, got %+v", status)
+	}
+
+	serve(http.MethodDelete, "/admin/maintenance", "")
+	if mode := health().Mode; mode != "normal" {
+		t.Errorf("Expected normal mode after maintenance, got %s", mode)
+	}
+}
+
+func TestAdminTournaments(t *testing.T) {
+	server := NewServer(":0", WithAdminToken("secret"))
+	defer server.wsHandler.Close()
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodPost, "/admin/tournaments", `{"name": "Office Cup"}`)
+	var created types.TournamentUpdateMessage
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || created.Name != "Office Cup" || created.State != "registering" {
+		t.Fatalf("Expected a new tournament, got %d %+v", rec.Code, created)
+	}
+	path := "/admin/tournaments/" + created.TournamentID
+
+	if rec := serve(http.MethodPost, path+"/start", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected a tournament without players not to start, got %d", rec.Code)
+	}
+	for _, name := range []string{"Alice", "Bob"} {
+		if rec := serve(http.MethodPost, path+"/players", `{"name": "`+name+`"}`); rec.Code != http.StatusOK {
+			t.Errorf("Expected %s to be registered, got %d: %s", name, rec.Code, rec.Body)
+		}
+	}
+	if rec := serve(http.MethodPost, path+"/players", `{"name": ""}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected an empty name to be rejected, got %d", rec.Code)
+	}
+
+	// Nobody is connected, so the first match waits for its players
+	rec = serve(http.MethodPost, path+"/start", "")
+	var started types.TournamentUpdateMessage
+	json.NewDecoder(rec.Body).Decode(&started)
+	if rec.Code != http.StatusOK || started.State != "running" || started.Rounds[0].Matches[0].Status != "waiting" {
+		t.Errorf("Expected a running tournament, got %d %+v", rec.Code, started)
+	}
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, path, http.StatusOK},
+		{http.MethodPost, path, http.StatusMethodNotAllowed},
+		{http.MethodGet, path + "/start", http.StatusMethodNotAllowed},
+		{http.MethodPost, path + "/players", http.StatusConflict},
+		{http.MethodGet, "/admin/tournaments/nope", http.StatusNotFound},
+		{http.MethodDelete, "/admin/tournaments", http.StatusMethodNotAllowed},
+	} {
+		if rec := serve(tc.method, tc.path, `{"name": "Carol"}`); rec.Code != tc.status {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.status, rec.Code)
+		}
+	}
+
+	var list []types.TournamentUpdateMessage
+	json.NewDecoder(serve(http.MethodGet, "/admin/tournaments", "").Body).Decode(&list)
+	if len(list) != 1 || list[0].TournamentID != created.TournamentID {
+		t.Errorf("Expected the tournament to be listed, got %+v", list)
+	}
+}
+
+func TestAdminLeagues(t *testing.T) {
+	server := NewServer(":0", WithAdminToken("secret"))
+	defer server.wsHandler.Close()
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		server.httpServer.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, body := range []string{
+		`{"format": "ladder"}`,
+		`{"format": "swiss", "round_interval": "daily"}`,
+		`{"format": "round_robin", "rounds": 3}`,
+	} {
+		if rec := serve(http.MethodPost, "/admin/leagues", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %d", body, rec.Code)
+		}
+	}
+
+	rec := serve(http.MethodPost, "/admin/leagues", `{"name": "Winter League", "format": "swiss", "rounds": 2, "round_interval": "24h"}`)
+	var created types.LeagueUpdateMessage
+	json.NewDecoder(rec.Body).Decode(&created)
+	if rec.Code != http.StatusCreated || created.Name != "Winter League" || created.Format != "swiss" || created.Rounds != 2 {
+		t.Fatalf("Expected a new league, got %d %+v", rec.Code, created)
+	}
+	path := "/admin/leagues/" + created.LeagueID
+
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		if rec := serve(http.MethodPost, path+"/players", `{"name": "`+name+`"}`); rec.Code != http.StatusOK {
+			t.Errorf("Expected %s to be registered, got %d: %s", name, rec.Code, rec.Body)
+		}
+	}
+	if rec := serve(http.MethodPost, path+"/start", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected the league to start, got %d: %s", rec.Code, rec.Body)
+	}
+
+	// Standings are public
+	req := httptest.NewRequest(http.MethodGet, "/leagues/"+created.LeagueID+"/standings", nil)
+	standings := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(standings, req)
+	var table types.LeagueUpdateMessage
+	json.NewDecoder(standings.Body).Decode(&table)
+	if standings.Code != http.StatusOK || table.Round != 1 || len(table.Standings) != 3 || len(table.Pairings) != 2 {
+		t.Errorf("Expected the first round's table, got %d %+v", standings.Code, table)
+	}
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, path, http.StatusOK},
+		{http.MethodPost, path + "/players", http.StatusConflict},
+		{http.MethodGet, "/admin/leagues/nope", http.StatusNotFound},
+		{http.MethodGet, "/leagues/nope/standings", http.StatusNotFound},
+		{http.MethodPost, "/leagues/" + created.LeagueID + "/standings", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/admin/leagues", http.StatusOK},
+	} {
+		if rec := serve(tc.method, tc.path, `{"name": "Dave"}`); rec.Code != tc.status {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.status, rec.Code)
+		}
+	}
+}
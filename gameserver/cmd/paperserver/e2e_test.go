@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net"
@@ -11,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/4hel/paper/gameserver/internal/protocol"
 	"github.com/4hel/paper/gameserver/internal/types"
 )
 
@@ -18,27 +18,58 @@ import (
 type Player struct {
 	name   string
 	conn   *websocket.Conn
+	codec  protocol.WireCodec
 	t      *testing.T
 	wg     *sync.WaitGroup
 	gameResults chan string
 }
 
-// NewPlayer creates a new automated player
-func NewPlayer(name string, serverURL string, t *testing.T, wg *sync.WaitGroup) (*Player, error) {
-	conn, _, err := websocket.DefaultDialer.Dial(serverURL, nil)
+// NewPlayer creates a new automated player speaking the given wire codec
+func NewPlayer(name string, serverURL string, codec protocol.WireCodec, t *testing.T, wg *sync.WaitGroup) (*Player, error) {
+	dialer := websocket.Dialer{Subprotocols: []string{codec.Subprotocol()}}
+	conn, _, err := dialer.Dial(serverURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	if conn.Subprotocol() != codec.Subprotocol() {
+		conn.Close()
+		return nil, fmt.Errorf("server negotiated %q instead of %q", conn.Subprotocol(), codec.Subprotocol())
+	}
+
 	return &Player{
 		name:        name,
 		conn:        conn,
+		codec:       codec,
 		t:           t,
 		wg:          wg,
 		gameResults: make(chan string, 1),
 	}, nil
 }
 
+// send encodes an event with the player's codec and writes it
+func (p *Player) send(event types.BaseGameEvent) error {
+	data, err := p.codec.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.conn.WriteMessage(p.codec.FrameType(), data)
+}
+
+// receive reads the next event and decodes it with the player's codec
+func (p *Player) receive() (types.BaseGameEvent, error) {
+	var event types.BaseGameEvent
+	frameType, data, err := p.conn.ReadMessage()
+	if err != nil {
+		return event, err
+	}
+	if frameType != p.codec.FrameType() {
+		return event, fmt.Errorf("expected frame type %d, got %d", p.codec.FrameType(), frameType)
+	}
+	err = p.codec.Unmarshal(data, &event)
+	return event, err
+}
+
 // Close closes the player connection
 func (p *Player) Close() {
 	p.conn.Close()
@@ -52,14 +83,21 @@ func (p *Player) Play() {
 
 	p.t.Logf("[%s] Starting gameplay", p.name)
 
-	// Send join_lobby message
-	joinData, _ := json.Marshal(types.JoinLobbyMessage{Name: p.name})
-	joinEvent := types.BaseGameEvent{
-		Type: "join_lobby",
-		Data: joinData,
+	// Introduce ourselves before joining
+	helloEvent, _ := protocol.Encode(types.HelloMessage{
+		ProtocolVersion: protocol.Version,
+		ClientName:      "e2e-test",
+		ClientVersion:   "test",
+	})
+	if err := p.send(helloEvent); err != nil {
+		p.t.Errorf("[%s] Failed to send hello: %v", p.name, err)
+		return
 	}
 
-	if err := p.conn.WriteJSON(joinEvent); err != nil {
+	// Send join_lobby message
+	joinEvent, _ := protocol.Encode(types.JoinLobbyMessage{Name: p.name})
+
+	if err := p.send(joinEvent); err != nil {
 		p.t.Errorf("[%s] Failed to send join_lobby: %v", p.name, err)
 		return
 	}
@@ -71,8 +109,7 @@ func (p *Player) Play() {
 
 	// Message handling loop
 	for {
-		var event types.BaseGameEvent
-		err := p.conn.ReadJSON(&event)
+		event, err := p.receive()
 		if err != nil {
 			p.t.Logf("[%s] Connection closed: %v", p.name, err)
 			return
@@ -81,13 +118,16 @@ func (p *Player) Play() {
 		p.t.Logf("[%s] Received: %s", p.name, event.Type)
 
 		switch event.Type {
-		case "player_waiting":
+		case protocol.TypeWelcome:
+			p.t.Logf("[%s] Handshake complete", p.name)
+
+		case protocol.TypePlayerWaiting:
 			p.t.Logf("[%s] Waiting for opponent...", p.name)
 
-		case "game_starting":
+		case protocol.TypeGameStarting:
 			p.t.Logf("[%s] Game starting!", p.name)
 
-		case "round_start":
+		case protocol.TypeRoundStart:
 			p.t.Logf("[%s] Round starting, making choice...", p.name)
 			
 			// Make random choice after small delay
@@ -95,13 +135,9 @@ func (p *Player) Play() {
 				time.Sleep(time.Duration(rand.Intn(100)+50) * time.Millisecond)
 				
 				choice := choices[rand.Intn(len(choices))]
-				choiceData, _ := json.Marshal(types.MakeChoiceMessage{Choice: choice})
-				choiceEvent := types.BaseGameEvent{
-					Type: "make_choice",
-					Data: choiceData,
-				}
+				choiceEvent, _ := protocol.Encode(types.MakeChoiceMessage{Choice: choice})
 
-				if err := p.conn.WriteJSON(choiceEvent); err != nil {
+				if err := p.send(choiceEvent); err != nil {
 					p.t.Errorf("[%s] Failed to send choice: %v", p.name, err)
 					return
 				}
@@ -109,10 +145,10 @@ func (p *Player) Play() {
 				p.t.Logf("[%s] Chose: %s", p.name, choice)
 			}()
 
-		case "round_result":
+		case protocol.TypeRoundResult:
 			
-			var result types.RoundResultMessage
-			if err := json.Unmarshal(event.Data, &result); err != nil {
+			result, err := protocol.Decode[types.RoundResultMessage](event)
+			if err != nil {
 				p.t.Errorf("[%s] Failed to parse round result: %v", p.name, err)
 				continue
 			}
@@ -120,10 +156,10 @@ func (p *Player) Play() {
 			p.t.Logf("[%s] Round result: %s (you: %s, opponent: %s)", 
 				p.name, result.Result, result.YourChoice, result.OpponentChoice)
 
-		case "game_ended":
+		case protocol.TypeGameEnded:
 			
-			var gameEnd types.GameEndedMessage
-			if err := json.Unmarshal(event.Data, &gameEnd); err != nil {
+			gameEnd, err := protocol.Decode[types.GameEndedMessage](event)
+			if err != nil {
 				p.t.Errorf("[%s] Failed to parse game end: %v", p.name, err)
 				continue
 			}
@@ -134,9 +170,9 @@ func (p *Player) Play() {
 			p.gameResults <- gameEnd.Result
 			return
 
-		case "error":
-			var errorMsg types.ErrorMessage
-			if err := json.Unmarshal(event.Data, &errorMsg); err != nil {
+		case protocol.TypeError:
+			errorMsg, err := protocol.Decode[types.ErrorMessage](event)
+			if err != nil {
 				p.t.Errorf("[%s] Failed to parse error: %v", p.name, err)
 			} else {
 				p.t.Errorf("[%s] Received error: %s", p.name, errorMsg.Message)
@@ -160,8 +196,18 @@ func (p *Player) GetGameResult() string {
 	}
 }
 
-// TestEndToEndGame tests a complete game between two automated players
+// TestEndToEndGame tests a complete game between two automated players,
+// once for every wire codec the server offers
 func TestEndToEndGame(t *testing.T) {
+	for _, codec := range protocol.Codecs {
+		t.Run(codec.Subprotocol(), func(t *testing.T) {
+			runEndToEndGame(t, codec)
+		})
+	}
+}
+
+// runEndToEndGame plays one complete game with both players using codec
+func runEndToEndGame(t *testing.T, codec protocol.WireCodec) {
 	// Find an available port
 	listener, err := net.Listen("tcp", ":0")
 	if err != nil {
@@ -191,12 +237,12 @@ func TestEndToEndGame(t *testing.T) {
 	var wg sync.WaitGroup
 	wg.Add(2)
 
-	player1, err := NewPlayer("TestPlayer1", serverURL, t, &wg)
+	player1, err := NewPlayer("TestPlayer1", serverURL, codec, t, &wg)
 	if err != nil {
 		t.Fatal("Failed to create player1:", err)
 	}
 
-	player2, err := NewPlayer("TestPlayer2", serverURL, t, &wg)
+	player2, err := NewPlayer("TestPlayer2", serverURL, codec, t, &wg)
 	if err != nil {
 		player1.Close()
 		t.Fatal("Failed to create player2:", err)
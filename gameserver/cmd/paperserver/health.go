@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/health"
+	"github.com/4hel/paper/gameserver/internal/lobby"
+)
+
+// healthStatus is the body of /health
+type healthStatus struct {
+	Status        string             `json:"status"` // "ok" when ready, "unavailable" otherwise
+	Mode          string             `json:"mode"`   // "normal", "maintenance" or "draining"
+	Maintenance   *lobby.Maintenance `json:"maintenance,omitempty"`
+	Components    []health.Component `json:"components"`
+	Connections   int                `json:"connections"`
+	UptimeSeconds int64              `json:"uptime_seconds"`
+}
+
+// handleLivez answers as long as the process serves HTTP. Orchestrators
+// restart the server when it fails.
+func (s *Server) handleLivez(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// handleReadyz answers 503 while the server should not get new players,
+// so load balancers send them elsewhere
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	components, ok := s.health.Run(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		for _, component := range components {
+			if component.Status != health.StatusOK {
+				w.Write([]byte(component.Name + ": " + component.Error + "\n"))
+			}
+		}
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// handleHealth shows the status of every component, the connection count
+// and uptime as JSON. It always answers 200, use /readyz for probes.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	components, ok := s.health.Run(r.Context())
+	status := healthStatus{
+		Status:        "ok",
+		Mode:          "normal",
+		Components:    components,
+		Connections:   s.wsHandler.ConnectionCount(),
+		UptimeSeconds: int64(time.Since(s.started).Seconds()),
+	}
+	if !ok {
+		status.Status = "unavailable"
+	}
+	if m, ok := s.wsHandler.Maintenance(); ok {
+		status.Mode = "maintenance"
+		status.Maintenance = &m
+	}
+	if s.wsHandler.Draining() {
+		status.Mode = "draining"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// checkLobby fails while the lobby does not accept new players
+func (s *Server) checkLobby(ctx context.Context) error {
+	if m, ok := s.wsHandler.Maintenance(); ok {
+		return errors.New("maintenance: " + m.Message)
+	}
+	return nil
+}
+
+// checkDrain fails once the server started draining
+func (s *Server) checkDrain(ctx context.Context) error {
+	if s.wsHandler.Draining() {
+		return errors.New("server is draining")
+	}
+	return nil
+}
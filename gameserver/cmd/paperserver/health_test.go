@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/4hel/paper/gameserver/internal/broker"
+	"github.com/4hel/paper/gameserver/internal/lobby"
+)
+
+// get serves a GET request for path on the server's mux
+func get(server *Server, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	server.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestReadyzReflectsLobbyAndDrain(t *testing.T) {
+	server := NewServer(":0")
+	defer server.wsHandler.Close()
+
+	if rec := get(server, "/readyz"); rec.Code != http.StatusOK {
+		t.Errorf("Expected a fresh server to be ready, got %d: %s", rec.Code, rec.Body)
+	}
+
+	server.wsHandler.SetMaintenance(lobby.Maintenance{Message: "Migrating"})
+	rec := get(server, "/readyz")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "lobby: maintenance: Migrating") {
+		t.Errorf("Expected not ready during maintenance, got %d: %s", rec.Code, rec.Body)
+	}
+	server.wsHandler.EndMaintenance()
+
+	server.wsHandler.Drain(context.Background())
+	if rec := get(server, "/readyz"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready while draining, got %d", rec.Code)
+	}
+	if rec := get(server, "/livez"); rec.Code != http.StatusOK {
+		t.Errorf("Expected to stay live while draining, got %d", rec.Code)
+	}
+}
+
+func TestHealthShowsComponents(t *testing.T) {
+	server := NewServer(":0", WithReadinessCheck("store", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}))
+	defer server.wsHandler.Close()
+
+	if rec := get(server, "/readyz"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a failing store to fail readiness, got %d", rec.Code)
+	}
+
+	rec := get(server, "/health")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected /health to answer 200, got %d", rec.Code)
+	}
+	var status healthStatus
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatal("Expected JSON:", err)
+	}
+
+	if status.Status != "unavailable" || status.Mode != "normal" {
+		t.Errorf("Expected unavailable in normal mode, got %+v", status)
+	}
+	names := []string{}
+	for _, component := range status.Components {
+		names = append(names, component.Name+"="+component.Status)
+	}
+	expected := "lobby=ok,drain=ok,store=fail,goroutines=ok"
+	if strings.Join(names, ",") != expected {
+		t.Errorf("Expected components %s, got %s", expected, strings.Join(names, ","))
+	}
+	if status.Connections != 0 {
+		t.Errorf("Expected no connections, got %d", status.Connections)
+	}
+}
+
+func TestReadyzChecksBroker(t *testing.T) {
+	redis, err := broker.StartLocalRedis()
+	if err != nil {
+		t.Fatal("Failed to start local redis:", err)
+	}
+	defer redis.Close()
+	b, err := broker.NewRedisBroker(context.Background(), redis.Addr())
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer b.Close()
+
+	server := NewServer(":0", WithBroker(b, "test"))
+	defer server.wsHandler.Close()
+
+	if rec := get(server, "/readyz"); rec.Code != http.StatusOK {
+		t.Errorf("Expected ready with a reachable broker, got %d: %s", rec.Code, rec.Body)
+	}
+
+	redis.Close()
+	rec := get(server, "/readyz")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "broker: ") {
+		t.Errorf("Expected not ready without the broker, got %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"log"
 	"net/http"
 	"os"
@@ -9,33 +11,114 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/4hel/paper/gameserver/internal/broker"
+	"github.com/4hel/paper/gameserver/internal/correspondence"
+	"github.com/4hel/paper/gameserver/internal/gameroom"
 	"github.com/4hel/paper/gameserver/internal/gateway"
+	"github.com/4hel/paper/gameserver/internal/health"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/snapshot"
 )
 
 // Server wraps the HTTP server and WebSocket handler for easier testing
 type Server struct {
-	httpServer *http.Server
-	wsHandler  *gateway.Handler
+	httpServer     *http.Server
+	wsHandler      *gateway.Handler
+	health         *health.Checker
+	started        time.Time
+	adminToken     string
+	goroutineLimit int
+	gatewayOptions []gateway.Option
+}
+
+// ServerOption configures a Server
+type ServerOption func(*Server)
+
+// WithAdminToken enables the /admin endpoints for requests carrying
+// "Authorization: Bearer <token>"
+func WithAdminToken(token string) ServerOption {
+	return func(s *Server) {
+		s.adminToken = token
+	}
+}
+
+// WithGoroutineLimit sets the goroutine count above which the server
+// reports itself as not ready
+func WithGoroutineLimit(max int) ServerOption {
+	return func(s *Server) {
+		s.goroutineLimit = max
+	}
+}
+
+// WithReadinessCheck adds a check to /readyz, e.g. for a store backend
+func WithReadinessCheck(name string, check health.Check) ServerOption {
+	return func(s *Server) {
+		s.health.Add(name, check)
+	}
+}
+
+// WithBroker shares matchmaking with the other instances using b. Brokers
+// that can be pinged get a "broker" readiness check.
+func WithBroker(b broker.Broker, instance string) ServerOption {
+	return func(s *Server) {
+		s.gatewayOptions = append(s.gatewayOptions, gateway.WithBroker(b, instance))
+		if pinger, ok := b.(interface{ Ping(context.Context) error }); ok {
+			s.health.Add("broker", pinger.Ping)
+		}
+	}
+}
+
+// WithGatewayOptions passes options on to the WebSocket handler
+func WithGatewayOptions(opts ...gateway.Option) ServerOption {
+	return func(s *Server) {
+		s.gatewayOptions = append(s.gatewayOptions, opts...)
+	}
 }
 
 // NewServer creates a new server instance
-func NewServer(port string) *Server {
-	wsHandler := gateway.NewHandler()
+func NewServer(port string, opts ...ServerOption) *Server {
+	s := &Server{
+		health:         health.NewChecker(2 * time.Second),
+		started:        time.Now(),
+		goroutineLimit: 10000,
+	}
+	s.health.Add("lobby", s.checkLobby)
+	s.health.Add("drain", s.checkDrain)
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.health.Add("goroutines", health.GoroutineLimit(s.goroutineLimit))
+
+	gatewayOptions := append([]gateway.Option{gateway.WithFeatures(protocol.FeatureResume)}, s.gatewayOptions...)
+	wsHandler := gateway.NewHandler(gatewayOptions...)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", wsHandler.HandleWebSocket)
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
+	mux.HandleFunc("/livez", s.handleLivez)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/leagues/{id}/standings", s.handleStandings)
+	mux.HandleFunc("/debug/delivery", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(wsHandler.DeliveryStats())
 	})
+	if s.adminToken != "" {
+		mux.Handle("/admin/drain", s.requireAdmin(http.HandlerFunc(s.handleDrain)))
+		mux.Handle("/admin/maintenance", s.requireAdmin(http.HandlerFunc(s.handleMaintenance)))
+		mux.Handle("/admin/tournaments", s.requireAdmin(http.HandlerFunc(s.handleTournaments)))
+		mux.Handle("/admin/tournaments/{id}", s.requireAdmin(http.HandlerFunc(s.handleTournament)))
+		mux.Handle("/admin/tournaments/{id}/{action}", s.requireAdmin(http.HandlerFunc(s.handleTournament)))
+		mux.Handle("/admin/leagues", s.requireAdmin(http.HandlerFunc(s.handleLeagues)))
+		mux.Handle("/admin/leagues/{id}", s.requireAdmin(http.HandlerFunc(s.handleLeague)))
+		mux.Handle("/admin/leagues/{id}/{action}", s.requireAdmin(http.HandlerFunc(s.handleLeague)))
+	}
 
-	return &Server{
-		httpServer: &http.Server{
-			Addr:    port,
-			Handler: mux,
-		},
-		wsHandler: wsHandler,
+	s.httpServer = &http.Server{
+		Addr:    port,
+		Handler: mux,
 	}
+	s.wsHandler = wsHandler
+	return s
 }
 
 // Start starts the server (blocking)
@@ -43,9 +126,13 @@ func (s *Server) Start() error {
 	return s.httpServer.ListenAndServe()
 }
 
-// Shutdown gracefully shuts down the server
+// Shutdown gracefully shuts down the server. Running games may finish
+// within the handler's drain timeout or until ctx is done.
 func (s *Server) Shutdown(ctx context.Context) error {
-	// Close WebSocket handler first
+	// Drain and close WebSocket handler first
+	if err := s.wsHandler.Drain(ctx); err != nil {
+		log.Printf("Drain did not finish: %v", err)
+	}
 	s.wsHandler.Close()
 	
 	// Then shutdown HTTP server
@@ -53,8 +140,85 @@ func (s *Server) Shutdown(ctx context.Context) error {
 }
 
 func main() {
+	drainTimeout := flag.Duration("drain-timeout", 30*time.Second, "How long running games may finish on shutdown")
+	goroutineLimit := flag.Int("max-goroutines", 10000, "Goroutine count above which /readyz fails")
+	redisAddr := flag.String("redis", "", "Redis compatible server shared by all instances, empty to run standalone")
+	hostname, _ := os.Hostname()
+	instance := flag.String("instance", hostname, "Name of this instance, unique among those sharing -redis")
+	snapshotFile := flag.String("snapshot-file", "", "File running games are saved to and restored from, empty to disable")
+	snapshotInterval := flag.Duration("snapshot-interval", 10*time.Second, "How often running games are saved")
+	reconnectGrace := flag.Duration("reconnect-grace", 2*time.Minute, "How long restored games wait for their players")
+	checkIn := flag.Duration("tournament-check-in", 2*time.Minute, "How long tournament players have to show up for a match")
+	resolution := flag.String("ffa-resolution", string(gameroom.ResolveDominant), "How free-for-all rounds are decided: dominant or points")
+	elimination := flag.Bool("ffa-elimination", false, "Knock out free-for-all players who lose a round")
+	ffaRounds := flag.Int("ffa-rounds", 0, "Rounds of a free-for-all, 0 for 5 or 10 with elimination")
+	matchesFile := flag.String("matches-file", "", "File correspondence matches are kept in, empty to keep them in memory")
+	moveDeadline := flag.Duration("move-deadline", 24*time.Hour, "How long a player of a correspondence match has for each move")
+	tieBreak := flag.String("team-tie-break", string(gameroom.TieBreakCaptain), "How a team decides when its players vote differently: captain or random")
+	suddenDeathRounds := flag.Int("sudden-death-rounds", 0, "Sudden-death rounds played at most when a two-player game ends tied")
+	fallback := flag.String("sudden-death-fallback", string(gameroom.FallbackDraw), "How a game still tied after sudden death ends: draw or first_decisive")
+	flag.Parse()
+
+	switch gameroom.Resolution(*resolution) {
+	case gameroom.ResolveDominant, gameroom.ResolvePoints:
+	default:
+		log.Fatalf("Unknown free-for-all resolution %q", *resolution)
+	}
+	if *ffaRounds < 0 {
+		log.Fatalf("Free-for-all rounds cannot be negative")
+	}
+	if *moveDeadline <= 0 {
+		log.Fatalf("Move deadline must be positive")
+	}
+	switch gameroom.TieBreak(*tieBreak) {
+	case gameroom.TieBreakCaptain, gameroom.TieBreakRandom:
+	default:
+		log.Fatalf("Unknown team tie-break %q", *tieBreak)
+	}
+	if *suddenDeathRounds < 0 {
+		log.Fatalf("Sudden-death rounds cannot be negative")
+	}
+	switch gameroom.Fallback(*fallback) {
+	case gameroom.FallbackDraw, gameroom.FallbackFirstDecisive:
+	default:
+		log.Fatalf("Unknown sudden-death fallback %q", *fallback)
+	}
+	suddenDeath := gameroom.SuddenDeath{Rounds: *suddenDeathRounds, Fallback: gameroom.Fallback(*fallback)}
+	rules := gameroom.Rules{Resolution: gameroom.Resolution(*resolution), Elimination: *elimination, Rounds: *ffaRounds}
+
 	port := ":8080"
-	server := NewServer(port)
+	opts := []ServerOption{
+		WithAdminToken(os.Getenv("PAPER_ADMIN_TOKEN")),
+		WithGoroutineLimit(*goroutineLimit),
+		WithGatewayOptions(gateway.WithDrainTimeout(*drainTimeout), gateway.WithTournamentCheckIn(*checkIn), gateway.WithFreeForAllRules(rules), gateway.WithTeamTieBreak(gameroom.TieBreak(*tieBreak)), gateway.WithMoveDeadline(*moveDeadline), gateway.WithSuddenDeath(suddenDeath)),
+	}
+
+	// Instances sharing a broker match players across each other
+	var sharedBroker *broker.RedisBroker
+	if *redisAddr != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		b, err := broker.NewRedisBroker(ctx, *redisAddr)
+		cancel()
+		if err != nil {
+			log.Fatalf("Cannot reach broker at %s: %v", *redisAddr, err)
+		}
+		sharedBroker = b
+		opts = append(opts, WithBroker(b, *instance))
+		log.Printf("Instance %s sharing matchmaking through %s", *instance, *redisAddr)
+	}
+	if *snapshotFile != "" {
+		store := snapshot.NewFileStore(*snapshotFile)
+		opts = append(opts,
+			WithGatewayOptions(gateway.WithSnapshots(store, *snapshotInterval), gateway.WithRestoreGrace(*reconnectGrace)),
+			WithReadinessCheck("store", store.Ping))
+	}
+	if *matchesFile != "" {
+		store := correspondence.NewFileStore(*matchesFile)
+		opts = append(opts,
+			WithGatewayOptions(gateway.WithMatchStore(store)),
+			WithReadinessCheck("matches", store.Ping))
+	}
+	server := NewServer(port, opts...)
 
 	log.Printf("Paper game server starting on port %s", port)
 	log.Printf("WebSocket endpoint: ws://localhost%s/ws", port)
@@ -67,8 +231,9 @@ func main() {
 		<-c
 		log.Println("Shutting down server...")
 		
-		// Create shutdown context with timeout
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		// Leave time for the whole drain, including the final snapshot
+		// and closing connections, and for the HTTP server after it
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), server.wsHandler.MaxDrainDuration()+5*time.Second)
 		defer cancel()
 		
 		// Shutdown server gracefully
@@ -77,6 +242,9 @@ func main() {
 		} else {
 			log.Println("Server shutdown gracefully")
 		}
+		if sharedBroker != nil {
+			sharedBroker.Close()
+		}
 		
 		log.Println("Server shutdown complete")
 		os.Exit(0)
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+)
+
+// generateAsyncAPI renders an AsyncAPI 2.6 document describing the
+// WebSocket protocol, with JSON Schema payloads for every message
+func generateAsyncAPI(m *model) ([]byte, error) {
+	schemas := make(map[string]any)
+	for _, def := range m.Structs {
+		schema, err := structSchema(def)
+		if err != nil {
+			return nil, err
+		}
+		schemas[def.Name] = schema
+	}
+
+	messages := make(map[string]any)
+	for _, msg := range m.Messages {
+		entry := map[string]any{
+			"name":  msg.Type,
+			"title": msg.Payload.Name,
+			"payload": map[string]any{
+				"type":                 "object",
+				"required":             []string{"type", "data"},
+				"additionalProperties": false,
+				"properties":           envelopeProperties(msg),
+			},
+		}
+		if msg.Payload.Doc != "" {
+			entry["summary"] = msg.Payload.Doc
+		}
+		messages[msg.Type] = entry
+	}
+
+	refs := func(dir protocol.Direction) []any {
+		var out []any
+		for _, msg := range m.messagesFor(dir) {
+			out = append(out, map[string]any{"$ref": "#/components/messages/" + msg.Type})
+		}
+		return out
+	}
+
+	doc := map[string]any{
+		"asyncapi": "2.6.0",
+		"info": map[string]any{
+			"title":       "Paper game protocol",
+			"version":     strconv.Itoa(protocol.Version),
+			"description": "Rock Paper Scissors WebSocket protocol. Generated by cmd/protogen, do not edit.",
+		},
+		"defaultContentType": "application/json",
+		"channels": map[string]any{
+			"/ws": map[string]any{
+				"bindings": map[string]any{
+					"ws": map[string]any{
+						"headers": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"Sec-WebSocket-Protocol": map[string]any{
+									"type": "string",
+									"enum": protocol.Subprotocols(),
+								},
+							},
+						},
+					},
+				},
+				// In AsyncAPI terms the client publishes to the server and
+				// subscribes to what the server sends back
+				"publish": map[string]any{
+					"operationId": "clientToServer",
+					"message":     map[string]any{"oneOf": refs(protocol.ClientToServer)},
+				},
+				"subscribe": map[string]any{
+					"operationId": "serverToClient",
+					"message":     map[string]any{"oneOf": refs(protocol.ServerToClient)},
+				},
+			},
+		},
+		"components": map[string]any{
+			"messages": messages,
+			"schemas":  schemas,
+		},
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// envelopeProperties describes the BaseGameEvent fields around a payload.
+// Only server events carry a sequence number
+func envelopeProperties(msg message) map[string]any {
+	properties := map[string]any{
+		"type": map[string]any{"const": msg.Type},
+		"data": map[string]any{"$ref": "#/components/schemas/" + msg.Payload.Name},
+	}
+	if msg.Direction == protocol.ServerToClient {
+		properties["seq"] = map[string]any{
+			"type":        "integer",
+			"minimum":     1,
+			"description": "Position of the event in the session, missing outside a session such as on welcome",
+		}
+	}
+	return properties
+}
+
+// structSchema returns the JSON Schema for a wire struct
+func structSchema(def *structDef) (map[string]any, error) {
+	properties := make(map[string]any)
+	required := []string{}
+	for _, f := range def.Fields {
+		schema, err := typeSchema(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", def.Name, f.GoName, err)
+		}
+		if f.Comment != "" {
+			schema["description"] = f.Comment
+		}
+		properties[f.JSONName] = schema
+		if !f.OmitEmpty {
+			required = append(required, f.JSONName)
+		}
+	}
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+	if def.Doc != "" {
+		schema["description"] = def.Doc
+	}
+	return schema, nil
+}
+
+// typeSchema maps a Go type to a JSON Schema fragment
+func typeSchema(t reflect.Type) (map[string]any, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.Slice, reflect.Array:
+		items, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map key %s is not a string", t.Key())
+		}
+		values, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
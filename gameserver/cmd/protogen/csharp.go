@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+)
+
+// generateCSharp renders GameMessages.cs for the Unity client
+func generateCSharp(m *model) ([]byte, error) {
+	var b bytes.Buffer
+	w := func(format string, args ...any) {
+		fmt.Fprintf(&b, format, args...)
+	}
+
+	clientMessages := m.messagesFor(protocol.ClientToServer)
+	serverMessages := m.messagesFor(protocol.ServerToClient)
+
+	w("// Code generated by cmd/protogen from internal/types/message.go. DO NOT EDIT.\n")
+	w("// Run `make protogen` in gameserver/ after changing the Go message definitions.\n\n")
+	w("using System;\n\n")
+	w("namespace Scripts.Network\n{\n")
+
+	w("    [Serializable]\n")
+	w("    public class BaseGameEvent<T>\n    {\n")
+	w("        public string type;\n")
+	w("        public T data;\n")
+	w("        public ulong seq;\n")
+	w("    }\n\n")
+
+	// Message type constants
+	w("    public static class MessageTypes\n    {\n")
+	for _, msg := range m.Messages {
+		w("        public const string %s = %s;\n", pascalCase(msg.Type), quote(msg.Type))
+	}
+	w("    }\n\n")
+
+	// Envelopes for outgoing messages
+	w("    // For sending messages with typed data\n")
+	for i, msg := range clientMessages {
+		if i > 0 {
+			w("\n")
+		}
+		w("    [Serializable]\n")
+		w("    public class %sEvent\n    {\n", baseName(msg.Payload.Name))
+		w("        public string type = MessageTypes.%s;\n", pascalCase(msg.Type))
+		w("        public %s data;\n", msg.Payload.Name)
+		w("    }\n")
+	}
+
+	written := make(map[string]bool)
+	writeStructs := func(title string, messages []message) error {
+		w("\n    // %s\n", title)
+		for i, msg := range messages {
+			if i > 0 {
+				w("\n")
+			}
+			if err := writeClass(w, msg.Payload); err != nil {
+				return err
+			}
+			written[msg.Payload.Name] = true
+		}
+		return nil
+	}
+	if err := writeStructs("Client to Server Messages", clientMessages); err != nil {
+		return nil, err
+	}
+	if err := writeStructs("Server to Client Messages", serverMessages); err != nil {
+		return nil, err
+	}
+
+	// Structs referenced from payloads
+	var nested []*structDef
+	for _, def := range m.Structs {
+		if !written[def.Name] {
+			nested = append(nested, def)
+		}
+	}
+	if len(nested) > 0 {
+		w("\n    // Nested types\n")
+		for i, def := range nested {
+			if i > 0 {
+				w("\n")
+			}
+			if err := writeClass(w, def); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Helper class
+	w("\n    // Utility class for message handling\n")
+	w("    public static class GameMessageHelper\n    {\n")
+	w("        // Protocol version spoken by this client\n")
+	w("        public const int ProtocolVersion = %d;\n", protocol.Version)
+	w("        \n")
+	w("        // Send message helpers - convert C# objects to JSON\n")
+	for i, msg := range clientMessages {
+		if i > 0 {
+			w("        \n")
+		}
+		if err := writeCreateMethod(w, msg); err != nil {
+			return nil, err
+		}
+	}
+	w("        \n")
+	w("%s", parseBaseEventSource)
+	w("        \n")
+	w("        // Type-safe message parsing\n")
+	for i, msg := range serverMessages {
+		if i > 0 {
+			w("        \n")
+		}
+		name := msg.Payload.Name
+		w("        public static %s Parse%s(string dataJson)\n        {\n", name, baseName(name))
+		w("            return ParseMessage<%s>(dataJson);\n", name)
+		w("        }\n")
+	}
+	w("    }\n")
+	w("}\n")
+
+	return b.Bytes(), nil
+}
+
+// writeClass emits a serializable C# class mirroring a Go struct
+func writeClass(w func(string, ...any), def *structDef) error {
+	if def.Doc != "" {
+		w("    // %s\n", def.Doc)
+	}
+	w("    [Serializable]\n")
+	w("    public class %s\n    {\n", def.Name)
+	if len(def.Fields) == 0 {
+		w("        // Empty message\n")
+	}
+	for _, f := range def.Fields {
+		csType, err := csharpType(f.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", def.Name, f.GoName, err)
+		}
+		line := fmt.Sprintf("        public %s %s;", csType, csharpIdent(f.JSONName))
+		if f.Comment != "" {
+			line += " // " + f.Comment
+		}
+		w("%s\n", line)
+	}
+	w("    }\n")
+	return nil
+}
+
+// csharpKeywords are reserved words that clash with wire field names
+var csharpKeywords = map[string]bool{
+	"abstract": true, "as": true, "base": true, "bool": true, "break": true, "byte": true,
+	"case": true, "catch": true, "char": true, "checked": true, "class": true,
+	"const": true, "continue": true, "decimal": true, "default": true, "delegate": true,
+	"do": true, "double": true, "else": true, "enum": true, "event": true, "explicit": true,
+	"extern": true, "false": true, "finally": true, "fixed": true, "float": true,
+	"for": true, "foreach": true, "goto": true, "if": true, "implicit": true, "in": true,
+	"int": true, "interface": true, "internal": true, "is": true, "lock": true,
+	"long": true, "namespace": true, "new": true, "null": true, "object": true,
+	"operator": true, "out": true, "override": true, "params": true, "private": true,
+	"protected": true, "public": true, "readonly": true, "ref": true, "return": true,
+	"sbyte": true, "sealed": true, "short": true, "sizeof": true, "stackalloc": true,
+	"static": true, "string": true, "struct": true, "switch": true, "this": true,
+	"throw": true, "true": true, "try": true, "typeof": true, "uint": true, "ulong": true,
+	"unchecked": true, "unsafe": true, "ushort": true, "using": true, "virtual": true,
+	"void": true, "volatile": true, "while": true,
+}
+
+// csharpIdent escapes a field or parameter name that is a C# keyword.
+// JsonUtility still uses the unescaped name on the wire.
+func csharpIdent(name string) string {
+	if csharpKeywords[name] {
+		return "@" + name
+	}
+	return name
+}
+
+// writeCreateMethod emits a GameMessageHelper.CreateX method taking one
+// parameter per payload field
+func writeCreateMethod(w func(string, ...any), msg message) error {
+	def := msg.Payload
+	params := make([]string, 0, len(def.Fields))
+	assigns := make([]string, 0, len(def.Fields))
+	for _, f := range def.Fields {
+		csType, err := csharpType(f.Type)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", def.Name, f.GoName, err)
+		}
+		params = append(params, csType+" "+csharpIdent(camelCase(f.JSONName)))
+		assigns = append(assigns, fmt.Sprintf("%s = %s", csharpIdent(f.JSONName), csharpIdent(camelCase(f.JSONName))))
+	}
+
+	name := baseName(def.Name)
+	w("        public static string Create%s(%s)\n        {\n", name, strings.Join(params, ", "))
+	w("            var envelope = new %sEvent\n            {\n", name)
+	switch len(assigns) {
+	case 0:
+		w("                data = new %s()\n", def.Name)
+	case 1:
+		w("                data = new %s { %s }\n", def.Name, assigns[0])
+	default:
+		w("                data = new %s\n                {\n", def.Name)
+		for i, assign := range assigns {
+			sep := ","
+			if i == len(assigns)-1 {
+				sep = ""
+			}
+			w("                    %s%s\n", assign, sep)
+		}
+		w("                }\n")
+	}
+	w("            };\n")
+	w("            return UnityEngine.JsonUtility.ToJson(envelope);\n")
+	w("        }\n")
+	return nil
+}
+
+// csharpType maps a Go type to the C# type JsonUtility can (de)serialize
+func csharpType(t reflect.Type) (string, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return "string", nil
+	case reflect.Bool:
+		return "bool", nil
+	case reflect.Int, reflect.Int32, reflect.Int16, reflect.Int8:
+		return "int", nil
+	case reflect.Int64:
+		return "long", nil
+	case reflect.Uint, reflect.Uint32, reflect.Uint16, reflect.Uint8:
+		return "uint", nil
+	case reflect.Uint64:
+		return "ulong", nil
+	case reflect.Float32:
+		return "float", nil
+	case reflect.Float64:
+		return "double", nil
+	case reflect.Pointer:
+		return csharpType(t.Elem())
+	case reflect.Slice, reflect.Array:
+		elem, err := csharpType(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return elem + "[]", nil
+	case reflect.Struct:
+		return t.Name(), nil
+	default:
+		return "", fmt.Errorf("type %s has no JsonUtility equivalent", t)
+	}
+}
+
+// parseBaseEventSource is the hand-written envelope parser. JsonUtility
+// cannot read a generic data object, so the payload is cut out as a string
+// and parsed once the type is known.
+const parseBaseEventSource = `        // Receive message helpers - parse JSON to C# objects
+        public static T ParseMessage<T>(string jsonData)
+        {
+            return UnityEngine.JsonUtility.FromJson<T>(jsonData);
+        }
+
+        // Simple BaseGameEvent for parsing incoming messages
+        [System.Serializable]
+        public class IncomingGameEvent
+        {
+            public string type;
+            public string data;
+            public ulong seq; // 0 for messages outside a session, e.g. welcome
+        }
+
+        public static IncomingGameEvent ParseBaseEvent(string json)
+        {
+            try
+            {
+                // Extract type field
+                int typeStart = json.IndexOf("\"type\":\"") + 8;
+                int typeEnd = json.IndexOf("\"", typeStart);
+                string messageType = json.Substring(typeStart, typeEnd - typeStart);
+                ulong seq = ParseSeq(json);
+
+                // Extract data part
+                int dataKeyIndex = json.IndexOf("\"data\":");
+                if (dataKeyIndex == -1)
+                {
+                    return new IncomingGameEvent { type = messageType, data = "{}", seq = seq };
+                }
+
+                // Find the start of data value (after the colon)
+                int colonIndex = json.IndexOf(":", dataKeyIndex);
+                int dataStart = colonIndex + 1;
+
+                // Skip whitespace
+                while (dataStart < json.Length && char.IsWhiteSpace(json[dataStart]))
+                    dataStart++;
+
+                // Find the end of the data object
+                int dataEnd;
+                if (json[dataStart] == '{')
+                {
+                    // Find matching closing brace
+                    int braceCount = 1;
+                    dataEnd = dataStart + 1;
+                    while (dataEnd < json.Length && braceCount > 0)
+                    {
+                        if (json[dataEnd] == '{') braceCount++;
+                        else if (json[dataEnd] == '}') braceCount--;
+                        dataEnd++;
+                    }
+                    dataEnd--; // Point to the closing brace
+                }
+                else
+                {
+                    // Handle other data types (shouldn't happen in our protocol)
+                    dataEnd = json.LastIndexOf('}') - 1;
+                }
+
+                string dataJson = json.Substring(dataStart, dataEnd - dataStart + 1);
+
+                return new IncomingGameEvent { type = messageType, data = dataJson, seq = seq };
+            }
+            catch (System.Exception e)
+            {
+                UnityEngine.Debug.LogError($"Failed to parse JSON: {json}, Error: {e.Message}");
+                return new IncomingGameEvent { type = "error", data = "{}" };
+            }
+        }
+
+        // ParseSeq reads the top level seq field. The server writes it after
+        // data, so search from the end to skip any seq inside the payload.
+        static ulong ParseSeq(string json)
+        {
+            int seqIndex = json.LastIndexOf("\"seq\":");
+            if (seqIndex == -1)
+            {
+                return 0;
+            }
+
+            int start = seqIndex + 6;
+            int end = start;
+            while (end < json.Length && char.IsDigit(json[end]))
+                end++;
+
+            ulong seq;
+            return ulong.TryParse(json.Substring(start, end - start), out seq) ? seq : 0;
+        }
+`
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// output is one generated file
+type output struct {
+	path string
+	data []byte
+}
+
+func main() {
+	var typesFile = flag.String("types", "internal/types/message.go", "Go file with the message definitions")
+	var csharpOut = flag.String("csharp", "../paper_client/Assets/Scripts/Network/GameMessages.cs", "Generated C# message classes")
+	var schemaOut = flag.String("asyncapi", "docs/asyncapi.json", "Generated AsyncAPI/JSON Schema description")
+	var check = flag.Bool("check", false, "Fail if the generated files are out of date instead of writing them")
+	flag.Parse()
+
+	outputs, err := generate(*typesFile, *csharpOut, *schemaOut)
+	if err != nil {
+		log.Fatal("protogen: ", err)
+	}
+
+	stale := false
+	for _, out := range outputs {
+		current, err := os.ReadFile(out.path)
+		if err == nil && bytes.Equal(current, out.data) {
+			continue
+		}
+
+		if *check {
+			fmt.Printf("protogen: %s is out of date\n", out.path)
+			stale = true
+			continue
+		}
+
+		if err := os.WriteFile(out.path, out.data, 0644); err != nil {
+			log.Fatal("protogen: ", err)
+		}
+		fmt.Printf("protogen: wrote %s\n", out.path)
+	}
+
+	if stale {
+		fmt.Println("protogen: run `make protogen` to regenerate")
+		os.Exit(1)
+	}
+}
+
+// generate builds every output from the registry and the message source
+func generate(typesFile, csharpOut, schemaOut string) ([]output, error) {
+	c, err := parseComments(typesFile)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", typesFile, err)
+	}
+
+	m, err := buildModel(c)
+	if err != nil {
+		return nil, err
+	}
+
+	csharp, err := generateCSharp(m)
+	if err != nil {
+		return nil, fmt.Errorf("generate C#: %w", err)
+	}
+
+	schema, err := generateAsyncAPI(m)
+	if err != nil {
+		return nil, fmt.Errorf("generate AsyncAPI: %w", err)
+	}
+
+	return []output{
+		{path: csharpOut, data: csharp},
+		{path: schemaOut, data: schema},
+	}, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+)
+
+const (
+	testTypesFile = "../../internal/types/message.go"
+	testCSharp    = "../../../paper_client/Assets/Scripts/Network/GameMessages.cs"
+	testAsyncAPI  = "../../docs/asyncapi.json"
+)
+
+// TestGeneratedFilesUpToDate fails when the Go messages changed without
+// regenerating the Unity classes and protocol description
+func TestGeneratedFilesUpToDate(t *testing.T) {
+	outputs, err := generate(testTypesFile, testCSharp, testAsyncAPI)
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	for _, out := range outputs {
+		current, err := os.ReadFile(out.path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", out.path, err)
+		}
+		if !bytes.Equal(current, out.data) {
+			t.Errorf("%s is out of date, run `make protogen`", out.path)
+		}
+	}
+}
+
+func TestCSharp_CoversEveryMessage(t *testing.T) {
+	c, err := parseComments(testTypesFile)
+	if err != nil {
+		t.Fatalf("parseComments failed: %v", err)
+	}
+	m, err := buildModel(c)
+	if err != nil {
+		t.Fatalf("buildModel failed: %v", err)
+	}
+	code, err := generateCSharp(m)
+	if err != nil {
+		t.Fatalf("generateCSharp failed: %v", err)
+	}
+	source := string(code)
+
+	for _, spec := range protocol.Specs() {
+		name := baseName(spec.GoType.Name())
+		if !strings.Contains(source, "public class "+spec.GoType.Name()) {
+			t.Errorf("Missing class for %s", spec.GoType.Name())
+		}
+
+		helper := "Parse" + name + "("
+		if spec.Direction == protocol.ClientToServer {
+			helper = "Create" + name + "("
+		}
+		if !strings.Contains(source, helper) {
+			t.Errorf("Missing %s helper for %s", helper, spec.Type)
+		}
+	}
+
+	// Field comments are carried over from the Go source
+	if !strings.Contains(source, `public string result; // "win", "lose", "draw"`) {
+		t.Error("Expected field comments to be copied from message.go")
+	}
+}
+
+func TestAsyncAPI_DescribesEveryMessage(t *testing.T) {
+	c, _ := parseComments(testTypesFile)
+	m, _ := buildModel(c)
+	data, err := generateAsyncAPI(m)
+	if err != nil {
+		t.Fatalf("generateAsyncAPI failed: %v", err)
+	}
+
+	var doc struct {
+		Components struct {
+			Messages map[string]json.RawMessage `json:"messages"`
+			Schemas  map[string]struct {
+				Required []string `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("AsyncAPI output is not valid JSON: %v", err)
+	}
+
+	for _, spec := range protocol.Specs() {
+		if _, ok := doc.Components.Messages[spec.Type]; !ok {
+			t.Errorf("Missing AsyncAPI message for %s", spec.Type)
+		}
+	}
+
+	// omitempty fields are optional, everything else is required
+	for _, field := range doc.Components.Schemas["ErrorMessage"].Required {
+		if field == "code" {
+			t.Error("Expected optional code field to be left out of required")
+		}
+	}
+}
+
+func TestCSharpType_RejectsMaps(t *testing.T) {
+	type withMap struct {
+		Scores map[string]int
+	}
+	def := &structDef{Name: "WithMap", Fields: []fieldDef{{GoName: "Scores", JSONName: "scores"}}}
+	def.Fields[0].Type = fieldType[withMap]("Scores")
+
+	var b bytes.Buffer
+	w := func(format string, args ...any) { b.WriteString(format) }
+	if err := writeClass(w, def); err == nil {
+		t.Error("Expected maps to be rejected since JsonUtility cannot read them")
+	}
+}
+
+func fieldType[T any](name string) reflect.Type {
+	f, _ := reflect.TypeFor[T]().FieldByName(name)
+	return f.Type
+}
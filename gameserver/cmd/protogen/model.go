@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+)
+
+// message is a registered protocol message with its payload struct
+type message struct {
+	Type      string
+	Direction protocol.Direction
+	Payload   *structDef
+}
+
+// structDef describes a Go struct that appears on the wire
+type structDef struct {
+	Name   string
+	Doc    string
+	Fields []fieldDef
+}
+
+// fieldDef describes one JSON-visible struct field
+type fieldDef struct {
+	GoName    string
+	JSONName  string
+	Type      reflect.Type
+	OmitEmpty bool
+	Comment   string
+}
+
+// model is everything the generators need to know about the protocol
+type model struct {
+	Messages []message
+	// Structs holds payload structs and the structs they reference,
+	// in the order they were first seen
+	Structs []*structDef
+}
+
+// comments maps struct name to its doc comment and field comments,
+// read from the Go source of the message definitions
+type comments struct {
+	structs map[string]string
+	fields  map[string]map[string]string
+}
+
+// parseComments reads doc and line comments from the Go message definitions
+func parseComments(path string) (*comments, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &comments{
+		structs: make(map[string]string),
+		fields:  make(map[string]map[string]string),
+	}
+
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			structType, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+
+			doc := typeSpec.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			// Only real doc comments count, not section headers such as
+			// "Client to Server Messages" that happen to sit above a type
+			if text := commentText(doc); strings.HasPrefix(text, typeSpec.Name.Name+" ") {
+				c.structs[typeSpec.Name.Name] = text
+			}
+
+			fields := make(map[string]string)
+			for _, field := range structType.Fields.List {
+				text := commentText(field.Comment)
+				if text == "" {
+					text = commentText(field.Doc)
+				}
+				for _, name := range field.Names {
+					fields[name.Name] = text
+				}
+			}
+			c.fields[typeSpec.Name.Name] = fields
+		}
+	}
+	return c, nil
+}
+
+func commentText(group *ast.CommentGroup) string {
+	if group == nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.Join(strings.Fields(group.Text()), " "))
+}
+
+// buildModel collects every registered message and its payload structs
+func buildModel(c *comments) (*model, error) {
+	m := &model{}
+	seen := make(map[reflect.Type]*structDef)
+
+	var addStruct func(t reflect.Type) (*structDef, error)
+	addStruct = func(t reflect.Type) (*structDef, error) {
+		if def, ok := seen[t]; ok {
+			return def, nil
+		}
+
+		def := &structDef{Name: t.Name(), Doc: c.structs[t.Name()]}
+		seen[t] = def
+		m.Structs = append(m.Structs, def)
+
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+
+			name, omitEmpty := parseJSONTag(f)
+			if name == "-" {
+				continue
+			}
+
+			def.Fields = append(def.Fields, fieldDef{
+				GoName:    f.Name,
+				JSONName:  name,
+				Type:      f.Type,
+				OmitEmpty: omitEmpty,
+				Comment:   c.fields[t.Name()][f.Name],
+			})
+
+			if nested := structElem(f.Type); nested != nil {
+				if _, err := addStruct(nested); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return def, nil
+	}
+
+	for _, spec := range protocol.Specs() {
+		if spec.GoType.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("%s: payload %s is not a struct", spec.Type, spec.GoType)
+		}
+		def, err := addStruct(spec.GoType)
+		if err != nil {
+			return nil, err
+		}
+		m.Messages = append(m.Messages, message{
+			Type:      spec.Type,
+			Direction: spec.Direction,
+			Payload:   def,
+		})
+	}
+	return m, nil
+}
+
+// parseJSONTag returns the wire name of a field and whether it is omitempty
+func parseJSONTag(f reflect.StructField) (string, bool) {
+	tag, ok := f.Tag.Lookup("json")
+	if !ok {
+		return f.Name, false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = f.Name
+	}
+	omitEmpty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty
+}
+
+// structElem returns the struct type behind slices and pointers, if any
+func structElem(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		return t
+	}
+	return nil
+}
+
+// messagesFor returns the messages sent in one direction
+func (m *model) messagesFor(dir protocol.Direction) []message {
+	var out []message
+	for _, msg := range m.Messages {
+		if msg.Direction == dir {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// baseName strips the Message suffix: HelloMessage -> Hello
+func baseName(structName string) string {
+	return strings.TrimSuffix(structName, "Message")
+}
+
+// pascalCase turns a wire name into a C# identifier: join_lobby -> JoinLobby
+func pascalCase(s string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(s, "_") {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// camelCase turns a wire name into a C# parameter: client_name -> clientName
+func camelCase(s string) string {
+	p := pascalCase(s)
+	if p == "" {
+		return p
+	}
+	return strings.ToLower(p[:1]) + p[1:]
+}
+
+// quote returns a C#/JSON compatible string literal
+func quote(s string) string {
+	return strconv.Quote(s)
+}
@@ -0,0 +1,41 @@
+// Package broker connects game server instances. It keeps one shared
+// matchmaking queue and carries messages between instances, so players
+// connected to different instances can be paired and play each other.
+package broker
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClosed is returned by a broker after Close
+var ErrClosed = errors.New("broker closed")
+
+// Ticket is a player waiting in the shared matchmaking queue
+type Ticket struct {
+	PlayerID string `json:"player_id"`
+	Name     string `json:"name"`
+	Instance string `json:"instance"` // instance the player is connected to
+}
+
+// Broker queues players and routes messages across instances.
+// Implementations must be safe for concurrent use.
+type Broker interface {
+	// Pair takes the oldest ticket from queue and returns it as the
+	// opponent for t. If nobody is waiting, t is queued and Pair
+	// returns nil.
+	Pair(ctx context.Context, queue string, t Ticket) (*Ticket, error)
+	// Remove takes a player's ticket out of queue, if it is there
+	Remove(ctx context.Context, queue string, playerID string) error
+	// Publish sends payload to every subscriber of topic
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe delivers the payloads published to topic until ctx is done
+	Subscribe(ctx context.Context, topic string) (<-chan []byte, error)
+	// Close releases the broker's connections
+	Close() error
+}
+
+// InstanceTopic is the topic an instance receives its messages on
+func InstanceTopic(instance string) string {
+	return "paper.instance." + instance
+}
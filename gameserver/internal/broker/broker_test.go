@@ -0,0 +1,272 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// brokers returns a fresh instance of every Broker implementation
+func brokers(t *testing.T) map[string]func() Broker {
+	return map[string]func() Broker{
+		"memory": func() Broker {
+			b := NewMemoryBroker()
+			t.Cleanup(func() { b.Close() })
+			return b
+		},
+		"redis": func() Broker {
+			server, err := StartLocalRedis()
+			if err != nil {
+				t.Fatal("Failed to start local redis:", err)
+			}
+			t.Cleanup(func() { server.Close() })
+
+			b, err := NewRedisBroker(context.Background(), server.Addr())
+			if err != nil {
+				t.Fatal("Failed to connect:", err)
+			}
+			t.Cleanup(func() { b.Close() })
+			return b
+		},
+	}
+}
+
+func TestBroker_Pair(t *testing.T) {
+	for name, newBroker := range brokers(t) {
+		t.Run(name, func(t *testing.T) {
+			b := newBroker()
+			ctx := context.Background()
+
+			alice := Ticket{PlayerID: "p1", Name: "Alice", Instance: "a"}
+			bob := Ticket{PlayerID: "p2", Name: "Bob", Instance: "b"}
+
+			opponent, err := b.Pair(ctx, "lobby", alice)
+			if err != nil || opponent != nil {
+				t.Fatalf("First ticket should be queued, got %v, %v", opponent, err)
+			}
+
+			// Queueing the same player again must not pair it with itself
+			if opponent, err := b.Pair(ctx, "lobby", alice); err != nil || opponent != nil {
+				t.Fatalf("Player should not be paired with itself, got %v, %v", opponent, err)
+			}
+
+			opponent, err = b.Pair(ctx, "lobby", bob)
+			if err != nil {
+				t.Fatal("Pair failed:", err)
+			}
+			if opponent == nil || *opponent != alice {
+				t.Errorf("Expected Alice as opponent, got %v", opponent)
+			}
+
+			// The queue is empty again
+			if opponent, _ := b.Pair(ctx, "other", bob); opponent != nil {
+				t.Errorf("Queues should be independent, got %v", opponent)
+			}
+		})
+	}
+}
+
+func TestBroker_Remove(t *testing.T) {
+	for name, newBroker := range brokers(t) {
+		t.Run(name, func(t *testing.T) {
+			b := newBroker()
+			ctx := context.Background()
+
+			b.Pair(ctx, "lobby", Ticket{PlayerID: "p1", Name: "Alice"})
+			b.Pair(ctx, "other", Ticket{PlayerID: "p3", Name: "Carol"})
+			if err := b.Remove(ctx, "lobby", "p1"); err != nil {
+				t.Fatal("Remove failed:", err)
+			}
+			if err := b.Remove(ctx, "lobby", "missing"); err != nil {
+				t.Error("Removing an unknown player should not fail:", err)
+			}
+
+			opponent, err := b.Pair(ctx, "lobby", Ticket{PlayerID: "p2", Name: "Bob"})
+			if err != nil || opponent != nil {
+				t.Errorf("Removed ticket should not be paired, got %v, %v", opponent, err)
+			}
+		})
+	}
+}
+
+func TestBroker_PublishSubscribe(t *testing.T) {
+	for name, newBroker := range brokers(t) {
+		t.Run(name, func(t *testing.T) {
+			b := newBroker()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			ch, err := b.Subscribe(ctx, InstanceTopic("a"))
+			if err != nil {
+				t.Fatal("Subscribe failed:", err)
+			}
+			other, err := b.Subscribe(ctx, InstanceTopic("b"))
+			if err != nil {
+				t.Fatal("Subscribe failed:", err)
+			}
+
+			for i := range 100 {
+				if err := b.Publish(ctx, InstanceTopic("a"), fmt.Appendf(nil, "m%d", i)); err != nil {
+					t.Fatal("Publish failed:", err)
+				}
+			}
+
+			// Messages arrive in publish order
+			for i := range 100 {
+				select {
+				case payload := <-ch:
+					if want := fmt.Sprintf("m%d", i); string(payload) != want {
+						t.Fatalf("Expected %s, got %s", want, payload)
+					}
+				case <-time.After(time.Second):
+					t.Fatalf("Timed out waiting for message %d", i)
+				}
+			}
+
+			select {
+			case payload := <-other:
+				t.Errorf("Other topic should not receive %s", payload)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			// Cancelling the context ends the subscription
+			cancel()
+			select {
+			case _, ok := <-ch:
+				if ok {
+					t.Error("Expected channel to be closed")
+				}
+			case <-time.After(time.Second):
+				t.Error("Subscription did not end after cancel")
+			}
+		})
+	}
+}
+
+func TestBroker_Closed(t *testing.T) {
+	for name, newBroker := range brokers(t) {
+		t.Run(name, func(t *testing.T) {
+			b := newBroker()
+			b.Close()
+
+			if _, err := b.Pair(context.Background(), "lobby", Ticket{PlayerID: "p1"}); err != ErrClosed {
+				t.Errorf("Expected ErrClosed, got %v", err)
+			}
+			if err := b.Publish(context.Background(), "topic", nil); err != ErrClosed {
+				t.Errorf("Expected ErrClosed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRedisBroker_Reconnect(t *testing.T) {
+	server, err := StartLocalRedis()
+	if err != nil {
+		t.Fatal("Failed to start local redis:", err)
+	}
+	defer server.Close()
+
+	b, err := NewRedisBroker(context.Background(), server.Addr())
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer b.Close()
+
+	// Drop the command connection behind the broker's back
+	b.mu.Lock()
+	b.conn.conn.Close()
+	b.mu.Unlock()
+
+	if err := b.Ping(context.Background()); err == nil {
+		t.Error("Ping on a dropped connection should fail once")
+	}
+	if err := b.Ping(context.Background()); err != nil {
+		t.Error("Broker should reconnect after a network error:", err)
+	}
+}
+
+func TestRedisBroker_Resubscribe(t *testing.T) {
+	server, err := StartLocalRedis()
+	if err != nil {
+		t.Fatal("Failed to start local redis:", err)
+	}
+	defer server.Close()
+
+	b, err := NewRedisBroker(context.Background(), server.Addr())
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer b.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := b.Subscribe(ctx, InstanceTopic("a"))
+	if err != nil {
+		t.Fatal("Subscribe failed:", err)
+	}
+
+	// Drop the subscription behind the broker's back
+	b.mu.Lock()
+	for conn := range b.subs {
+		conn.Close()
+	}
+	b.mu.Unlock()
+
+	// Messages arrive again once the broker subscribed anew
+	deadline := time.After(5 * time.Second)
+	for {
+		if err := b.Publish(ctx, InstanceTopic("a"), []byte("hello")); err != nil {
+			t.Fatal("Publish failed:", err)
+		}
+		select {
+		case payload, ok := <-ch:
+			if !ok {
+				t.Fatal("Subscription ended when its connection dropped")
+			}
+			if string(payload) != "hello" {
+				t.Fatalf("Expected hello, got %s", payload)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("Timed out waiting for the broker to subscribe again")
+		}
+	}
+}
+
+func TestRedisBroker_UnlockKeepsAnotherInstancesLock(t *testing.T) {
+	server, err := StartLocalRedis()
+	if err != nil {
+		t.Fatal("Failed to start local redis:", err)
+	}
+	defer server.Close()
+
+	b, err := NewRedisBroker(context.Background(), server.Addr())
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer b.Close()
+
+	ctx := context.Background()
+	key := queueKey("lobby") + ":lock"
+	unlock, err := b.lock(ctx, "lobby")
+	if err != nil {
+		t.Fatal("Lock failed:", err)
+	}
+	unlock()
+	if reply, _ := b.do(ctx, "GET", key); reply != nil {
+		t.Fatalf("Expected the lock to be released, it holds %s", asBytes(reply))
+	}
+
+	// The lock expired and another instance took it before the release
+	unlock, err = b.lock(ctx, "lobby")
+	if err != nil {
+		t.Fatal("Lock failed:", err)
+	}
+	b.do(ctx, "SET", key, "other")
+	unlock()
+	if reply, _ := b.do(ctx, "GET", key); string(asBytes(reply)) != "other" {
+		t.Errorf("Expected the other instance to keep its lock, got %q", asBytes(reply))
+	}
+}
@@ -0,0 +1,335 @@
+package broker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LocalRedis is an in-memory server for the subset of the Redis protocol
+// that RedisBroker uses. It stands in for a real server in tests, it is not
+// meant for production.
+type LocalRedis struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	strings map[string]localValue
+	lists   map[string][]string
+	subs    map[string]map[*localConn]struct{}
+	conns   map[*localConn]struct{}
+	wg      sync.WaitGroup
+}
+
+// localValue is a string key with an optional expiry
+type localValue struct {
+	value   string
+	expires time.Time
+}
+
+// localConn is one client of a LocalRedis
+type localConn struct {
+	*respConn
+	writeMu sync.Mutex
+}
+
+// StartLocalRedis listens on a random local port and serves until Close
+func StartLocalRedis() (*LocalRedis, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &LocalRedis{
+		ln:      ln,
+		strings: make(map[string]localValue),
+		lists:   make(map[string][]string),
+		subs:    make(map[string]map[*localConn]struct{}),
+		conns:   make(map[*localConn]struct{}),
+	}
+	r.wg.Add(1)
+	go r.accept()
+	return r, nil
+}
+
+// Addr returns the address to pass to NewRedisBroker
+func (r *LocalRedis) Addr() string {
+	return r.ln.Addr().String()
+}
+
+// Close stops the server and drops every connection
+func (r *LocalRedis) Close() error {
+	err := r.ln.Close()
+	r.mu.Lock()
+	for c := range r.conns {
+		c.conn.Close()
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+	return err
+}
+
+func (r *LocalRedis) accept() {
+	defer r.wg.Done()
+	for {
+		conn, err := r.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &localConn{respConn: newRESPConn(conn)}
+		r.mu.Lock()
+		r.conns[c] = struct{}{}
+		r.mu.Unlock()
+
+		r.wg.Add(1)
+		go r.serve(c)
+	}
+}
+
+// serve answers commands from one connection until it closes
+func (r *LocalRedis) serve(c *localConn) {
+	defer r.wg.Done()
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, c)
+		for _, subscribers := range r.subs {
+			delete(subscribers, c)
+		}
+		r.mu.Unlock()
+		c.conn.Close()
+	}()
+
+	for {
+		reply, err := c.readReply()
+		if err != nil {
+			if err != io.EOF && !strings.Contains(err.Error(), "closed") {
+				log.Printf("Local redis: %v", err)
+			}
+			return
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) == 0 {
+			c.write(respError("ERR expected a command array"))
+			continue
+		}
+		args := make([]string, len(parts))
+		for i, part := range parts {
+			args[i] = string(asBytes(part))
+		}
+		reply, push := r.exec(c, strings.ToUpper(args[0]), args[1:])
+		if push != nil {
+			// Delivered before the reply so a publisher's messages stay in order
+			push()
+		}
+		c.write(reply)
+	}
+}
+
+// exec runs one command and returns its reply. PUBLISH also returns the
+// function pushing the message, to be called without holding mu.
+func (r *LocalRedis) exec(c *localConn, cmd string, args []string) (any, func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch cmd {
+	case "PING":
+		return "PONG", nil
+
+	case "SET":
+		if len(args) < 2 {
+			return wrongArgs(cmd), nil
+		}
+		key, value := args[0], args[1]
+		nx := false
+		var expires time.Time
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				if i+1 >= len(args) {
+					return wrongArgs(cmd), nil
+				}
+				ms, err := strconv.Atoi(args[i+1])
+				if err != nil {
+					return respError("ERR value is not an integer"), nil
+				}
+				expires = time.Now().Add(time.Duration(ms) * time.Millisecond)
+				i++
+			}
+		}
+		if _, exists := r.get(key); nx && exists {
+			return nil, nil
+		}
+		r.strings[key] = localValue{value: value, expires: expires}
+		return "OK", nil
+
+	case "GET":
+		if len(args) != 1 {
+			return wrongArgs(cmd), nil
+		}
+		if value, ok := r.get(args[0]); ok {
+			return []byte(value), nil
+		}
+		return nil, nil
+
+	case "DEL":
+		deleted := int64(0)
+		for _, key := range args {
+			if _, ok := r.get(key); ok {
+				delete(r.strings, key)
+				deleted++
+			}
+			if _, ok := r.lists[key]; ok {
+				delete(r.lists, key)
+				deleted++
+			}
+		}
+		return deleted, nil
+
+	case "EVAL":
+		// Only the script RedisBroker releases its locks with
+		if len(args) != 4 || args[0] != unlockScript || args[1] != "1" {
+			return respError("ERR local redis only runs the unlock script"), nil
+		}
+		if value, ok := r.get(args[2]); ok && value == args[3] {
+			delete(r.strings, args[2])
+			return int64(1), nil
+		}
+		return int64(0), nil
+
+	case "RPUSH":
+		if len(args) < 2 {
+			return wrongArgs(cmd), nil
+		}
+		r.lists[args[0]] = append(r.lists[args[0]], args[1:]...)
+		return int64(len(r.lists[args[0]])), nil
+
+	case "LPOP":
+		if len(args) != 1 {
+			return wrongArgs(cmd), nil
+		}
+		list := r.lists[args[0]]
+		if len(list) == 0 {
+			return nil, nil
+		}
+		r.lists[args[0]] = list[1:]
+		return []byte(list[0]), nil
+
+	case "LRANGE":
+		if len(args) != 3 {
+			return wrongArgs(cmd), nil
+		}
+		list := r.lists[args[0]]
+		start, err1 := strconv.Atoi(args[1])
+		stop, err2 := strconv.Atoi(args[2])
+		if err1 != nil || err2 != nil {
+			return respError("ERR value is not an integer"), nil
+		}
+		if stop < 0 {
+			stop += len(list)
+		}
+		values := []any{}
+		for i := max(start, 0); i <= stop && i < len(list); i++ {
+			values = append(values, []byte(list[i]))
+		}
+		return values, nil
+
+	case "LREM":
+		if len(args) != 3 {
+			return wrongArgs(cmd), nil
+		}
+		count, err := strconv.Atoi(args[1])
+		if err != nil {
+			return respError("ERR value is not an integer"), nil
+		}
+		list := r.lists[args[0]]
+		kept := list[:0]
+		removed := int64(0)
+		for _, item := range list {
+			if item == args[2] && (count <= 0 || removed < int64(count)) {
+				removed++
+				continue
+			}
+			kept = append(kept, item)
+		}
+		r.lists[args[0]] = kept
+		return removed, nil
+
+	case "PUBLISH":
+		if len(args) != 2 {
+			return wrongArgs(cmd), nil
+		}
+		message := []any{[]byte("message"), []byte(args[0]), []byte(args[1])}
+		subscribers := make([]*localConn, 0, len(r.subs[args[0]]))
+		for subscriber := range r.subs[args[0]] {
+			subscribers = append(subscribers, subscriber)
+		}
+		return int64(len(subscribers)), func() {
+			for _, subscriber := range subscribers {
+				subscriber.write(message)
+			}
+		}
+
+	case "SUBSCRIBE":
+		if len(args) != 1 {
+			return respError("ERR local redis supports one channel per SUBSCRIBE"), nil
+		}
+		if r.subs[args[0]] == nil {
+			r.subs[args[0]] = make(map[*localConn]struct{})
+		}
+		r.subs[args[0]][c] = struct{}{}
+		return []any{[]byte("subscribe"), []byte(args[0]), int64(1)}, nil
+	}
+	return respError(fmt.Sprintf("ERR unknown command '%s'", cmd)), nil
+}
+
+// get returns a string key unless it expired, the caller must hold mu
+func (r *LocalRedis) get(key string) (string, bool) {
+	v, ok := r.strings[key]
+	if !ok {
+		return "", false
+	}
+	if !v.expires.IsZero() && time.Now().After(v.expires) {
+		delete(r.strings, key)
+		return "", false
+	}
+	return v.value, true
+}
+
+func wrongArgs(cmd string) respError {
+	return respError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+}
+
+// write encodes one reply
+func (c *localConn) write(reply any) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	writeValue(c.w, reply)
+	c.w.Flush()
+}
+
+// writeValue encodes a reply in RESP
+func writeValue(w *bufio.Writer, value any) {
+	switch v := value.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case string:
+		fmt.Fprintf(w, "+%s\r\n", v)
+	case respError:
+		fmt.Fprintf(w, "-%s\r\n", string(v))
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case []byte:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []any:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeValue(w, item)
+		}
+	}
+}
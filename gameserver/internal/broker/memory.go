@@ -0,0 +1,156 @@
+package broker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many messages a subscriber may lag behind
+// before Publish waits for it
+const subscriberBuffer = 256
+
+// MemoryBroker is a Broker for a single process. Several lobbies sharing
+// one MemoryBroker behave like instances sharing a network broker.
+type MemoryBroker struct {
+	mu          sync.Mutex
+	queues      map[string][]Ticket
+	subscribers map[string]map[chan []byte]struct{}
+	closed      bool
+}
+
+// NewMemoryBroker creates an empty in-process broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		queues:      make(map[string][]Ticket),
+		subscribers: make(map[string]map[chan []byte]struct{}),
+	}
+}
+
+// Pair takes the oldest waiting ticket or queues t
+func (b *MemoryBroker) Pair(ctx context.Context, queue string, t Ticket) (*Ticket, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+	for len(b.queues[queue]) > 0 {
+		opponent := b.queues[queue][0]
+		b.queues[queue] = b.queues[queue][1:]
+		if opponent.PlayerID != t.PlayerID {
+			return &opponent, nil
+		}
+	}
+	b.queues[queue] = append(b.queues[queue], t)
+	return nil, nil
+}
+
+// Remove takes a player's ticket out of queue
+func (b *MemoryBroker) Remove(ctx context.Context, queue string, playerID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tickets := b.queues[queue]
+	for i, t := range tickets {
+		if t.PlayerID == playerID {
+			b.queues[queue] = append(tickets[:i:i], tickets[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Publish hands payload to every subscriber of topic, waiting for
+// subscribers that are more than subscriberBuffer messages behind
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrClosed
+	}
+	subscribers := make([]chan []byte, 0, len(b.subscribers[topic]))
+	for ch := range b.subscribers[topic] {
+		subscribers = append(subscribers, ch)
+	}
+	b.mu.Unlock()
+
+	for _, ch := range subscribers {
+		if err := b.deliver(ctx, topic, ch, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver sends payload to one subscriber unless it unsubscribed meanwhile
+func (b *MemoryBroker) deliver(ctx context.Context, topic string, ch chan []byte, payload []byte) error {
+	for {
+		b.mu.Lock()
+		if _, ok := b.subscribers[topic][ch]; !ok {
+			b.mu.Unlock()
+			return nil
+		}
+		select {
+		case ch <- payload:
+			b.mu.Unlock()
+			return nil
+		default:
+		}
+		b.mu.Unlock()
+
+		// The subscriber is behind, give it a moment without holding the lock
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// Subscribe delivers payloads published to topic until ctx is done
+func (b *MemoryBroker) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+	ch := make(chan []byte, subscriberBuffer)
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = make(map[chan []byte]struct{})
+	}
+	b.subscribers[topic][ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		b.unsubscribe(topic, ch)
+	}()
+	return ch, nil
+}
+
+// unsubscribe removes and closes a subscriber channel
+func (b *MemoryBroker) unsubscribe(topic string, ch chan []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[topic][ch]; ok {
+		delete(b.subscribers[topic], ch)
+		close(ch)
+	}
+}
+
+// Close drops all queues and ends every subscription
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.queues = make(map[string][]Ticket)
+	for topic, subscribers := range b.subscribers {
+		for ch := range subscribers {
+			close(ch)
+		}
+		delete(b.subscribers, topic)
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Timeouts used by RedisBroker
+const (
+	redisDialTimeout = 5 * time.Second
+	redisOpTimeout   = 5 * time.Second
+	// redisLockTTL expires the queue lock of an instance that died holding it
+	redisLockTTL = 5 * time.Second
+	// A queue lock held by another instance is tried again after
+	// redisLockRetryMin, doubling up to redisLockRetryMax
+	redisLockRetryMin = 2 * time.Millisecond
+	redisLockRetryMax = 100 * time.Millisecond
+	// A dropped subscription is renewed after redisRetryMin, doubling up
+	// to redisRetryMax while the server cannot be reached
+	redisRetryMin = 100 * time.Millisecond
+	redisRetryMax = 5 * time.Second
+)
+
+// RedisBroker is a Broker backed by a Redis compatible server. The queue
+// is a list guarded by a short lived lock key, messages use PUBLISH and
+// SUBSCRIBE. Commands share one connection, every subscription gets its own.
+type RedisBroker struct {
+	addr   string
+	mu     sync.Mutex
+	conn   *respConn // nil until first use or after a network error
+	subs   map[net.Conn]struct{}
+	closed bool
+}
+
+// NewRedisBroker connects to the Redis compatible server at addr
+func NewRedisBroker(ctx context.Context, addr string) (*RedisBroker, error) {
+	b := &RedisBroker{addr: addr, subs: make(map[net.Conn]struct{})}
+	if err := b.Ping(ctx); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Ping checks that the server answers, for readiness checks
+func (b *RedisBroker) Ping(ctx context.Context) error {
+	_, err := b.do(ctx, "PING")
+	return err
+}
+
+// dial opens a connection to the server
+func (b *RedisBroker) dial(ctx context.Context) (*respConn, error) {
+	dialer := net.Dialer{Timeout: redisDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", b.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis broker: %w", err)
+	}
+	return newRESPConn(conn), nil
+}
+
+// do runs one command on the shared connection, reconnecting if needed
+func (b *RedisBroker) do(ctx context.Context, args ...string) (any, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+	if b.conn == nil {
+		conn, err := b.dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		b.conn = conn
+	}
+
+	deadline := time.Now().Add(redisOpTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	b.conn.conn.SetDeadline(deadline)
+
+	reply, err := b.conn.do(args...)
+	var replyErr respError
+	if err != nil && !errors.As(err, &replyErr) {
+		// The connection is in an unknown state, start over next time
+		b.conn.conn.Close()
+		b.conn = nil
+		return nil, fmt.Errorf("redis broker: %s: %w", args[0], err)
+	}
+	return reply, err
+}
+
+// unlockScript deletes the lock key only if it still holds the caller's
+// token, so a lock that expired and was taken by someone else survives
+const unlockScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+// queueKey is the list holding the tickets of queue
+func queueKey(queue string) string {
+	return "paper:queue:" + queue
+}
+
+// lock takes the lock guarding queue and returns the function releasing it
+func (b *RedisBroker) lock(ctx context.Context, queue string) (func(), error) {
+	key := queueKey(queue) + ":lock"
+	token := make([]byte, 16)
+	rand.Read(token)
+	value := hex.EncodeToString(token)
+
+	retry := redisLockRetryMin
+	for {
+		reply, err := b.do(ctx, "SET", key, value, "NX", "PX", fmt.Sprint(redisLockTTL.Milliseconds()))
+		if err != nil {
+			return nil, err
+		}
+		if reply != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retry):
+		}
+		retry = min(2*retry, redisLockRetryMax)
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), redisOpTimeout)
+		defer cancel()
+		if _, err := b.do(ctx, "EVAL", unlockScript, "1", key, value); err != nil {
+			log.Printf("Redis broker: releasing the lock of %s failed: %v", queue, err)
+		}
+	}, nil
+}
+
+// Pair takes the oldest waiting ticket or queues t
+func (b *RedisBroker) Pair(ctx context.Context, queue string, t Ticket) (*Ticket, error) {
+	unlock, err := b.lock(ctx, queue)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	for {
+		reply, err := b.do(ctx, "LPOP", queueKey(queue))
+		if err != nil {
+			return nil, err
+		}
+		if reply == nil {
+			break
+		}
+		var opponent Ticket
+		if err := json.Unmarshal(asBytes(reply), &opponent); err != nil {
+			log.Printf("Redis broker: dropping malformed ticket in %s: %v", queue, err)
+			continue
+		}
+		if opponent.PlayerID != t.PlayerID {
+			return &opponent, nil
+		}
+	}
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := b.do(ctx, "RPUSH", queueKey(queue), string(data)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// Remove takes a player's ticket out of queue
+func (b *RedisBroker) Remove(ctx context.Context, queue string, playerID string) error {
+	unlock, err := b.lock(ctx, queue)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	reply, err := b.do(ctx, "LRANGE", queueKey(queue), "0", "-1")
+	if err != nil {
+		return err
+	}
+	items, _ := reply.([]any)
+	for _, item := range items {
+		var t Ticket
+		if json.Unmarshal(asBytes(item), &t) == nil && t.PlayerID == playerID {
+			_, err := b.do(ctx, "LREM", queueKey(queue), "1", string(asBytes(item)))
+			return err
+		}
+	}
+	return nil
+}
+
+// Publish sends payload to the subscribers of topic
+func (b *RedisBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	_, err := b.do(ctx, "PUBLISH", topic, string(payload))
+	return err
+}
+
+// Subscribe opens a dedicated connection that receives topic until ctx is
+// done. If the connection drops it subscribes again with backoff, messages
+// published in between are lost. The channel is closed when ctx is done or
+// the broker is closed.
+func (b *RedisBroker) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	conn, err := b.subscribe(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan []byte, subscriberBuffer)
+	go func() {
+		defer close(ch)
+		for {
+			err := b.receive(ctx, conn, ch)
+			if ctx.Err() != nil || b.isClosed() {
+				return
+			}
+
+			retry := redisRetryMin
+			log.Printf("Redis broker: subscription to %s dropped: %v, subscribing again in %v", topic, err, retry)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(retry):
+				}
+				if conn, err = b.subscribe(ctx, topic); err == nil {
+					break
+				}
+				if errors.Is(err, ErrClosed) {
+					return
+				}
+				retry = min(2*retry, redisRetryMax)
+				log.Printf("Redis broker: subscribing to %s failed: %v, trying again in %v", topic, err, retry)
+			}
+			log.Printf("Redis broker: subscribed to %s again", topic)
+		}
+	}()
+	return ch, nil
+}
+
+// subscribe opens a connection subscribed to topic
+func (b *RedisBroker) subscribe(ctx context.Context, topic string) (*respConn, error) {
+	conn, err := b.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn.conn.SetDeadline(time.Now().Add(redisOpTimeout))
+	if _, err := conn.do("SUBSCRIBE", topic); err != nil {
+		conn.conn.Close()
+		return nil, fmt.Errorf("redis broker: SUBSCRIBE: %w", err)
+	}
+	conn.conn.SetDeadline(time.Time{})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		conn.conn.Close()
+		return nil, ErrClosed
+	}
+	b.subs[conn.conn] = struct{}{}
+	return conn, nil
+}
+
+// receive passes the messages pushed on a subscribed connection to ch
+// until ctx is done or the connection drops, then closes it
+func (b *RedisBroker) receive(ctx context.Context, conn *respConn, ch chan<- []byte) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		conn.conn.Close()
+	}()
+	defer func() {
+		b.mu.Lock()
+		delete(b.subs, conn.conn)
+		b.mu.Unlock()
+	}()
+
+	for {
+		reply, err := conn.readReply()
+		if err != nil {
+			return err
+		}
+		// Pushed messages look like ["message", topic, payload]
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 3 || string(asBytes(parts[0])) != "message" {
+			continue
+		}
+		select {
+		case ch <- asBytes(parts[2]):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// isClosed reports whether Close was called
+func (b *RedisBroker) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// Close closes the command connection and ends every subscription
+func (b *RedisBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	if b.conn != nil {
+		b.conn.conn.Close()
+		b.conn = nil
+	}
+	for conn := range b.subs {
+		conn.Close()
+	}
+	return nil
+}
+
+// asBytes returns the bytes of a bulk or simple string reply
+func asBytes(reply any) []byte {
+	switch v := reply.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	return nil
+}
@@ -0,0 +1,547 @@
+// Package correspondence runs turn-based matches that are played over
+// hours or days. Players are identified by a secret player key the server
+// issues and need not stay connected: every move is saved to a Store, and
+// the player to move has a long deadline before they forfeit.
+package correspondence
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// Match states as sent in match_update
+const (
+	StateWaiting  = "waiting" // for an opponent
+	StateActive   = "active"
+	StateFinished = "finished"
+)
+
+// retention is how long finished matches are kept, so players who were
+// away still see the result
+const retention = 7 * 24 * time.Hour
+
+// storeTimeout bounds every call into the store
+const storeTimeout = 5 * time.Second
+
+var (
+	// ErrNotFound is returned for match IDs the player has no match with
+	ErrNotFound = errors.New("match not found")
+	// ErrUnknownGame is returned when creating a match of a game type that
+	// does not exist
+	ErrUnknownGame = errors.New("unknown game")
+	// ErrNotTurnBased is returned when creating a match of a game of
+	// simultaneous moves
+	ErrNotTurnBased = errors.New("correspondence matches need a turn-based game")
+	// ErrNotYourTurn is returned for moves out of turn, or before the
+	// match has an opponent
+	ErrNotYourTurn = errors.New("not your turn")
+	// ErrFinished is returned for moves in a match that is over
+	ErrFinished = errors.New("match is over")
+	// ErrInvalidKey is returned for player keys the server cannot have
+	// issued
+	ErrInvalidKey = errors.New("invalid player key")
+)
+
+// keySize is the number of random bytes in a player key
+const keySize = 32
+
+// Match is a correspondence match as saved in the Store. The board is not
+// saved, it is rebuilt by replaying the moves.
+type Match struct {
+	ID        string          `json:"id"`
+	Game      string          `json:"game"`
+	Players   [2]string       `json:"players"`            // names in turn order, the second empty while waiting for an opponent
+	PlayerIDs [2]string       `json:"player_ids"`         // hashes of the players' keys, in turn order
+	Moves     []gameroom.Move `json:"moves,omitempty"`    // cells taken, in order
+	Deadline  time.Time       `json:"deadline,omitzero"`  // when the player to move forfeits
+	Finished  bool            `json:"finished,omitempty"` // won, drawn or forfeited
+	Winner    string          `json:"winner,omitempty"`   // player ID, empty for a draw
+	Forfeit   bool            `json:"forfeit,omitempty"`  // the loser ran out of time
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// match is a Match with its rules and deadline timer
+type match struct {
+	Match
+	gameType gameroom.GameType
+	game     gameroom.TurnGame
+	timer    *time.Timer
+}
+
+// state returns the match state as sent to clients
+func (mt *match) state() string {
+	switch {
+	case mt.Finished:
+		return StateFinished
+	case mt.PlayerIDs[1] == "":
+		return StateWaiting
+	}
+	return StateActive
+}
+
+// seat returns the place of a player in the turn order, or -1
+func (mt *match) seat(playerID string) int {
+	if playerID == "" {
+		return -1
+	}
+	return slices.Index(mt.PlayerIDs[:], playerID)
+}
+
+// turn returns the seat of the player to move
+func (mt *match) turn() int {
+	return len(mt.Moves) % 2
+}
+
+// Manager creates correspondence matches, takes their moves and keeps them
+// in its Store. Connections take part as the player whose key they
+// presented to Identify.
+type Manager struct {
+	store    Store
+	deadline time.Duration // per move
+	mu       sync.Mutex
+	matches  []*match                 // oldest first
+	players  map[*types.Client]string // connection -> player ID
+	version  uint64                   // of the matches, counts changes
+	closed   bool
+	saveMu   sync.Mutex
+	saved    uint64 // version in the store
+}
+
+// update is what a change leaves to do once mu is released: the matches
+// to save, if they changed, and the events for connected players
+type update struct {
+	version uint64
+	records []Match
+	events  []delivery
+}
+
+type delivery struct {
+	client *types.Client
+	event  types.BaseGameEvent
+}
+
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithStore keeps the matches in store, so they survive a restart. By
+// default they are only kept in memory.
+func WithStore(store Store) Option {
+	return func(m *Manager) {
+		m.store = store
+	}
+}
+
+// WithMoveDeadline sets how long a player has for each move before they
+// forfeit, 24 hours by default
+func WithMoveDeadline(deadline time.Duration) Option {
+	return func(m *Manager) {
+		m.deadline = deadline
+	}
+}
+
+// NewManager creates a manager and loads the matches saved in its store
+func NewManager(opts ...Option) *Manager {
+	m := &Manager{
+		deadline: 24 * time.Hour,
+		players:  make(map[*types.Client]string),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.store == nil {
+		m.store = NewMemoryStore()
+	}
+	m.load()
+	return m
+}
+
+// load restores the saved matches and restarts their deadlines
+func (m *Manager) load() {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	saved, err := m.store.Load(ctx)
+	if err != nil {
+		log.Printf("Correspondence: cannot load matches: %v", err)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, record := range saved {
+		gameType, ok := gameroom.LookupGameType(record.Game)
+		if !ok || !gameType.TurnBased() {
+			log.Printf("Correspondence: dropping match %s of unknown game %q", record.ID, record.Game)
+			continue
+		}
+		if record.PlayerIDs[0] == "" {
+			log.Printf("Correspondence: dropping match %s saved without player keys", record.ID)
+			continue
+		}
+		mt := &match{Match: record, gameType: gameType, game: gameType.NewTurnGame()}
+		for i, move := range mt.Moves {
+			if _, err := mt.game.Play(i%2, move); err != nil {
+				log.Printf("Correspondence: dropping match %s, move %d: %v", record.ID, i+1, err)
+				mt = nil
+				break
+			}
+		}
+		if mt == nil {
+			continue
+		}
+		if mt.state() == StateActive {
+			m.arm(mt)
+		}
+		m.matches = append(m.matches, mt)
+	}
+	log.Printf("Correspondence: %d matches loaded", len(m.matches))
+}
+
+// Identify lets client take part as the player the key belongs to. With
+// an empty key a client that was identified before stays the same player,
+// otherwise it becomes a new one. It returns the key of a new player, which
+// the client must present again to get back to the matches on a later
+// connection.
+func (m *Manager) Identify(client *types.Client, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key == "" {
+		if _, ok := m.players[client]; ok {
+			return "", nil
+		}
+		b := make([]byte, keySize)
+		rand.Read(b)
+		key = hex.EncodeToString(b)
+	}
+	playerID, err := playerID(key)
+	if err != nil {
+		return "", err
+	}
+	m.players[client] = playerID
+	return key, nil
+}
+
+// Forget stops sending a connection the updates of its player's matches
+func (m *Manager) Forget(client *types.Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.players, client)
+}
+
+// Create finds an opponent for a client's player, the longest waiting
+// player of the same game who moves first, or opens a match that waits for
+// one. A player waits for at most one opponent per game. The client must
+// have been identified.
+func (m *Manager) Create(client *types.Client, game string) error {
+	gameType, ok := gameroom.LookupGameType(game)
+	switch {
+	case !ok:
+		return ErrUnknownGame
+	case !gameType.TurnBased():
+		return ErrNotTurnBased
+	}
+	u, err := m.create(client, gameType)
+	m.apply(u)
+	return err
+}
+
+// create is Create under mu
+func (m *Manager) create(client *types.Client, gameType gameroom.GameType) (update, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var u update
+	id, name := m.players[client], client.GetName()
+	if id == "" {
+		return u, ErrInvalidKey
+	}
+	for _, mt := range m.matches {
+		if mt.state() != StateWaiting || mt.Game != gameType.Name {
+			continue
+		}
+		if mt.PlayerIDs[0] == id {
+			// Already waiting
+			queue(m, &u, id, types.MatchUpdateMessage{Match: info(mt, id)})
+			return u, nil
+		}
+		mt.Players[1], mt.PlayerIDs[1] = name, id
+		mt.UpdatedAt = time.Now()
+		mt.Deadline = mt.UpdatedAt.Add(m.deadline)
+		m.arm(mt)
+		m.save(&u)
+		log.Printf("Correspondence match %s started between %s and %s", mt.ID, mt.Players[0], name)
+		m.notify(&u, mt)
+		return u, nil
+	}
+
+	mt := &match{
+		Match: Match{
+			ID:        newMatchID(),
+			Game:      gameType.Name,
+			Players:   [2]string{name},
+			PlayerIDs: [2]string{id},
+			UpdatedAt: time.Now(),
+		},
+		gameType: gameType,
+		game:     gameType.NewTurnGame(),
+	}
+	m.matches = append(m.matches, mt)
+	m.save(&u)
+	log.Printf("Correspondence match %s (%s) waits for an opponent of %s", mt.ID, mt.Game, name)
+	queue(m, &u, id, types.MatchUpdateMessage{Match: info(mt, id)})
+	return u, nil
+}
+
+// List returns the matches of a client's player, oldest first
+func (m *Manager) List(client *types.Client) []types.MatchInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.players[client]
+	matches := []types.MatchInfo{}
+	for _, mt := range m.matches {
+		if mt.seat(id) >= 0 {
+			matches = append(matches, info(mt, id))
+		}
+	}
+	return matches
+}
+
+// Move makes a move for a client's player in one of their matches. Moves
+// the game does not allow return its error, e.g. gameroom.ErrCellTaken.
+func (m *Manager) Move(client *types.Client, matchID string, move gameroom.Move) error {
+	u, err := m.move(client, matchID, move)
+	m.apply(u)
+	return err
+}
+
+// move is Move under mu
+func (m *Manager) move(client *types.Client, matchID string, move gameroom.Move) (update, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var u update
+	i := slices.IndexFunc(m.matches, func(mt *match) bool { return mt.ID == matchID })
+	if i < 0 || m.matches[i].seat(m.players[client]) < 0 {
+		return u, ErrNotFound
+	}
+	mt := m.matches[i]
+	seat := mt.seat(m.players[client])
+	switch {
+	case mt.Finished:
+		return u, ErrFinished
+	case mt.state() == StateWaiting || seat != mt.turn():
+		return u, ErrNotYourTurn
+	}
+	placed, err := mt.game.Play(seat, move)
+	if err != nil {
+		return u, err
+	}
+
+	mt.Moves = append(mt.Moves, placed)
+	mt.UpdatedAt = time.Now()
+	if mt.game.Over() {
+		m.finish(mt, mt.game.Winner(), false)
+	} else {
+		mt.Deadline = mt.UpdatedAt.Add(m.deadline)
+		m.arm(mt)
+	}
+	m.save(&u)
+	log.Printf("Correspondence match %s move %d: %s at %d,%d", mt.ID, len(mt.Moves), mt.Players[seat], placed.Row, placed.Column)
+	m.notify(&u, mt)
+	return u, nil
+}
+
+// arm restarts the deadline of the player to move, the caller must hold mu
+func (m *Manager) arm(mt *match) {
+	if mt.timer != nil {
+		mt.timer.Stop()
+	}
+	id := mt.ID
+	mt.timer = time.AfterFunc(time.Until(mt.Deadline), func() { m.expire(id) })
+}
+
+// expire ends a match whose player to move ran out of time
+func (m *Manager) expire(matchID string) {
+	m.apply(m.forfeit(matchID))
+}
+
+// forfeit is expire under mu
+func (m *Manager) forfeit(matchID string) update {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var u update
+	i := slices.IndexFunc(m.matches, func(mt *match) bool { return mt.ID == matchID })
+	if m.closed || i < 0 {
+		return u
+	}
+	mt := m.matches[i]
+	if mt.state() != StateActive || time.Now().Before(mt.Deadline) {
+		// The player moved while the timer fired
+		return u
+	}
+	log.Printf("Correspondence match %s: %s ran out of time", mt.ID, mt.Players[mt.turn()])
+	mt.UpdatedAt = time.Now()
+	m.finish(mt, 1-mt.turn(), true)
+	m.save(&u)
+	m.notify(&u, mt)
+	return u
+}
+
+// finish ends a match, won by seat 0 or 1 or drawn if winner is -1. The
+// caller must hold mu.
+func (m *Manager) finish(mt *match, winner int, forfeit bool) {
+	mt.Finished = true
+	mt.Winner = ""
+	name := ""
+	if winner >= 0 {
+		mt.Winner, name = mt.PlayerIDs[winner], mt.Players[winner]
+	}
+	mt.Forfeit = forfeit
+	mt.Deadline = time.Time{}
+	if mt.timer != nil {
+		mt.timer.Stop()
+		mt.timer = nil
+	}
+	log.Printf("Correspondence match %s ended after %d moves, winner: %q", mt.ID, len(mt.Moves), name)
+}
+
+// save drops matches that finished long ago and adds the others to u, to
+// be written to the store once mu is released. The caller must hold mu.
+func (m *Manager) save(u *update) {
+	m.matches = slices.DeleteFunc(m.matches, func(mt *match) bool {
+		return mt.Finished && time.Since(mt.UpdatedAt) > retention
+	})
+	u.records = make([]Match, len(m.matches))
+	for i, mt := range m.matches {
+		u.records[i] = mt.Match
+		u.records[i].Moves = slices.Clone(mt.Moves)
+	}
+	m.version++
+	u.version = m.version
+}
+
+// notify adds the match for both players to u, and your_turn for the
+// player to move. The caller must hold mu.
+func (m *Manager) notify(u *update, mt *match) {
+	for _, id := range mt.PlayerIDs {
+		queue(m, u, id, types.MatchUpdateMessage{Match: info(mt, id)})
+	}
+	if mt.state() == StateActive {
+		turn := mt.turn()
+		queue(m, u, mt.PlayerIDs[turn], types.YourTurnMessage{
+			MatchID:  mt.ID,
+			Opponent: mt.Players[1-turn],
+			Deadline: mt.Deadline.Unix(),
+		})
+	}
+}
+
+// queue adds msg for the connected clients of a player to u, the caller
+// must hold mu
+func queue[T any](m *Manager, u *update, playerID string, msg T) {
+	event, err := protocol.Encode(msg)
+	if err != nil {
+		log.Printf("Correspondence: %v", err)
+		return
+	}
+	for client, id := range m.players {
+		if id == playerID {
+			u.events = append(u.events, delivery{client: client, event: event})
+		}
+	}
+}
+
+// apply writes the matches in u to the store and sends its events. It runs
+// without mu, a save that was overtaken by a newer one is skipped.
+func (m *Manager) apply(u update) {
+	if u.records != nil {
+		m.saveMu.Lock()
+		if u.version > m.saved {
+			ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+			if err := m.store.Save(ctx, u.records); err != nil {
+				log.Printf("Correspondence: saving matches failed: %v", err)
+			}
+			cancel()
+			m.saved = u.version
+		}
+		m.saveMu.Unlock()
+	}
+	for _, d := range u.events {
+		if err := d.client.Deliver(d.event); err != nil {
+			log.Printf("Correspondence: sending %s to %s failed: %v", d.event.Type, d.client.ID, err)
+		}
+	}
+}
+
+// info returns a match as seen by one of its players
+func info(mt *match, playerID string) types.MatchInfo {
+	seat := mt.seat(playerID)
+	match := types.MatchInfo{
+		MatchID:    mt.ID,
+		Game:       mt.Game,
+		State:      mt.state(),
+		Opponent:   mt.Players[1-seat],
+		Role:       mt.gameType.Roles[seat],
+		Board:      mt.game.Board(),
+		MoveNumber: len(mt.Moves),
+	}
+	if n := len(mt.Moves); n > 0 {
+		last := mt.Moves[n-1]
+		match.LastMove = &types.BoardMove{Player: mt.Players[(n-1)%2], Row: last.Row, Column: last.Column}
+	}
+	switch match.State {
+	case StateActive:
+		match.YourTurn = seat == mt.turn()
+		match.Deadline = mt.Deadline.Unix()
+	case StateFinished:
+		match.Result = "draw"
+		if mt.Winner == playerID {
+			match.Result = "win"
+		} else if mt.Winner != "" {
+			match.Result = "lose"
+		}
+	}
+	return match
+}
+
+// playerID returns the ID a player key stands for, its hash, so the store
+// never holds the keys themselves
+func playerID(key string) (string, error) {
+	if b, err := hex.DecodeString(key); err != nil || len(b) != keySize {
+		return "", ErrInvalidKey
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// newMatchID returns a random match ID, unique across restarts
+func newMatchID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "match-" + hex.EncodeToString(b)
+}
+
+// Close stops all deadlines, matches are kept in the store
+func (m *Manager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for _, mt := range m.matches {
+		if mt.timer != nil {
+			mt.timer.Stop()
+		}
+	}
+}
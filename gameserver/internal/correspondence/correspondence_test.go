@@ -0,0 +1,265 @@
+package correspondence
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// newPlayer connects a client named name as a new player, and returns it
+// with the player's key
+func newPlayer(t *testing.T, manager *Manager, name string) (*types.Client, string) {
+	t.Helper()
+	client := types.NewClient(name+"-conn", nil)
+	client.SetName(name)
+	key, err := manager.Identify(client, "")
+	if err != nil {
+		t.Fatal("Identify failed:", err)
+	}
+	return client, key
+}
+
+// reconnect connects a new client for the player with the given key
+func reconnect(t *testing.T, manager *Manager, name, key string) *types.Client {
+	t.Helper()
+	client := types.NewClient(name+"-conn", nil)
+	client.SetName(name)
+	if _, err := manager.Identify(client, key); err != nil {
+		t.Fatal("Identify failed:", err)
+	}
+	return client
+}
+
+// take returns the events queued for a client
+func take(client *types.Client) []types.BaseGameEvent {
+	var events []types.BaseGameEvent
+	for {
+		select {
+		case event := <-client.Send:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// lastUpdate returns the last match_update among a player's events
+func lastUpdate(t *testing.T, events []types.BaseGameEvent) types.MatchInfo {
+	t.Helper()
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == protocol.TypeMatchUpdate {
+			update, _ := protocol.Decode[types.MatchUpdateMessage](events[i])
+			return update.Match
+		}
+	}
+	t.Fatal("Expected a match_update event")
+	return types.MatchInfo{}
+}
+
+func TestManager_MatchSurvivesRestart(t *testing.T) {
+	store := NewMemoryStore()
+	manager := NewManager(WithStore(store))
+	alice, aliceKey := newPlayer(t, manager, "Alice")
+	bob, bobKey := newPlayer(t, manager, "Bob")
+
+	if err := manager.Create(alice, "tic_tac_toe"); err != nil {
+		t.Fatal("Create failed:", err)
+	}
+	waiting := lastUpdate(t, take(alice))
+	if waiting.State != StateWaiting {
+		t.Errorf("Expected Alice to wait for an opponent, got %+v", waiting)
+	}
+	if err := manager.Create(bob, "tic_tac_toe"); err != nil {
+		t.Fatal("Create failed:", err)
+	}
+
+	// Alice waited longer and moves first
+	events := take(alice)
+	if len(events) != 2 || events[1].Type != protocol.TypeYourTurn {
+		t.Fatalf("Expected the match and your_turn for Alice, got %v", events)
+	}
+	if started := lastUpdate(t, take(bob)); started.State != StateActive || started.YourTurn || started.Opponent != "Alice" {
+		t.Errorf("Expected Bob to wait for Alice's move, got %+v", started)
+	}
+	if err := manager.Move(bob, waiting.MatchID, gameroom.Move{Row: 0, Column: 0}); !errors.Is(err, ErrNotYourTurn) {
+		t.Errorf("Expected ErrNotYourTurn, got %v", err)
+	}
+	manager.Move(alice, waiting.MatchID, gameroom.Move{Row: 1, Column: 1})
+	if events := take(bob); len(events) != 2 || events[1].Type != protocol.TypeYourTurn {
+		t.Errorf("Expected Bob to be told it is their turn, got %v", events)
+	}
+	manager.Close()
+
+	// The board is rebuilt from the saved moves, the players come back
+	// with their keys
+	manager = NewManager(WithStore(store))
+	defer manager.Close()
+	alice = reconnect(t, manager, "Alice", aliceKey)
+	bob = reconnect(t, manager, "Bob", bobKey)
+	matches := manager.List(bob)
+	if len(matches) != 1 || !matches[0].YourTurn || matches[0].Board[1] != ".X." || matches[0].Role != "O" {
+		t.Fatalf("Expected Bob's turn after a restart, got %+v", matches)
+	}
+	if err := manager.Move(bob, waiting.MatchID, gameroom.Move{Row: 1, Column: 1}); !errors.Is(err, gameroom.ErrCellTaken) {
+		t.Errorf("Expected ErrCellTaken, got %v", err)
+	}
+	carol, _ := newPlayer(t, manager, "Carol")
+	if err := manager.Move(carol, waiting.MatchID, gameroom.Move{Row: 0, Column: 0}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a stranger, got %v", err)
+	}
+
+	// Alice completes the middle row
+	moves := []struct {
+		player      *types.Client
+		row, column int
+	}{
+		{bob, 0, 0}, {alice, 1, 0}, {bob, 0, 1}, {alice, 1, 2},
+	}
+	for _, move := range moves {
+		if err := manager.Move(move.player, waiting.MatchID, gameroom.Move{Row: move.row, Column: move.column}); err != nil {
+			t.Fatalf("%s's move failed: %v", move.player.GetName(), err)
+		}
+	}
+	if ended := lastUpdate(t, take(bob)); ended.State != StateFinished || ended.Result != "lose" {
+		t.Errorf("Expected Bob to lose, got %+v", ended)
+	}
+	if matches := manager.List(alice); len(matches) != 1 || matches[0].Result != "win" {
+		t.Errorf("Expected the finished match in Alice's list, got %+v", matches)
+	}
+}
+
+func TestManager_NameDoesNotIdentifyPlayer(t *testing.T) {
+	manager := NewManager()
+	defer manager.Close()
+	alice, _ := newPlayer(t, manager, "Alice")
+	bob, _ := newPlayer(t, manager, "Bob")
+	manager.Create(alice, "tic_tac_toe")
+	manager.Create(bob, "tic_tac_toe")
+	matchID := lastUpdate(t, take(alice)).MatchID
+
+	// Someone else calling themselves Alice gets neither Alice's matches
+	// nor their notifications
+	impostor, _ := newPlayer(t, manager, "Alice")
+	if matches := manager.List(impostor); len(matches) != 0 {
+		t.Errorf("Expected no matches for another player named Alice, got %+v", matches)
+	}
+	if err := manager.Move(impostor, matchID, gameroom.Move{Row: 0, Column: 0}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for another player named Alice, got %v", err)
+	}
+	if err := manager.Move(alice, matchID, gameroom.Move{Row: 0, Column: 0}); err != nil {
+		t.Fatal("Alice's move failed:", err)
+	}
+	if events := take(impostor); len(events) != 0 {
+		t.Errorf("Expected no events for another player named Alice, got %v", events)
+	}
+
+	// Keys the server cannot have issued are rejected
+	if _, err := manager.Identify(impostor, "Alice"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected ErrInvalidKey, got %v", err)
+	}
+}
+
+func TestManager_SavesWithoutTheLock(t *testing.T) {
+	store := &blockingStore{MemoryStore: NewMemoryStore(), saving: make(chan struct{}), release: make(chan struct{})}
+	manager := NewManager(WithStore(store))
+	defer manager.Close()
+	alice, _ := newPlayer(t, manager, "Alice")
+
+	created := make(chan error, 1)
+	go func() { created <- manager.Create(alice, "tic_tac_toe") }()
+	<-store.saving
+
+	// Other players are served while the store is slow
+	bob, _ := newPlayer(t, manager, "Bob")
+	if matches := manager.List(bob); len(matches) != 0 {
+		t.Errorf("Expected no matches for Bob, got %+v", matches)
+	}
+	close(store.release)
+	if err := <-created; err != nil {
+		t.Fatal("Create failed:", err)
+	}
+}
+
+// blockingStore signals a save and holds it until released
+type blockingStore struct {
+	*MemoryStore
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) Save(ctx context.Context, matches []Match) error {
+	select {
+	case s.saving <- struct{}{}:
+	default:
+	}
+	<-s.release
+	return s.MemoryStore.Save(ctx, matches)
+}
+
+func TestManager_DeadlineForfeits(t *testing.T) {
+	manager := NewManager(WithMoveDeadline(20 * time.Millisecond))
+	defer manager.Close()
+	alice, _ := newPlayer(t, manager, "Alice")
+	bob, _ := newPlayer(t, manager, "Bob")
+
+	manager.Create(alice, "connect_four")
+	manager.Create(bob, "connect_four")
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if matches := manager.List(bob); matches[0].State == StateFinished {
+			if matches[0].Result != "win" {
+				t.Errorf("Expected Bob to win when Alice runs out of time, got %+v", matches[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the match to end")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestManager_RejectsGames(t *testing.T) {
+	manager := NewManager()
+	defer manager.Close()
+	alice, _ := newPlayer(t, manager, "Alice")
+
+	if err := manager.Create(alice, "chess"); !errors.Is(err, ErrUnknownGame) {
+		t.Errorf("Expected ErrUnknownGame, got %v", err)
+	}
+	if err := manager.Create(alice, "rps"); !errors.Is(err, ErrNotTurnBased) {
+		t.Errorf("Expected ErrNotTurnBased, got %v", err)
+	}
+}
+
+func TestFileStore_SaveLoad(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "matches.json"))
+	ctx := context.Background()
+
+	if matches, err := store.Load(ctx); err != nil || len(matches) != 0 {
+		t.Fatalf("Expected no matches before the first save, got %v, %v", matches, err)
+	}
+	saved := []Match{{
+		ID:        "match-1",
+		Game:      "connect_four",
+		Players:   [2]string{"Alice", "Bob"},
+		PlayerIDs: [2]string{"alice-id", "bob-id"},
+		Moves:     []gameroom.Move{{Row: 5, Column: 3}},
+		Deadline:  time.Now().Add(time.Hour).Truncate(time.Second),
+		UpdatedAt: time.Now().Truncate(time.Second),
+	}}
+	if err := store.Save(ctx, saved); err != nil {
+		t.Fatal("Save failed:", err)
+	}
+	loaded, err := store.Load(ctx)
+	if err != nil || len(loaded) != 1 || loaded[0].Moves[0] != saved[0].Moves[0] || !loaded[0].Deadline.Equal(saved[0].Deadline) {
+		t.Errorf("Expected %+v, got %+v, %v", saved, loaded, err)
+	}
+}
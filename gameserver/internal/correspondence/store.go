@@ -0,0 +1,133 @@
+package correspondence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Store keeps the correspondence matches. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Save replaces the stored matches
+	Save(ctx context.Context, matches []Match) error
+	// Load returns the stored matches, none if nothing was saved yet
+	Load(ctx context.Context) ([]Match, error)
+}
+
+// MemoryStore keeps the matches in memory, they survive disconnects but
+// not a restart
+type MemoryStore struct {
+	mu      sync.Mutex
+	matches []Match
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Save replaces the stored matches
+func (s *MemoryStore) Save(ctx context.Context, matches []Match) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.matches = copyMatches(matches)
+	return nil
+}
+
+// Load returns a copy of the stored matches
+func (s *MemoryStore) Load(ctx context.Context) ([]Match, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyMatches(s.matches), nil
+}
+
+// copyMatches copies matches and their moves
+func copyMatches(matches []Match) []Match {
+	copied := slices.Clone(matches)
+	for i := range copied {
+		copied[i].Moves = slices.Clone(copied[i].Moves)
+	}
+	return copied
+}
+
+// FileStore keeps the matches as a JSON file. Saves write a temporary file
+// and rename it, so a crash never leaves a half written file.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStore stores matches at path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Save writes the matches to the file
+func (s *FileStore) Save(ctx context.Context, matches []Match) error {
+	data, err := json.MarshalIndent(matches, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("correspondence: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("correspondence: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("correspondence: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("correspondence: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("correspondence: %w", err)
+	}
+	return nil
+}
+
+// Load reads the file, a missing file means no matches
+func (s *FileStore) Load(ctx context.Context) ([]Match, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("correspondence: %w", err)
+	}
+	var matches []Match
+	if err := json.Unmarshal(data, &matches); err != nil {
+		return nil, fmt.Errorf("correspondence: %s: %w", s.path, err)
+	}
+	return matches, nil
+}
+
+// Ping checks that the directory of the file exists, for readiness checks
+func (s *FileStore) Ping(ctx context.Context) error {
+	info, err := os.Stat(filepath.Dir(s.path))
+	if err != nil {
+		return fmt.Errorf("correspondence: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("correspondence: %s is not a directory", filepath.Dir(s.path))
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package gameroom
+
+import (
+	"errors"
+	"strings"
+)
+
+// Move is a move of a turn-based game: the cell a piece goes to. Cells
+// count from 0, row 0 is the top row.
+type Move struct {
+	Row    int `json:"row"`
+	Column int `json:"column"`
+}
+
+// TurnGame is the rules of a turn-based game for two players, run by a
+// TurnRoom. Players move in turn, player 0 first. Like Game it belongs to
+// one room and is only used from the room's goroutine.
+type TurnGame interface {
+	// Play makes a move of player 0 or 1 and returns the cell the piece
+	// went to, or an error if the move is not allowed
+	Play(player int, move Move) (Move, error)
+	// Board returns the rows top first, a character per cell
+	Board() []string
+	// Winner returns the player who won, or -1 while nobody has
+	Winner() int
+	// Over reports whether the game is won or the board is full
+	Over() bool
+}
+
+// Errors for moves a game does not allow
+var (
+	ErrOffBoard   = errors.New("cell is not on the board")
+	ErrCellTaken  = errors.New("cell is taken")
+	ErrColumnFull = errors.New("column is full")
+)
+
+// emptyCell marks a free cell in Board
+const emptyCell = '.'
+
+// grid is a board on which a player wins with a line of pieces in a row,
+// column or diagonal
+type grid struct {
+	cells  [][]byte
+	marks  [2]byte // the pieces of player 0 and 1
+	line   int     // pieces in a row that win
+	moves  int
+	winner int
+}
+
+// newGrid returns an empty board of rows by columns
+func newGrid(rows, columns, line int, marks [2]byte) *grid {
+	g := &grid{marks: marks, line: line, winner: -1}
+	for range rows {
+		g.cells = append(g.cells, []byte(strings.Repeat(string(emptyCell), columns)))
+	}
+	return g
+}
+
+// inside reports whether a cell is on the board
+func (g *grid) inside(row, column int) bool {
+	return row >= 0 && row < len(g.cells) && column >= 0 && column < len(g.cells[0])
+}
+
+// place puts a piece of player on a free cell and checks whether it
+// completes a line
+func (g *grid) place(player, row, column int) {
+	g.cells[row][column] = g.marks[player]
+	g.moves++
+
+	for _, dir := range [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}} {
+		count := 1
+		for _, sign := range []int{1, -1} {
+			r, c := row+sign*dir[0], column+sign*dir[1]
+			for g.inside(r, c) && g.cells[r][c] == g.marks[player] {
+				count++
+				r, c = r+sign*dir[0], c+sign*dir[1]
+			}
+		}
+		if count >= g.line {
+			g.winner = player
+		}
+	}
+}
+
+func (g *grid) Board() []string {
+	board := make([]string, len(g.cells))
+	for i, row := range g.cells {
+		board[i] = string(row)
+	}
+	return board
+}
+
+func (g *grid) Winner() int {
+	return g.winner
+}
+
+func (g *grid) Over() bool {
+	return g.winner >= 0 || g.moves == len(g.cells)*len(g.cells[0])
+}
+
+// ticTacToe is played on 3 by 3 cells, three in a row win
+type ticTacToe struct {
+	*grid
+}
+
+func (t ticTacToe) Play(player int, move Move) (Move, error) {
+	switch {
+	case !t.inside(move.Row, move.Column):
+		return move, ErrOffBoard
+	case t.cells[move.Row][move.Column] != emptyCell:
+		return move, ErrCellTaken
+	}
+	t.place(player, move.Row, move.Column)
+	return move, nil
+}
+
+// connectFour is played on 6 rows of 7 columns. Pieces are dropped into a
+// column and land on the lowest free cell, four in a row win.
+type connectFour struct {
+	*grid
+}
+
+func (c connectFour) Play(player int, move Move) (Move, error) {
+	if !c.inside(0, move.Column) {
+		return move, ErrOffBoard
+	}
+	for row := len(c.cells) - 1; row >= 0; row-- {
+		if c.cells[row][move.Column] == emptyCell {
+			c.place(player, row, move.Column)
+			return Move{Row: row, Column: move.Column}, nil
+		}
+	}
+	return move, ErrColumnFull
+}
+
+func init() {
+	RegisterGameType(GameType{Name: "tic_tac_toe", Roles: [2]string{"X", "O"}, BestOf: 1, NewTurnGame: func() TurnGame {
+		return ticTacToe{newGrid(3, 3, 3, [2]byte{'X', 'O'})}
+	}})
+	RegisterGameType(GameType{Name: "connect_four", Roles: [2]string{"red", "yellow"}, BestOf: 1, NewTurnGame: func() TurnGame {
+		return connectFour{newGrid(6, 7, 4, [2]byte{'R', 'Y'})}
+	}})
+}
@@ -0,0 +1,398 @@
+package gameroom
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// Resolution decides the rounds of a free-for-all
+type Resolution string
+
+const (
+	// ResolveDominant lets a move that beats every other move played win
+	// the round: with exactly two different moves, everyone who played the
+	// stronger one wins and scores a point. A single move, or all three, is
+	// a draw.
+	ResolveDominant Resolution = "dominant"
+	// ResolvePoints scores every player a point per opponent their move
+	// beats. Players who beat more opponents than beat them win the round.
+	ResolvePoints Resolution = "points"
+)
+
+// Rules configure a free-for-all game
+type Rules struct {
+	Resolution  Resolution // ResolveDominant unless set
+	Elimination bool       // players who lose a round are out, the last one standing wins
+	Rounds      int        // rounds played, at most in elimination mode. Zero for 5, 10 with elimination.
+}
+
+// withDefaults fills in the rules left empty
+func (r Rules) withDefaults() Rules {
+	if r.Resolution == "" {
+		r.Resolution = ResolveDominant
+	}
+	if r.Rounds == 0 {
+		r.Rounds = 5
+		if r.Elimination {
+			r.Rounds = 10
+		}
+	}
+	return r
+}
+
+// resolve returns each player's result of a round and the points they
+// score, by client ID. An empty choice means the player ran out of time
+// and loses, unless nobody chose.
+func (r Rules) resolve(choices map[string]Choice) (map[string]string, map[string]int) {
+	results := make(map[string]string, len(choices))
+	points := make(map[string]int, len(choices))
+
+	moves := make(map[Choice]bool)
+	for _, choice := range choices {
+		if choice != "" {
+			moves[choice] = true
+		}
+	}
+	for id, choice := range choices {
+		switch {
+		case len(moves) == 0:
+			results[id] = "draw"
+		case choice == "":
+			results[id] = "lose"
+		}
+	}
+	if len(moves) == 0 {
+		return results, points
+	}
+
+	switch r.Resolution {
+	case ResolvePoints:
+		for id, choice := range choices {
+			if choice == "" {
+				continue
+			}
+			beaten, beatenBy := 0, 0
+			for other, otherChoice := range choices {
+				switch {
+				case other == id:
+				case otherChoice == "" || beats(choice, otherChoice):
+					beaten++
+				case beats(otherChoice, choice):
+					beatenBy++
+				}
+			}
+			points[id] = beaten
+			switch {
+			case beaten > beatenBy:
+				results[id] = "win"
+			case beaten < beatenBy:
+				results[id] = "lose"
+			default:
+				results[id] = "draw"
+			}
+		}
+	default:
+		var dominant Choice
+		if len(moves) == 2 {
+			for move := range moves {
+				for other := range moves {
+					if beats(move, other) {
+						dominant = move
+					}
+				}
+			}
+		}
+		for id, choice := range choices {
+			switch {
+			case choice == "":
+			case dominant == "":
+				results[id] = "draw"
+			case choice == dominant:
+				results[id] = "win"
+				points[id] = 1
+			default:
+				results[id] = "lose"
+			}
+		}
+	}
+	return results, points
+}
+
+// FreeForAll manages a Rock Paper Scissors game between three or more
+// players. Like GameRoom, all game state is owned by a single goroutine
+// working through the room's inbox.
+type FreeForAll struct {
+	ID           string
+	Players      []*types.Client // in seat order, including players who are out
+	CurrentRound int
+	GameEnded    bool
+	rules        Rules
+	points       map[string]int    // client ID -> points
+	choices      map[string]Choice // client ID -> choice in the current round
+	out          map[string]bool   // client ID -> eliminated or left
+	runner
+}
+
+// NewFreeForAll creates a room for players playing by rules and starts
+// its goroutine. Of the options only the round timeout applies.
+func NewFreeForAll(id string, players []*types.Client, rules Rules, onGameEnd func(string), opts ...Option) *FreeForAll {
+	room := &FreeForAll{
+		ID:           id,
+		Players:      players,
+		CurrentRound: 1,
+		rules:        rules.withDefaults(),
+		points:       make(map[string]int),
+		choices:      make(map[string]Choice),
+		out:          make(map[string]bool),
+		runner:       newRunner(newSettings(opts).roundTimeout),
+	}
+
+	var names []string
+	for _, player := range players {
+		if err := player.EnterGame(id); err != nil {
+			log.Printf("FreeForAll %s: %v", id, err)
+		}
+		names = append(names, player.GetName())
+	}
+
+	room.start(id, room, onGameEnd)
+
+	log.Printf("FreeForAll %s created for %s (%s, elimination: %v)", id, strings.Join(names, ", "), room.rules.Resolution, room.rules.Elimination)
+	return room
+}
+
+// handle applies one command to the room state
+func (ffa *FreeForAll) handle(cmd any) error {
+	switch cmd := cmd.(type) {
+	case startCmd:
+		ffa.startRound()
+	case choiceCmd:
+		ffa.makeChoice(cmd.clientID, cmd.choice)
+	case leaveCmd:
+		ffa.leave(cmd.clientID)
+	}
+	return nil
+}
+
+// timeoutRound ends a round whose deadline passed, players who have not
+// chosen lose it
+func (ffa *FreeForAll) timeoutRound() {
+	log.Printf("FreeForAll %s: Round %d timed out", ffa.ID, ffa.CurrentRound)
+	ffa.processRound()
+}
+
+// ended reports whether the game is over
+func (ffa *FreeForAll) ended() bool {
+	return ffa.GameEnded
+}
+
+// StartFirstRound begins the first round of the game
+func (ffa *FreeForAll) StartFirstRound() {
+	ffa.do(startCmd{})
+}
+
+// MakeChoice processes a player's choice. Choices for a room that already
+// finished, or from players who are out, are ignored.
+func (ffa *FreeForAll) MakeChoice(clientID string, choice Choice) error {
+	if err := ffa.do(choiceCmd{clientID: clientID, choice: choice}); err != nil && !errors.Is(err, ErrRoomClosed) {
+		return err
+	}
+	return nil
+}
+
+// Leave removes a disconnected player, who is out of the game. The last
+// player left wins by forfeit.
+func (ffa *FreeForAll) Leave(clientID string) {
+	ffa.do(leaveCmd{clientID: clientID})
+}
+
+// active returns the players still in the game
+func (ffa *FreeForAll) active() []*types.Client {
+	var active []*types.Client
+	for _, player := range ffa.Players {
+		if !ffa.out[player.ID] {
+			active = append(active, player)
+		}
+	}
+	return active
+}
+
+// player returns the player with the given ID if they are still in the game
+func (ffa *FreeForAll) player(clientID string) *types.Client {
+	for _, player := range ffa.active() {
+		if player.ID == clientID {
+			return player
+		}
+	}
+	return nil
+}
+
+// makeChoice records a choice and plays the round once everybody chose
+func (ffa *FreeForAll) makeChoice(clientID string, choice Choice) {
+	if ffa.GameEnded {
+		return
+	}
+	player := ffa.player(clientID)
+	if player == nil {
+		return
+	}
+	if choice != Rock && choice != Paper && choice != Scissors {
+		protocol.Send(player, types.ErrorMessage{
+			Code:    protocol.ErrorCodeInvalidChoice,
+			Message: "Invalid choice. Use rock, paper, or scissors",
+			Ref:     protocol.TypeMakeChoice,
+			Details: []types.ErrorDetail{{Key: "choice", Value: string(choice)}},
+		})
+		return
+	}
+
+	ffa.choices[clientID] = choice
+	log.Printf("FreeForAll %s: %s chose %s", ffa.ID, player.GetName(), choice)
+	if len(ffa.choices) == len(ffa.active()) {
+		ffa.processRound()
+	}
+}
+
+// processRound resolves the round, knocks out its losers in elimination
+// mode and starts the next round or ends the game
+func (ffa *FreeForAll) processRound() {
+	ffa.stopRoundTimer()
+
+	active := ffa.active()
+	choices := make(map[string]Choice, len(active))
+	for _, player := range active {
+		choices[player.ID] = ffa.choices[player.ID]
+	}
+	results, points := ffa.rules.resolve(choices)
+	var eliminated []*types.Client
+	for _, player := range active {
+		ffa.points[player.ID] += points[player.ID]
+		if ffa.rules.Elimination && results[player.ID] == "lose" {
+			ffa.out[player.ID] = true
+			eliminated = append(eliminated, player)
+		}
+	}
+
+	summary := ffa.summary(choices, results)
+	log.Printf("FreeForAll %s Round %d: %+v", ffa.ID, ffa.CurrentRound, summary)
+	for _, player := range active {
+		protocol.Send(player, types.RoundResultMessage{
+			Result:     results[player.ID],
+			YourChoice: string(choices[player.ID]),
+			Players:    summary,
+		})
+	}
+	ffa.choices = make(map[string]Choice)
+
+	// Knocked out players are done, the others play on
+	for _, player := range eliminated {
+		log.Printf("FreeForAll %s: %s is eliminated", ffa.ID, player.GetName())
+		ffa.release(player, "lose")
+	}
+
+	if ffa.CurrentRound >= ffa.rules.Rounds || (ffa.rules.Elimination && len(ffa.active()) <= 1) {
+		ffa.endGame()
+		return
+	}
+	ffa.CurrentRound++
+	ffa.startRound()
+}
+
+// summary lists every player's round and points, the choices and results
+// of players who are still in are given by client ID
+func (ffa *FreeForAll) summary(choices map[string]Choice, results map[string]string) []types.PlayerResult {
+	summary := make([]types.PlayerResult, 0, len(ffa.Players))
+	for _, player := range ffa.Players {
+		summary = append(summary, types.PlayerResult{
+			Name:       player.GetName(),
+			Choice:     string(choices[player.ID]),
+			Result:     results[player.ID],
+			Points:     ffa.points[player.ID],
+			Eliminated: ffa.out[player.ID],
+		})
+	}
+	return summary
+}
+
+// startRound begins a new round for the players still in
+func (ffa *FreeForAll) startRound() {
+	if ffa.GameEnded {
+		return
+	}
+
+	log.Printf("FreeForAll %s: Starting round %d", ffa.ID, ffa.CurrentRound)
+	for _, player := range ffa.active() {
+		protocol.Send(player, types.RoundStartMessage{RoundNumber: ffa.CurrentRound})
+	}
+
+	ffa.startRoundTimer()
+}
+
+// leave takes a disconnected player out of the game
+func (ffa *FreeForAll) leave(clientID string) {
+	player := ffa.player(clientID)
+	if player == nil || ffa.GameEnded {
+		return
+	}
+	log.Printf("FreeForAll %s: %s left", ffa.ID, player.GetName())
+	ffa.out[clientID] = true
+	delete(ffa.choices, clientID)
+
+	switch active := ffa.active(); {
+	case len(active) <= 1:
+		ffa.endGame()
+	case len(ffa.choices) == len(active):
+		// Everyone else already chose
+		ffa.processRound()
+	}
+}
+
+// endGame finishes the game. The last player standing wins, otherwise
+// those with the most points do, sharing a draw if there is more than one.
+func (ffa *FreeForAll) endGame() {
+	ffa.GameEnded = true
+	ffa.stopRoundTimer()
+
+	active := ffa.active()
+	best := 0
+	for _, player := range active {
+		best = max(best, ffa.points[player.ID])
+	}
+	var leaders []*types.Client
+	for _, player := range active {
+		// Survivors of an elimination game all share the lead
+		if ffa.rules.Elimination || ffa.points[player.ID] == best {
+			leaders = append(leaders, player)
+		}
+	}
+
+	for _, player := range active {
+		result := "lose"
+		if slices.Contains(leaders, player) {
+			result = "win"
+			if len(leaders) > 1 {
+				result = "draw"
+			}
+		}
+		ffa.release(player, result)
+	}
+
+	var names []string
+	for _, player := range leaders {
+		names = append(names, player.GetName())
+	}
+	log.Printf("FreeForAll %s ended after %d rounds, leading: %s", ffa.ID, ffa.CurrentRound, strings.Join(names, ", "))
+}
+
+// release moves a player out of the game and tells them their result
+func (ffa *FreeForAll) release(player *types.Client, result string) {
+	if err := player.Transition(types.StatePostGame); err != nil {
+		log.Printf("FreeForAll %s: %v", ffa.ID, err)
+	}
+	protocol.Send(player, types.GameEndedMessage{Result: result})
+}
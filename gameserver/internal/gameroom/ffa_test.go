@@ -0,0 +1,234 @@
+package gameroom
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+func TestRules_Resolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		resolution Resolution
+		choices    map[string]Choice
+		results    map[string]string
+		points     map[string]int
+	}{
+		{
+			name:    "dominant move wins",
+			choices: map[string]Choice{"a": Rock, "b": Rock, "c": Scissors},
+			results: map[string]string{"a": "win", "b": "win", "c": "lose"},
+			points:  map[string]int{"a": 1, "b": 1},
+		},
+		{
+			name:    "all three moves draw",
+			choices: map[string]Choice{"a": Rock, "b": Paper, "c": Scissors},
+			results: map[string]string{"a": "draw", "b": "draw", "c": "draw"},
+			points:  map[string]int{},
+		},
+		{
+			name:    "one move draws",
+			choices: map[string]Choice{"a": Paper, "b": Paper, "c": Paper},
+			results: map[string]string{"a": "draw", "b": "draw", "c": "draw"},
+			points:  map[string]int{},
+		},
+		{
+			name:    "timed out player loses",
+			choices: map[string]Choice{"a": Rock, "b": "", "c": Rock},
+			results: map[string]string{"a": "draw", "b": "lose", "c": "draw"},
+			points:  map[string]int{},
+		},
+		{
+			name:    "nobody chose",
+			choices: map[string]Choice{"a": "", "b": ""},
+			results: map[string]string{"a": "draw", "b": "draw"},
+			points:  map[string]int{},
+		},
+		{
+			name:       "points per beaten opponent",
+			resolution: ResolvePoints,
+			choices:    map[string]Choice{"a": Rock, "b": Scissors, "c": Scissors, "d": Paper},
+			results:    map[string]string{"a": "win", "b": "draw", "c": "draw", "d": "lose"},
+			points:     map[string]int{"a": 2, "b": 1, "c": 1, "d": 1},
+		},
+		{
+			name:       "points beat timed out players",
+			resolution: ResolvePoints,
+			choices:    map[string]Choice{"a": Rock, "b": "", "c": Paper},
+			results:    map[string]string{"a": "draw", "b": "lose", "c": "win"},
+			points:     map[string]int{"a": 1, "c": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := Rules{Resolution: tt.resolution}.withDefaults()
+			results, points := rules.resolve(tt.choices)
+			if !reflect.DeepEqual(results, tt.results) {
+				t.Errorf("Expected results %v, got %v", tt.results, results)
+			}
+			if !reflect.DeepEqual(points, tt.points) {
+				t.Errorf("Expected points %v, got %v", tt.points, points)
+			}
+		})
+	}
+}
+
+// createPlayers returns queued clients named Alice, Bob, Carol...
+func createPlayers(t *testing.T, n int) []*types.Client {
+	var players []*types.Client
+	for i, name := range []string{"Alice", "Bob", "Carol", "Dave"}[:n] {
+		players = append(players, createMockClient(t, "player"+string(rune('1'+i)), name))
+	}
+	return players
+}
+
+// gameResult returns the result of the game_ended event among events
+func gameResult(t *testing.T, events []types.BaseGameEvent) string {
+	t.Helper()
+	for _, event := range events {
+		if event.Type == protocol.TypeGameEnded {
+			ended, _ := protocol.Decode[types.GameEndedMessage](event)
+			return ended.Result
+		}
+	}
+	t.Fatal("Expected a game_ended event")
+	return ""
+}
+
+func TestFreeForAll_Scoring(t *testing.T) {
+	players := createPlayers(t, 3)
+	ended := make(chan string, 1)
+	room := NewFreeForAll("test-room", players, Rules{Rounds: 2}, func(roomID string) {
+		ended <- roomID
+	})
+	defer room.Close()
+
+	room.StartFirstRound()
+	room.MakeChoice(players[0].ID, Rock)
+	room.MakeChoice(players[1].ID, Rock)
+	room.MakeChoice(players[2].ID, Scissors)
+
+	events := drainEvents(players[2])
+	if len(events) != 3 || events[1].Type != protocol.TypeRoundResult {
+		t.Fatalf("Expected round_start, round_result, round_start, got %v", events)
+	}
+	result, _ := protocol.Decode[types.RoundResultMessage](events[1])
+	expected := []types.PlayerResult{
+		{Name: "Alice", Choice: "rock", Result: "win", Points: 1},
+		{Name: "Bob", Choice: "rock", Result: "win", Points: 1},
+		{Name: "Carol", Choice: "scissors", Result: "lose"},
+	}
+	if result.Result != "lose" || !reflect.DeepEqual(result.Players, expected) {
+		t.Errorf("Expected Carol to lose against %+v, got %+v", expected, result)
+	}
+
+	// Alice pulls ahead in the last round
+	room.MakeChoice(players[0].ID, Paper)
+	room.MakeChoice(players[1].ID, Rock)
+	room.MakeChoice(players[2].ID, Rock)
+
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("Game end callback should have been called")
+	}
+	for i, expected := range []string{"win", "lose", "lose"} {
+		if got := gameResult(t, drainEvents(players[i])); got != expected {
+			t.Errorf("Expected %s to %s, got %s", players[i].GetName(), expected, got)
+		}
+		if players[i].State() != types.StatePostGame {
+			t.Errorf("%s should be post game, got %s", players[i].GetName(), players[i].State())
+		}
+	}
+}
+
+func TestFreeForAll_Elimination(t *testing.T) {
+	players := createPlayers(t, 3)
+	room := NewFreeForAll("test-room", players, Rules{Elimination: true}, nil)
+	defer room.Close()
+
+	room.StartFirstRound()
+	room.MakeChoice(players[0].ID, Rock)
+	room.MakeChoice(players[1].ID, Rock)
+	room.MakeChoice(players[2].ID, Scissors)
+
+	// Carol is out right away, the others play on
+	if got := gameResult(t, drainEvents(players[2])); got != "lose" {
+		t.Errorf("Expected Carol to be knocked out, got %s", got)
+	}
+	if players[2].State() != types.StatePostGame {
+		t.Errorf("Carol should be post game, got %s", players[2].State())
+	}
+
+	room.MakeChoice(players[2].ID, Paper) // ignored
+	room.MakeChoice(players[0].ID, Paper)
+	room.MakeChoice(players[1].ID, Rock)
+
+	if got := gameResult(t, drainEvents(players[0])); got != "win" {
+		t.Errorf("Expected Alice to be the last one standing, got %s", got)
+	}
+	if got := gameResult(t, drainEvents(players[1])); got != "lose" {
+		t.Errorf("Expected Bob to be knocked out, got %s", got)
+	}
+	if drainEvents(players[2]) != nil {
+		t.Error("Carol should get nothing once knocked out")
+	}
+}
+
+func TestFreeForAll_RoundTimeout(t *testing.T) {
+	players := createPlayers(t, 3)
+	ended := make(chan string, 1)
+	room := NewFreeForAll("test-room", players, Rules{Elimination: true}, func(roomID string) {
+		ended <- roomID
+	}, WithRoundTimeout(20*time.Millisecond))
+	defer room.Close()
+
+	room.StartFirstRound()
+	room.MakeChoice(players[0].ID, Rock)
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the round to time out")
+	}
+
+	// Nobody but Alice chose in time
+	if got := gameResult(t, drainEvents(players[0])); got != "win" {
+		t.Errorf("Expected Alice to win, got %s", got)
+	}
+}
+
+func TestFreeForAll_Leave(t *testing.T) {
+	players := createPlayers(t, 3)
+	ended := make(chan string, 1)
+	room := NewFreeForAll("test-room", players, Rules{}, func(roomID string) {
+		ended <- roomID
+	})
+	defer room.Close()
+
+	room.StartFirstRound()
+	room.MakeChoice(players[1].ID, Rock)
+	room.MakeChoice(players[2].ID, Scissors)
+	drainEvents(players[1])
+
+	// The round is played once Alice is gone
+	room.Leave(players[0].ID)
+	events := drainEvents(players[1])
+	if len(events) != 2 || events[0].Type != protocol.TypeRoundResult {
+		t.Fatalf("Expected the round to be played, got %v", events)
+	}
+
+	// The last player left wins, whatever the score
+	room.Leave(players[1].ID)
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("Game end callback should have been called")
+	}
+	if got := gameResult(t, drainEvents(players[2])); got != "win" {
+		t.Errorf("Expected Carol to win by forfeit, got %s", got)
+	}
+}
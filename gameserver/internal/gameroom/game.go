@@ -0,0 +1,255 @@
+package gameroom
+
+import (
+	"slices"
+	"strings"
+)
+
+// Game is the rules of a two-player game of simultaneous moves, run by a
+// GameRoom: every round both players pick an action, the game scores the
+// round and decides when it is over. A Game belongs to one room and is
+// only used from the room's goroutine, so it may keep state between rounds.
+type Game interface {
+	// Actions returns the actions player 0 or 1 may take this round
+	Actions(player int) []Choice
+	// Resolve scores a round, returning the points of both players. An
+	// empty action means the player ran out of time.
+	Resolve(actions [2]Choice) [2]int
+	// Over reports whether the game is over after round, of at most
+	// bestOf, with the given score
+	Over(round, bestOf int, score [2]int) bool
+}
+
+// GameType is a game players can queue for, either of simultaneous moves
+// or turn-based
+type GameType struct {
+	Name        string          // as sent in join_lobby
+	Roles       [2]string       // what the first and second player play as, empty if both play the same
+	BestOf      int             // rounds played at most
+	New         func() Game     // creates the rules for one room
+	NewTurnGame func() TurnGame // creates the rules for one room of a turn-based game, instead of New
+	Hand        map[Choice]int  // how often each player may play each action per game, unlimited if nil
+}
+
+// TurnBased reports whether the game is played in a TurnRoom
+func (gt GameType) TurnBased() bool {
+	return gt.NewTurnGame != nil
+}
+
+// DefaultGame is the game type of players who do not ask for one
+const DefaultGame = "rps"
+
+var gameTypes = map[string]GameType{}
+
+// RegisterGameType makes a game type available for matchmaking. It is
+// meant to be called from init functions and panics on duplicate names.
+func RegisterGameType(gameType GameType) {
+	if _, exists := gameTypes[gameType.Name]; exists {
+		panic("gameroom: game type " + gameType.Name + " registered twice")
+	}
+	gameTypes[gameType.Name] = gameType
+}
+
+// LookupGameType returns the game type with the given name, DefaultGame
+// for an empty name
+func LookupGameType(name string) (GameType, bool) {
+	if name == "" {
+		name = DefaultGame
+	}
+	gameType, ok := gameTypes[name]
+	return gameType, ok
+}
+
+// GameTypes returns the names of all game types, sorted
+func GameTypes() []string {
+	var names []string
+	for name := range gameTypes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// choiceHint lists actions the way an error message suggests them, e.g.
+// "rock, paper, or scissors"
+func choiceHint(actions []Choice) string {
+	names := make([]string, len(actions))
+	for i, action := range actions {
+		names[i] = string(action)
+	}
+	switch len(names) {
+	case 0:
+		return "nothing"
+	case 1:
+		return names[0]
+	case 2:
+		return names[0] + " or " + names[1]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", or " + names[len(names)-1]
+}
+
+// majority ends a game once a player won more than half of bestOf, or
+// after bestOf rounds
+func majority(round, bestOf int, score [2]int) bool {
+	return score[0] > bestOf/2 || score[1] > bestOf/2 || round >= bestOf
+}
+
+// point gives the round to one player: 0 or 1, or -1 for nobody
+func point(winner int) [2]int {
+	var points [2]int
+	if winner >= 0 {
+		points[winner] = 1
+	}
+	return points
+}
+
+// absent returns the player who ran out of time while the other did not,
+// or -1
+func absent(actions [2]Choice) int {
+	switch {
+	case actions[0] == "" && actions[1] != "":
+		return 0
+	case actions[1] == "" && actions[0] != "":
+		return 1
+	}
+	return -1
+}
+
+func init() {
+	RegisterGameType(GameType{Name: "rps", BestOf: 3, New: func() Game { return rockPaperScissors{} }})
+	RegisterGameType(GameType{Name: "matching_pennies", Roles: [2]string{"matcher", "mismatcher"}, BestOf: 3, New: func() Game { return matchingPennies{} }})
+	RegisterGameType(GameType{Name: "odds_evens", Roles: [2]string{"odds", "evens"}, BestOf: 3, New: func() Game { return oddsEvens{} }})
+	RegisterGameType(GameType{Name: "prisoners_dilemma", BestOf: 5, New: func() Game { return prisonersDilemma{} }})
+	// Rock Paper Scissors with three of each move, every play uses one up
+	RegisterGameType(GameType{Name: "limited_rps", BestOf: 9, New: func() Game { return rockPaperScissors{} },
+		Hand: map[Choice]int{Rock: 3, Paper: 3, Scissors: 3}})
+}
+
+// beats reports whether move a beats move b in Rock Paper Scissors
+func beats(a, b Choice) bool {
+	return (a == Rock && b == Scissors) || (a == Paper && b == Rock) || (a == Scissors && b == Paper)
+}
+
+// rockPaperScissors is the classic: rock beats scissors, scissors beat
+// paper, paper beats rock
+type rockPaperScissors struct{}
+
+func (rockPaperScissors) Actions(int) []Choice {
+	return []Choice{Rock, Paper, Scissors}
+}
+
+func (rockPaperScissors) Resolve(actions [2]Choice) [2]int {
+	switch {
+	case actions[0] == actions[1]:
+		return point(-1)
+	case actions[1] == "" || beats(actions[0], actions[1]):
+		return point(0)
+	default:
+		return point(1)
+	}
+}
+
+func (rockPaperScissors) Over(round, bestOf int, score [2]int) bool {
+	return majority(round, bestOf, score)
+}
+
+// Actions of matching pennies
+const (
+	Heads Choice = "heads"
+	Tails Choice = "tails"
+)
+
+// matchingPennies gives the round to the first player if both coins show
+// the same side, to the second if they differ
+type matchingPennies struct{}
+
+func (matchingPennies) Actions(int) []Choice {
+	return []Choice{Heads, Tails}
+}
+
+func (matchingPennies) Resolve(actions [2]Choice) [2]int {
+	switch {
+	case absent(actions) >= 0:
+		return point(1 - absent(actions))
+	case actions[0] == "":
+		return point(-1)
+	case actions[0] == actions[1]:
+		return point(0)
+	default:
+		return point(1)
+	}
+}
+
+func (matchingPennies) Over(round, bestOf int, score [2]int) bool {
+	return majority(round, bestOf, score)
+}
+
+// Actions of odds and evens, the number of fingers shown
+const (
+	One Choice = "1"
+	Two Choice = "2"
+)
+
+// oddsEvens gives the round to the first player if the fingers shown add
+// up to an odd number, to the second if they are even
+type oddsEvens struct{}
+
+func (oddsEvens) Actions(int) []Choice {
+	return []Choice{One, Two}
+}
+
+func (oddsEvens) Resolve(actions [2]Choice) [2]int {
+	switch {
+	case absent(actions) >= 0:
+		return point(1 - absent(actions))
+	case actions[0] == "":
+		return point(-1)
+	case actions[0] != actions[1]:
+		// One and two make three
+		return point(0)
+	default:
+		return point(1)
+	}
+}
+
+func (oddsEvens) Over(round, bestOf int, score [2]int) bool {
+	return majority(round, bestOf, score)
+}
+
+// Actions of the prisoner's dilemma
+const (
+	Cooperate Choice = "cooperate"
+	Defect    Choice = "defect"
+)
+
+// prisonersDilemma pays 3 points each for mutual cooperation, 1 each for
+// mutual defection and 5 to a defector whose opponent cooperated, who gets
+// nothing. A player who runs out of time scores nothing and counts as
+// cooperating. All rounds are played, the higher total wins.
+type prisonersDilemma struct{}
+
+func (prisonersDilemma) Actions(int) []Choice {
+	return []Choice{Cooperate, Defect}
+}
+
+func (prisonersDilemma) Resolve(actions [2]Choice) [2]int {
+	payoff := func(own, other Choice) int {
+		switch {
+		case own == "":
+			return 0
+		case own == Cooperate && other != Defect:
+			return 3
+		case own == Cooperate:
+			return 0
+		case other != Defect:
+			return 5
+		default:
+			return 1
+		}
+	}
+	return [2]int{payoff(actions[0], actions[1]), payoff(actions[1], actions[0])}
+}
+
+func (prisonersDilemma) Over(round, bestOf int, score [2]int) bool {
+	return round >= bestOf
+}
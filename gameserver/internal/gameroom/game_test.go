@@ -0,0 +1,162 @@
+package gameroom
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+func TestGames_Resolve(t *testing.T) {
+	tests := []struct {
+		game    string
+		actions [2]Choice
+		points  [2]int
+	}{
+		{"rps", [2]Choice{Rock, Scissors}, [2]int{1, 0}},
+		{"rps", [2]Choice{"", Paper}, [2]int{0, 1}},
+		{"rps", [2]Choice{"", ""}, [2]int{0, 0}},
+		{"matching_pennies", [2]Choice{Heads, Heads}, [2]int{1, 0}},
+		{"matching_pennies", [2]Choice{Heads, Tails}, [2]int{0, 1}},
+		{"matching_pennies", [2]Choice{Tails, ""}, [2]int{1, 0}},
+		{"odds_evens", [2]Choice{One, Two}, [2]int{1, 0}},
+		{"odds_evens", [2]Choice{Two, Two}, [2]int{0, 1}},
+		{"odds_evens", [2]Choice{"", ""}, [2]int{0, 0}},
+		{"prisoners_dilemma", [2]Choice{Cooperate, Cooperate}, [2]int{3, 3}},
+		{"prisoners_dilemma", [2]Choice{Cooperate, Defect}, [2]int{0, 5}},
+		{"prisoners_dilemma", [2]Choice{Defect, Defect}, [2]int{1, 1}},
+		{"prisoners_dilemma", [2]Choice{Defect, ""}, [2]int{5, 0}},
+		{"weighted_rps", [2]Choice{Paper, Rock}, [2]int{2, 0}},
+		{"weighted_rps", [2]Choice{Rock, Rock}, [2]int{1, 1}},
+		{"weighted_rps", [2]Choice{Paper, Paper}, [2]int{0, 0}},
+		{"weighted_rps", [2]Choice{"", Paper}, [2]int{0, 2}},
+		{"weighted_rps", [2]Choice{"", ""}, [2]int{0, 0}},
+	}
+
+	for _, tt := range tests {
+		gameType, ok := LookupGameType(tt.game)
+		if !ok {
+			t.Fatalf("Game %s is not registered", tt.game)
+		}
+		if points := gameType.New().Resolve(tt.actions); points != tt.points {
+			t.Errorf("%s %v: expected %v, got %v", tt.game, tt.actions, tt.points, points)
+		}
+	}
+
+	if names := GameTypes(); !slices.Equal(names, []string{"connect_four", "limited_rps", "matching_pennies", "odds_evens", "prisoners_dilemma", "rps", "tic_tac_toe", "weighted_rps"}) {
+		t.Errorf("Unexpected game types %v", names)
+	}
+}
+
+func TestGameRoom_PlaysOtherGames(t *testing.T) {
+	player1 := createMockClient(t, "player1", "Alice")
+	player2 := createMockClient(t, "player2", "Bob")
+	gameType, _ := LookupGameType("prisoners_dilemma")
+	gameRoom := NewGameRoom("test-room", player1, player2, nil, WithGame(gameType))
+	defer gameRoom.Close()
+	gameRoom.StartFirstRound()
+
+	gameRoom.MakeChoice(player1.ID, Rock)
+	events := drainEvents(player1)
+	if len(events) != 2 || events[1].Type != protocol.TypeError {
+		t.Fatalf("Expected round_start and an error, got %v", events)
+	}
+	if msg, _ := protocol.Decode[types.ErrorMessage](events[1]); msg.Message != "Invalid choice. Use cooperate or defect" {
+		t.Errorf("Expected the game's actions in the error, got %q", msg.Message)
+	}
+
+	// Bob defects in every round but the first and wins on points, even
+	// though nobody reaches a majority early
+	for round := 1; round <= 5; round++ {
+		choice := Defect
+		if round == 1 {
+			choice = Cooperate
+		}
+		gameRoom.MakeChoice(player1.ID, Cooperate)
+		gameRoom.MakeChoice(player2.ID, choice)
+	}
+
+	events = drainEvents(player2)
+	if len(events) == 0 || events[len(events)-1].Type != protocol.TypeGameEnded {
+		t.Fatalf("Expected the game to end after 5 rounds, got %v", events)
+	}
+	if result := gameRoom.Result(); result.Winner != "Bob" || result.Player1Wins != 3 || result.Player2Wins != 23 {
+		t.Errorf("Expected Bob to win 23 to 3, got %+v", result)
+	}
+}
+
+func TestGameRoom_LimitedHand(t *testing.T) {
+	player1 := createMockClient(t, "player1", "Alice")
+	player2 := createMockClient(t, "player2", "Bob")
+	gameType, _ := LookupGameType("limited_rps")
+	gameRoom := NewGameRoom("test-room", player1, player2, nil, WithGame(gameType))
+	defer gameRoom.Close()
+	gameRoom.StartFirstRound()
+
+	// Alice plays all three rocks, Bob answers with scissors twice and a
+	// draw
+	for _, choice := range []Choice{Scissors, Scissors, Rock} {
+		gameRoom.MakeChoice(player1.ID, Rock)
+		gameRoom.MakeChoice(player2.ID, choice)
+	}
+	events := drainEvents(player1)
+	start, _ := protocol.Decode[types.RoundStartMessage](events[len(events)-1])
+	if start.RoundNumber != 4 {
+		t.Fatalf("Expected round 4 to start, got %v", events)
+	}
+	yourHand := []types.HandCount{{Action: "rock", Count: 0}, {Action: "paper", Count: 3}, {Action: "scissors", Count: 3}}
+	opponentHand := []types.HandCount{{Action: "rock", Count: 2}, {Action: "paper", Count: 3}, {Action: "scissors", Count: 1}}
+	if !reflect.DeepEqual(start.YourHand, yourHand) || !reflect.DeepEqual(start.OpponentHand, opponentHand) {
+		t.Errorf("Expected hands %v and %v, got %+v", yourHand, opponentHand, start)
+	}
+
+	gameRoom.MakeChoice(player1.ID, Rock)
+	events = drainEvents(player1)
+	if len(events) != 1 || events[0].Type != protocol.TypeError {
+		t.Fatalf("Expected an error for a rock Alice no longer has, got %v", events)
+	}
+	if msg, _ := protocol.Decode[types.ErrorMessage](events[0]); msg.Message != "No rock left. Use paper or scissors" {
+		t.Errorf("Unexpected error %q", msg.Message)
+	}
+
+	snap, _ := gameRoom.Snapshot()
+	if !reflect.DeepEqual(snap.Hands, []map[Choice]int{{Rock: 0, Paper: 3, Scissors: 3}, {Rock: 2, Paper: 3, Scissors: 1}}) {
+		t.Errorf("Expected both hands in the snapshot, got %v", snap.Hands)
+	}
+}
+
+func TestGameRoom_PlaysToTargetPoints(t *testing.T) {
+	player1 := createMockClient(t, "player1", "Alice")
+	player2 := createMockClient(t, "player2", "Bob")
+	gameType, _ := LookupGameType("weighted_rps")
+	gameRoom := NewGameRoom("test-room", player1, player2, nil, WithGame(gameType))
+	defer gameRoom.Close()
+	gameRoom.StartFirstRound()
+
+	// Two rocks score 1 each, then Alice wins twice with paper and reaches 5
+	gameRoom.MakeChoice(player1.ID, Rock)
+	gameRoom.MakeChoice(player2.ID, Rock)
+	events := drainEvents(player1)
+	round, _ := protocol.Decode[types.RoundResultMessage](events[1])
+	if round.Result != "draw" || round.Points != 1 || round.OpponentPoints != 1 || round.TotalPoints != 1 {
+		t.Errorf("Expected a draw worth a point each, got %+v", round)
+	}
+
+	for range 2 {
+		gameRoom.MakeChoice(player1.ID, Paper)
+		gameRoom.MakeChoice(player2.ID, Rock)
+	}
+	events = drainEvents(player2)
+	if len(events) == 0 || events[len(events)-1].Type != protocol.TypeGameEnded {
+		t.Fatalf("Expected the game to end at 5 points, got %v", events)
+	}
+	round, _ = protocol.Decode[types.RoundResultMessage](events[len(events)-2])
+	if round.Result != "lose" || round.OpponentPoints != 2 || round.TotalPoints != 1 || round.OpponentTotalPoints != 5 {
+		t.Errorf("Expected Bob to lose the round 0 to 2, got %+v", round)
+	}
+	if ended, _ := protocol.Decode[types.GameEndedMessage](events[len(events)-1]); ended.Result != "lose" || ended.Points != 1 || ended.OpponentPoints != 5 {
+		t.Errorf("Expected Bob to lose 1 to 5, got %+v", ended)
+	}
+}
@@ -1,11 +1,12 @@
 package gameroom
 
 import (
-	"context"
-	"encoding/json"
+	"errors"
 	"log"
-	"sync"
+	"maps"
+	"slices"
 
+	"github.com/4hel/paper/gameserver/internal/protocol"
 	"github.com/4hel/paper/gameserver/internal/types"
 )
 
@@ -18,7 +19,16 @@ const (
 	Scissors Choice = "scissors"
 )
 
-// GameRoom manages a Rock Paper Scissors game between two players
+// ErrRoomClosed is returned for commands sent after the room stopped
+var ErrRoomClosed = errors.New("game room closed")
+
+// GameRoom runs a game of simultaneous moves between two players, Rock
+// Paper Scissors unless WithGame picks another Game.
+//
+// All game state is owned by a single goroutine that works through the
+// room's inbox one command at a time, so every event (choices, timeouts,
+// disconnects, spectators) is handled in arrival order and the messages a
+// room sends can never overtake each other.
 type GameRoom struct {
 	ID             string
 	Player1        *types.Client
@@ -31,58 +41,166 @@ type GameRoom struct {
 	Player1Ready   bool
 	Player2Ready   bool
 	GameEnded      bool
-	mu             sync.RWMutex
-	ctx            context.Context
-	cancel         context.CancelFunc
-	onGameEnd      func(gameRoomID string) // Callback to notify when game ends
+	Spectators     []*types.Client
+	result         Result
+	gameType       GameType
+	game           Game
+	bestOf         int // rounds played at most, the game may end earlier
+	hands          [2]map[Choice]int // actions each player has left, nil if unlimited
+	suddenDeath    SuddenDeath
+	suddenDeaths   int // sudden-death rounds started so far
+	firstDecisive  int // player who won the earliest round that was not drawn, -1 if none
+	runner
+}
+
+// Result is the outcome of a finished game. Players are named, so the
+// result still means something once the clients are gone.
+type Result struct {
+	Player1     string
+	Player2     string
+	Player1Wins int
+	Player2Wins int
+	Winner      string // name of the winner, empty for a draw
+	Forfeit     bool   // the loser left before the game was over
+}
+
+// envelope carries a command into the room's goroutine and its result back
+type envelope struct {
+	cmd   any
+	reply chan error
 }
 
-// NewGameRoom creates a new game room for two players
-func NewGameRoom(id string, player1, player2 *types.Client, onGameEnd func(string)) *GameRoom {
-	ctx, cancel := context.WithCancel(context.Background())
-	
+// Commands processed by the room's goroutine
+type (
+	startCmd    struct{}
+	choiceCmd   struct {
+		clientID string
+		choice   Choice
+	}
+	leaveCmd    struct{ clientID string }
+	spectateCmd struct{ client *types.Client }
+	snapshotCmd struct{ snap *Snapshot }
+)
+
+// NewGameRoom creates a new game room for two players and starts its goroutine
+func NewGameRoom(id string, player1, player2 *types.Client, onGameEnd func(string), opts ...Option) *GameRoom {
+	return newGameRoom(id, player1, player2, onGameEnd, nil, opts...)
+}
+
+// newGameRoom creates a game room, lets restore set up the state of a game
+// in progress if it is not nil, and starts the room's goroutine
+func newGameRoom(id string, player1, player2 *types.Client, onGameEnd func(string), restore func(*GameRoom), opts ...Option) *GameRoom {
+	settings := newSettings(opts)
 	room := &GameRoom{
-		ID:           id,
-		Player1:      player1,
-		Player2:      player2,
-		CurrentRound: 1,
-		ctx:          ctx,
-		cancel:       cancel,
-		onGameEnd:    onGameEnd,
+		ID:            id,
+		Player1:       player1,
+		Player2:       player2,
+		CurrentRound:  1,
+		firstDecisive: -1,
+		gameType:      settings.gameType,
+		bestOf:        settings.bestOf,
+		suddenDeath:   settings.suddenDeath,
+		runner:        newRunner(settings.roundTimeout),
 	}
 
-	// Set players' game room ID and status
-	player1.GameRoomID = id
-	player1.InGame = true
-	player1.InLobby = false
+	room.game = room.gameType.New()
+	if restore != nil {
+		restore(room)
+	}
+	if room.gameType.Hand != nil && room.hands[0] == nil {
+		for seat := range room.hands {
+			room.hands[seat] = maps.Clone(room.gameType.Hand)
+		}
+	}
 
-	player2.GameRoomID = id
-	player2.InGame = true
-	player2.InLobby = false
+	// Move both players into the room
+	for _, player := range []*types.Client{player1, player2} {
+		if err := player.EnterGame(id); err != nil {
+			log.Printf("GameRoom %s: %v", id, err)
+		}
+	}
+
+	room.start(id, room, onGameEnd)
 
 	// Don't start the round immediately - let the lobby send game_starting first
-	log.Printf("GameRoom %s created for players %s and %s", id, player1.GetName(), player2.GetName())
+	log.Printf("GameRoom %s created for players %s and %s (%s)", id, player1.GetName(), player2.GetName(), room.gameType.Name)
 	return room
 }
 
+// handle applies one command to the room state
+func (gr *GameRoom) handle(cmd any) error {
+	switch cmd := cmd.(type) {
+	case startCmd:
+		gr.startRound()
+	case choiceCmd:
+		gr.makeChoice(cmd.clientID, cmd.choice)
+	case leaveCmd:
+		gr.leave(cmd.clientID)
+	case spectateCmd:
+		gr.spectate(cmd.client)
+	case snapshotCmd:
+		if gr.GameEnded {
+			return ErrRoomClosed
+		}
+		*cmd.snap = gr.snapshot()
+	}
+	return nil
+}
+
+// ended reports whether the game is over
+func (gr *GameRoom) ended() bool {
+	return gr.GameEnded
+}
+
 // StartFirstRound begins the first round of the game
 func (gr *GameRoom) StartFirstRound() {
-	gr.startRound()
+	gr.do(startCmd{})
 }
 
-// MakeChoice processes a player's choice
+// MakeChoice processes a player's choice. Choices for a room that already
+// finished are ignored.
 func (gr *GameRoom) MakeChoice(clientID string, choice Choice) error {
-	gr.mu.Lock()
-	defer gr.mu.Unlock()
+	if err := gr.do(choiceCmd{clientID: clientID, choice: choice}); err != nil && !errors.Is(err, ErrRoomClosed) {
+		return err
+	}
+	return nil
+}
+
+// Leave removes a disconnected client. A player leaving forfeits the game.
+func (gr *GameRoom) Leave(clientID string) {
+	gr.do(leaveCmd{clientID: clientID})
+}
 
+// Spectate adds a client that watches the game without playing. It returns
+// ErrRoomClosed if the game is already over.
+func (gr *GameRoom) Spectate(client *types.Client) error {
+	return gr.do(spectateCmd{client: client})
+}
+
+// makeChoice records a player's choice and plays the round once both chose
+func (gr *GameRoom) makeChoice(clientID string, choice Choice) {
 	if gr.GameEnded {
-		return nil // Game already ended
+		return // Game already ended
+	}
+
+	player := gr.getClientByID(clientID)
+	if player == nil {
+		return // Player not in this game
 	}
 
 	// Validate choice
-	if choice != Rock && choice != Paper && choice != Scissors {
-		gr.sendError(gr.getClientByID(clientID), "Invalid choice. Use rock, paper, or scissors")
-		return nil
+	seat := 0
+	if player == gr.Player2 {
+		seat = 1
+	}
+	if actions := gr.playable(seat); !slices.Contains(actions, choice) {
+		message := "Invalid choice. Use " + choiceHint(actions)
+		if slices.Contains(gr.game.Actions(seat), choice) {
+			message = "No " + string(choice) + " left. Use " + choiceHint(actions)
+		}
+		gr.sendError(player, protocol.ErrorCodeInvalidChoice, protocol.TypeMakeChoice,
+			message, types.ErrorDetail{Key: "choice", Value: string(choice)})
+		return
 	}
 
 	// Record the choice
@@ -95,36 +213,58 @@ func (gr *GameRoom) MakeChoice(clientID string, choice Choice) error {
 		gr.Player2Ready = true
 		log.Printf("GameRoom %s: Player2 %s chose %s", gr.ID, gr.Player2.GetName(), choice)
 	} else {
-		return nil // Player not in this game
+		return // Player not in this game
 	}
 
 	// Check if both players have made their choices
 	if gr.Player1Ready && gr.Player2Ready {
 		gr.processRound()
 	}
+}
 
-	return nil
+// timeoutRound ends a round whose deadline passed. A player who has not
+// chosen loses the round, or it is a draw if neither has.
+func (gr *GameRoom) timeoutRound() {
+	log.Printf("GameRoom %s: Round %d timed out", gr.ID, gr.CurrentRound)
+	gr.processRound()
 }
 
-// processRound determines the winner and sends results
+// processRound determines the winner, sends results and starts the next round
 func (gr *GameRoom) processRound() {
-	var shouldStartNextRound bool
-	// Determine round winner
-	result1, result2 := gr.determineWinner(gr.Player1Choice, gr.Player2Choice)
-
-	// Update scores
-	if result1 == "win" {
-		gr.Player1Wins++
-	} else if result2 == "win" {
-		gr.Player2Wins++
+	gr.stopRoundTimer()
+
+	// Score the round, whoever scored more won it
+	points := gr.game.Resolve([2]Choice{gr.Player1Choice, gr.Player2Choice})
+	gr.Player1Wins += points[0]
+	gr.Player2Wins += points[1]
+	result1, result2 := "draw", "draw"
+	if points[0] > points[1] {
+		result1, result2 = "win", "lose"
+	} else if points[1] > points[0] {
+		result1, result2 = "lose", "win"
+	}
+	if gr.firstDecisive < 0 && points[0] != points[1] {
+		gr.firstDecisive = 0
+		if points[1] > points[0] {
+			gr.firstDecisive = 1
+		}
 	}
 
-	log.Printf("GameRoom %s Round %d: %s vs %s - Score: %d-%d", 
+	log.Printf("GameRoom %s Round %d: %s vs %s - Score: %d-%d",
 		gr.ID, gr.CurrentRound, gr.Player1Choice, gr.Player2Choice, gr.Player1Wins, gr.Player2Wins)
 
 	// Send round results
-	gr.sendRoundResult(gr.Player1, result1, string(gr.Player1Choice), string(gr.Player2Choice))
-	gr.sendRoundResult(gr.Player2, result2, string(gr.Player2Choice), string(gr.Player1Choice))
+	choices := [2]Choice{gr.Player1Choice, gr.Player2Choice}
+	gr.sendRoundResult(0, result1, choices, points)
+	gr.sendRoundResult(1, result2, choices, points)
+	gr.sendSpectateUpdate()
+
+	// Played actions are used up
+	for seat, choice := range [2]Choice{gr.Player1Choice, gr.Player2Choice} {
+		if gr.hands[seat] != nil && choice != "" {
+			gr.hands[seat][choice]--
+		}
+	}
 
 	// Reset choices for next round
 	gr.Player1Choice = ""
@@ -132,79 +272,87 @@ func (gr *GameRoom) processRound() {
 	gr.Player1Ready = false
 	gr.Player2Ready = false
 
-	// Check if game is over (best of 3)
-	if gr.Player1Wins >= 2 || gr.Player2Wins >= 2 || gr.CurrentRound >= 3 {
-		gr.endGame()
-	} else {
-		// Prepare for next round
-		gr.CurrentRound++
-		shouldStartNextRound = true
-	}
-	
-	// Start next round in a goroutine to avoid deadlock
-	if shouldStartNextRound {
-		go gr.startRound()
-	}
-}
-
-// determineWinner returns the result for player1 and player2
-func (gr *GameRoom) determineWinner(choice1, choice2 Choice) (string, string) {
-	if choice1 == choice2 {
-		return "draw", "draw"
+	// Check if game is over, a tie may go to sudden death
+	if gr.game.Over(gr.CurrentRound, gr.bestOf, [2]int{gr.Player1Wins, gr.Player2Wins}) && !gr.nextSuddenDeath() {
+		gr.endGame(nil)
+		return
 	}
 
-	// Rock Paper Scissors logic
-	switch {
-	case (choice1 == Rock && choice2 == Scissors) ||
-		 (choice1 == Paper && choice2 == Rock) ||
-		 (choice1 == Scissors && choice2 == Paper):
-		return "win", "lose"
-	default:
-		return "lose", "win"
-	}
+	// Prepare for next round, always after this round's results went out
+	gr.CurrentRound++
+	gr.startRound()
 }
 
 // startRound begins a new round
 func (gr *GameRoom) startRound() {
-	gr.mu.Lock()
 	if gr.GameEnded {
-		gr.mu.Unlock()
 		return
 	}
-	
-	roundNumber := gr.CurrentRound
-	player1 := gr.Player1
-	player2 := gr.Player2
-	gameID := gr.ID
-	gr.mu.Unlock()
 
-	log.Printf("GameRoom %s: Starting round %d", gameID, roundNumber)
+	log.Printf("GameRoom %s: Starting round %d", gr.ID, gr.CurrentRound)
+
+	gr.sendRoundStart(gr.Player1, gr.CurrentRound, gr.hand(0), gr.hand(1))
+	gr.sendRoundStart(gr.Player2, gr.CurrentRound, gr.hand(1), gr.hand(0))
 
-	// Send messages without holding the mutex to avoid deadlock
-	gr.sendRoundStart(player1, roundNumber)
-	gr.sendRoundStart(player2, roundNumber)
+	gr.startRoundTimer()
 }
 
+// leave handles a disconnected client
+func (gr *GameRoom) leave(clientID string) {
+	for i, spectator := range gr.Spectators {
+		if spectator.ID == clientID {
+			gr.Spectators = append(gr.Spectators[:i], gr.Spectators[i+1:]...)
+			return
+		}
+	}
+
+	if player := gr.getClientByID(clientID); player != nil && !gr.GameEnded {
+		log.Printf("GameRoom %s: %s left, forfeiting the game", gr.ID, player.GetName())
+		gr.endGame(player)
+	}
+}
 
-// endGame finishes the game and determines the winner
-func (gr *GameRoom) endGame() {
+// spectate adds a spectator and sends them the current score
+func (gr *GameRoom) spectate(client *types.Client) {
+	gr.Spectators = append(gr.Spectators, client)
+	log.Printf("GameRoom %s: %s is spectating", gr.ID, client.GetName())
+	protocol.Send(client, gr.spectateUpdate())
+}
+
+// endGame finishes the game and determines the winner. If forfeit is set,
+// that player left and the opponent wins regardless of the score.
+func (gr *GameRoom) endGame(forfeit *types.Client) {
 	gr.GameEnded = true
+	gr.stopRoundTimer()
 
 	var result1, result2 string
 
 	// Determine final game result
-	if gr.Player1Wins > gr.Player2Wins {
+	switch {
+	case forfeit == gr.Player1:
+		result1 = "lose"
+		result2 = "win"
+	case forfeit == gr.Player2:
+		result1 = "win"
+		result2 = "lose"
+	case gr.Player1Wins > gr.Player2Wins:
 		result1 = "win"
 		result2 = "lose"
-	} else if gr.Player2Wins > gr.Player1Wins {
+	case gr.Player2Wins > gr.Player1Wins:
 		result1 = "lose"
 		result2 = "win"
-	} else {
+	case gr.fallbackWinner() == 0:
+		result1 = "win"
+		result2 = "lose"
+	case gr.fallbackWinner() == 1:
+		result1 = "lose"
+		result2 = "win"
+	default:
 		result1 = "draw"
 		result2 = "draw"
 	}
 
-	log.Printf("GameRoom %s ended: %s (%d) vs %s (%d) - Winner: %s", 
+	log.Printf("GameRoom %s ended: %s (%d) vs %s (%d) - Winner: %s",
 		gr.ID, gr.Player1.GetName(), gr.Player1Wins, gr.Player2.GetName(), gr.Player2Wins,
 		func() string {
 			if result1 == "win" { return gr.Player1.GetName() }
@@ -212,22 +360,77 @@ func (gr *GameRoom) endGame() {
 			return "Draw"
 		}())
 
+	gr.result = Result{
+		Player1:     gr.Player1.GetName(),
+		Player2:     gr.Player2.GetName(),
+		Player1Wins: gr.Player1Wins,
+		Player2Wins: gr.Player2Wins,
+		Forfeit:     forfeit != nil,
+	}
+	if result1 == "win" {
+		gr.result.Winner = gr.result.Player1
+	} else if result2 == "win" {
+		gr.result.Winner = gr.result.Player2
+	}
+
+	// Release the players before telling them, so a quick play_again
+	// already finds them in the post-game state
+	for _, player := range []*types.Client{gr.Player1, gr.Player2} {
+		if err := player.Transition(types.StatePostGame); err != nil {
+			log.Printf("GameRoom %s: %v", gr.ID, err)
+		}
+	}
+
 	// Send game ended messages
-	gr.sendGameEnded(gr.Player1, result1)
-	gr.sendGameEnded(gr.Player2, result2)
+	gr.sendGameEnded(0, result1)
+	gr.sendGameEnded(1, result2)
+
+	// Spectators get the final score and are released as well
+	gr.sendSpectateUpdate()
+	for _, spectator := range gr.Spectators {
+		if err := spectator.Transition(types.StateNamed); err != nil {
+			log.Printf("GameRoom %s: %v", gr.ID, err)
+		}
+	}
+}
 
-	// Reset player states
-	gr.Player1.InGame = false
-	gr.Player1.InLobby = true
-	gr.Player1.GameRoomID = ""
-	gr.Player2.InGame = false
-	gr.Player2.InLobby = true
-	gr.Player2.GameRoomID = ""
+// Result returns the outcome of the game. It is only valid once the game
+// ended, e.g. in the onGameEnd callback.
+func (gr *GameRoom) Result() Result {
+	return gr.result
+}
+
+// playable returns the actions player 0 or 1 may take this round and has
+// left in their hand
+func (gr *GameRoom) playable(seat int) []Choice {
+	actions := gr.game.Actions(seat)
+	if gr.hands[seat] == nil {
+		return actions
+	}
+	return slices.DeleteFunc(slices.Clone(actions), func(action Choice) bool {
+		return gr.hands[seat][action] <= 0
+	})
+}
 
-	// Notify that game has ended
-	if gr.onGameEnd != nil {
-		gr.onGameEnd(gr.ID)
+// hand lists how often player 0 or 1 can still play each action, nil if
+// their hand is unlimited
+func (gr *GameRoom) hand(seat int) []types.HandCount {
+	if gr.hands[seat] == nil {
+		return nil
+	}
+	hand := []types.HandCount{}
+	for _, action := range gr.game.Actions(seat) {
+		hand = append(hand, types.HandCount{Action: string(action), Count: gr.hands[seat][action]})
 	}
+	return hand
+}
+
+// player returns player 0 or 1
+func (gr *GameRoom) player(seat int) *types.Client {
+	if seat == 1 {
+		return gr.Player2
+	}
+	return gr.Player1
 }
 
 // getClientByID returns the client with the given ID
@@ -240,98 +443,71 @@ func (gr *GameRoom) getClientByID(clientID string) *types.Client {
 	return nil
 }
 
-// Message sending functions
-func (gr *GameRoom) sendRoundResult(client *types.Client, result, yourChoice, opponentChoice string) {
-	if client.IsClosed() {
-		return
+// spectateUpdate returns the current score as seen by spectators
+func (gr *GameRoom) spectateUpdate() types.SpectateUpdateMessage {
+	return types.SpectateUpdateMessage{
+		RoundNumber:   gr.CurrentRound,
+		Player1:       gr.Player1.GetName(),
+		Player2:       gr.Player2.GetName(),
+		Player1Wins:   gr.Player1Wins,
+		Player2Wins:   gr.Player2Wins,
+		Player1Choice: string(gr.Player1Choice),
+		Player2Choice: string(gr.Player2Choice),
+		GameOver:      gr.GameEnded,
 	}
-	
-	data, _ := json.Marshal(types.RoundResultMessage{
-		Result:         result,
-		YourChoice:     yourChoice,
-		OpponentChoice: opponentChoice,
+}
+
+// Message sending functions
+func (gr *GameRoom) sendRoundResult(seat int, result string, choices [2]Choice, points [2]int) {
+	totals := [2]int{gr.Player1Wins, gr.Player2Wins}
+	protocol.Send(gr.player(seat), types.RoundResultMessage{
+		Result:              result,
+		YourChoice:          string(choices[seat]),
+		OpponentChoice:      string(choices[1-seat]),
+		Points:              points[seat],
+		OpponentPoints:      points[1-seat],
+		TotalPoints:         totals[seat],
+		OpponentTotalPoints: totals[1-seat],
 	})
-	event := types.BaseGameEvent{
-		Type: "round_result",
-		Data: data,
-	}
-	
-	select {
-	case client.Send <- event:
-	case <-gr.ctx.Done():
-		return
-	default:
-		log.Printf("Failed to send round_result to client %s", client.ID)
-	}
 }
 
-func (gr *GameRoom) sendRoundStart(client *types.Client, roundNumber int) {
-	if client.IsClosed() {
-		return
-	}
-	
-	data, _ := json.Marshal(types.RoundStartMessage{
-		RoundNumber: roundNumber,
+func (gr *GameRoom) sendRoundStart(client *types.Client, roundNumber int, yourHand, opponentHand []types.HandCount) {
+	protocol.Send(client, types.RoundStartMessage{
+		RoundNumber:  roundNumber,
+		YourHand:     yourHand,
+		OpponentHand: opponentHand,
+		SuddenDeath:  gr.suddenDeaths > 0,
 	})
-	event := types.BaseGameEvent{
-		Type: "round_start",
-		Data: data,
-	}
-	
-	select {
-	case client.Send <- event:
-	case <-gr.ctx.Done():
-		return
-	default:
-		log.Printf("Failed to send round_start to client %s", client.ID)
-	}
 }
 
-func (gr *GameRoom) sendGameEnded(client *types.Client, result string) {
-	if client.IsClosed() {
-		return
-	}
-	
-	data, _ := json.Marshal(types.GameEndedMessage{
-		Result: result,
+func (gr *GameRoom) sendGameEnded(seat int, result string) {
+	totals := [2]int{gr.Player1Wins, gr.Player2Wins}
+	protocol.Send(gr.player(seat), types.GameEndedMessage{
+		Result:         result,
+		Points:         totals[seat],
+		OpponentPoints: totals[1-seat],
 	})
-	event := types.BaseGameEvent{
-		Type: "game_ended",
-		Data: data,
-	}
-	
-	select {
-	case client.Send <- event:
-	case <-gr.ctx.Done():
-		return
-	default:
-		log.Printf("Failed to send game_ended to client %s", client.ID)
-	}
 }
 
-func (gr *GameRoom) sendError(client *types.Client, message string) {
-	if client.IsClosed() {
-		return
+func (gr *GameRoom) sendSpectateUpdate() {
+	update := gr.spectateUpdate()
+	watching := gr.Spectators[:0]
+	for _, spectator := range gr.Spectators {
+		// Spectators who went back to the lobby stop watching
+		if spectator.State() != types.StateSpectating {
+			continue
+		}
+		protocol.Send(spectator, update)
+		watching = append(watching, spectator)
 	}
-	
-	data, _ := json.Marshal(types.ErrorMessage{
+	gr.Spectators = watching
+}
+
+func (gr *GameRoom) sendError(client *types.Client, code, ref, message string, details ...types.ErrorDetail) {
+	protocol.Send(client, types.ErrorMessage{
+		Code:    code,
 		Message: message,
+		Ref:     ref,
+		Details: details,
 	})
-	event := types.BaseGameEvent{
-		Type: "error",
-		Data: data,
-	}
-	
-	select {
-	case client.Send <- event:
-	case <-gr.ctx.Done():
-		return
-	default:
-		log.Printf("Failed to send error to client %s", client.ID)
-	}
 }
-
-// Close cleans up the game room
-func (gr *GameRoom) Close() {
-	gr.cancel()
-}
\ No newline at end of file
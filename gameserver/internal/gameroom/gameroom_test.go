@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/4hel/paper/gameserver/internal/protocol"
 	"github.com/4hel/paper/gameserver/internal/types"
 )
 
@@ -33,6 +34,12 @@ func createMockClient(t *testing.T, id, name string) *types.Client {
 
 	client := types.NewClient(id, conn)
 	client.SetName(name)
+	// Game rooms only accept players that were queued by the lobby
+	for _, state := range []types.ClientState{types.StateNamed, types.StateQueued} {
+		if err := client.Transition(state); err != nil {
+			t.Fatal("Failed to queue client:", err)
+		}
+	}
 	t.Cleanup(client.Close)
 	return client
 }
@@ -132,11 +139,9 @@ func TestGameRoom_BestOfThreeFlow(t *testing.T) {
 	player1 := createMockClient(t, "player1", "Alice")
 	player2 := createMockClient(t, "player2", "Bob")
 
-	var gameEndCalled bool
-	var gameEndedRoomID string
+	gameEnded := make(chan string, 1)
 	gameRoom := NewGameRoom("test-room", player1, player2, func(roomID string) {
-		gameEndCalled = true
-		gameEndedRoomID = roomID
+		gameEnded <- roomID
 	})
 	defer gameRoom.Close()
 	
@@ -216,19 +221,21 @@ func TestGameRoom_BestOfThreeFlow(t *testing.T) {
 	// Wait for callback
 	time.Sleep(10 * time.Millisecond)
 	
-	if !gameEndCalled {
+	select {
+	case gameEndedRoomID := <-gameEnded:
+		if gameEndedRoomID != "test-room" {
+			t.Errorf("Expected room ID 'test-room', got '%s'", gameEndedRoomID)
+		}
+	case <-time.After(time.Second):
 		t.Error("Game end callback should have been called")
 	}
-	if gameEndedRoomID != "test-room" {
-		t.Errorf("Expected room ID 'test-room', got '%s'", gameEndedRoomID)
-	}
 
 	// Check that players are no longer in game
-	if player1.InGame {
-		t.Error("Player1 should no longer be in game")
+	if player1.State() != types.StatePostGame {
+		t.Errorf("Player1 should be post game, got %s", player1.State())
 	}
-	if player2.InGame {
-		t.Error("Player2 should no longer be in game")
+	if player2.State() != types.StatePostGame {
+		t.Errorf("Player2 should be post game, got %s", player2.State())
 	}
 }
 
@@ -336,9 +343,7 @@ func TestGameRoom_ChoiceAfterGameEnd(t *testing.T) {
 	gameRoom.StartFirstRound()
 
 	// Manually end the game
-	gameRoom.mu.Lock()
 	gameRoom.GameEnded = true
-	gameRoom.mu.Unlock()
 
 	// Try to make a choice after game ended
 	err := gameRoom.MakeChoice(player1.ID, Rock)
@@ -356,9 +361,9 @@ func TestGameRoom_DrawScenarios(t *testing.T) {
 	player1 := createMockClient(t, "player1", "Alice")
 	player2 := createMockClient(t, "player2", "Bob")
 
-	var gameEndCalled bool
+	gameEnded := make(chan string, 1)
 	gameRoom := NewGameRoom("test-room", player1, player2, func(roomID string) {
-		gameEndCalled = true
+		gameEnded <- roomID
 	})
 	defer gameRoom.Close()
 	
@@ -449,7 +454,9 @@ func TestGameRoom_DrawScenarios(t *testing.T) {
 	// Wait for callback
 	time.Sleep(10 * time.Millisecond)
 	
-	if !gameEndCalled {
+	select {
+	case <-gameEnded:
+	case <-time.After(time.Second):
 		t.Error("Game end callback should have been called after 3 draws")
 	}
 }
@@ -458,9 +465,9 @@ func TestGameRoom_MaxRoundsReached(t *testing.T) {
 	player1 := createMockClient(t, "player1", "Alice")
 	player2 := createMockClient(t, "player2", "Bob")
 
-	var gameEndCalled bool
+	gameEnded := make(chan string, 1)
 	gameRoom := NewGameRoom("test-room", player1, player2, func(roomID string) {
-		gameEndCalled = true
+		gameEnded <- roomID
 	})
 	defer gameRoom.Close()
 	
@@ -468,11 +475,9 @@ func TestGameRoom_MaxRoundsReached(t *testing.T) {
 	gameRoom.StartFirstRound()
 
 	// Simulate 3 rounds with 1-1 score going into round 3
-	gameRoom.mu.Lock()
 	gameRoom.Player1Wins = 1
 	gameRoom.Player2Wins = 1
 	gameRoom.CurrentRound = 3
-	gameRoom.mu.Unlock()
 
 	// Clear any pending messages
 	select {
@@ -500,7 +505,9 @@ func TestGameRoom_MaxRoundsReached(t *testing.T) {
 		t.Errorf("Expected Player1Wins = 2, got %d", gameRoom.Player1Wins)
 	}
 
-	if !gameEndCalled {
+	select {
+	case <-gameEnded:
+	case <-time.After(time.Second):
 		t.Error("Game end callback should have been called")
 	}
 }
@@ -574,4 +581,225 @@ func TestGameRoom_StressTestMultipleGames(t *testing.T) {
 	if finalCount != numGames {
 		t.Errorf("Expected %d games to end, got %d", numGames, finalCount)
 	}
-}
\ No newline at end of file
+}
+func TestGameRoom_EventOrder(t *testing.T) {
+	player1 := createMockClient(t, "player1", "Alice")
+	player2 := createMockClient(t, "player2", "Bob")
+
+	gameRoom := NewGameRoom("test-room", player1, player2, nil)
+	defer gameRoom.Close()
+
+	gameRoom.StartFirstRound()
+	gameRoom.MakeChoice(player1.ID, Rock)
+	gameRoom.MakeChoice(player2.ID, Rock)
+	gameRoom.MakeChoice(player1.ID, Paper)
+	gameRoom.MakeChoice(player2.ID, Rock)
+
+	// Every round_result must reach the client before the next round_start
+	expected := []string{"round_start", "round_result", "round_start", "round_result", "round_start"}
+	var got []string
+	for _, event := range drainEvents(player1) {
+		got = append(got, event.Type)
+	}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestGameRoom_RoundTimeout(t *testing.T) {
+	player1 := createMockClient(t, "player1", "Alice")
+	player2 := createMockClient(t, "player2", "Bob")
+
+	gameRoom := NewGameRoom("test-room", player1, player2, nil, WithRoundTimeout(20*time.Millisecond))
+	defer gameRoom.Close()
+
+	gameRoom.StartFirstRound()
+	gameRoom.MakeChoice(player1.ID, Rock)
+
+	time.Sleep(30 * time.Millisecond)
+
+	// A no-op command syncs with the room goroutine before reading state
+	gameRoom.MakeChoice("nonexistent", Rock)
+
+	// Bob never chose, so Alice takes the round
+	if gameRoom.Player1Wins != 1 {
+		t.Errorf("Expected Alice to win the timed out round, got %d wins", gameRoom.Player1Wins)
+	}
+	if gameRoom.Player2Wins != 0 {
+		t.Errorf("Expected Bob to have no wins, got %d", gameRoom.Player2Wins)
+	}
+}
+
+func TestGameRoom_LeaveForfeitsGame(t *testing.T) {
+	player1 := createMockClient(t, "player1", "Alice")
+	player2 := createMockClient(t, "player2", "Bob")
+
+	ended := make(chan string, 1)
+	gameRoom := NewGameRoom("test-room", player1, player2, func(roomID string) {
+		ended <- roomID
+	})
+	defer gameRoom.Close()
+
+	gameRoom.StartFirstRound()
+	drainEvents(player2)
+
+	gameRoom.Leave(player1.ID)
+
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("Game end callback should have been called")
+	}
+
+	event := <-player2.Send
+	result, _ := protocol.Decode[types.GameEndedMessage](event)
+	if event.Type != "game_ended" || result.Result != "win" {
+		t.Errorf("Expected Bob to win by forfeit, got %s %+v", event.Type, result)
+	}
+	if player2.State() != types.StatePostGame {
+		t.Errorf("Bob should be post game, got %s", player2.State())
+	}
+}
+
+func TestGameRoom_Result(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		play     func(gameRoom *GameRoom, player1, player2 *types.Client)
+		expected Result
+	}{
+		{
+			name: "won",
+			play: func(gameRoom *GameRoom, player1, player2 *types.Client) {
+				for i := 0; i < 2; i++ {
+					gameRoom.MakeChoice(player1.ID, Paper)
+					gameRoom.MakeChoice(player2.ID, Rock)
+				}
+			},
+			expected: Result{Player1: "Alice", Player2: "Bob", Player1Wins: 2, Winner: "Alice"},
+		},
+		{
+			name: "forfeit",
+			play: func(gameRoom *GameRoom, player1, player2 *types.Client) {
+				gameRoom.MakeChoice(player1.ID, Paper)
+				gameRoom.MakeChoice(player2.ID, Rock)
+				gameRoom.Leave(player1.ID)
+			},
+			expected: Result{Player1: "Alice", Player2: "Bob", Player1Wins: 1, Winner: "Bob", Forfeit: true},
+		},
+		{
+			name: "draw",
+			play: func(gameRoom *GameRoom, player1, player2 *types.Client) {
+				for i := 0; i < 3; i++ {
+					gameRoom.MakeChoice(player1.ID, Rock)
+					gameRoom.MakeChoice(player2.ID, Rock)
+				}
+			},
+			expected: Result{Player1: "Alice", Player2: "Bob"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			player1 := createMockClient(t, "player1", "Alice")
+			player2 := createMockClient(t, "player2", "Bob")
+
+			results := make(chan Result, 1)
+			var gameRoom *GameRoom
+			gameRoom = NewGameRoom("test-room", player1, player2, func(string) {
+				results <- gameRoom.Result()
+			})
+			defer gameRoom.Close()
+
+			gameRoom.StartFirstRound()
+			tc.play(gameRoom, player1, player2)
+
+			select {
+			case result := <-results:
+				if result != tc.expected {
+					t.Errorf("Expected %+v, got %+v", tc.expected, result)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Game end callback should have been called")
+			}
+		})
+	}
+}
+
+func TestGameRoom_Spectate(t *testing.T) {
+	player1 := createMockClient(t, "player1", "Alice")
+	player2 := createMockClient(t, "player2", "Bob")
+	spectator := createMockClient(t, "spectator", "Carol")
+	spectator.Transition(types.StateNamed) // undo the queueing done by createMockClient
+	if err := spectator.Transition(types.StateSpectating); err != nil {
+		t.Fatal(err)
+	}
+
+	gameRoom := NewGameRoom("test-room", player1, player2, nil)
+	defer gameRoom.Close()
+
+	gameRoom.StartFirstRound()
+	if err := gameRoom.Spectate(spectator); err != nil {
+		t.Fatal("Spectate failed:", err)
+	}
+	for round := 0; round < 2; round++ {
+		gameRoom.MakeChoice(player1.ID, Rock)
+		gameRoom.MakeChoice(player2.ID, Scissors)
+	}
+
+	var updates []types.SpectateUpdateMessage
+	for _, event := range drainEvents(spectator) {
+		update, err := protocol.Decode[types.SpectateUpdateMessage](event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		updates = append(updates, update)
+	}
+
+	// Snapshot on join, one update per round, and the final score
+	if len(updates) != 4 {
+		t.Fatalf("Expected 4 spectate updates, got %d", len(updates))
+	}
+	if updates[1].Player1Choice != "rock" || updates[1].Player2Choice != "scissors" {
+		t.Errorf("Round update should show both choices, got %+v", updates[1])
+	}
+	final := updates[3]
+	if !final.GameOver || final.Player1Wins != 2 {
+		t.Errorf("Expected final update with Alice on 2 wins, got %+v", final)
+	}
+	if spectator.State() != types.StateNamed {
+		t.Errorf("Spectator should be released after the game, got %s", spectator.State())
+	}
+
+	if err := gameRoom.Spectate(spectator); err != ErrRoomClosed {
+		t.Errorf("Expected ErrRoomClosed after the game, got %v", err)
+	}
+}
+
+func TestGameRoom_CloseStopsRoom(t *testing.T) {
+	player1 := createMockClient(t, "player1", "Alice")
+	player2 := createMockClient(t, "player2", "Bob")
+
+	gameRoom := NewGameRoom("test-room", player1, player2, nil)
+	gameRoom.StartFirstRound()
+
+	gameRoom.Close()
+	gameRoom.Close() // Safe to call twice
+
+	if err := gameRoom.MakeChoice(player1.ID, Rock); err != nil {
+		t.Errorf("Choices after Close should be ignored, got %v", err)
+	}
+	if gameRoom.Player1Ready {
+		t.Error("Closed room should not record choices")
+	}
+}
+
+// drainEvents returns all queued messages for a client
+func drainEvents(client *types.Client) []types.BaseGameEvent {
+	var got []types.BaseGameEvent
+	for {
+		select {
+		case event := <-client.Send:
+			got = append(got, event)
+		default:
+			return got
+		}
+	}
+}
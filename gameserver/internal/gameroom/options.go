@@ -0,0 +1,49 @@
+package gameroom
+
+import "time"
+
+// settings are what Options configure. Every room takes the round timeout,
+// the game, best-of and sudden death only apply to GameRoom.
+type settings struct {
+	roundTimeout time.Duration
+	gameType     GameType
+	bestOf       int
+	suddenDeath  SuddenDeath
+}
+
+// Option configures a room
+type Option func(*settings)
+
+// newSettings applies opts to the defaults, Rock Paper Scissors without
+// a round timeout
+func newSettings(opts []Option) settings {
+	s := settings{gameType: gameTypes[DefaultGame], bestOf: gameTypes[DefaultGame].BestOf}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// WithRoundTimeout limits how long a round waits for choices. When it
+// expires, players who have not chosen lose the round. Zero disables it.
+func WithRoundTimeout(timeout time.Duration) Option {
+	return func(s *settings) {
+		s.roundTimeout = timeout
+	}
+}
+
+// WithGame plays gameType instead of Rock Paper Scissors
+func WithGame(gameType GameType) Option {
+	return func(s *settings) {
+		s.gameType = gameType
+		s.bestOf = gameType.BestOf
+	}
+}
+
+// WithSuddenDeath plays games that end tied on with sudden-death rounds,
+// and decides those still tied after them by the fallback
+func WithSuddenDeath(suddenDeath SuddenDeath) Option {
+	return func(s *settings) {
+		s.suddenDeath = suddenDeath
+	}
+}
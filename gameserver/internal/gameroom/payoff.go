@@ -0,0 +1,79 @@
+package gameroom
+
+import "slices"
+
+// Payoff is a payoff matrix: the points both players score for each pair
+// of actions, the first player's action first. Pairs that are not listed
+// score nothing.
+type Payoff map[[2]Choice][2]int
+
+// PayoffGame returns a game type decided by points. Every round pays both
+// players by payoff, the first player to reach target points wins, and
+// after bestOf rounds the higher total wins. A player who runs out of time
+// scores nothing, their opponent scores the most their action can earn.
+// It panics if payoff names an action that is not in actions.
+func PayoffGame(name string, actions []Choice, payoff Payoff, target, bestOf int) GameType {
+	for pair := range payoff {
+		for _, action := range pair {
+			if !slices.Contains(actions, action) {
+				panic("gameroom: payoff of " + name + " for unknown action " + string(action))
+			}
+		}
+	}
+	return GameType{Name: name, BestOf: bestOf, New: func() Game {
+		return payoffGame{actions: actions, payoff: payoff, target: target}
+	}}
+}
+
+// payoffGame scores rounds by a payoff matrix and ends at a target score
+type payoffGame struct {
+	actions []Choice
+	payoff  Payoff
+	target  int
+}
+
+func (g payoffGame) Actions(int) []Choice {
+	return g.actions
+}
+
+func (g payoffGame) Resolve(actions [2]Choice) [2]int {
+	if player := absent(actions); player >= 0 {
+		var points [2]int
+		points[1-player] = g.best(1-player, actions[1-player])
+		return points
+	}
+	// Nobody scores if both ran out of time
+	return g.payoff[actions]
+}
+
+// best returns the most player 0 or 1 can score with action, whatever the
+// opponent plays
+func (g payoffGame) best(player int, action Choice) int {
+	most := 0
+	for _, other := range g.actions {
+		pair := [2]Choice{action, other}
+		if player == 1 {
+			pair = [2]Choice{other, action}
+		}
+		most = max(most, g.payoff[pair][player])
+	}
+	return most
+}
+
+func (g payoffGame) Over(round, bestOf int, score [2]int) bool {
+	return score[0] >= g.target || score[1] >= g.target || round >= bestOf
+}
+
+func init() {
+	// Rock Paper Scissors where winning with paper is worth 2 points and
+	// two rocks score 1 each, first to 5 points
+	RegisterGameType(PayoffGame("weighted_rps", []Choice{Rock, Paper, Scissors}, Payoff{
+		{Rock, Rock}:      {1, 1},
+		{Rock, Scissors}:  {1, 0},
+		{Scissors, Rock}:  {0, 1},
+		{Paper, Rock}:     {2, 0},
+		{Rock, Paper}:     {0, 2},
+		{Scissors, Paper}: {1, 0},
+		{Paper, Scissors}: {0, 1},
+	}, 5, 9))
+}
@@ -0,0 +1,125 @@
+package gameroom
+
+import (
+	"context"
+	"time"
+)
+
+// actor is the game state a runner's goroutine works on. handle applies
+// one command, timeoutRound is called when the round deadline passed and
+// ended reports whether the game is over.
+type actor interface {
+	handle(cmd any) error
+	timeoutRound()
+	ended() bool
+}
+
+// runner owns the goroutine, inbox and round deadline every room type
+// shares. Rooms embed it and start it with themselves as the actor.
+type runner struct {
+	roundTimeout time.Duration
+	roundTimer   *time.Timer
+	inbox        chan envelope
+	done         chan struct{}
+	ctx          context.Context
+	cancel       context.CancelFunc
+}
+
+// newRunner returns a runner whose rounds time out after roundTimeout, or
+// never if it is zero
+func newRunner(roundTimeout time.Duration) runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return runner{
+		roundTimeout: roundTimeout,
+		inbox:        make(chan envelope),
+		done:         make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// start runs the goroutine working on a. onGameEnd, if set, is called with
+// id once the game ended but not when the room was closed first.
+func (r *runner) start(id string, a actor, onGameEnd func(string)) {
+	go func() {
+		ended := r.run(a)
+		// The room no longer owns any state here, so the callback is free
+		// to take other locks and Close the room
+		if ended && onGameEnd != nil {
+			onGameEnd(id)
+		}
+	}()
+}
+
+// run processes the inbox until the game ends or the room is closed. It
+// reports whether the game ended.
+func (r *runner) run(a actor) bool {
+	defer close(r.done)
+	defer r.stopRoundTimer()
+
+	for {
+		select {
+		case <-r.ctx.Done():
+			return false
+		case env := <-r.inbox:
+			err := a.handle(env.cmd)
+			// Read the state before replying, the caller may look at the
+			// room as soon as it has its answer
+			ended := a.ended()
+			env.reply <- err
+			if ended {
+				return true
+			}
+		case <-r.roundDeadline():
+			r.roundTimer = nil
+			a.timeoutRound()
+			if a.ended() {
+				return true
+			}
+		}
+	}
+}
+
+// do sends a command to the room's goroutine and waits until it was handled
+func (r *runner) do(cmd any) error {
+	env := envelope{cmd: cmd, reply: make(chan error, 1)}
+	select {
+	case r.inbox <- env:
+		// A received command is always answered before the loop exits
+		return <-env.reply
+	case <-r.done:
+		return ErrRoomClosed
+	}
+}
+
+// startRoundTimer starts the current round's deadline, if rounds are timed
+func (r *runner) startRoundTimer() {
+	if r.roundTimeout > 0 {
+		r.stopRoundTimer()
+		r.roundTimer = time.NewTimer(r.roundTimeout)
+	}
+}
+
+// roundDeadline returns the channel that fires when the current round times
+// out, or nil when rounds are untimed
+func (r *runner) roundDeadline() <-chan time.Time {
+	if r.roundTimer == nil {
+		return nil
+	}
+	return r.roundTimer.C
+}
+
+// stopRoundTimer cancels the current round's deadline, if any
+func (r *runner) stopRoundTimer() {
+	if r.roundTimer != nil {
+		r.roundTimer.Stop()
+		r.roundTimer = nil
+	}
+}
+
+// Close stops the room's goroutine and waits for it to exit. It is safe to
+// call more than once and from the onGameEnd callback.
+func (r *runner) Close() {
+	r.cancel()
+	<-r.done
+}
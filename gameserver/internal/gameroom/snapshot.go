@@ -0,0 +1,130 @@
+package gameroom
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// Snapshot is the part of a game's state that survives a server restart.
+// Players get new client IDs when they reconnect, they are recognised by
+// the session they present. Names are only kept for display.
+// Choices of an unfinished round are not kept, the round is replayed.
+type Snapshot struct {
+	ID      string `json:"id"`
+	Game    string `json:"game,omitempty"` // game type, Rock Paper Scissors if empty
+	Player1 string `json:"player1"`
+	Player2 string `json:"player2"`
+	// Player1Session and Player2Session are the fingerprints of the
+	// players' sessions
+	Player1Session string `json:"player1_session"`
+	Player2Session string `json:"player2_session"`
+	Player1Wins    int    `json:"player1_wins"`
+	Player2Wins    int    `json:"player2_wins"`
+	CurrentRound   int    `json:"current_round"`
+	BestOf         int    `json:"best_of"`
+	// Hands are the actions both players have left, in limited hand games
+	Hands []map[Choice]int `json:"hands,omitempty"`
+	// SuddenDeath counts the sudden-death rounds started after a tie,
+	// played after BestOf
+	SuddenDeath int `json:"sudden_death,omitempty"`
+	// FirstDecisive names the player who won the earliest round that was
+	// not drawn
+	FirstDecisive string `json:"first_decisive,omitempty"`
+}
+
+// Snapshot returns the room's current state. It returns ErrRoomClosed once
+// the game is over.
+func (gr *GameRoom) Snapshot() (Snapshot, error) {
+	var snap Snapshot
+	err := gr.do(snapshotCmd{snap: &snap})
+	return snap, err
+}
+
+// snapshot copies the room state, it must run on the room's goroutine
+func (gr *GameRoom) snapshot() Snapshot {
+	snap := Snapshot{
+		ID:             gr.ID,
+		Game:           gr.gameType.Name,
+		Player1:        gr.Player1.GetName(),
+		Player2:        gr.Player2.GetName(),
+		Player1Session: gr.Player1.Session().Fingerprint(),
+		Player2Session: gr.Player2.Session().Fingerprint(),
+		Player1Wins:    gr.Player1Wins,
+		Player2Wins:    gr.Player2Wins,
+		CurrentRound:   gr.CurrentRound,
+		BestOf:         gr.bestOf,
+		Hands:          gr.snapshotHands(),
+		SuddenDeath:    gr.suddenDeaths,
+	}
+	if gr.firstDecisive >= 0 {
+		snap.FirstDecisive = gr.player(gr.firstDecisive).GetName()
+	}
+	return snap
+}
+
+// snapshotHands copies both players' hands, nil if they are unlimited
+func (gr *GameRoom) snapshotHands() []map[Choice]int {
+	if gr.hands[0] == nil {
+		return nil
+	}
+	return []map[Choice]int{maps.Clone(gr.hands[0]), maps.Clone(gr.hands[1])}
+}
+
+// Validate reports snapshots that cannot be resumed, e.g. from a file
+// edited by hand
+func (snap Snapshot) Validate() error {
+	if snap.Player1 == "" || snap.Player2 == "" || snap.Player1 == snap.Player2 {
+		return fmt.Errorf("snapshot %s: players %q and %q", snap.ID, snap.Player1, snap.Player2)
+	}
+	if snap.Player1Session == "" || snap.Player2Session == "" || snap.Player1Session == snap.Player2Session {
+		return fmt.Errorf("snapshot %s: %s and %s cannot be recognised without their sessions", snap.ID, snap.Player1, snap.Player2)
+	}
+	gameType, ok := LookupGameType(snap.Game)
+	if !ok {
+		return fmt.Errorf("snapshot %s: unknown game %q", snap.ID, snap.Game)
+	}
+	if gameType.TurnBased() {
+		return fmt.Errorf("snapshot %s: turn-based game %q cannot be restored", snap.ID, snap.Game)
+	}
+	if snap.BestOf < 1 || snap.SuddenDeath < 0 || snap.CurrentRound < 1 || snap.CurrentRound > snap.BestOf+snap.SuddenDeath {
+		return fmt.Errorf("snapshot %s: round %d of %d", snap.ID, snap.CurrentRound, snap.BestOf)
+	}
+	if hands := len(snap.Hands); (gameType.Hand == nil && hands != 0) || (gameType.Hand != nil && hands != 2) {
+		return fmt.Errorf("snapshot %s: %d hands for game %q", snap.ID, hands, snap.Game)
+	}
+	return nil
+}
+
+// RestoreGameRoom creates a room that continues the game in snap with the
+// reconnected players, player1 being the one whose session is
+// snap.Player1Session.
+// StartFirstRound resumes it with the snapshot's round.
+func RestoreGameRoom(id string, snap Snapshot, player1, player2 *types.Client, onGameEnd func(string), opts ...Option) (*GameRoom, error) {
+	if err := snap.Validate(); err != nil {
+		return nil, err
+	}
+	if !player1.Session().Claims(snap.Player1Session) || !player2.Session().Claims(snap.Player2Session) {
+		return nil, fmt.Errorf("players %s and %s do not match snapshot %s", player1.ID, player2.ID, snap.ID)
+	}
+
+	gameType, _ := LookupGameType(snap.Game)
+	restore := func(gr *GameRoom) {
+		gr.Player1Wins = snap.Player1Wins
+		gr.Player2Wins = snap.Player2Wins
+		gr.CurrentRound = snap.CurrentRound
+		gr.bestOf = snap.BestOf
+		for seat, hand := range snap.Hands {
+			gr.hands[seat] = maps.Clone(hand)
+		}
+		gr.suddenDeaths = snap.SuddenDeath
+		switch snap.FirstDecisive {
+		case snap.Player1:
+			gr.firstDecisive = 0
+		case snap.Player2:
+			gr.firstDecisive = 1
+		}
+	}
+	return newGameRoom(id, player1, player2, onGameEnd, restore, append(opts[:len(opts):len(opts)], WithGame(gameType))...), nil
+}
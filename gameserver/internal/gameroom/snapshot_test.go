@@ -0,0 +1,111 @@
+package gameroom
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+func TestGameRoom_SnapshotAndRestore(t *testing.T) {
+	player1 := createMockClient(t, "player1", "Alice")
+	player2 := createMockClient(t, "player2", "Bob")
+	player1.AttachSession(types.NewSession("alice-session", 0))
+	player2.AttachSession(types.NewSession("bob-session", 0))
+
+	gameRoom := NewGameRoom("test-room", player1, player2, nil)
+	gameRoom.StartFirstRound()
+	gameRoom.MakeChoice(player1.ID, Rock)
+	gameRoom.MakeChoice(player2.ID, Scissors)
+
+	snap, err := gameRoom.Snapshot()
+	if err != nil {
+		t.Fatal("Snapshot failed:", err)
+	}
+	expected := Snapshot{ID: "test-room", Game: "rps", Player1: "Alice", Player2: "Bob",
+		Player1Session: player1.Session().Fingerprint(), Player2Session: player2.Session().Fingerprint(),
+		Player1Wins: 1, CurrentRound: 2, BestOf: 3, FirstDecisive: "Alice"}
+	if !reflect.DeepEqual(snap, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, snap)
+	}
+	gameRoom.Close()
+
+	// The players come back with new connections and sessions after a
+	// restart, presenting their old ones
+	alice := createMockClient(t, "alice-2", "Alice")
+	bob := createMockClient(t, "bob-2", "Bob")
+	for client, previous := range map[*types.Client]string{alice: "alice-session", bob: "bob-session"} {
+		session := types.NewSession(client.ID, 0)
+		session.Succeed(previous)
+		client.AttachSession(session)
+	}
+	if _, err := RestoreGameRoom("room-1", snap, bob, alice, nil); err == nil {
+		t.Error("Expected players in the wrong order to be rejected")
+	}
+	impostor := createMockClient(t, "impostor", "Alice")
+	impostor.AttachSession(types.NewSession("impostor", 0))
+	if _, err := RestoreGameRoom("room-1", snap, impostor, bob, nil); err == nil {
+		t.Error("Expected a player with the same name but another session to be rejected")
+	}
+
+	ended := make(chan string, 1)
+	restored, err := RestoreGameRoom("room-1", snap, alice, bob, func(id string) { ended <- id })
+	if err != nil {
+		t.Fatal("Restore failed:", err)
+	}
+	defer restored.Close()
+
+	restored.StartFirstRound()
+	start, _ := protocol.Decode[types.RoundStartMessage](<-alice.Send)
+	if start.RoundNumber != 2 {
+		t.Errorf("Expected the game to continue with round 2, got %d", start.RoundNumber)
+	}
+
+	// One more win is enough for Alice
+	restored.MakeChoice(alice.ID, Paper)
+	restored.MakeChoice(bob.ID, Rock)
+	<-ended
+
+	var result types.GameEndedMessage
+	for _, event := range drainEvents(alice) {
+		if event.Type == "game_ended" {
+			result, _ = protocol.Decode[types.GameEndedMessage](event)
+		}
+	}
+	if result.Result != "win" {
+		t.Errorf("Expected Alice to win 2-0, got %q", result.Result)
+	}
+	if _, err := restored.Snapshot(); err != ErrRoomClosed {
+		t.Errorf("Expected ErrRoomClosed for a finished game, got %v", err)
+	}
+}
+
+func TestSnapshot_Validate(t *testing.T) {
+	valid := []Snapshot{
+		{ID: "room-1", Player1: "Alice", Player2: "Bob", Player1Session: "a", Player2Session: "b", CurrentRound: 1, BestOf: 3},
+		{ID: "room-1", Player1: "Alice", Player2: "Bob", Player1Session: "a", Player2Session: "b", CurrentRound: 4, BestOf: 3, SuddenDeath: 1},
+	}
+	for _, snap := range valid {
+		if err := snap.Validate(); err != nil {
+			t.Error("Expected a valid snapshot:", err)
+		}
+	}
+
+	invalid := []Snapshot{
+		{ID: "room-1", Player1: "Alice", Player2: "Alice", Player1Session: "a", Player2Session: "b", CurrentRound: 1, BestOf: 3},
+		{ID: "room-1", Player1: "Alice", CurrentRound: 1, BestOf: 3},
+		{ID: "room-1", Player1: "Alice", Player2: "Bob", Player1Session: "a", Player2Session: "b", CurrentRound: 4, BestOf: 3},
+		{ID: "room-1", Player1: "Alice", Player2: "Bob", Player1Session: "a", Player2Session: "b", CurrentRound: 0, BestOf: 3},
+		{ID: "room-1", Player1: "Alice", Player2: "Bob", Player1Session: "a", Player2Session: "b", CurrentRound: 1, BestOf: 3, SuddenDeath: -1},
+		{ID: "room-1", Game: "limited_rps", Player1: "Alice", Player2: "Bob", Player1Session: "a", Player2Session: "b", CurrentRound: 1, BestOf: 9},
+		{ID: "room-1", Player1: "Alice", Player2: "Bob", Player1Session: "a", Player2Session: "b", CurrentRound: 1, BestOf: 3, Hands: []map[Choice]int{{Rock: 1}, {Rock: 1}}},
+		{ID: "room-1", Player1: "Alice", Player2: "Bob", CurrentRound: 1, BestOf: 3},
+		{ID: "room-1", Player1: "Alice", Player2: "Bob", Player1Session: "a", Player2Session: "a", CurrentRound: 1, BestOf: 3},
+	}
+	for _, snap := range invalid {
+		if err := snap.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", snap)
+		}
+	}
+}
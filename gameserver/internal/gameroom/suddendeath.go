@@ -0,0 +1,46 @@
+package gameroom
+
+import "log"
+
+// SuddenDeath decides two-player games that end tied. The zero value
+// leaves them drawn.
+type SuddenDeath struct {
+	Rounds   int      // extra rounds played at most, the first one won decides the game
+	Fallback Fallback // how a game still tied after them ends, FallbackDraw if empty
+}
+
+// Fallback decides a game that is still tied after its sudden-death rounds
+type Fallback string
+
+const (
+	// FallbackDraw ends the game drawn
+	FallbackDraw Fallback = "draw"
+	// FallbackFirstDecisive gives the game to the player who won the
+	// earliest round that was not drawn, it is a draw if every round was
+	FallbackFirstDecisive Fallback = "first_decisive"
+)
+
+// nextSuddenDeath reports whether a game that is over goes on with a
+// sudden-death round because the score is tied. There is none once a
+// player has nothing left to play in a limited hand game.
+func (gr *GameRoom) nextSuddenDeath() bool {
+	if gr.Player1Wins != gr.Player2Wins || gr.suddenDeaths >= gr.suddenDeath.Rounds {
+		return false
+	}
+	if len(gr.playable(0)) == 0 || len(gr.playable(1)) == 0 {
+		log.Printf("GameRoom %s: Tied at %d with a hand used up, no sudden death", gr.ID, gr.Player1Wins)
+		return false
+	}
+	gr.suddenDeaths++
+	log.Printf("GameRoom %s: Tied at %d, sudden death %d of %d", gr.ID, gr.Player1Wins, gr.suddenDeaths, gr.suddenDeath.Rounds)
+	return true
+}
+
+// fallbackWinner returns the player who wins a game that ended tied, 0 or
+// 1, or -1 for a draw
+func (gr *GameRoom) fallbackWinner() int {
+	if gr.suddenDeath.Fallback == FallbackFirstDecisive {
+		return gr.firstDecisive
+	}
+	return -1
+}
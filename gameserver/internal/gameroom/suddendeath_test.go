@@ -0,0 +1,81 @@
+package gameroom
+
+import (
+	"testing"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+func TestGameRoom_SuddenDeath(t *testing.T) {
+	// Alice wins the first round, Bob the second and the third is drawn
+	tied := [][2]Choice{{Rock, Scissors}, {Scissors, Rock}, {Rock, Rock}}
+
+	tests := []struct {
+		name        string
+		suddenDeath SuddenDeath
+		extra       [][2]Choice // rounds after the tie
+		winner      string
+		flagged     int // round_start messages flagged as sudden death
+	}{
+		{"no tiebreak", SuddenDeath{}, nil, "", 0},
+		{"won in sudden death", SuddenDeath{Rounds: 3}, [][2]Choice{{Paper, Paper}, {Scissors, Rock}}, "Bob", 2},
+		{"drawn after the cap", SuddenDeath{Rounds: 1, Fallback: FallbackDraw}, [][2]Choice{{Paper, Paper}}, "", 1},
+		{"earliest win after the cap", SuddenDeath{Rounds: 2, Fallback: FallbackFirstDecisive}, [][2]Choice{{Paper, Paper}, {Rock, Rock}}, "Alice", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player1 := createMockClient(t, "player1", "Alice")
+			player2 := createMockClient(t, "player2", "Bob")
+			gameRoom := NewGameRoom("test-room", player1, player2, nil, WithSuddenDeath(tt.suddenDeath))
+			defer gameRoom.Close()
+			gameRoom.StartFirstRound()
+
+			for _, choices := range append(tied, tt.extra...) {
+				gameRoom.MakeChoice(player1.ID, choices[0])
+				gameRoom.MakeChoice(player2.ID, choices[1])
+			}
+
+			suddenDeath := 0
+			events := drainEvents(player1)
+			for _, event := range events {
+				if start, err := protocol.Decode[types.RoundStartMessage](event); err == nil && start.SuddenDeath {
+					suddenDeath++
+				}
+			}
+			if suddenDeath != tt.flagged {
+				t.Errorf("Expected %d sudden-death rounds, got %d", tt.flagged, suddenDeath)
+			}
+			if len(events) == 0 || events[len(events)-1].Type != protocol.TypeGameEnded {
+				t.Fatalf("Expected the game to end, got %v", events)
+			}
+			if result := gameRoom.Result(); result.Winner != tt.winner {
+				t.Errorf("Expected winner %q, got %+v", tt.winner, result)
+			}
+		})
+	}
+}
+
+func TestGameRoom_NoSuddenDeathWithEmptyHands(t *testing.T) {
+	player1 := createMockClient(t, "player1", "Alice")
+	player2 := createMockClient(t, "player2", "Bob")
+	gameType, _ := LookupGameType("limited_rps")
+	gameRoom := NewGameRoom("test-room", player1, player2, nil, WithGame(gameType), WithSuddenDeath(SuddenDeath{Rounds: 3}))
+	defer gameRoom.Close()
+	gameRoom.StartFirstRound()
+
+	// Every round is drawn until both hands are empty
+	for _, choice := range []Choice{Rock, Rock, Rock, Paper, Paper, Paper, Scissors, Scissors, Scissors} {
+		gameRoom.MakeChoice(player1.ID, choice)
+		gameRoom.MakeChoice(player2.ID, choice)
+	}
+
+	events := drainEvents(player1)
+	if len(events) == 0 || events[len(events)-1].Type != protocol.TypeGameEnded {
+		t.Fatalf("Expected the game to end once the hands are used up, got %v", events)
+	}
+	if ended, _ := protocol.Decode[types.GameEndedMessage](events[len(events)-1]); ended.Result != "draw" {
+		t.Errorf("Expected a draw, got %+v", ended)
+	}
+}
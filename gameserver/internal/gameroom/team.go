@@ -0,0 +1,320 @@
+package gameroom
+
+import (
+	"errors"
+	"log"
+	"math/rand/v2"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// TieBreak decides a team's move when its players voted for different moves
+type TieBreak string
+
+const (
+	// TieBreakCaptain plays the captain's vote. The captain is the team's
+	// first player.
+	TieBreakCaptain TieBreak = "captain"
+	// TieBreakRandom plays one of the votes, picked at random
+	TieBreakRandom TieBreak = "random"
+)
+
+// TeamRoom manages a Rock Paper Scissors game between two teams of two.
+// Players vote for their team's move with make_choice and may change their
+// vote until the round locks, teammates see each other's votes as they
+// come in. The round locks once every player voted or when it times out.
+// A team plays the move its players agree on, the only vote cast, or the
+// one its tie-break picks. Like GameRoom the game is best of 3 and all
+// state is owned by a single goroutine working through the room's inbox.
+type TeamRoom struct {
+	ID           string
+	Teams        [2][]*types.Client // captain first
+	Wins         [2]int
+	CurrentRound int
+	GameEnded    bool
+	tieBreak     TieBreak
+	bestOf       int
+	votes        map[string]Choice // client ID -> vote in the current round
+	left         map[string]bool   // client ID -> disconnected
+	runner
+}
+
+// NewTeamRoom creates a room for two teams and starts its goroutine. The
+// tie-break defaults to TieBreakCaptain, of the options only the round
+// timeout applies.
+func NewTeamRoom(id string, teams [2][]*types.Client, tieBreak TieBreak, onGameEnd func(string), opts ...Option) *TeamRoom {
+	room := &TeamRoom{
+		ID:           id,
+		Teams:        teams,
+		CurrentRound: 1,
+		tieBreak:     tieBreak,
+		bestOf:       3,
+		votes:        make(map[string]Choice),
+		left:         make(map[string]bool),
+		runner:       newRunner(newSettings(opts).roundTimeout),
+	}
+	if room.tieBreak == "" {
+		room.tieBreak = TieBreakCaptain
+	}
+
+	for _, team := range teams {
+		for _, player := range team {
+			if err := player.EnterGame(id); err != nil {
+				log.Printf("TeamRoom %s: %v", id, err)
+			}
+		}
+	}
+
+	room.start(id, room, onGameEnd)
+
+	log.Printf("TeamRoom %s created for %s and %s against %s and %s (tie-break: %s)", id,
+		teams[0][0].GetName(), teams[0][1].GetName(), teams[1][0].GetName(), teams[1][1].GetName(), room.tieBreak)
+	return room
+}
+
+// handle applies one command to the room state
+func (tr *TeamRoom) handle(cmd any) error {
+	switch cmd := cmd.(type) {
+	case startCmd:
+		tr.startRound()
+	case choiceCmd:
+		tr.vote(cmd.clientID, cmd.choice)
+	case leaveCmd:
+		tr.leave(cmd.clientID)
+	}
+	return nil
+}
+
+// timeoutRound locks a round whose deadline passed with the votes cast so
+// far
+func (tr *TeamRoom) timeoutRound() {
+	log.Printf("TeamRoom %s: Round %d timed out", tr.ID, tr.CurrentRound)
+	tr.processRound()
+}
+
+// ended reports whether the game is over
+func (tr *TeamRoom) ended() bool {
+	return tr.GameEnded
+}
+
+// StartFirstRound begins the first round of the game
+func (tr *TeamRoom) StartFirstRound() {
+	tr.do(startCmd{})
+}
+
+// MakeChoice records a player's vote for their team's move. Votes for a
+// room that already finished are ignored.
+func (tr *TeamRoom) MakeChoice(clientID string, choice Choice) error {
+	if err := tr.do(choiceCmd{clientID: clientID, choice: choice}); err != nil && !errors.Is(err, ErrRoomClosed) {
+		return err
+	}
+	return nil
+}
+
+// Leave removes a disconnected player. Their teammate plays on alone, a
+// team without players loses by forfeit.
+func (tr *TeamRoom) Leave(clientID string) {
+	tr.do(leaveCmd{clientID: clientID})
+}
+
+// active returns the players of a team who are still connected
+func (tr *TeamRoom) active(team int) []*types.Client {
+	var active []*types.Client
+	for _, player := range tr.Teams[team] {
+		if !tr.left[player.ID] {
+			active = append(active, player)
+		}
+	}
+	return active
+}
+
+// player returns the team and player with the given ID if they are still
+// connected, or -1 and nil
+func (tr *TeamRoom) player(clientID string) (int, *types.Client) {
+	for team := range tr.Teams {
+		for _, player := range tr.active(team) {
+			if player.ID == clientID {
+				return team, player
+			}
+		}
+	}
+	return -1, nil
+}
+
+// vote records a player's vote, shows it to their teammate and locks the
+// round once every player voted
+func (tr *TeamRoom) vote(clientID string, choice Choice) {
+	if tr.GameEnded {
+		return
+	}
+	team, player := tr.player(clientID)
+	if player == nil {
+		return
+	}
+	if choice != Rock && choice != Paper && choice != Scissors {
+		protocol.Send(player, types.ErrorMessage{
+			Code:    protocol.ErrorCodeInvalidChoice,
+			Message: "Invalid choice. Use rock, paper, or scissors",
+			Ref:     protocol.TypeMakeChoice,
+			Details: []types.ErrorDetail{{Key: "choice", Value: string(choice)}},
+		})
+		return
+	}
+
+	tr.votes[clientID] = choice
+	log.Printf("TeamRoom %s: %s voted %s", tr.ID, player.GetName(), choice)
+	for _, teammate := range tr.active(team) {
+		if teammate != player {
+			protocol.Send(teammate, types.TeamVoteMessage{Player: player.GetName(), Choice: string(choice)})
+		}
+	}
+	if len(tr.votes) == len(tr.active(0))+len(tr.active(1)) {
+		tr.processRound()
+	}
+}
+
+// move returns the move a team plays this round, empty if nobody on the
+// team voted
+func (tr *TeamRoom) move(team int) Choice {
+	var votes []Choice
+	for _, player := range tr.active(team) {
+		if vote := tr.votes[player.ID]; vote != "" {
+			votes = append(votes, vote)
+		}
+	}
+	switch {
+	case len(votes) == 0:
+		return ""
+	case len(votes) == 1 || votes[0] == votes[1]:
+		return votes[0]
+	case tr.tieBreak == TieBreakRandom:
+		return votes[rand.IntN(len(votes))]
+	default:
+		// Both voted, so the first vote is the captain's
+		return votes[0]
+	}
+}
+
+// processRound locks the round, plays both teams' moves and starts the
+// next round or ends the game
+func (tr *TeamRoom) processRound() {
+	tr.stopRoundTimer()
+
+	moves := [2]Choice{tr.move(0), tr.move(1)}
+	var results [2]string
+	switch {
+	case moves[0] == moves[1]:
+		results = [2]string{"draw", "draw"}
+	case moves[1] == "" || beats(moves[0], moves[1]):
+		results = [2]string{"win", "lose"}
+		tr.Wins[0]++
+	default:
+		results = [2]string{"lose", "win"}
+		tr.Wins[1]++
+	}
+	log.Printf("TeamRoom %s Round %d: %s vs %s - Score: %d-%d",
+		tr.ID, tr.CurrentRound, moves[0], moves[1], tr.Wins[0], tr.Wins[1])
+
+	var summary []types.PlayerResult
+	for team, players := range tr.Teams {
+		for _, player := range players {
+			summary = append(summary, types.PlayerResult{
+				Name:       player.GetName(),
+				Choice:     string(tr.votes[player.ID]),
+				Result:     results[team],
+				Points:     tr.Wins[team],
+				Eliminated: tr.left[player.ID],
+			})
+		}
+	}
+	for team := range tr.Teams {
+		for _, player := range tr.active(team) {
+			protocol.Send(player, types.RoundResultMessage{
+				Result:         results[team],
+				YourChoice:     string(moves[team]),
+				OpponentChoice: string(moves[1-team]),
+				Players:        summary,
+			})
+		}
+	}
+	tr.votes = make(map[string]Choice)
+
+	if tr.Wins[0] > tr.bestOf/2 || tr.Wins[1] > tr.bestOf/2 || tr.CurrentRound >= tr.bestOf {
+		tr.endGame()
+		return
+	}
+	tr.CurrentRound++
+	tr.startRound()
+}
+
+// startRound begins a new round
+func (tr *TeamRoom) startRound() {
+	if tr.GameEnded {
+		return
+	}
+
+	log.Printf("TeamRoom %s: Starting round %d", tr.ID, tr.CurrentRound)
+	for team := range tr.Teams {
+		for _, player := range tr.active(team) {
+			protocol.Send(player, types.RoundStartMessage{RoundNumber: tr.CurrentRound})
+		}
+	}
+
+	tr.startRoundTimer()
+}
+
+// leave takes a disconnected player out of the game
+func (tr *TeamRoom) leave(clientID string) {
+	team, player := tr.player(clientID)
+	if player == nil || tr.GameEnded {
+		return
+	}
+	log.Printf("TeamRoom %s: %s left", tr.ID, player.GetName())
+	tr.left[clientID] = true
+	delete(tr.votes, clientID)
+
+	switch {
+	case len(tr.active(team)) == 0:
+		tr.endGame()
+	case len(tr.votes) == len(tr.active(0))+len(tr.active(1)):
+		// Everyone else already voted
+		tr.processRound()
+	}
+}
+
+// endGame finishes the game. A team that has no players left loses by
+// forfeit, otherwise the team with more round wins wins.
+func (tr *TeamRoom) endGame() {
+	tr.GameEnded = true
+	tr.stopRoundTimer()
+
+	winner := -1
+	switch {
+	case len(tr.active(0)) == 0:
+		winner = 1
+	case len(tr.active(1)) == 0:
+		winner = 0
+	case tr.Wins[0] > tr.Wins[1]:
+		winner = 0
+	case tr.Wins[1] > tr.Wins[0]:
+		winner = 1
+	}
+
+	for team := range tr.Teams {
+		result := "draw"
+		switch winner {
+		case team:
+			result = "win"
+		case 1 - team:
+			result = "lose"
+		}
+		for _, player := range tr.active(team) {
+			if err := player.Transition(types.StatePostGame); err != nil {
+				log.Printf("TeamRoom %s: %v", tr.ID, err)
+			}
+			protocol.Send(player, types.GameEndedMessage{Result: result})
+		}
+	}
+	log.Printf("TeamRoom %s ended after %d rounds, score %d-%d", tr.ID, tr.CurrentRound, tr.Wins[0], tr.Wins[1])
+}
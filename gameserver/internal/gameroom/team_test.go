@@ -0,0 +1,153 @@
+package gameroom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// createTeams returns Alice and Bob against Carol and Dave, captains first
+func createTeams(t *testing.T) [2][]*types.Client {
+	players := createPlayers(t, 4)
+	return [2][]*types.Client{players[:2], players[2:]}
+}
+
+// roundResult returns the round_result event among events
+func roundResult(t *testing.T, events []types.BaseGameEvent) types.RoundResultMessage {
+	t.Helper()
+	for _, event := range events {
+		if event.Type == protocol.TypeRoundResult {
+			result, _ := protocol.Decode[types.RoundResultMessage](event)
+			return result
+		}
+	}
+	t.Fatal("Expected a round_result event")
+	return types.RoundResultMessage{}
+}
+
+func TestTeamRoom_TeammatesSeeVotes(t *testing.T) {
+	teams := createTeams(t)
+	room := NewTeamRoom("test-room", teams, TieBreakCaptain, nil)
+	defer room.Close()
+	room.StartFirstRound()
+	drainEvents(teams[0][1])
+
+	// Alice switches to paper, the round stays open until everyone voted
+	room.MakeChoice(teams[0][0].ID, Rock)
+	room.MakeChoice(teams[0][0].ID, Paper)
+	events := drainEvents(teams[0][1])
+	if len(events) != 2 || events[1].Type != protocol.TypeTeamVote {
+		t.Fatalf("Expected Bob to see both votes, got %v", events)
+	}
+	vote, _ := protocol.Decode[types.TeamVoteMessage](events[1])
+	if vote.Player != "Alice" || vote.Choice != "paper" {
+		t.Errorf("Expected Alice's vote for paper, got %+v", vote)
+	}
+	for _, opponent := range teams[1] {
+		if events := drainEvents(opponent); len(events) != 1 {
+			t.Errorf("Opponents should not see the votes, got %v", events)
+		}
+	}
+
+	room.MakeChoice(teams[0][1].ID, Paper)
+	room.MakeChoice(teams[1][0].ID, Rock)
+	room.MakeChoice(teams[1][1].ID, Rock)
+
+	result := roundResult(t, drainEvents(teams[1][1]))
+	if result.Result != "lose" || result.YourChoice != "rock" || result.OpponentChoice != "paper" {
+		t.Errorf("Expected rock to lose against paper, got %+v", result)
+	}
+	if len(result.Players) != 4 || result.Players[0].Choice != "paper" || result.Players[0].Points != 1 {
+		t.Errorf("Expected every vote and the team scores, got %+v", result.Players)
+	}
+}
+
+func TestTeamRoom_TieBreaks(t *testing.T) {
+	tests := []struct {
+		name     string
+		tieBreak TieBreak
+		moves    []string
+	}{
+		{"captain decides", TieBreakCaptain, []string{"scissors"}},
+		{"random vote", TieBreakRandom, []string{"scissors", "rock"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			teams := createTeams(t)
+			room := NewTeamRoom("test-room", teams, tt.tieBreak, nil)
+			defer room.Close()
+			room.StartFirstRound()
+
+			room.MakeChoice(teams[0][0].ID, Scissors)
+			room.MakeChoice(teams[0][1].ID, Rock)
+			room.MakeChoice(teams[1][0].ID, Paper)
+			room.MakeChoice(teams[1][1].ID, Paper)
+
+			move := roundResult(t, drainEvents(teams[1][0])).OpponentChoice
+			found := false
+			for _, expected := range tt.moves {
+				found = found || move == expected
+			}
+			if !found {
+				t.Errorf("Expected the team to play one of %v, got %s", tt.moves, move)
+			}
+		})
+	}
+}
+
+func TestTeamRoom_Leave(t *testing.T) {
+	teams := createTeams(t)
+	ended := make(chan string, 1)
+	room := NewTeamRoom("test-room", teams, TieBreakCaptain, func(roomID string) {
+		ended <- roomID
+	})
+	defer room.Close()
+	room.StartFirstRound()
+
+	// Bob plays on alone once Alice is gone
+	room.MakeChoice(teams[0][1].ID, Rock)
+	room.MakeChoice(teams[1][0].ID, Scissors)
+	room.MakeChoice(teams[1][1].ID, Scissors)
+	room.Leave(teams[0][0].ID)
+	if result := roundResult(t, drainEvents(teams[0][1])); result.Result != "win" {
+		t.Errorf("Expected Bob's vote to win the round, got %s", result.Result)
+	}
+
+	// A team with nobody left loses
+	room.Leave(teams[0][1].ID)
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("Game end callback should have been called")
+	}
+	for _, player := range teams[1] {
+		if got := gameResult(t, drainEvents(player)); got != "win" {
+			t.Errorf("Expected %s to win by forfeit, got %s", player.GetName(), got)
+		}
+	}
+}
+
+func TestTeamRoom_RoundTimeout(t *testing.T) {
+	teams := createTeams(t)
+	ended := make(chan string, 1)
+	room := NewTeamRoom("test-room", teams, TieBreakCaptain, func(roomID string) {
+		ended <- roomID
+	}, WithRoundTimeout(20*time.Millisecond))
+	defer room.Close()
+	room.StartFirstRound()
+
+	// Only Dave votes, in the first round. The others are draws.
+	room.MakeChoice(teams[1][1].ID, Paper)
+
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the game to end")
+	}
+	if got := gameResult(t, drainEvents(teams[1][0])); got != "win" {
+		t.Errorf("Expected Carol and Dave to win, got %s", got)
+	}
+}
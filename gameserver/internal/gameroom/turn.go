@@ -0,0 +1,249 @@
+package gameroom
+
+import (
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// TurnRoom runs a turn-based game such as Tic-Tac-Toe between two players.
+// The first player moves first, every move is answered with a board_update
+// to both players. A player who leaves, or does not move before the round
+// timeout, loses. Like GameRoom all state is owned by a single goroutine
+// working through the room's inbox.
+type TurnRoom struct {
+	ID        string
+	Players   [2]*types.Client // in turn order
+	Moves     int
+	GameEnded bool
+	gameType  GameType
+	game      TurnGame
+	turn      int              // player to move
+	lastMove  *types.BoardMove // nil before the first move
+	result    Result
+	runner    // the round timeout limits each move
+}
+
+// moveCmd is a player's move, processed by a TurnRoom's goroutine
+type moveCmd struct {
+	clientID string
+	move     Move
+}
+
+// NewTurnRoom creates a room for a turn-based game of gameType and starts
+// its goroutine. Of the options only the round timeout applies, it limits
+// each move.
+func NewTurnRoom(id string, player1, player2 *types.Client, gameType GameType, onGameEnd func(string), opts ...Option) *TurnRoom {
+	room := &TurnRoom{
+		ID:       id,
+		Players:  [2]*types.Client{player1, player2},
+		gameType: gameType,
+		game:     gameType.NewTurnGame(),
+		runner:   newRunner(newSettings(opts).roundTimeout),
+	}
+
+	for _, player := range room.Players {
+		if err := player.EnterGame(id); err != nil {
+			log.Printf("TurnRoom %s: %v", id, err)
+		}
+	}
+
+	room.start(id, room, onGameEnd)
+
+	log.Printf("TurnRoom %s created for %s and %s (%s)", id, player1.GetName(), player2.GetName(), gameType.Name)
+	return room
+}
+
+// handle applies one command to the room state
+func (tr *TurnRoom) handle(cmd any) error {
+	switch cmd := cmd.(type) {
+	case startCmd:
+		tr.sendBoard()
+		tr.startRoundTimer()
+	case moveCmd:
+		tr.move(cmd.clientID, cmd.move)
+	case choiceCmd:
+		tr.rejectChoice(cmd.clientID)
+	case leaveCmd:
+		tr.leave(cmd.clientID)
+	}
+	return nil
+}
+
+// timeoutRound ends the game against the player to move, who ran out of
+// time
+func (tr *TurnRoom) timeoutRound() {
+	log.Printf("TurnRoom %s: %s ran out of time", tr.ID, tr.Players[tr.turn].GetName())
+	tr.endGame(1-tr.turn, true)
+}
+
+// ended reports whether the game is over
+func (tr *TurnRoom) ended() bool {
+	return tr.GameEnded
+}
+
+// StartFirstRound shows both players the empty board, the first player is
+// to move
+func (tr *TurnRoom) StartFirstRound() {
+	tr.do(startCmd{})
+}
+
+// MakeMove processes a player's move. Moves for a room that already
+// finished are ignored.
+func (tr *TurnRoom) MakeMove(clientID string, move Move) error {
+	if err := tr.do(moveCmd{clientID: clientID, move: move}); err != nil && !errors.Is(err, ErrRoomClosed) {
+		return err
+	}
+	return nil
+}
+
+// MakeChoice answers make_choice with an error, turn-based games take
+// make_move
+func (tr *TurnRoom) MakeChoice(clientID string, choice Choice) error {
+	if err := tr.do(choiceCmd{clientID: clientID, choice: choice}); err != nil && !errors.Is(err, ErrRoomClosed) {
+		return err
+	}
+	return nil
+}
+
+// Leave removes a disconnected player, who forfeits the game
+func (tr *TurnRoom) Leave(clientID string) {
+	tr.do(leaveCmd{clientID: clientID})
+}
+
+// seat returns the player with the given ID and their place in the turn
+// order, or nil and -1
+func (tr *TurnRoom) seat(clientID string) (*types.Client, int) {
+	for i, player := range tr.Players {
+		if player.ID == clientID {
+			return player, i
+		}
+	}
+	return nil, -1
+}
+
+// move makes a player's move if it is their turn and the game allows it
+func (tr *TurnRoom) move(clientID string, move Move) {
+	player, seat := tr.seat(clientID)
+	if player == nil || tr.GameEnded {
+		return
+	}
+	details := []types.ErrorDetail{{Key: "row", Value: strconv.Itoa(move.Row)}, {Key: "column", Value: strconv.Itoa(move.Column)}}
+	if seat != tr.turn {
+		tr.sendError(player, protocol.ErrorCodeNotYourTurn, "It is "+tr.Players[tr.turn].GetName()+"'s turn", details...)
+		return
+	}
+	placed, err := tr.game.Play(seat, move)
+	if err != nil {
+		tr.sendError(player, protocol.ErrorCodeInvalidMove, "Invalid move: "+err.Error(), details...)
+		return
+	}
+
+	tr.stopRoundTimer()
+	tr.Moves++
+	tr.lastMove = &types.BoardMove{Player: player.GetName(), Row: placed.Row, Column: placed.Column}
+	log.Printf("TurnRoom %s move %d: %s at %d,%d", tr.ID, tr.Moves, player.GetName(), placed.Row, placed.Column)
+
+	if tr.game.Over() {
+		tr.GameEnded = true
+		tr.sendBoard()
+		tr.endGame(tr.game.Winner(), false)
+		return
+	}
+	tr.turn = 1 - tr.turn
+	tr.sendBoard()
+	tr.startRoundTimer()
+}
+
+// rejectChoice tells a player who sent make_choice to send make_move
+func (tr *TurnRoom) rejectChoice(clientID string) {
+	if player, _ := tr.seat(clientID); player != nil && !tr.GameEnded {
+		protocol.Send(player, types.ErrorMessage{
+			Code:    protocol.ErrorCodeInvalidChoice,
+			Message: tr.gameType.Name + " is turn-based, use make_move",
+			Ref:     protocol.TypeMakeChoice,
+		})
+	}
+}
+
+// sendBoard sends the board to both players
+func (tr *TurnRoom) sendBoard() {
+	board := tr.game.Board()
+	turn := ""
+	if !tr.GameEnded {
+		turn = tr.Players[tr.turn].GetName()
+	}
+	for i, player := range tr.Players {
+		protocol.Send(player, types.BoardUpdateMessage{
+			Board:      board,
+			MoveNumber: tr.Moves,
+			Turn:       turn,
+			YourTurn:   !tr.GameEnded && i == tr.turn,
+			LastMove:   tr.lastMove,
+		})
+	}
+}
+
+// leave ends the game in favour of the opponent of a player who left
+func (tr *TurnRoom) leave(clientID string) {
+	player, seat := tr.seat(clientID)
+	if player == nil || tr.GameEnded {
+		return
+	}
+	log.Printf("TurnRoom %s: %s left, forfeiting the game", tr.ID, player.GetName())
+	tr.endGame(1-seat, true)
+}
+
+// endGame finishes the game, won by player 0 or 1 or drawn if winner is
+// -1. forfeit is set if the loser left or ran out of time.
+func (tr *TurnRoom) endGame(winner int, forfeit bool) {
+	tr.GameEnded = true
+	tr.stopRoundTimer()
+
+	tr.result = Result{
+		Player1: tr.Players[0].GetName(),
+		Player2: tr.Players[1].GetName(),
+		Forfeit: forfeit,
+	}
+	switch winner {
+	case 0:
+		tr.result.Player1Wins = 1
+		tr.result.Winner = tr.result.Player1
+	case 1:
+		tr.result.Player2Wins = 1
+		tr.result.Winner = tr.result.Player2
+	}
+
+	for i, player := range tr.Players {
+		if err := player.Transition(types.StatePostGame); err != nil {
+			log.Printf("TurnRoom %s: %v", tr.ID, err)
+		}
+		result := "draw"
+		switch winner {
+		case i:
+			result = "win"
+		case 1 - i:
+			result = "lose"
+		}
+		protocol.Send(player, types.GameEndedMessage{Result: result})
+	}
+	log.Printf("TurnRoom %s ended after %d moves, winner: %q", tr.ID, tr.Moves, tr.result.Winner)
+}
+
+// Result returns the outcome of the game, a win counts as one round. It
+// is only valid once the game ended.
+func (tr *TurnRoom) Result() Result {
+	return tr.result
+}
+
+func (tr *TurnRoom) sendError(client *types.Client, code, message string, details ...types.ErrorDetail) {
+	protocol.Send(client, types.ErrorMessage{
+		Code:    code,
+		Message: message,
+		Ref:     protocol.TypeMakeMove,
+		Details: details,
+	})
+}
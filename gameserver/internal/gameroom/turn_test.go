@@ -0,0 +1,164 @@
+package gameroom
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// newTurnGame returns the rules of a registered turn-based game
+func newTurnGame(t *testing.T, name string) TurnGame {
+	gameType, ok := LookupGameType(name)
+	if !ok || !gameType.TurnBased() {
+		t.Fatalf("%s is not a turn-based game", name)
+	}
+	return gameType.NewTurnGame()
+}
+
+func TestTicTacToe(t *testing.T) {
+	game := newTurnGame(t, "tic_tac_toe")
+
+	if _, err := game.Play(0, Move{Row: 3, Column: 0}); !errors.Is(err, ErrOffBoard) {
+		t.Errorf("Expected ErrOffBoard, got %v", err)
+	}
+	game.Play(0, Move{Row: 1, Column: 1})
+	if _, err := game.Play(1, Move{Row: 1, Column: 1}); !errors.Is(err, ErrCellTaken) {
+		t.Errorf("Expected ErrCellTaken, got %v", err)
+	}
+
+	// X takes the diagonal
+	for i, move := range []Move{{0, 1}, {0, 0}, {0, 2}, {2, 2}} {
+		if game.Over() {
+			t.Fatal("Game ended too early")
+		}
+		game.Play(1-i%2, move)
+	}
+	if !game.Over() || game.Winner() != 0 {
+		t.Errorf("Expected X to win, board %v", game.Board())
+	}
+	if board := game.Board(); !slices.Equal(board, []string{"XOO", ".X.", "..X"}) {
+		t.Errorf("Unexpected board %v", board)
+	}
+
+	// A full board without a line is a draw
+	game = newTurnGame(t, "tic_tac_toe")
+	for i, move := range []Move{{0, 0}, {0, 1}, {0, 2}, {1, 1}, {1, 0}, {1, 2}, {2, 1}, {2, 0}, {2, 2}} {
+		game.Play(i%2, move)
+	}
+	if !game.Over() || game.Winner() != -1 {
+		t.Errorf("Expected a draw, board %v", game.Board())
+	}
+}
+
+func TestConnectFour(t *testing.T) {
+	game := newTurnGame(t, "connect_four")
+
+	// Pieces drop to the lowest free cell, the row asked for is ignored
+	if placed, _ := game.Play(0, Move{Row: 0, Column: 3}); placed.Row != 5 {
+		t.Errorf("Expected the piece to land in row 5, got %d", placed.Row)
+	}
+	if placed, _ := game.Play(1, Move{Column: 3}); placed.Row != 4 {
+		t.Errorf("Expected the piece to land in row 4, got %d", placed.Row)
+	}
+	if _, err := game.Play(0, Move{Column: 7}); !errors.Is(err, ErrOffBoard) {
+		t.Errorf("Expected ErrOffBoard, got %v", err)
+	}
+	for i := range 4 {
+		game.Play(i%2, Move{Column: 3})
+	}
+	if _, err := game.Play(0, Move{Column: 3}); !errors.Is(err, ErrColumnFull) {
+		t.Errorf("Expected ErrColumnFull, got %v", err)
+	}
+
+	// Red builds a diagonal from the bottom left
+	game = newTurnGame(t, "connect_four")
+	for i, column := range []int{0, 1, 1, 2, 2, 3, 2, 3, 3, 6, 3} {
+		if game.Over() {
+			t.Fatalf("Game ended too early, board %v", game.Board())
+		}
+		game.Play(i%2, Move{Column: column})
+	}
+	if !game.Over() || game.Winner() != 0 {
+		t.Errorf("Expected red to win on the diagonal, board %v", game.Board())
+	}
+}
+
+func TestTurnRoom_TakesTurns(t *testing.T) {
+	players := createPlayers(t, 2)
+	gameType, _ := LookupGameType("tic_tac_toe")
+	ended := make(chan string, 1)
+	room := NewTurnRoom("test-room", players[0], players[1], gameType, func(roomID string) {
+		ended <- roomID
+	})
+	defer room.Close()
+	room.StartFirstRound()
+
+	events := drainEvents(players[1])
+	if len(events) != 1 || events[0].Type != protocol.TypeBoardUpdate {
+		t.Fatalf("Expected the empty board, got %v", events)
+	}
+
+	// Bob may not move first, nor send make_choice
+	room.MakeMove(players[1].ID, Move{Row: 0, Column: 0})
+	room.MakeChoice(players[1].ID, Rock)
+	events = drainEvents(players[1])
+	if len(events) != 2 {
+		t.Fatalf("Expected two errors, got %v", events)
+	}
+	for i, code := range []string{protocol.ErrorCodeNotYourTurn, protocol.ErrorCodeInvalidChoice} {
+		if msg, _ := protocol.Decode[types.ErrorMessage](events[i]); msg.Code != code {
+			t.Errorf("Expected %s, got %+v", code, msg)
+		}
+	}
+
+	room.MakeMove(players[0].ID, Move{Row: 1, Column: 1})
+	room.MakeMove(players[1].ID, Move{Row: 1, Column: 1})
+	events = drainEvents(players[1])
+	if len(events) != 2 || events[1].Type != protocol.TypeError {
+		t.Fatalf("Expected the board and an error, got %v", events)
+	}
+	board, _ := protocol.Decode[types.BoardUpdateMessage](events[0])
+	if !board.YourTurn || board.LastMove == nil || board.LastMove.Player != "Alice" || board.Board[1] != ".X." {
+		t.Errorf("Expected Bob's turn after Alice's move, got %+v", board)
+	}
+	if msg, _ := protocol.Decode[types.ErrorMessage](events[1]); msg.Code != protocol.ErrorCodeInvalidMove {
+		t.Errorf("Expected INVALID_MOVE, got %+v", msg)
+	}
+
+	// Bob leaves and forfeits
+	room.Leave(players[1].ID)
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("Game end callback should have been called")
+	}
+	if result := room.Result(); result.Winner != "Alice" || !result.Forfeit {
+		t.Errorf("Expected Alice to win by forfeit, got %+v", result)
+	}
+}
+
+func TestTurnRoom_MoveTimeout(t *testing.T) {
+	players := createPlayers(t, 2)
+	gameType, _ := LookupGameType("connect_four")
+	ended := make(chan string, 1)
+	room := NewTurnRoom("test-room", players[0], players[1], gameType, func(roomID string) {
+		ended <- roomID
+	}, WithRoundTimeout(20*time.Millisecond))
+	defer room.Close()
+	room.StartFirstRound()
+
+	// Alice moves, Bob does not
+	room.MakeMove(players[0].ID, Move{Column: 3})
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the game to end")
+	}
+	if got := gameResult(t, drainEvents(players[1])); got != "lose" {
+		t.Errorf("Expected Bob to lose on time, got %s", got)
+	}
+}
@@ -0,0 +1,143 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/broker"
+	"github.com/4hel/paper/gameserver/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+// startInstances runs one handler per instance name, all sharing b, and
+// returns their WebSocket URLs
+func startInstances(t *testing.T, b broker.Broker, instances ...string) []string {
+	urls := make([]string, len(instances))
+	for i, instance := range instances {
+		handler := NewHandler(WithBroker(b, instance))
+		server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+		t.Cleanup(server.Close)
+		t.Cleanup(handler.Close)
+		urls[i] = "ws" + strings.TrimPrefix(server.URL, "http")
+	}
+	return urls
+}
+
+// readGameEvent reads events until one of the given type arrives and
+// decodes its payload into v
+func readGameEvent(conn *websocket.Conn, eventType string, v any) error {
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event types.BaseGameEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			return err
+		}
+		if event.Type == eventType {
+			return json.Unmarshal(event.Data, v)
+		}
+	}
+}
+
+func TestHandler_GameAcrossInstances(t *testing.T) {
+	brokers := map[string]func() broker.Broker{
+		"memory": func() broker.Broker { return broker.NewMemoryBroker() },
+		"redis": func() broker.Broker {
+			server, err := broker.StartLocalRedis()
+			if err != nil {
+				t.Fatal("Failed to start local redis:", err)
+			}
+			t.Cleanup(func() { server.Close() })
+			b, err := broker.NewRedisBroker(context.Background(), server.Addr())
+			if err != nil {
+				t.Fatal("Failed to connect:", err)
+			}
+			return b
+		},
+	}
+
+	for name, newBroker := range brokers {
+		t.Run(name, func(t *testing.T) {
+			b := newBroker()
+			t.Cleanup(func() { b.Close() })
+			urls := startInstances(t, b, "a", "b")
+
+			alice := joinGame(t, urls[0], "Alice")
+			defer alice.Close()
+			if err := readUntil(alice, "player_waiting"); err != nil {
+				t.Fatal("Alice was not queued:", err)
+			}
+			bob := joinGame(t, urls[1], "Bob")
+			defer bob.Close()
+
+			var starting types.GameStartingMessage
+			if err := readGameEvent(alice, "game_starting", &starting); err != nil {
+				t.Fatal("Alice's game did not start:", err)
+			}
+			if starting.OpponentName != "Bob" {
+				t.Errorf("Alice should play Bob, got %q", starting.OpponentName)
+			}
+			if err := readGameEvent(bob, "game_starting", &starting); err != nil {
+				t.Fatal("Bob's game did not start:", err)
+			}
+			if starting.OpponentName != "Alice" {
+				t.Errorf("Bob should play Alice, got %q", starting.OpponentName)
+			}
+
+			done := make(chan error, 1)
+			go func() { done <- playUntilEnd(bob, "scissors") }()
+			if err := playUntilEnd(alice, "rock"); err != nil {
+				t.Fatal("Alice did not finish:", err)
+			}
+			if err := <-done; err != nil {
+				t.Fatal("Bob did not finish:", err)
+			}
+
+			// Both can queue again, now on the other side
+			playAgain := types.BaseGameEvent{Type: "play_again", Data: json.RawMessage(`{}`)}
+			bob.WriteJSON(playAgain)
+			if err := readUntil(bob, "player_waiting"); err != nil {
+				t.Fatal("Bob was not queued again:", err)
+			}
+			alice.WriteJSON(playAgain)
+			for _, conn := range []*websocket.Conn{alice, bob} {
+				if err := readUntil(conn, "game_starting"); err != nil {
+					t.Fatal("Rematch did not start:", err)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_RemotePlayerDisconnectForfeits(t *testing.T) {
+	b := broker.NewMemoryBroker()
+	defer b.Close()
+	urls := startInstances(t, b, "a", "b")
+
+	// Alice waits on a, so the game is hosted by b
+	alice := joinGame(t, urls[0], "Alice")
+	if err := readUntil(alice, "player_waiting"); err != nil {
+		t.Fatal("Alice was not queued:", err)
+	}
+	bob := joinGame(t, urls[1], "Bob")
+	defer bob.Close()
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		if err := readUntil(conn, "round_start"); err != nil {
+			t.Fatal("Game did not start:", err)
+		}
+	}
+
+	alice.Close()
+
+	var ended types.GameEndedMessage
+	if err := readGameEvent(bob, "game_ended", &ended); err != nil {
+		t.Fatal("Bob's game did not end:", err)
+	}
+	if ended.Result != "win" {
+		t.Errorf("Bob should win by forfeit, got %q", ended.Result)
+	}
+}
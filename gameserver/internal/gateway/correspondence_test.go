@@ -0,0 +1,105 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/4hel/paper/gameserver/internal/correspondence"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+// sendCorrespondence connects a player and sends a correspondence message
+// that names them
+func sendCorrespondence(t *testing.T, wsURL, eventType string, msg any) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	sendHello(t, conn)
+	data, _ := json.Marshal(msg)
+	conn.WriteJSON(types.BaseGameEvent{Type: eventType, Data: data})
+	return conn
+}
+
+func TestHandler_CorrespondenceMatchAcrossConnections(t *testing.T) {
+	handler := NewHandler()
+	defer handler.Close()
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	// Alice opens a match and goes offline before anyone accepts
+	alice := sendCorrespondence(t, wsURL, "create_match", types.CreateMatchMessage{Name: "Alice", Game: "tic_tac_toe"})
+	var issued types.MatchListMessage
+	if err := readGameEvent(alice, "match_list", &issued); err != nil {
+		t.Fatal("Alice was not issued a player key:", err)
+	}
+	if issued.PlayerKey == "" {
+		t.Fatalf("Expected a player key for Alice, got %+v", issued)
+	}
+	var update types.MatchUpdateMessage
+	if err := readGameEvent(alice, "match_update", &update); err != nil {
+		t.Fatal("Alice's match was not created:", err)
+	}
+	if update.Match.State != correspondence.StateWaiting {
+		t.Errorf("Expected Alice to wait for an opponent, got %+v", update.Match)
+	}
+	alice.Close()
+
+	bob := sendCorrespondence(t, wsURL, "create_match", types.CreateMatchMessage{Name: "Bob", Game: "tic_tac_toe"})
+	defer bob.Close()
+	update = types.MatchUpdateMessage{}
+	if err := readGameEvent(bob, "match_update", &update); err != nil {
+		t.Fatal("Bob's match was not created:", err)
+	}
+	if update.Match.State != correspondence.StateActive || update.Match.Opponent != "Alice" || update.Match.YourTurn {
+		t.Errorf("Expected Bob to be paired with Alice, who moves first, got %+v", update.Match)
+	}
+	matchID := update.Match.MatchID
+
+	// Someone else who calls themselves Alice does not see Alice's match
+	impostor := sendCorrespondence(t, wsURL, "list_matches", types.ListMatchesMessage{Name: "Alice"})
+	var list types.MatchListMessage
+	if err := readGameEvent(impostor, "match_list", &list); err != nil {
+		t.Fatal("Expected a match list:", err)
+	}
+	if len(list.Matches) != 0 || list.PlayerKey == "" || list.PlayerKey == issued.PlayerKey {
+		t.Errorf("Expected a new player without matches, got %+v", list)
+	}
+	impostor.Close()
+
+	// Alice comes back later with their key and finds it is their turn
+	alice = sendCorrespondence(t, wsURL, "list_matches", types.ListMatchesMessage{Name: "Alice", PlayerKey: issued.PlayerKey})
+	defer alice.Close()
+	list = types.MatchListMessage{}
+	if err := readGameEvent(alice, "match_list", &list); err != nil {
+		t.Fatal("Expected Alice's matches:", err)
+	}
+	if len(list.Matches) != 1 || list.Matches[0].MatchID != matchID || !list.Matches[0].YourTurn || list.PlayerKey != "" {
+		t.Fatalf("Expected it to be Alice's turn, got %+v", list.Matches)
+	}
+
+	moveData, _ := json.Marshal(types.MakeMoveMessage{MatchID: matchID, Row: 1, Column: 1})
+	alice.WriteJSON(types.BaseGameEvent{Type: "make_move", Data: moveData})
+	var turn types.YourTurnMessage
+	if err := readGameEvent(bob, "your_turn", &turn); err != nil {
+		t.Fatal("Expected Bob to be told it is their turn:", err)
+	}
+	if turn.MatchID != matchID || turn.Opponent != "Alice" || turn.Deadline == 0 {
+		t.Errorf("Unexpected your_turn %+v", turn)
+	}
+
+	alice.WriteJSON(types.BaseGameEvent{Type: "make_move", Data: moveData})
+	var errMsg types.ErrorMessage
+	if err := readGameEvent(alice, "error", &errMsg); err != nil {
+		t.Fatal("Expected an error for Alice's second move:", err)
+	}
+	if errMsg.Code != protocol.ErrorCodeNotYourTurn {
+		t.Errorf("Expected NOT_YOUR_TURN, got %+v", errMsg)
+	}
+}
@@ -0,0 +1,103 @@
+package gateway
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+// CloseReasonShuttingDown is sent with CloseGoingAway to every connection
+// still open when a drain ends
+const CloseReasonShuttingDown = "server_shutting_down"
+
+// closeTimeout bounds how long a drain waits for connections to go away
+// after sending them a close frame
+const closeTimeout = 10 * time.Second
+
+// Drain shuts the handler down gently. New connections and new games are
+// refused, every client is told the deadline, and running games may finish
+// until drainTimeout has passed. Then all connections are closed with
+// CloseGoingAway. Drain may be called more than once, later calls wait for
+// the first one. It returns early with ctx's error if ctx is done first.
+func (h *Handler) Drain(ctx context.Context) error {
+	h.drainOnce.Do(func() {
+		h.draining.Store(true)
+		go h.drain()
+	})
+
+	select {
+	case <-h.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MaxDrainDuration is the longest a Drain can take: drainTimeout for the
+// games, then closeTimeout each for the final snapshot and the connections
+// to close. A shutdown context should allow at least this much.
+func (h *Handler) MaxDrainDuration() time.Duration {
+	return h.drainTimeout + 2*closeTimeout
+}
+
+// Draining reports whether Drain has been called
+func (h *Handler) Draining() bool {
+	return h.draining.Load()
+}
+
+// drain runs the drain phases and closes drained when done
+func (h *Handler) drain() {
+	defer close(h.drained)
+
+	deadline := time.Now().Add(h.drainTimeout)
+	gamesDone := h.lobby.Drain()
+
+	clients := h.clientList()
+	log.Printf("Draining %d clients, closing connections at %s", len(clients), deadline.Format(time.RFC3339))
+	for _, client := range clients {
+		protocol.Send(client, types.ServerShuttingDownMessage{
+			Deadline: deadline.Unix(),
+			Message:  "The server is restarting. Running games can be finished, new games cannot be started.",
+		})
+	}
+
+	timer := time.NewTimer(h.drainTimeout)
+	select {
+	case <-gamesDone:
+		log.Printf("All games finished, closing connections")
+	case <-timer.C:
+		log.Printf("Drain timeout reached, closing connections with games still running")
+	}
+	timer.Stop()
+
+	// Save the games still running before closing their connections
+	// forfeits them, so the next server can resume them
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	if err := h.lobby.SaveFinalSnapshot(ctx); err != nil {
+		log.Printf("Saving the final snapshot failed: %v", err)
+	}
+	cancel()
+
+	h.mu.Lock()
+	h.idle = make(chan struct{})
+	if len(h.clients) == 0 {
+		close(h.idle)
+	}
+	idle := h.idle
+	h.mu.Unlock()
+
+	for _, client := range h.clientList() {
+		client.CloseGracefully(websocket.CloseGoingAway, CloseReasonShuttingDown)
+	}
+
+	select {
+	case <-idle:
+		log.Printf("Drain complete")
+	case <-time.After(closeTimeout):
+		log.Printf("Drain complete, some connections did not close in time")
+	}
+}
@@ -0,0 +1,200 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/snapshot"
+	"github.com/4hel/paper/gameserver/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+// startDrain drains the handler in the background and returns Drain's result
+func startDrain(handler *Handler) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- handler.Drain(context.Background())
+	}()
+	return done
+}
+
+// expectGoingAway reads until the connection is closed and checks the code
+func expectGoingAway(t *testing.T, conn *websocket.Conn) {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var event types.BaseGameEvent
+		err := conn.ReadJSON(&event)
+		if err == nil {
+			continue
+		}
+		if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+			t.Errorf("Expected going away close, got %v", err)
+		}
+		return
+	}
+}
+
+func TestHandler_DrainLetsRunningGamesFinish(t *testing.T) {
+	handler := NewHandler(WithDrainTimeout(5 * time.Second))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	alice := joinGame(t, wsURL, "Alice")
+	defer alice.Close()
+	if err := readUntil(alice, "player_waiting"); err != nil {
+		t.Fatal("Alice was not queued:", err)
+	}
+	bob := joinGame(t, wsURL, "Bob")
+	defer bob.Close()
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		if err := readUntil(conn, "game_starting"); err != nil {
+			t.Fatal("Game did not start:", err)
+		}
+	}
+
+	drained := startDrain(handler)
+
+	// Both players are warned with the deadline
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		for {
+			var event types.BaseGameEvent
+			if err := conn.ReadJSON(&event); err != nil {
+				t.Fatal("Expected server_shutting_down, got:", err)
+			}
+			if event.Type != "server_shutting_down" {
+				continue
+			}
+			var msg types.ServerShuttingDownMessage
+			json.Unmarshal(event.Data, &msg)
+			if msg.Deadline < time.Now().Unix() {
+				t.Errorf("Expected a deadline in the future, got %d", msg.Deadline)
+			}
+			break
+		}
+	}
+
+	// New connections are refused while draining
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL, nil); err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected new connections to be refused while draining, got %v", err)
+	}
+
+	select {
+	case <-drained:
+		t.Fatal("Drain should wait for the running game")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The game is played to the end, then the connections are closed. The
+	// first round_start was read above, so answer it right away.
+	for conn, choice := range map[*websocket.Conn]string{alice: "rock", bob: "scissors"} {
+		choiceData, _ := json.Marshal(types.MakeChoiceMessage{Choice: choice})
+		conn.WriteJSON(types.BaseGameEvent{Type: "make_choice", Data: choiceData})
+	}
+	done := make(chan error, 1)
+	go func() { done <- playUntilEnd(bob, "scissors") }()
+	if err := playUntilEnd(alice, "rock"); err != nil {
+		t.Fatal("Alice did not finish:", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal("Bob did not finish:", err)
+	}
+
+	expectGoingAway(t, alice)
+	expectGoingAway(t, bob)
+
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Errorf("Drain failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Drain did not finish after the game ended")
+	}
+}
+
+func TestHandler_DrainTimeoutClosesRunningGames(t *testing.T) {
+	handler := NewHandler(WithDrainTimeout(100 * time.Millisecond))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	alice := joinGame(t, wsURL, "Alice")
+	defer alice.Close()
+	if err := readUntil(alice, "player_waiting"); err != nil {
+		t.Fatal("Alice was not queued:", err)
+	}
+	bob := joinGame(t, wsURL, "Bob")
+	defer bob.Close()
+	if err := readUntil(bob, "game_starting"); err != nil {
+		t.Fatal("Game did not start:", err)
+	}
+
+	// Nobody plays, so the drain deadline ends the game
+	drained := startDrain(handler)
+	expectGoingAway(t, alice)
+	expectGoingAway(t, bob)
+
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Errorf("Drain failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Drain did not finish after its timeout")
+	}
+}
+
+func TestHandler_CloseWaitsForDrain(t *testing.T) {
+	store := snapshot.NewMemoryStore()
+	handler := NewHandler(WithSnapshots(store, time.Hour), WithDrainTimeout(100*time.Millisecond))
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	alice := joinGame(t, wsURL, "Alice")
+	defer alice.Close()
+	if err := readUntil(alice, "player_waiting"); err != nil {
+		t.Fatal("Alice was not queued:", err)
+	}
+	bob := joinGame(t, wsURL, "Bob")
+	defer bob.Close()
+	if err := readUntil(bob, "game_starting"); err != nil {
+		t.Fatal("Game did not start:", err)
+	}
+
+	// The caller gives up on the drain right away, Close must still let
+	// it save the running game
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := handler.Drain(ctx); err == nil {
+		t.Fatal("Expected Drain to return with the context's error")
+	}
+	closed := make(chan struct{})
+	go func() {
+		handler.Close()
+		close(closed)
+	}()
+	expectGoingAway(t, alice)
+	expectGoingAway(t, bob)
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return after the drain")
+	}
+	if state, _ := store.Load(context.Background()); len(state.Rooms) != 1 {
+		t.Errorf("Expected the running game in the final snapshot, got %+v", state.Rooms)
+	}
+}
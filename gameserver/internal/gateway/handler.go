@@ -3,17 +3,29 @@ package gateway
 import (
 	"context"
 	"crypto/rand"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/4hel/paper/gameserver/internal/broker"
+	"github.com/4hel/paper/gameserver/internal/correspondence"
+	"github.com/4hel/paper/gameserver/internal/gameroom"
 	"github.com/4hel/paper/gameserver/internal/lobby"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/tournament"
 	"github.com/4hel/paper/gameserver/internal/types"
 	"github.com/gorilla/websocket"
 )
 
+// maxMessageSize is the largest message a client may send, in bytes.
+// Bigger messages close the connection.
+const maxMessageSize = 64 * 1024
+
 /*
 Handler manages WebSocket connections and message routing.
 
@@ -22,21 +34,51 @@ Main entry point: HandleWebSocket() upgrades HTTP connections to WebSocket.
 The Handler uses a pump-based architecture for bidirectional communication:
   - readPump:  Connection → Application (pulls data from WebSocket, pushes to message handler)
   - writePump: Application → Connection (pulls data from Send channel, pushes to WebSocket)
+
+Messages from clients in a game are routed by the session router straight
+to their game room, the lobby only sees matchmaking traffic.
 */
 type Handler struct {
-	upgrader websocket.Upgrader
-	lobby    *lobby.Lobby
-	clients  map[string]*types.Client
-	mu       sync.RWMutex
-	ctx      context.Context
-	cancel   context.CancelFunc
+	upgrader           websocket.Upgrader
+	lobby              *lobby.Lobby
+	router             *router
+	dispatcher         *protocol.Dispatcher
+	clients            map[string]*types.Client
+	minProtocolVersion int
+	features           []string
+	serverVersion      string
+	roundTimeout       time.Duration
+	deliveryPolicy     types.DeliveryPolicy
+	deliveryStats      types.DeliveryStats
+	sessions           map[string]*sessionEntry
+	sessionsMu         sync.Mutex
+	replayBuffer       int
+	rateLimit          int // messages per second of one connection, 0 for no limit
+	rateBurst          int
+	sessionTTL         time.Duration
+	drainTimeout       time.Duration
+	drainOnce          sync.Once
+	draining           atomic.Bool
+	drained            chan struct{}
+	idle               chan struct{} // closed once the last client is gone after a drain
+	observers          []types.TransitionObserver
+	broker             broker.Broker
+	instance           string
+	lobbyOptions       []lobby.Option
+	tournaments        *tournament.Manager
+	tournamentOptions  []tournament.Option
+	matches            *correspondence.Manager
+	matchOptions       []correspondence.Option
+	mu                 sync.RWMutex
+	ctx                context.Context
+	cancel             context.CancelFunc
 }
 
 // NewHandler creates a new WebSocket handler
-func NewHandler() *Handler {
+func NewHandler(opts ...Option) *Handler {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Handler{
+	h := &Handler{
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -44,35 +86,96 @@ func NewHandler() *Handler {
 				// Allow all origins for development
 				return true
 			},
+			Subprotocols: protocol.Subprotocols(),
 		},
-		lobby:   lobby.NewLobby(),
-		clients: make(map[string]*types.Client),
-		ctx:     ctx,
-		cancel:  cancel,
+		router:             newRouter(),
+		dispatcher:         protocol.NewDispatcher(),
+		clients:            make(map[string]*types.Client),
+		minProtocolVersion: protocol.MinSupportedVersion,
+		features:           []string{},
+		serverVersion:      "dev",
+		deliveryPolicy:     types.DefaultDeliveryPolicy,
+		sessions:           make(map[string]*sessionEntry),
+		replayBuffer:       256,
+		rateLimit:          20,
+		rateBurst:          40,
+		sessionTTL:         2 * time.Minute,
+		drainTimeout:       30 * time.Second,
+		drained:            make(chan struct{}),
+		ctx:                ctx,
+		cancel:             cancel,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+	lobbyOptions := []lobby.Option{
+		lobby.WithRoomStarted(h.router.bind),
+		lobby.WithFreeForAllStarted(h.router.bindFreeForAll),
+		lobby.WithTeamRoomStarted(h.router.bindTeamRoom),
+		lobby.WithTurnRoomStarted(h.router.bindTurnRoom),
+		lobby.WithRemoteGame(h.router.bindRemote),
+		lobby.WithRoundTimeout(h.roundTimeout),
 	}
+	if h.broker != nil {
+		lobbyOptions = append(lobbyOptions, lobby.WithBroker(h.broker, h.instance))
+	}
+	lobbyOptions = append(lobbyOptions, h.lobbyOptions...)
+	h.lobby = lobby.NewLobby(lobbyOptions...)
+	h.tournaments = tournament.NewManager(h.lobby, h.tournamentOptions...)
+	h.matches = correspondence.NewManager(h.matchOptions...)
+
+	protocol.On(h.dispatcher, h.onHello)
+	protocol.On(h.dispatcher, h.onAck)
+	protocol.On(h.dispatcher, h.onJoinLobby)
+	protocol.On(h.dispatcher, h.onMakeChoice)
+	protocol.On(h.dispatcher, h.onMakeMove)
+	protocol.On(h.dispatcher, h.onSpectate)
+	protocol.On(h.dispatcher, h.onPlayAgain)
+	protocol.On(h.dispatcher, h.onDisconnect)
+	protocol.On(h.dispatcher, h.onJoinTournament)
+	protocol.On(h.dispatcher, h.onCreateMatch)
+	protocol.On(h.dispatcher, h.onListMatches)
+
+	return h
 }
 
 // HandleWebSocket upgrades HTTP connection to WebSocket
 func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if h.Draining() {
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
 		return
 	}
 
+	// Clients that do not ask for a subprotocol get JSON
+	codec := protocol.CodecFor(conn.Subprotocol())
+
 	// Create client
 	clientID := generateClientID()
 	client := types.NewClient(clientID, conn)
+	policy := h.deliveryPolicy
+	policy.Stats = &h.deliveryStats
+	client.SetDeliveryPolicy(policy)
+	client.Observe(h.router.onTransition)
+	for _, observer := range h.observers {
+		client.Observe(observer)
+	}
 
 	// Add client to handler and lobby
 	h.addClient(client)
 	h.lobby.AddClient(client)
 
 	// Start client goroutines
-	go h.writePump(client)
-	go h.readPump(client)
+	go h.writePump(client, codec)
+	go h.readPump(client, codec)
 
-	log.Printf("New WebSocket connection established: %s", clientID)
+	log.Printf("New WebSocket connection established: %s (%s)", clientID, codec.Subprotocol())
 }
 
 // addClient adds a client to the handler's client map
@@ -82,34 +185,71 @@ func (h *Handler) addClient(client *types.Client) {
 	h.clients[client.ID] = client
 }
 
+// clientList returns the connected clients, so they can be sent to or
+// closed without holding mu
+func (h *Handler) clientList() []*types.Client {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := make([]*types.Client, 0, len(h.clients))
+	for _, client := range h.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
 // removeClient removes a client from handler and lobby
 func (h *Handler) removeClient(client *types.Client) {
+	// Leaving the game and the lobby may block, so mu is only taken once
+	// the client is gone from both
+	client.Close()
+	for _, gone := range h.releaseSession(client) {
+		h.leave(gone)
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	delete(h.clients, client.ID)
+	if h.idle != nil && len(h.clients) == 0 {
+		select {
+		case <-h.idle:
+		default:
+			close(h.idle)
+		}
+	}
+}
+
+// leave takes a client out of its game and the lobby. A player who drops
+// out of a running game forfeits it.
+func (h *Handler) leave(client *types.Client) {
+	if room := h.router.room(client.ID); room != nil {
+		room.Leave(client.ID)
+	}
+	h.router.unbind(client.ID)
 	h.lobby.RemoveClient(client.ID)
-	client.Close()
+	h.matches.Forget(client)
 }
 
 // readPump handles incoming messages from client
-func (h *Handler) readPump(client *types.Client) {
+func (h *Handler) readPump(client *types.Client, codec protocol.WireCodec) {
 	defer h.removeClient(client)
 
-	// Set read deadline and pong handler
+	// Set read limit, read deadline and pong handler
+	client.Conn.SetReadLimit(maxMessageSize)
 	client.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	client.Conn.SetPongHandler(func(string) error {
 		client.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 		return nil
 	})
 
+	limiter := newRateLimiter(h.rateLimit, h.rateBurst)
 	for {
 		select {
 		case <-client.Ctx.Done():
 			return
 		default:
-			var event types.BaseGameEvent
-			err := client.Conn.ReadJSON(&event)
+			_, data, err := client.Conn.ReadMessage()
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 					log.Printf("WebSocket error for client %s: %v", client.ID, err)
@@ -119,6 +259,21 @@ func (h *Handler) readPump(client *types.Client) {
 				return
 			}
 
+			var event types.BaseGameEvent
+			if err := codec.Unmarshal(data, &event); err != nil {
+				log.Printf("[READPUMP] Malformed message from client %s: %v", client.ID, err)
+				return
+			}
+
+			if !limiter.allow(time.Now()) {
+				protocol.Send(client, types.ErrorMessage{
+					Code:    protocol.ErrorCodeRateLimited,
+					Message: "Too many messages, slow down",
+					Ref:     event.Type,
+				})
+				continue
+			}
+
 			log.Printf("[READPUMP] Successfully read message from client %s: %s", client.ID, event.Type)
 			h.handleMessage(client, event)
 		}
@@ -126,7 +281,7 @@ func (h *Handler) readPump(client *types.Client) {
 }
 
 // writePump handles outgoing messages to client
-func (h *Handler) writePump(client *types.Client) {
+func (h *Handler) writePump(client *types.Client, codec protocol.WireCodec) {
 	ticker := time.NewTicker(54 * time.Second)
 	defer func() {
 		ticker.Stop()
@@ -137,15 +292,30 @@ func (h *Handler) writePump(client *types.Client) {
 		select {
 		case <-client.Ctx.Done():
 			return
-		case event, ok := <-client.Send:
+		case <-client.Closing():
+			// Flush what is already queued, then say goodbye
+		flush:
+			for {
+				select {
+				case event := <-client.Send:
+					if !h.writeEvent(client, codec, event) {
+						return
+					}
+				default:
+					break flush
+				}
+			}
+
 			client.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if !ok {
-				client.Conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
+			closeMessage := []byte{}
+			if code, reason := client.CloseReason(); code != 0 {
+				closeMessage = websocket.FormatCloseMessage(code, reason)
 			}
+			client.Conn.WriteMessage(websocket.CloseMessage, closeMessage)
+			return
 
-			if err := client.Conn.WriteJSON(event); err != nil {
-				log.Printf("Write error for client %s: %v", client.ID, err)
+		case event := <-client.Send:
+			if !h.writeEvent(client, codec, event) {
 				return
 			}
 
@@ -158,48 +328,339 @@ func (h *Handler) writePump(client *types.Client) {
 	}
 }
 
+// writeEvent encodes and writes one event. It returns false once the
+// connection is unusable.
+func (h *Handler) writeEvent(client *types.Client, codec protocol.WireCodec, event types.BaseGameEvent) bool {
+	client.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+
+	data, err := codec.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode %s for client %s: %v", event.Type, client.ID, err)
+		return true
+	}
+
+	if err := client.Conn.WriteMessage(codec.FrameType(), data); err != nil {
+		log.Printf("Write error for client %s: %v", client.ID, err)
+		return false
+	}
+	return true
+}
+
+// DeliveryStats returns delivery counts summed over all clients the
+// handler has served
+func (h *Handler) DeliveryStats() types.DeliveryCounts {
+	return h.deliveryStats.Snapshot()
+}
+
+// ConnectionCount returns the number of open WebSocket connections
+func (h *Handler) ConnectionCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// SetMaintenance pauses matchmaking while games in progress continue
+func (h *Handler) SetMaintenance(m lobby.Maintenance) {
+	h.lobby.SetMaintenance(m)
+}
+
+// EndMaintenance resumes matchmaking
+func (h *Handler) EndMaintenance() {
+	h.lobby.EndMaintenance()
+}
+
+// Maintenance returns the maintenance settings and whether they are active
+func (h *Handler) Maintenance() (lobby.Maintenance, bool) {
+	return h.lobby.Maintenance()
+}
+
 // handleMessage processes incoming messages from clients
 func (h *Handler) handleMessage(client *types.Client, event types.BaseGameEvent) {
 	log.Printf("[GATEWAY] Received message type '%s' from client %s", event.Type, client.ID)
-	
-	switch event.Type {
-	case "join_lobby":
-		var joinMsg types.JoinLobbyMessage
-		if err := json.Unmarshal(event.Data, &joinMsg); err != nil {
-			log.Printf("Failed to unmarshal join_lobby message from client %s: %v", client.ID, err)
-			return
-		}
 
-		if err := h.lobby.JoinLobby(client.ID, joinMsg); err != nil {
-			log.Printf("Failed to join lobby for client %s: %v", client.ID, err)
-		}
+	// Clients must introduce themselves before anything else. Clients that
+	// predate the handshake never send hello and are treated as version 0.
+	if _, done := client.Capabilities(); !done && event.Type != protocol.TypeHello {
+		log.Printf("Client %s sent %s before hello", client.ID, event.Type)
+		h.rejectOutdated(client, 0)
+		return
+	}
 
-	case "make_choice":
-		var choiceMsg types.MakeChoiceMessage
-		if err := json.Unmarshal(event.Data, &choiceMsg); err != nil {
-			log.Printf("Failed to unmarshal make_choice message from client %s: %v", client.ID, err)
-			return
-		}
-		
-		if err := h.lobby.MakeChoice(client.ID, choiceMsg.Choice); err != nil {
-			log.Printf("Failed to make choice for client %s: %v", client.ID, err)
-		}
+	// A connection that resumed a session plays as the client it took
+	// the session over from
+	err := h.dispatcher.Dispatch(h.seat(client), event)
+	if err == nil {
+		return
+	}
 
-	case "play_again":
-		log.Printf("[GATEWAY] Processing play_again message from client %s", client.ID)
-		if err := h.lobby.PlayAgain(client.ID); err != nil {
-			log.Printf("Failed to play again for client %s: %v", client.ID, err)
-		} else {
-			log.Printf("[GATEWAY] play_again processed successfully for client %s", client.ID)
+	var validationErr *protocol.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		log.Printf("Rejected %s message from client %s: %v", event.Type, client.ID, err)
+		code := validationErr.Code
+		if code == "" {
+			code = protocol.ErrorCodeInvalidMessage
 		}
+		protocol.Send(client, types.ErrorMessage{Code: code, Message: validationErr.Reason, Ref: event.Type})
+	case errors.Is(err, protocol.ErrMalformed):
+		log.Printf("Malformed %s message from client %s: %v", event.Type, client.ID, err)
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeInvalidMessage,
+			Message: fmt.Sprintf("Could not read %s message", event.Type),
+			Ref:     event.Type,
+		})
+	case errors.Is(err, protocol.ErrUnknownType):
+		log.Printf("Unknown message type '%s' from client %s", event.Type, client.ID)
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeUnknownMessageType,
+			Message: fmt.Sprintf("Unknown message type '%s'", event.Type),
+			Ref:     event.Type,
+		})
+	default:
+		log.Printf("Failed to handle %s message from client %s: %v", event.Type, client.ID, err)
+	}
+}
 
-	case "disconnect":
-		log.Printf("Client %s requested disconnect", client.ID)
-		client.Close()
+// onJoinLobby handles join_lobby messages
+func (h *Handler) onJoinLobby(client *types.Client, msg types.JoinLobbyMessage) error {
+	return h.lobby.JoinLobby(client.ID, msg)
+}
 
-	default:
-		log.Printf("Unknown message type '%s' from client %s", event.Type, client.ID)
+// onMakeChoice routes make_choice messages straight to the client's game room
+func (h *Handler) onMakeChoice(client *types.Client, msg types.MakeChoiceMessage) error {
+	room := h.router.room(client.ID)
+	if room == nil {
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeNotInGame,
+			Message: fmt.Sprintf("Cannot make a choice while %s", client.State()),
+			Ref:     protocol.TypeMakeChoice,
+			Details: []types.ErrorDetail{{Key: "state", Value: client.State().String()}},
+		})
+		return fmt.Errorf("client %s not in a game room", client.ID)
+	}
+	return room.MakeChoice(client.ID, gameroom.Choice(msg.Choice))
+}
+
+// onMakeMove routes make_move messages straight to the client's game room,
+// if it is turn-based, or to a correspondence match
+func (h *Handler) onMakeMove(client *types.Client, msg types.MakeMoveMessage) error {
+	if msg.MatchID != "" {
+		return h.moveInMatch(client, msg)
+	}
+	room := h.router.room(client.ID)
+	if room == nil {
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeNotInGame,
+			Message: fmt.Sprintf("Cannot make a move while %s", client.State()),
+			Ref:     protocol.TypeMakeMove,
+			Details: []types.ErrorDetail{{Key: "state", Value: client.State().String()}},
+		})
+		return fmt.Errorf("client %s not in a game room", client.ID)
+	}
+	moveRoom, ok := room.(lobby.MoveRoom)
+	if !ok {
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeInvalidMove,
+			Message: "This game is not turn-based, use make_choice",
+			Ref:     protocol.TypeMakeMove,
+		})
+		return fmt.Errorf("client %s is not in a turn-based game", client.ID)
+	}
+	return moveRoom.MakeMove(client.ID, gameroom.Move{Row: msg.Row, Column: msg.Column})
+}
+
+// onSpectate handles spectate messages from clients that negotiated the feature
+func (h *Handler) onSpectate(client *types.Client, msg types.SpectateMessage) error {
+	if !client.HasFeature(protocol.FeatureSpectate) {
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeFeatureDisabled,
+			Message: "Spectating is not enabled for this client",
+			Ref:     protocol.TypeSpectate,
+			Details: []types.ErrorDetail{{Key: "feature", Value: protocol.FeatureSpectate}},
+		})
+		return fmt.Errorf("client %s did not negotiate %s", client.ID, protocol.FeatureSpectate)
 	}
+	return h.lobby.Spectate(client.ID, msg.PlayerName)
+}
+
+// onPlayAgain handles play_again messages
+func (h *Handler) onPlayAgain(client *types.Client, msg types.PlayAgainMessage) error {
+	log.Printf("[GATEWAY] Processing play_again message from client %s", client.ID)
+	if err := h.lobby.PlayAgain(client.ID); err != nil {
+		return err
+	}
+	log.Printf("[GATEWAY] play_again processed successfully for client %s", client.ID)
+	return nil
+}
+
+// onJoinTournament registers the client's player name for a tournament or
+// league
+func (h *Handler) onJoinTournament(client *types.Client, msg types.JoinTournamentMessage) error {
+	// Players are registered with their name and client. Clients that have
+	// not joined the lobby pick a name here and wait for their games
+	// without being queued.
+	if err := h.ensureName(client, msg.Name, protocol.TypeJoinTournament); err != nil {
+		return err
+	}
+
+	name, err := h.tournaments.Register(msg.TournamentID, client.GetName(), client.ID)
+	switch {
+	case errors.Is(err, tournament.ErrNotFound):
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeTournamentNotFound,
+			Message: fmt.Sprintf("There is no tournament or league %s", msg.TournamentID),
+			Ref:     protocol.TypeJoinTournament,
+			Details: []types.ErrorDetail{{Key: "tournament_id", Value: msg.TournamentID}},
+		})
+	case errors.Is(err, tournament.ErrStarted):
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeTournamentClosed,
+			Message: fmt.Sprintf("%s has already started", name),
+			Ref:     protocol.TypeJoinTournament,
+			Details: []types.ErrorDetail{{Key: "tournament_id", Value: msg.TournamentID}},
+		})
+	case errors.Is(err, tournament.ErrNameTaken):
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeNameTaken,
+			Message: fmt.Sprintf("Another player registered as %s for %s", client.GetName(), name),
+			Ref:     protocol.TypeJoinTournament,
+			Details: []types.ErrorDetail{{Key: "name", Value: client.GetName()}},
+		})
+	}
+	return err
+}
+
+// ensureName names a client that has not joined the lobby, for messages
+// of type ref that identify the player by name
+func (h *Handler) ensureName(client *types.Client, name, ref string) error {
+	if client.GetName() != "" {
+		return nil
+	}
+	if strings.TrimSpace(name) == "" {
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeNameInvalid,
+			Message: "Name cannot be empty",
+			Ref:     ref,
+		})
+		return fmt.Errorf("empty name for client %s", client.ID)
+	}
+	err := h.lobby.SetName(client.ID, name)
+	if errors.Is(err, lobby.ErrNameTaken) {
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeNameTaken,
+			Message: "Name already taken",
+			Ref:     ref,
+			Details: []types.ErrorDetail{{Key: "name", Value: name}},
+		})
+	}
+	return err
+}
+
+// onCreateMatch finds the client's player an opponent for a correspondence
+// match, or lets them wait for one
+func (h *Handler) onCreateMatch(client *types.Client, msg types.CreateMatchMessage) error {
+	if err := h.ensureName(client, msg.Name, protocol.TypeCreateMatch); err != nil {
+		return err
+	}
+	key, err := h.identifyPlayer(client, msg.PlayerKey, protocol.TypeCreateMatch)
+	if err != nil {
+		return err
+	}
+	if key != "" {
+		protocol.Send(client, types.MatchListMessage{Matches: []types.MatchInfo{}, PlayerKey: key})
+	}
+	err = h.matches.Create(client, msg.Game)
+	switch {
+	case errors.Is(err, correspondence.ErrUnknownGame):
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeUnknownGame,
+			Message: "Unknown game",
+			Ref:     protocol.TypeCreateMatch,
+			Details: []types.ErrorDetail{{Key: "game", Value: msg.Game}},
+		})
+	case errors.Is(err, correspondence.ErrNotTurnBased):
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeInvalidMessage,
+			Message: "Correspondence matches need a turn-based game",
+			Ref:     protocol.TypeCreateMatch,
+			Details: []types.ErrorDetail{{Key: "game", Value: msg.Game}},
+		})
+	}
+	return err
+}
+
+// onListMatches sends the client its player's correspondence matches
+func (h *Handler) onListMatches(client *types.Client, msg types.ListMatchesMessage) error {
+	if err := h.ensureName(client, msg.Name, protocol.TypeListMatches); err != nil {
+		return err
+	}
+	key, err := h.identifyPlayer(client, msg.PlayerKey, protocol.TypeListMatches)
+	if err != nil {
+		return err
+	}
+	protocol.Send(client, types.MatchListMessage{Matches: h.matches.List(client), PlayerKey: key})
+	return nil
+}
+
+// identifyPlayer lets the client take part in correspondence matches as
+// the player the key belongs to. Without a key it stays the player it was,
+// or becomes a new one. It returns the key if it was newly issued.
+func (h *Handler) identifyPlayer(client *types.Client, key, ref string) (string, error) {
+	issued, err := h.matches.Identify(client, key)
+	if err != nil {
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeInvalidMessage,
+			Message: "Invalid player key",
+			Ref:     ref,
+		})
+		return "", err
+	}
+	if key != "" {
+		return "", nil
+	}
+	return issued, nil
+}
+
+// moveInMatch makes a move in one of the client's correspondence matches
+func (h *Handler) moveInMatch(client *types.Client, msg types.MakeMoveMessage) error {
+	err := h.matches.Move(client, msg.MatchID, gameroom.Move{Row: msg.Row, Column: msg.Column})
+	if err == nil {
+		return nil
+	}
+	code, message := protocol.ErrorCodeInvalidMove, "Invalid move: "+err.Error()
+	switch {
+	case errors.Is(err, correspondence.ErrNotFound):
+		code, message = protocol.ErrorCodeMatchNotFound, fmt.Sprintf("You have no match %s", msg.MatchID)
+	case errors.Is(err, correspondence.ErrNotYourTurn):
+		code, message = protocol.ErrorCodeNotYourTurn, "It is not your turn"
+	case errors.Is(err, correspondence.ErrFinished):
+		code, message = protocol.ErrorCodeInvalidMove, "The match is over"
+	}
+	protocol.Send(client, types.ErrorMessage{
+		Code:    code,
+		Message: message,
+		Ref:     protocol.TypeMakeMove,
+		Details: []types.ErrorDetail{{Key: "match_id", Value: msg.MatchID}},
+	})
+	return err
+}
+
+// Tournaments returns the tournaments and leagues played on this handler's
+// lobby
+func (h *Handler) Tournaments() *tournament.Manager {
+	return h.tournaments
+}
+
+// onDisconnect handles disconnect messages
+func (h *Handler) onDisconnect(client *types.Client, msg types.DisconnectMessage) error {
+	log.Printf("Client %s requested disconnect", client.ID)
+	// A player who says goodbye does not resume, so they leave right away
+	if conn := h.endSession(client); conn != nil {
+		conn.Close()
+	}
+	client.Close()
+	h.leave(client)
+	return nil
 }
 
 // generateClientID generates a unique client ID
@@ -230,15 +691,20 @@ func randomString(length int) string {
 	return string(b)
 }
 
-// Close shuts down the handler
+// Close shuts down the handler. Once Drain was called it first waits for
+// the drain to finish, so the final snapshot and close frames are not cut
+// short.
 func (h *Handler) Close() {
+	if h.Draining() {
+		<-h.drained
+	}
 	h.cancel()
+	h.stopSessions()
+	h.tournaments.Close()
+	h.matches.Close()
 	h.lobby.Close()
 
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	for _, client := range h.clients {
+	for _, client := range h.clientList() {
 		client.Close()
 	}
 }
@@ -2,17 +2,32 @@ package gateway
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/4hel/paper/gameserver/internal/protocol"
 	"github.com/4hel/paper/gameserver/internal/types"
 )
 
+// sendHello performs the client side of the hello handshake
+func sendHello(t *testing.T, conn *websocket.Conn) {
+	helloData, _ := json.Marshal(types.HelloMessage{
+		ProtocolVersion: 1,
+		ClientName:      "handler-test",
+		ClientVersion:   "test",
+	})
+	if err := conn.WriteJSON(types.BaseGameEvent{Type: "hello", Data: helloData}); err != nil {
+		t.Errorf("Failed to send hello: %v", err)
+	}
+}
+
 func TestHandler_ConcurrentConnections(t *testing.T) {
 	handler := NewHandler()
 	defer handler.Close()
@@ -104,7 +119,8 @@ func TestHandler_ConcurrentJoinLobby(t *testing.T) {
 		wg.Add(1)
 		go func(idx int) {
 			defer wg.Done()
-			
+
+			sendHello(t, connections[idx])
 			joinData, _ := json.Marshal(types.JoinLobbyMessage{
 				Name: "Player" + string(rune('0'+idx)),
 			})
@@ -150,6 +166,8 @@ func TestHandler_ClientDisconnectDuringGame(t *testing.T) {
 	}
 
 	// Both clients join lobby
+	sendHello(t, conn1)
+	sendHello(t, conn2)
 	joinData1, _ := json.Marshal(types.JoinLobbyMessage{Name: "Alice"})
 	joinEvent1 := types.BaseGameEvent{Type: "join_lobby", Data: joinData1}
 	conn1.WriteJSON(joinEvent1)
@@ -203,6 +221,7 @@ func TestHandler_StressTestConnectDisconnect(t *testing.T) {
 			}
 
 			// Join lobby
+			sendHello(t, conn)
 			joinData, _ := json.Marshal(types.JoinLobbyMessage{
 				Name: "Player" + string(rune('A'+idx%26)),
 			})
@@ -226,4 +245,387 @@ func TestHandler_StressTestConnectDisconnect(t *testing.T) {
 	if clientCount != 0 {
 		t.Errorf("Expected 0 clients after stress test, got %d", clientCount)
 	}
-}
\ No newline at end of file
+}
+func TestHandler_RejectsClientWithoutHello(t *testing.T) {
+	handler := NewHandler()
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer conn.Close()
+
+	// A legacy client goes straight to join_lobby
+	joinData, _ := json.Marshal(types.JoinLobbyMessage{Name: "Legacy"})
+	conn.WriteJSON(types.BaseGameEvent{Type: "join_lobby", Data: joinData})
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var event types.BaseGameEvent
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal("Expected error message, got:", err)
+	}
+
+	var errorMsg types.ErrorMessage
+	json.Unmarshal(event.Data, &errorMsg)
+	if event.Type != "error" || errorMsg.Code != "UPGRADE_REQUIRED" {
+		t.Errorf("Expected UPGRADE_REQUIRED, got %s %+v", event.Type, errorMsg)
+	}
+
+	// The server should then close the connection with a policy violation
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("Expected policy violation close, got %v", err)
+	}
+}
+
+func TestHandler_RejectsOutdatedProtocolVersion(t *testing.T) {
+	handler := NewHandler(WithMinProtocolVersion(2))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer conn.Close()
+
+	sendHello(t, conn)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var event types.BaseGameEvent
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal("Expected error message, got:", err)
+	}
+
+	var errorMsg types.ErrorMessage
+	json.Unmarshal(event.Data, &errorMsg)
+	if errorMsg.Code != "UPGRADE_REQUIRED" {
+		t.Errorf("Expected UPGRADE_REQUIRED, got %+v", errorMsg)
+	}
+}
+
+func TestHandler_HelloNegotiatesFeatures(t *testing.T) {
+	handler := NewHandler(WithFeatures("resume", "spectate"), WithServerVersion("1.2.3"))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer conn.Close()
+
+	helloData, _ := json.Marshal(types.HelloMessage{
+		ProtocolVersion: 1,
+		ClientName:      "unity",
+		ClientVersion:   "0.9.0",
+		Features:        []string{"spectate", "rulesets"},
+	})
+	conn.WriteJSON(types.BaseGameEvent{Type: "hello", Data: helloData})
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var event types.BaseGameEvent
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal("Expected welcome message, got:", err)
+	}
+
+	var welcome types.WelcomeMessage
+	json.Unmarshal(event.Data, &welcome)
+	if event.Type != "welcome" {
+		t.Fatalf("Expected welcome, got %s", event.Type)
+	}
+	if welcome.ServerVersion != "1.2.3" {
+		t.Errorf("Expected server version 1.2.3, got %s", welcome.ServerVersion)
+	}
+	if len(welcome.Features) != 1 || welcome.Features[0] != "spectate" {
+		t.Errorf("Expected only spectate to be negotiated, got %v", welcome.Features)
+	}
+
+	// Capabilities are recorded on the client
+	handler.mu.RLock()
+	var caps types.Capabilities
+	for _, client := range handler.clients {
+		caps, _ = client.Capabilities()
+	}
+	handler.mu.RUnlock()
+
+	if caps.ClientName != "unity" || caps.ClientVersion != "0.9.0" || caps.ProtocolVersion != 1 {
+		t.Errorf("Unexpected capabilities recorded: %+v", caps)
+	}
+}
+
+func TestHandler_TransitionObserverSeesLifecycle(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	handler := NewHandler(WithTransitionObserver(func(client *types.Client, from, to types.ClientState) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, from.String()+"->"+to.String())
+	}))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer conn.Close()
+
+	sendHello(t, conn)
+	joinData, _ := json.Marshal(types.JoinLobbyMessage{Name: "Alice"})
+	conn.WriteJSON(types.BaseGameEvent{Type: "join_lobby", Data: joinData})
+
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{"connected->named", "named->queued"}
+	if strings.Join(seen, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected transitions %v, got %v", expected, seen)
+	}
+}
+
+func TestHandler_MakeChoiceOutsideGameIsRejected(t *testing.T) {
+	handler := NewHandler()
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer conn.Close()
+
+	sendHello(t, conn)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var welcome types.BaseGameEvent
+	if err := conn.ReadJSON(&welcome); err != nil {
+		t.Fatal("Expected welcome message, got:", err)
+	}
+
+	choiceData, _ := json.Marshal(types.MakeChoiceMessage{Choice: "rock"})
+	conn.WriteJSON(types.BaseGameEvent{Type: "make_choice", Data: choiceData})
+
+	var event types.BaseGameEvent
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal("Expected error message, got:", err)
+	}
+
+	var errMsg types.ErrorMessage
+	json.Unmarshal(event.Data, &errMsg)
+	if event.Type != "error" || errMsg.Code != "NOT_IN_GAME" || errMsg.Ref != "make_choice" {
+		t.Errorf("Expected NOT_IN_GAME for make_choice, got %s %+v", event.Type, errMsg)
+	}
+}
+
+func TestHandler_UnknownMessageTypeIsReported(t *testing.T) {
+	handler := NewHandler()
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer conn.Close()
+
+	sendHello(t, conn)
+	conn.WriteJSON(types.BaseGameEvent{Type: "dance", Data: json.RawMessage(`{}`)})
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		var event types.BaseGameEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatal("Expected error message, got:", err)
+		}
+		if event.Type != "error" {
+			continue
+		}
+		var errMsg types.ErrorMessage
+		json.Unmarshal(event.Data, &errMsg)
+		if errMsg.Code != "UNKNOWN_MESSAGE_TYPE" || errMsg.Ref != "dance" {
+			t.Errorf("Expected UNKNOWN_MESSAGE_TYPE for dance, got %+v", errMsg)
+		}
+		return
+	}
+}
+
+func TestHandler_RateLimitsMessages(t *testing.T) {
+	handler := NewHandler(WithRateLimit(1, 3))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer conn.Close()
+
+	// The hello and two choices use up the burst, the third choice is over
+	// the limit
+	sendHello(t, conn)
+	choiceData, _ := json.Marshal(types.MakeChoiceMessage{Choice: "rock"})
+	for range 3 {
+		conn.WriteJSON(types.BaseGameEvent{Type: "make_choice", Data: choiceData})
+	}
+
+	var codes []string
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for len(codes) < 3 {
+		var event types.BaseGameEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatal("Expected error messages, got:", err)
+		}
+		if event.Type != "error" {
+			continue
+		}
+		var errMsg types.ErrorMessage
+		json.Unmarshal(event.Data, &errMsg)
+		codes = append(codes, errMsg.Code)
+	}
+	if !slices.Equal(codes, []string{"NOT_IN_GAME", "NOT_IN_GAME", "RATE_LIMITED"}) {
+		t.Errorf("Expected the third choice to be rate limited, got %v", codes)
+	}
+}
+
+func TestHandler_OversizedMessageClosesConnection(t *testing.T) {
+	handler := NewHandler()
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer conn.Close()
+
+	sendHello(t, conn)
+	name := strings.Repeat("a", maxMessageSize)
+	joinData, _ := json.Marshal(types.JoinLobbyMessage{Name: name})
+	conn.WriteJSON(types.BaseGameEvent{Type: "join_lobby", Data: joinData})
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		var event types.BaseGameEvent
+		err := conn.ReadJSON(&event)
+		if websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+			return
+		}
+		if err != nil {
+			t.Fatal("Expected the server to close with 1009, got:", err)
+		}
+	}
+}
+
+func TestHandler_SpectateRequiresFeature(t *testing.T) {
+	handler := NewHandler(WithFeatures("spectate"))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer conn.Close()
+
+	// sendHello does not ask for the spectate feature
+	sendHello(t, conn)
+	spectateData, _ := json.Marshal(types.SpectateMessage{PlayerName: "Alice"})
+	conn.WriteJSON(types.BaseGameEvent{Type: "spectate", Data: spectateData})
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		var event types.BaseGameEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatal("Expected error message, got:", err)
+		}
+		if event.Type == "error" {
+			break
+		}
+	}
+}
+
+func TestHandler_StalledReaderIsDisconnected(t *testing.T) {
+	handler := NewHandler(WithDeliveryPolicy(types.DeliveryPolicy{
+		Mode:    types.DeliverBlock,
+		Timeout: 50 * time.Millisecond,
+	}))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+	defer conn.Close()
+	sendHello(t, conn)
+	time.Sleep(50 * time.Millisecond)
+
+	handler.mu.RLock()
+	var client *types.Client
+	for _, c := range handler.clients {
+		client = c
+	}
+	handler.mu.RUnlock()
+
+	// The test never reads, so socket buffers and then the send buffer
+	// fill up until the server gives up on the client
+	big := types.ErrorMessage{Message: strings.Repeat("x", 64*1024)}
+	deadline := time.Now().Add(10 * time.Second)
+	for !client.IsClosed() && time.Now().Before(deadline) {
+		protocol.Send(client, big)
+	}
+	if !client.IsClosed() {
+		t.Fatal("Stalled client should have been disconnected")
+	}
+
+	counts := handler.DeliveryStats()
+	if counts.Disconnected != 1 || counts.Dropped != 1 {
+		t.Errorf("Expected one disconnect and one dropped message, got %+v", counts)
+	}
+
+	// Once the client reads again it gets everything that was queued,
+	// followed by the slow consumer close frame
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation || closeErr.Text != types.CloseReasonSlowConsumer {
+		t.Errorf("Expected slow consumer close frame, got %v", err)
+	}
+}
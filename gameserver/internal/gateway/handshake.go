@@ -0,0 +1,68 @@
+package gateway
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+// onHello handles the hello handshake and negotiates capabilities
+func (h *Handler) onHello(client *types.Client, msg types.HelloMessage) error {
+	if _, done := client.Capabilities(); done {
+		protocol.Send(client, types.ErrorMessage{
+			Code:    protocol.ErrorCodeInvalidState,
+			Message: "Handshake already completed",
+			Ref:     protocol.TypeHello,
+		})
+		return fmt.Errorf("client %s sent hello twice", client.ID)
+	}
+
+	if msg.ProtocolVersion < h.minProtocolVersion {
+		h.rejectOutdated(client, msg.ProtocolVersion)
+		return fmt.Errorf("client %s speaks protocol %d, minimum is %d",
+			client.ID, msg.ProtocolVersion, h.minProtocolVersion)
+	}
+
+	features := protocol.NegotiateFeatures(h.features, msg.Features)
+	client.SetCapabilities(types.Capabilities{
+		ProtocolVersion: msg.ProtocolVersion,
+		ClientName:      msg.ClientName,
+		ClientVersion:   msg.ClientVersion,
+		Features:        features,
+	})
+
+	h.openSession(client, msg, func(session *types.Session, missed []types.BaseGameEvent, resumed bool) {
+		// The welcome is not part of the session, so it carries no seq
+		protocol.Send(client, types.WelcomeMessage{
+			ProtocolVersion: protocol.Version,
+			ServerVersion:   h.serverVersion,
+			Features:        features,
+			SessionID:       session.ID,
+			Resumed:         resumed,
+		})
+		client.AttachSession(session)
+		if resumed {
+			client.Replay(missed)
+		}
+	})
+
+	log.Printf("Client %s completed handshake: %s %s, protocol %d, features %v",
+		client.ID, msg.ClientName, msg.ClientVersion, msg.ProtocolVersion, features)
+	return nil
+}
+
+// rejectOutdated tells a client it must upgrade and closes the connection
+// once the error has been flushed
+func (h *Handler) rejectOutdated(client *types.Client, version int) {
+	protocol.Send(client, types.ErrorMessage{
+		Code: protocol.ErrorCodeUpgradeRequired,
+		Message: fmt.Sprintf("Protocol version %d is no longer supported, please upgrade to version %d or newer",
+			version, h.minProtocolVersion),
+		Details: []types.ErrorDetail{{Key: "min_protocol_version", Value: strconv.Itoa(h.minProtocolVersion)}},
+	})
+	client.CloseGracefully(websocket.ClosePolicyViolation, protocol.ErrorCodeUpgradeRequired)
+}
@@ -0,0 +1,174 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/broker"
+	"github.com/4hel/paper/gameserver/internal/correspondence"
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/lobby"
+	"github.com/4hel/paper/gameserver/internal/snapshot"
+	"github.com/4hel/paper/gameserver/internal/tournament"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// Option configures a Handler
+type Option func(*Handler)
+
+// WithMinProtocolVersion sets the oldest client protocol version the
+// handler accepts. Older clients are rejected with upgrade_required.
+func WithMinProtocolVersion(version int) Option {
+	return func(h *Handler) {
+		h.minProtocolVersion = version
+	}
+}
+
+// WithFeatures sets the optional features the server offers during the
+// hello handshake
+func WithFeatures(features ...string) Option {
+	return func(h *Handler) {
+		h.features = features
+	}
+}
+
+// WithServerVersion sets the server version reported in welcome messages
+func WithServerVersion(version string) Option {
+	return func(h *Handler) {
+		h.serverVersion = version
+	}
+}
+
+// WithTransitionObserver registers fn on every new client so it sees each
+// state transition, e.g. for metrics or debugging
+func WithTransitionObserver(fn types.TransitionObserver) Option {
+	return func(h *Handler) {
+		h.observers = append(h.observers, fn)
+	}
+}
+
+// WithRoundTimeout limits how long each round waits for both choices.
+// Players who have not chosen in time lose the round. Zero disables it.
+func WithRoundTimeout(timeout time.Duration) Option {
+	return func(h *Handler) {
+		h.roundTimeout = timeout
+	}
+}
+
+// WithRateLimit limits each connection to perSecond messages on average
+// and burst at once. Messages over the limit are answered with a
+// RATE_LIMITED error and dropped. Zero disables the limit.
+func WithRateLimit(perSecond, burst int) Option {
+	return func(h *Handler) {
+		h.rateLimit = perSecond
+		h.rateBurst = burst
+	}
+}
+
+// WithDeliveryPolicy sets how messages are queued for clients that read
+// slower than the server writes
+func WithDeliveryPolicy(policy types.DeliveryPolicy) Option {
+	return func(h *Handler) {
+		h.deliveryPolicy = policy
+	}
+}
+
+// WithReplayBuffer sets how many unacknowledged events each session keeps
+// for clients that reconnect
+func WithReplayBuffer(size int) Option {
+	return func(h *Handler) {
+		h.replayBuffer = size
+	}
+}
+
+// WithDrainTimeout sets how long Drain lets running games finish before
+// closing the remaining connections
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(h *Handler) {
+		h.drainTimeout = timeout
+	}
+}
+
+// WithSessionTTL sets how long a disconnected player's session can still
+// be resumed, and so how long they keep their place in the queue or game
+func WithSessionTTL(ttl time.Duration) Option {
+	return func(h *Handler) {
+		h.sessionTTL = ttl
+	}
+}
+
+// WithBroker pairs players with those of other instances sharing b, so
+// the instances form one matchmaking pool. instance must be unique among
+// them. The caller closes b after the handler.
+func WithBroker(b broker.Broker, instance string) Option {
+	return func(h *Handler) {
+		h.broker = b
+		h.instance = instance
+	}
+}
+
+// WithSnapshots saves running games to store every interval and when a
+// drain ends, and restores them when the handler starts. Players continue
+// a restored game by sending the session_id of their last welcome in hello
+// and joining the same two-player game again. The name is ignored on
+// purpose, unlike a rejoin under the same name a name alone cannot take a
+// seat.
+func WithSnapshots(store snapshot.Store, interval time.Duration) Option {
+	return func(h *Handler) {
+		h.lobbyOptions = append(h.lobbyOptions, lobby.WithSnapshots(store, interval))
+	}
+}
+
+// WithRestoreGrace sets how long restored games wait for their players
+func WithRestoreGrace(grace time.Duration) Option {
+	return func(h *Handler) {
+		h.lobbyOptions = append(h.lobbyOptions, lobby.WithRestoreGrace(grace))
+	}
+}
+
+// WithFreeForAllRules sets the rules of the games played by clients that
+// ask for rooms of three or more players in join_lobby
+func WithFreeForAllRules(rules gameroom.Rules) Option {
+	return func(h *Handler) {
+		h.lobbyOptions = append(h.lobbyOptions, lobby.WithFreeForAllRules(rules))
+	}
+}
+
+// WithTeamTieBreak sets how teams decide when their players vote for
+// different moves
+func WithTeamTieBreak(tieBreak gameroom.TieBreak) Option {
+	return func(h *Handler) {
+		h.lobbyOptions = append(h.lobbyOptions, lobby.WithTeamTieBreak(tieBreak))
+	}
+}
+
+// WithSuddenDeath sets how two-player games that end tied are decided,
+// with sudden-death rounds up to a cap and a fallback after it
+func WithSuddenDeath(suddenDeath gameroom.SuddenDeath) Option {
+	return func(h *Handler) {
+		h.lobbyOptions = append(h.lobbyOptions, lobby.WithSuddenDeath(suddenDeath))
+	}
+}
+
+// WithTournamentCheckIn sets how long the players of a due tournament
+// match have to show up before they lose by no-show
+func WithTournamentCheckIn(checkIn time.Duration) Option {
+	return func(h *Handler) {
+		h.tournamentOptions = append(h.tournamentOptions, tournament.WithCheckIn(checkIn))
+	}
+}
+
+// WithMatchStore keeps correspondence matches in store, so they survive a
+// restart
+func WithMatchStore(store correspondence.Store) Option {
+	return func(h *Handler) {
+		h.matchOptions = append(h.matchOptions, correspondence.WithStore(store))
+	}
+}
+
+// WithMoveDeadline sets how long a player of a correspondence match has
+// for each move
+func WithMoveDeadline(deadline time.Duration) Option {
+	return func(h *Handler) {
+		h.matchOptions = append(h.matchOptions, correspondence.WithMoveDeadline(deadline))
+	}
+}
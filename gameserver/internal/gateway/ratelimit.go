@@ -0,0 +1,38 @@
+package gateway
+
+import "time"
+
+// rateLimiter is a token bucket that limits the messages of one
+// connection. It is only used from the connection's read pump.
+type rateLimiter struct {
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+}
+
+// newRateLimiter allows perSecond messages on average and up to burst at
+// once. It returns nil, which allows everything, if perSecond is zero.
+func newRateLimiter(perSecond, burst int) *rateLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	burst = max(burst, 1)
+	return &rateLimiter{perSecond: float64(perSecond), burst: float64(burst), tokens: float64(burst)}
+}
+
+// allow reports whether a message arriving at now is within the limit
+func (rl *rateLimiter) allow(now time.Time) bool {
+	if rl == nil {
+		return true
+	}
+	if !rl.last.IsZero() {
+		rl.tokens = min(rl.burst, rl.tokens+now.Sub(rl.last).Seconds()*rl.perSecond)
+	}
+	rl.last = now
+	if rl.tokens < 1 {
+		return false
+	}
+	rl.tokens--
+	return true
+}
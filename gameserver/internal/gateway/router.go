@@ -0,0 +1,92 @@
+package gateway
+
+import (
+	"sync"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/lobby"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// router binds each client to the game room it is playing in, so in-game
+// messages go straight to the room without touching the lobby mutex.
+// Clients without a binding are handled by the lobby. Clients in a game
+// hosted by another instance are bound to a lobby.Room that forwards
+// their messages there.
+type router struct {
+	mu    sync.RWMutex
+	rooms map[string]lobby.Room
+}
+
+// newRouter creates an empty session router
+func newRouter() *router {
+	return &router{
+		rooms: make(map[string]lobby.Room),
+	}
+}
+
+// bind routes both players of a new game room to it
+func (r *router) bind(room *gameroom.GameRoom) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rooms[room.Player1.ID] = room
+	r.rooms[room.Player2.ID] = room
+}
+
+// bindFreeForAll routes every player of a new free-for-all room to it
+func (r *router) bindFreeForAll(room *gameroom.FreeForAll) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, player := range room.Players {
+		r.rooms[player.ID] = room
+	}
+}
+
+// bindTeamRoom routes all four players of a new team room to it
+func (r *router) bindTeamRoom(room *gameroom.TeamRoom) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, team := range room.Teams {
+		for _, player := range team {
+			r.rooms[player.ID] = room
+		}
+	}
+}
+
+// bindTurnRoom routes both players of a new turn-based game to its room
+func (r *router) bindTurnRoom(room *gameroom.TurnRoom) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, player := range room.Players {
+		r.rooms[player.ID] = room
+	}
+}
+
+// bindRemote routes a client to a game hosted by another instance
+func (r *router) bindRemote(clientID string, room lobby.Room) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rooms[clientID] = room
+}
+
+// unbind hands a client back to the lobby
+func (r *router) unbind(clientID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.rooms, clientID)
+}
+
+// room returns the game room a client is bound to, or nil while the
+// client is in the lobby
+func (r *router) room(clientID string) lobby.Room {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rooms[clientID]
+}
+
+// onTransition unbinds clients as soon as they leave their game
+func (r *router) onTransition(client *types.Client, from, to types.ClientState) {
+	if from == types.StateInGame {
+		r.unbind(client.ID)
+	}
+}
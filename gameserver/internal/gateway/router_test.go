@@ -0,0 +1,284 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+// queuedClient returns a client without a connection that is ready to be
+// matched, observed by the router
+func queuedClient(t testing.TB, r *router, id, name string) *types.Client {
+	client := types.NewClient(id, nil)
+	client.Observe(r.onTransition)
+	client.SetName(name)
+	for _, state := range []types.ClientState{types.StateNamed, types.StateQueued} {
+		if err := client.Transition(state); err != nil {
+			t.Fatal("Failed to queue client:", err)
+		}
+	}
+	return client
+}
+
+func TestRouter_BindsPlayersForTheirGame(t *testing.T) {
+	r := newRouter()
+	alice := queuedClient(t, r, "alice", "Alice")
+	bob := queuedClient(t, r, "bob", "Bob")
+
+	if r.room("alice") != nil {
+		t.Fatal("Queued client should be routed to the lobby")
+	}
+
+	room := gameroom.NewGameRoom("room-1", alice, bob, nil)
+	r.bind(room)
+
+	if r.room("alice") != room || r.room("bob") != room {
+		t.Fatal("Both players should be routed to their game room")
+	}
+
+	// Alice wins two rounds, which ends the game
+	for round := 0; round < 2; round++ {
+		r.room("alice").MakeChoice("alice", gameroom.Rock)
+		r.room("bob").MakeChoice("bob", gameroom.Scissors)
+	}
+
+	if alice.State() != types.StatePostGame {
+		t.Fatalf("Expected game to end, Alice is %s", alice.State())
+	}
+	if r.room("alice") != nil || r.room("bob") != nil {
+		t.Error("Players should be handed back to the lobby when the game ends")
+	}
+}
+
+func TestRouter_UnbindsKnockedOutPlayers(t *testing.T) {
+	r := newRouter()
+	players := []*types.Client{
+		queuedClient(t, r, "alice", "Alice"),
+		queuedClient(t, r, "bob", "Bob"),
+		queuedClient(t, r, "carol", "Carol"),
+	}
+
+	room := gameroom.NewFreeForAll("room-1", players, gameroom.Rules{Elimination: true}, nil)
+	defer room.Close()
+	r.bindFreeForAll(room)
+	room.StartFirstRound()
+
+	r.room("alice").MakeChoice("alice", gameroom.Rock)
+	r.room("bob").MakeChoice("bob", gameroom.Rock)
+	r.room("carol").MakeChoice("carol", gameroom.Scissors)
+
+	if r.room("carol") != nil {
+		t.Error("Carol should be handed back to the lobby once knocked out")
+	}
+	if r.room("alice") != room || r.room("bob") != room {
+		t.Error("Alice and Bob should still be routed to their game room")
+	}
+}
+
+// blockingRoom is a remote room whose Leave waits until release is closed
+type blockingRoom struct {
+	leaving chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingRoom) MakeChoice(clientID string, choice gameroom.Choice) error { return nil }
+
+func (r *blockingRoom) Leave(clientID string) {
+	close(r.leaving)
+	<-r.release
+}
+
+func TestHandler_RemoveClientLeavesWithoutTheHandlerLock(t *testing.T) {
+	handler := NewHandler()
+	defer handler.Close()
+
+	alice := types.NewClient("alice", nil)
+	handler.addClient(alice)
+	room := &blockingRoom{leaving: make(chan struct{}), release: make(chan struct{})}
+	handler.router.bindRemote(alice.ID, room)
+
+	removed := make(chan struct{})
+	go func() {
+		handler.removeClient(alice)
+		close(removed)
+	}()
+	<-room.leaving
+
+	// Other connections are still served while Alice leaves their game
+	counted := make(chan int)
+	go func() { counted <- handler.ConnectionCount() }()
+	select {
+	case <-counted:
+	case <-time.After(time.Second):
+		close(room.release)
+		t.Fatal("Handler lock was held while leaving the game")
+	}
+
+	close(room.release)
+	<-removed
+	if n := handler.ConnectionCount(); n != 0 {
+		t.Errorf("Expected no connections after removing Alice, got %d", n)
+	}
+}
+
+// joinGame connects a player, completes the handshake and joins the lobby
+func joinGame(t *testing.T, wsURL, name string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("%s failed to connect: %v", name, err)
+	}
+	sendHello(t, conn)
+	joinData, _ := json.Marshal(types.JoinLobbyMessage{Name: name})
+	conn.WriteJSON(types.BaseGameEvent{Type: "join_lobby", Data: joinData})
+	return conn
+}
+
+// readUntil reads events until one of the given type arrives
+func readUntil(conn *websocket.Conn, eventType string) error {
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event types.BaseGameEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			return err
+		}
+		if event.Type == eventType {
+			return nil
+		}
+	}
+}
+
+// playUntilEnd answers every round_start with choice until game_ended
+func playUntilEnd(conn *websocket.Conn, choice string) error {
+	choiceData, _ := json.Marshal(types.MakeChoiceMessage{Choice: choice})
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event types.BaseGameEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			return err
+		}
+		switch event.Type {
+		case "round_start":
+			if err := conn.WriteJSON(types.BaseGameEvent{Type: "make_choice", Data: choiceData}); err != nil {
+				return err
+			}
+		case "game_ended":
+			return nil
+		}
+	}
+}
+
+func TestHandler_InGameChoicesBypassTheLobbyLock(t *testing.T) {
+	// The observer stalls Blocker's join_lobby while JoinLobby holds the
+	// lobby mutex, which it does for every transition it makes
+	stalled := make(chan struct{})
+	release := make(chan struct{})
+	var stall, unstall sync.Once
+	handler := NewHandler(WithTransitionObserver(func(client *types.Client, from, to types.ClientState) {
+		if to == types.StateQueued && client.GetName() == "Blocker" {
+			stall.Do(func() { close(stalled) })
+			<-release
+		}
+	}))
+	defer handler.Close()
+	defer unstall.Do(func() { close(release) })
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	alice := joinGame(t, wsURL, "Alice")
+	defer alice.Close()
+	if err := readUntil(alice, "player_waiting"); err != nil {
+		t.Fatal("Alice was not queued:", err)
+	}
+	bob := joinGame(t, wsURL, "Bob")
+	defer bob.Close()
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		if err := readUntil(conn, "round_start"); err != nil {
+			t.Fatal("Game did not start:", err)
+		}
+	}
+
+	blocker := joinGame(t, wsURL, "Blocker")
+	defer blocker.Close()
+	select {
+	case <-stalled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Blocker never reached the lobby")
+	}
+
+	// The round still resolves while the lobby is locked
+	for conn, choice := range map[*websocket.Conn]string{alice: "rock", bob: "scissors"} {
+		choiceData, _ := json.Marshal(types.MakeChoiceMessage{Choice: choice})
+		conn.WriteJSON(types.BaseGameEvent{Type: "make_choice", Data: choiceData})
+	}
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		if err := readUntil(conn, "round_result"); err != nil {
+			t.Fatal("Round did not resolve while the lobby was locked:", err)
+		}
+	}
+
+	unstall.Do(func() { close(release) })
+	if err := readUntil(blocker, "player_waiting"); err != nil {
+		t.Error("Blocker was not queued once the lobby was released:", err)
+	}
+}
+
+// BenchmarkHandler_MakeChoiceUnderLobbyChurn measures routing a choice to
+// its room while other clients keep the lobby mutex busy
+func BenchmarkHandler_MakeChoiceUnderLobbyChurn(b *testing.B) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	handler := NewHandler()
+	defer handler.Close()
+
+	// Only one player ever chooses, so the round never completes and the
+	// room stays open for the whole benchmark
+	alice := queuedClient(b, handler.router, "alice", "Alice")
+	bob := queuedClient(b, handler.router, "bob", "Bob")
+	handler.router.bind(gameroom.NewGameRoom("bench-room", alice, bob, nil))
+
+	stop := make(chan struct{})
+	var churn sync.WaitGroup
+	churn.Add(1)
+	go func() {
+		defer churn.Done()
+		for n := 0; ; n++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			id := fmt.Sprintf("churn-%d", n)
+			client := types.NewClient(id, nil)
+			handler.lobby.AddClient(client)
+			handler.lobby.JoinLobby(id, types.JoinLobbyMessage{Name: id})
+			handler.lobby.RemoveClient(id)
+		}
+	}()
+
+	msg := types.MakeChoiceMessage{Choice: "rock"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := handler.onMakeChoice(alice, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+
+	close(stop)
+	churn.Wait()
+}
@@ -0,0 +1,209 @@
+package gateway
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+// CloseReasonSessionResumed is sent to a connection whose session was
+// taken over by a newer connection of the same player
+const CloseReasonSessionResumed = "session_resumed"
+
+// sessionEntry tracks which connection currently owns a session
+type sessionEntry struct {
+	session *types.Session
+	// seat is the client the session started with. Games and the lobby
+	// know the player by it, later connections get its events relayed.
+	seat   *types.Client
+	client *types.Client // nil while the player is disconnected
+	expiry *time.Timer
+}
+
+// openSession resumes the session the client asked for if it can, and
+// otherwise starts a new one. greet runs before any event of the session
+// reaches the client, with the events it missed if it resumed.
+func (h *Handler) openSession(client *types.Client, msg types.HelloMessage, greet func(session *types.Session, missed []types.BaseGameEvent, resumed bool)) {
+	h.sessionsMu.Lock()
+	defer h.sessionsMu.Unlock()
+
+	entry, known := h.sessions[msg.SessionID]
+	if known && client.HasFeature(protocol.FeatureResume) && h.resumeSession(entry, client, msg.LastSeq, greet) {
+		return
+	}
+
+	session := types.NewSession(randomString(32), h.replayBuffer)
+	if msg.SessionID != "" && !known {
+		// Games saved before a restart know the player by the old session,
+		// even if the client cannot resume sessions
+		session.Succeed(msg.SessionID)
+	}
+	h.sessions[session.ID] = &sessionEntry{session: session, seat: client, client: client}
+	greet(session, nil, false)
+}
+
+// resumeSession hands the session's seat to client if every event after
+// lastSeq is still buffered. The caller must hold sessionsMu.
+func (h *Handler) resumeSession(entry *sessionEntry, client *types.Client, lastSeq uint64, greet func(*types.Session, []types.BaseGameEvent, bool)) bool {
+	previous := entry.client
+	replayed := 0
+	resumed := entry.seat.RelayTo(client, func() bool {
+		// A half-open old connection must stop recording first, so
+		// nothing is stamped between the replay and the takeover
+		if previous != nil && previous != entry.seat {
+			previous.DetachSession()
+		}
+
+		missed, ok := entry.session.After(lastSeq)
+		if !ok {
+			// The old connection keeps its session if we cannot resume
+			if previous != nil && previous != entry.seat {
+				previous.AttachSession(entry.session)
+			}
+			return false
+		}
+		if caps, ok := client.Capabilities(); ok {
+			entry.seat.SetCapabilities(caps)
+		}
+		greet(entry.session, missed, true)
+		replayed = len(missed)
+		return true
+	})
+	if !resumed {
+		log.Printf("Client %s cannot resume session %s from seq %d", client.ID, entry.session.ID, lastSeq)
+		return false
+	}
+
+	if previous != nil {
+		previous.CloseGracefully(websocket.CloseNormalClosure, CloseReasonSessionResumed)
+	}
+	if entry.expiry != nil {
+		entry.expiry.Stop()
+		entry.expiry = nil
+	}
+	entry.client = client
+	log.Printf("Client %s resumed session %s of client %s after seq %d, replaying %d events",
+		client.ID, entry.session.ID, entry.seat.ID, lastSeq, replayed)
+	return true
+}
+
+// seat returns the client a connection plays as. That is an earlier
+// connection once it resumed that one's session.
+func (h *Handler) seat(client *types.Client) *types.Client {
+	session := client.Session()
+	if session == nil {
+		return client
+	}
+
+	h.sessionsMu.Lock()
+	defer h.sessionsMu.Unlock()
+
+	if entry, ok := h.sessions[session.ID]; ok && entry.client == client {
+		return entry.seat
+	}
+	return client
+}
+
+// releaseSession ends a closed connection's hold on its session and
+// returns the clients that give up their game and lobby place now. A
+// player who can resume keeps their seat for sessionTTL.
+func (h *Handler) releaseSession(client *types.Client) []*types.Client {
+	session := client.Session()
+	if session == nil {
+		return []*types.Client{client}
+	}
+
+	h.sessionsMu.Lock()
+	defer h.sessionsMu.Unlock()
+
+	entry, ok := h.sessions[session.ID]
+	switch {
+	case !ok:
+		return []*types.Client{client}
+	case entry.client != client:
+		// Taken over by a newer connection, which keeps the seat
+		if entry.seat == client {
+			return nil
+		}
+		return []*types.Client{client}
+	}
+
+	var gone []*types.Client
+	if client != entry.seat {
+		gone = append(gone, client)
+	}
+	if !client.HasFeature(protocol.FeatureResume) || h.Draining() {
+		delete(h.sessions, session.ID)
+		return append(gone, entry.seat)
+	}
+
+	// The seat's events are only recorded until the player is back
+	entry.seat.RelayTo(nil, nil)
+	entry.client = nil
+	entry.expiry = time.AfterFunc(h.sessionTTL, func() { h.expireSession(entry) })
+	log.Printf("Client %s disconnected, session %s can be resumed for %v", client.ID, session.ID, h.sessionTTL)
+	return gone
+}
+
+// expireSession gives up the seat of a player who did not resume their
+// session within sessionTTL
+func (h *Handler) expireSession(entry *sessionEntry) {
+	h.sessionsMu.Lock()
+	if entry.client != nil || h.sessions[entry.session.ID] != entry {
+		h.sessionsMu.Unlock()
+		return // Resumed in the meantime
+	}
+	delete(h.sessions, entry.session.ID)
+	h.sessionsMu.Unlock()
+
+	log.Printf("Session %s expired, client %s leaves", entry.session.ID, entry.seat.ID)
+	h.leave(entry.seat)
+}
+
+// endSession forgets the session of a player who will not resume it and
+// returns the connection that held it, if any
+func (h *Handler) endSession(seat *types.Client) *types.Client {
+	session := seat.Session()
+	if session == nil {
+		return nil
+	}
+
+	h.sessionsMu.Lock()
+	defer h.sessionsMu.Unlock()
+
+	entry, ok := h.sessions[session.ID]
+	if !ok || entry.seat != seat {
+		return nil
+	}
+	delete(h.sessions, session.ID)
+	if entry.expiry != nil {
+		entry.expiry.Stop()
+	}
+	return entry.client
+}
+
+// stopSessions stops the expiry of every disconnected player's session
+func (h *Handler) stopSessions() {
+	h.sessionsMu.Lock()
+	defer h.sessionsMu.Unlock()
+
+	for _, entry := range h.sessions {
+		if entry.expiry != nil {
+			entry.expiry.Stop()
+		}
+	}
+}
+
+// onAck handles ack messages by trimming the replay buffer
+func (h *Handler) onAck(client *types.Client, msg types.AckMessage) error {
+	session := client.Session()
+	if session == nil {
+		return fmt.Errorf("client %s sent ack without a session", client.ID)
+	}
+	session.Ack(msg.Seq)
+	return nil
+}
@@ -0,0 +1,237 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+// resumeHello connects, asks to resume sessionID after lastSeq and returns
+// the welcome
+func resumeHello(t *testing.T, wsURL, sessionID string, lastSeq uint64) (*websocket.Conn, types.WelcomeMessage) {
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal("Failed to connect:", err)
+	}
+
+	helloData, _ := json.Marshal(types.HelloMessage{
+		ProtocolVersion: 1,
+		ClientName:      "session-test",
+		ClientVersion:   "test",
+		Features:        []string{"resume"},
+		SessionID:       sessionID,
+		LastSeq:         lastSeq,
+	})
+	conn.WriteJSON(types.BaseGameEvent{Type: "hello", Data: helloData})
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var event types.BaseGameEvent
+	if err := conn.ReadJSON(&event); err != nil || event.Type != "welcome" {
+		t.Fatalf("Expected welcome, got %s %v", event.Type, err)
+	}
+	if event.Seq != 0 {
+		t.Errorf("Welcome should not be numbered, got seq %d", event.Seq)
+	}
+
+	var welcome types.WelcomeMessage
+	json.Unmarshal(event.Data, &welcome)
+	return conn, welcome
+}
+
+func TestHandler_ResumeReplaysMissedEvents(t *testing.T) {
+	handler := NewHandler(WithFeatures("resume"))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, welcome := resumeHello(t, wsURL, "", 0)
+	if welcome.SessionID == "" || welcome.Resumed {
+		t.Fatalf("Expected a new session, got %+v", welcome)
+	}
+
+	joinData, _ := json.Marshal(types.JoinLobbyMessage{Name: "Alice"})
+	conn.WriteJSON(types.BaseGameEvent{Type: "join_lobby", Data: joinData})
+
+	var waiting types.BaseGameEvent
+	if err := conn.ReadJSON(&waiting); err != nil || waiting.Type != "player_waiting" {
+		t.Fatalf("Expected player_waiting, got %s %v", waiting.Type, err)
+	}
+	if waiting.Seq != 1 {
+		t.Errorf("Expected the first event in the session to have seq 1, got %d", waiting.Seq)
+	}
+	conn.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	// Pretend player_waiting never arrived
+	conn, resumed := resumeHello(t, wsURL, welcome.SessionID, 0)
+	defer conn.Close()
+	if !resumed.Resumed || resumed.SessionID != welcome.SessionID {
+		t.Fatalf("Expected session %s to be resumed, got %+v", welcome.SessionID, resumed)
+	}
+
+	var replayed types.BaseGameEvent
+	if err := conn.ReadJSON(&replayed); err != nil {
+		t.Fatal("Expected replayed event, got:", err)
+	}
+	if replayed.Type != "player_waiting" || replayed.Seq != 1 {
+		t.Errorf("Expected player_waiting with seq 1 to be replayed, got %s seq %d", replayed.Type, replayed.Seq)
+	}
+}
+
+func TestHandler_ResumeAfterAckedEventsFails(t *testing.T) {
+	handler := NewHandler(WithFeatures("resume"))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, welcome := resumeHello(t, wsURL, "", 0)
+
+	joinData, _ := json.Marshal(types.JoinLobbyMessage{Name: "Alice"})
+	conn.WriteJSON(types.BaseGameEvent{Type: "join_lobby", Data: joinData})
+	if err := readUntil(conn, "player_waiting"); err != nil {
+		t.Fatal("Expected player_waiting, got:", err)
+	}
+
+	ackData, _ := json.Marshal(types.AckMessage{Seq: 1})
+	conn.WriteJSON(types.BaseGameEvent{Type: "ack", Data: ackData})
+	time.Sleep(50 * time.Millisecond)
+	conn.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	// Seq 1 was acked and is gone, so the session cannot be resumed from 0
+	conn, resumed := resumeHello(t, wsURL, welcome.SessionID, 0)
+	conn.Close()
+	if resumed.Resumed || resumed.SessionID == welcome.SessionID {
+		t.Errorf("Expected a new session after asking for acked events, got %+v", resumed)
+	}
+
+	conn, unknown := resumeHello(t, wsURL, "no-such-session", 0)
+	conn.Close()
+	if unknown.Resumed {
+		t.Errorf("Unknown session should not be resumed, got %+v", unknown)
+	}
+}
+
+func TestHandler_SessionExpiresAfterTTL(t *testing.T) {
+	handler := NewHandler(WithFeatures("resume"), WithSessionTTL(20*time.Millisecond))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, welcome := resumeHello(t, wsURL, "", 0)
+	conn.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	conn, resumed := resumeHello(t, wsURL, welcome.SessionID, 0)
+	conn.Close()
+	if resumed.Resumed {
+		t.Error("Session should have expired before the client came back")
+	}
+}
+
+// readSeq reads events until one of the given type arrives and returns
+// its seq
+func readSeq(t *testing.T, conn *websocket.Conn, eventType string) uint64 {
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for {
+		var event types.BaseGameEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("Expected %s, got: %v", eventType, err)
+		}
+		if event.Type == eventType {
+			return event.Seq
+		}
+	}
+}
+
+func TestHandler_ResumeContinuesGame(t *testing.T) {
+	handler := NewHandler(WithFeatures("resume"))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	alice, welcome := resumeHello(t, wsURL, "", 0)
+	joinData, _ := json.Marshal(types.JoinLobbyMessage{Name: "Alice"})
+	alice.WriteJSON(types.BaseGameEvent{Type: "join_lobby", Data: joinData})
+	lastSeq := readSeq(t, alice, "player_waiting")
+
+	// Alice drops before the game starts and misses its first events
+	alice.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	bob := joinGame(t, wsURL, "Bob")
+	defer bob.Close()
+	if err := readUntil(bob, "round_start"); err != nil {
+		t.Fatal("Game did not start while Alice was away:", err)
+	}
+	choiceData, _ := json.Marshal(types.MakeChoiceMessage{Choice: "rock"})
+	bob.WriteJSON(types.BaseGameEvent{Type: "make_choice", Data: choiceData})
+
+	alice, resumed := resumeHello(t, wsURL, welcome.SessionID, lastSeq)
+	defer alice.Close()
+	if !resumed.Resumed {
+		t.Fatalf("Expected session %s to be resumed, got %+v", welcome.SessionID, resumed)
+	}
+	if seq := readSeq(t, alice, "game_starting"); seq != lastSeq+1 {
+		t.Errorf("Expected game_starting to be replayed with seq %d, got %d", lastSeq+1, seq)
+	}
+	readSeq(t, alice, "round_start")
+
+	// Alice still holds their seat, so their choice completes the round
+	choiceData, _ = json.Marshal(types.MakeChoiceMessage{Choice: "scissors"})
+	alice.WriteJSON(types.BaseGameEvent{Type: "make_choice", Data: choiceData})
+	var result types.RoundResultMessage
+	if err := readGameEvent(alice, "round_result", &result); err != nil {
+		t.Fatal("Expected round_result after resuming, got:", err)
+	}
+	if result.Result != "lose" {
+		t.Errorf("Expected Alice to lose the round, got %+v", result)
+	}
+	if err := readUntil(bob, "round_result"); err != nil {
+		t.Error("Expected Bob to get the round result, got:", err)
+	}
+}
+
+func TestHandler_ExpiredSessionForfeitsGame(t *testing.T) {
+	handler := NewHandler(WithFeatures("resume"), WithSessionTTL(50*time.Millisecond))
+	defer handler.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	alice, _ := resumeHello(t, wsURL, "", 0)
+	joinData, _ := json.Marshal(types.JoinLobbyMessage{Name: "Alice"})
+	alice.WriteJSON(types.BaseGameEvent{Type: "join_lobby", Data: joinData})
+	readSeq(t, alice, "player_waiting")
+
+	bob := joinGame(t, wsURL, "Bob")
+	defer bob.Close()
+	readSeq(t, alice, "round_start")
+	if err := readUntil(bob, "round_start"); err != nil {
+		t.Fatal("Game did not start:", err)
+	}
+
+	alice.Close()
+	var ended types.GameEndedMessage
+	if err := readGameEvent(bob, "game_ended", &ended); err != nil {
+		t.Fatal("Expected the game to end once Alice's session expired, got:", err)
+	}
+	if ended.Result != "win" {
+		t.Errorf("Expected Bob to win by forfeit, got %+v", ended)
+	}
+}
@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/snapshot"
+	"github.com/4hel/paper/gameserver/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+func TestHandler_GameSurvivesRestart(t *testing.T) {
+	store := snapshot.NewMemoryStore()
+
+	// First server: Alice wins round 1, then the server restarts
+	first := NewHandler(WithSnapshots(store, time.Hour), WithDrainTimeout(100*time.Millisecond))
+	server := httptest.NewServer(http.HandlerFunc(first.HandleWebSocket))
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	alice, aliceSession := joinWithSession(t, wsURL, "Alice", "")
+	if err := readUntil(alice, "player_waiting"); err != nil {
+		t.Fatal("Alice was not queued:", err)
+	}
+	bob, bobSession := joinWithSession(t, wsURL, "Bob", "")
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		if err := readUntil(conn, "round_start"); err != nil {
+			t.Fatal("Game did not start:", err)
+		}
+	}
+	rock, _ := json.Marshal(types.MakeChoiceMessage{Choice: "rock"})
+	scissors, _ := json.Marshal(types.MakeChoiceMessage{Choice: "scissors"})
+	alice.WriteJSON(types.BaseGameEvent{Type: "make_choice", Data: rock})
+	bob.WriteJSON(types.BaseGameEvent{Type: "make_choice", Data: scissors})
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		if err := readUntil(conn, "round_start"); err != nil {
+			t.Fatal("Round 2 did not start:", err)
+		}
+	}
+
+	if err := first.Drain(context.Background()); err != nil {
+		t.Fatal("Drain failed:", err)
+	}
+	first.Close()
+	server.Close()
+	alice.Close()
+	bob.Close()
+
+	// Second server: both present their old sessions and finish the match.
+	// Someone else using Alice's name is not seated in their place.
+	second := NewHandler(WithSnapshots(store, time.Hour))
+	defer second.Close()
+	server = httptest.NewServer(http.HandlerFunc(second.HandleWebSocket))
+	defer server.Close()
+	wsURL = "ws" + strings.TrimPrefix(server.URL, "http")
+
+	impostor, _ := joinWithSession(t, wsURL, "Alice", "")
+	if err := readUntil(impostor, "player_waiting"); err != nil {
+		t.Fatal("The impostor was not queued:", err)
+	}
+	impostor.Close()
+
+	alice, _ = joinWithSession(t, wsURL, "Alice", aliceSession)
+	defer alice.Close()
+	if err := readUntil(alice, "player_waiting"); err != nil {
+		t.Fatal("Alice was not waiting for Bob:", err)
+	}
+	bob, _ = joinWithSession(t, wsURL, "Bob", bobSession)
+	defer bob.Close()
+
+	var resumed types.GameResumedMessage
+	if err := readGameEvent(alice, "game_resumed", &resumed); err != nil {
+		t.Fatal("Alice's game was not resumed:", err)
+	}
+	expected := types.GameResumedMessage{OpponentName: "Bob", RoundNumber: 2, YourWins: 1, OpponentWins: 0,
+		Game: "rps", Actions: []string{"rock", "paper", "scissors"}}
+	if !reflect.DeepEqual(resumed, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, resumed)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- playUntilEnd(bob, "scissors") }()
+	alice.WriteJSON(types.BaseGameEvent{Type: "make_choice", Data: rock})
+	var ended types.GameEndedMessage
+	if err := readGameEvent(alice, "game_ended", &ended); err != nil {
+		t.Fatal("Alice's game did not end:", err)
+	}
+	if ended.Result != "win" {
+		t.Errorf("Expected Alice to win 2-0, got %q", ended.Result)
+	}
+	if err := <-done; err != nil {
+		t.Fatal("Bob did not finish:", err)
+	}
+}
+
+// joinWithSession joins the lobby after a hello asking to resume sessionID
+// and returns the session the server gave the player
+func joinWithSession(t *testing.T, wsURL, name, sessionID string) (*websocket.Conn, string) {
+	conn, welcome := resumeHello(t, wsURL, sessionID, 0)
+	joinData, _ := json.Marshal(types.JoinLobbyMessage{Name: name})
+	conn.WriteJSON(types.BaseGameEvent{Type: "join_lobby", Data: joinData})
+	return conn, welcome.SessionID
+}
@@ -0,0 +1,167 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/tournament"
+	"github.com/4hel/paper/gameserver/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+// joinTournament connects a player and registers them for a tournament
+// without joining the lobby
+func joinTournament(t *testing.T, wsURL, name, tournamentID string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("%s failed to connect: %v", name, err)
+	}
+	sendHello(t, conn)
+	joinData, _ := json.Marshal(types.JoinTournamentMessage{TournamentID: tournamentID, Name: name})
+	conn.WriteJSON(types.BaseGameEvent{Type: "join_tournament", Data: joinData})
+	return conn
+}
+
+// readFinalBracket reads tournament updates until the tournament is over
+func readFinalBracket(conn *websocket.Conn) (types.TournamentUpdateMessage, error) {
+	for {
+		var update types.TournamentUpdateMessage
+		if err := readGameEvent(conn, "tournament_update", &update); err != nil {
+			return update, err
+		}
+		if update.State == tournament.StateFinished {
+			return update, nil
+		}
+	}
+}
+
+func TestHandler_TournamentIsPlayedToTheEnd(t *testing.T) {
+	handler := NewHandler()
+	defer handler.Close()
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	cup := handler.Tournaments().Create("Cup")
+	players := map[string]*websocket.Conn{}
+	for _, name := range []string{"Alice", "Bob", "Carol"} {
+		conn := joinTournament(t, wsURL, name, cup.ID)
+		defer conn.Close()
+		var update types.TournamentUpdateMessage
+		if err := readGameEvent(conn, "tournament_update", &update); err != nil {
+			t.Fatalf("%s was not registered: %v", name, err)
+		}
+		players[name] = conn
+	}
+	if err := cup.Start(); err != nil {
+		t.Fatal("Failed to start:", err)
+	}
+
+	// Alice has a bye, Carol beats Bob and loses the final to Alice
+	errs := make(chan error, 3)
+	go func() { errs <- playUntilEnd(players["Alice"], "paper") }()
+	go func() { errs <- playUntilEnd(players["Bob"], "scissors") }()
+	go func() {
+		err := playUntilEnd(players["Carol"], "rock")
+		if err == nil {
+			err = playUntilEnd(players["Carol"], "rock")
+		}
+		errs <- err
+	}()
+	for range 3 {
+		if err := <-errs; err != nil {
+			t.Fatal("Game did not finish:", err)
+		}
+	}
+
+	for name, conn := range players {
+		bracket, err := readFinalBracket(conn)
+		if err != nil {
+			t.Fatalf("%s did not see the tournament end: %v", name, err)
+		}
+		if bracket.Champion != "Alice" {
+			t.Errorf("%s: expected Alice to be champion, got %q", name, bracket.Champion)
+		}
+	}
+
+	// Registration is closed once the tournament started
+	late := joinTournament(t, wsURL, "Dave", cup.ID)
+	defer late.Close()
+	var errMsg types.ErrorMessage
+	if err := readGameEvent(late, "error", &errMsg); err != nil {
+		t.Fatal("Expected an error:", err)
+	}
+	if errMsg.Code != protocol.ErrorCodeTournamentClosed {
+		t.Errorf("Expected %s, got %+v", protocol.ErrorCodeTournamentClosed, errMsg)
+	}
+}
+
+func TestHandler_JoinUnknownTournament(t *testing.T) {
+	handler := NewHandler()
+	defer handler.Close()
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+
+	conn := joinTournament(t, "ws"+strings.TrimPrefix(server.URL, "http"), "Alice", "nope")
+	defer conn.Close()
+	var errMsg types.ErrorMessage
+	if err := readGameEvent(conn, "error", &errMsg); err != nil {
+		t.Fatal("Expected an error:", err)
+	}
+	if errMsg.Code != protocol.ErrorCodeTournamentNotFound || errMsg.Ref != "join_tournament" {
+		t.Errorf("Expected %s, got %+v", protocol.ErrorCodeTournamentNotFound, errMsg)
+	}
+}
+
+func TestHandler_LeagueIsPlayedToTheEnd(t *testing.T) {
+	handler := NewHandler()
+	defer handler.Close()
+	server := httptest.NewServer(http.HandlerFunc(handler.HandleWebSocket))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	league, err := handler.Tournaments().CreateLeague(tournament.LeagueConfig{Name: "League", Format: tournament.FormatRoundRobin})
+	if err != nil {
+		t.Fatal("Failed to create league:", err)
+	}
+	alice := joinTournament(t, wsURL, "Alice", league.ID)
+	defer alice.Close()
+	bob := joinTournament(t, wsURL, "Bob", league.ID)
+	defer bob.Close()
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		var update types.LeagueUpdateMessage
+		if err := readGameEvent(conn, "league_update", &update); err != nil {
+			t.Fatal("Player was not registered:", err)
+		}
+	}
+	if err := league.Start(); err != nil {
+		t.Fatal("Failed to start:", err)
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- playUntilEnd(alice, "rock") }()
+	go func() { errs <- playUntilEnd(bob, "scissors") }()
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatal("Game did not finish:", err)
+		}
+	}
+
+	for {
+		var table types.LeagueUpdateMessage
+		if err := readGameEvent(bob, "league_update", &table); err != nil {
+			t.Fatal("Bob did not see the league end:", err)
+		}
+		if table.State != tournament.StateFinished {
+			continue
+		}
+		if top := table.Standings[0]; top.Player != "Alice" || top.Points != 1 {
+			t.Errorf("Expected Alice on top with a point, got %+v", top)
+		}
+		break
+	}
+}
@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/4hel/paper/gameserver/internal/broker"
+	"github.com/4hel/paper/gameserver/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+// joinTurnGame connects a player and queues them for a game of the given type
+func joinTurnGame(t *testing.T, wsURL, name, game string) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("%s failed to connect: %v", name, err)
+	}
+	sendHello(t, conn)
+	joinData, _ := json.Marshal(types.JoinLobbyMessage{Name: name, Game: game})
+	conn.WriteJSON(types.BaseGameEvent{Type: "join_lobby", Data: joinData})
+	return conn
+}
+
+// sendMove sends a make_move message
+func sendMove(conn *websocket.Conn, row, column int) {
+	moveData, _ := json.Marshal(types.MakeMoveMessage{Row: row, Column: column})
+	conn.WriteJSON(types.BaseGameEvent{Type: "make_move", Data: moveData})
+}
+
+func TestHandler_TicTacToeAcrossInstances(t *testing.T) {
+	b := broker.NewMemoryBroker()
+	defer b.Close()
+	urls := startInstances(t, b, "a", "b")
+
+	// Alice waits on a, so Bob's instance hosts the game and Bob moves first
+	alice := joinTurnGame(t, urls[0], "Alice", "tic_tac_toe")
+	defer alice.Close()
+	if err := readUntil(alice, "player_waiting"); err != nil {
+		t.Fatal("Alice was not queued:", err)
+	}
+	bob := joinTurnGame(t, urls[1], "Bob", "tic_tac_toe")
+	defer bob.Close()
+
+	var starting types.GameStartingMessage
+	if err := readGameEvent(bob, "game_starting", &starting); err != nil {
+		t.Fatal("Bob's game did not start:", err)
+	}
+	if starting.Game != "tic_tac_toe" || starting.Role != "X" {
+		t.Errorf("Expected Bob to play X at tic_tac_toe, got %+v", starting)
+	}
+	var board types.BoardUpdateMessage
+	for _, conn := range []*websocket.Conn{alice, bob} {
+		if err := readGameEvent(conn, "board_update", &board); err != nil {
+			t.Fatal("Expected the empty board:", err)
+		}
+	}
+	if board.Turn != "Bob" || !board.YourTurn || board.Board[0] != "..." {
+		t.Errorf("Expected Bob to move first on an empty board, got %+v", board)
+	}
+
+	sendMove(alice, 1, 1)
+	var errMsg types.ErrorMessage
+	if err := readGameEvent(alice, "error", &errMsg); err != nil {
+		t.Fatal("Expected an error for Alice's move:", err)
+	}
+	if errMsg.Code != "NOT_YOUR_TURN" {
+		t.Errorf("Expected NOT_YOUR_TURN, got %+v", errMsg)
+	}
+
+	// Bob takes the top row
+	moves := []struct {
+		conn        *websocket.Conn
+		row, column int
+	}{
+		{bob, 0, 0}, {alice, 1, 0}, {bob, 0, 1}, {alice, 1, 1}, {bob, 0, 2},
+	}
+	for _, move := range moves {
+		sendMove(move.conn, move.row, move.column)
+		for _, conn := range []*websocket.Conn{alice, bob} {
+			board = types.BoardUpdateMessage{}
+			if err := readGameEvent(conn, "board_update", &board); err != nil {
+				t.Fatal("Expected the board after a move:", err)
+			}
+		}
+	}
+	if board.Board[0] != "XXX" || board.Board[1] != "OO." || board.Turn != "" || board.LastMove.Player != "Bob" {
+		t.Errorf("Unexpected final board %+v", board)
+	}
+
+	var ended types.GameEndedMessage
+	if err := readGameEvent(alice, "game_ended", &ended); err != nil || ended.Result != "lose" {
+		t.Errorf("Expected Alice to lose, got %q: %v", ended.Result, err)
+	}
+	if err := readGameEvent(bob, "game_ended", &ended); err != nil || ended.Result != "win" {
+		t.Errorf("Expected Bob to win, got %q: %v", ended.Result, err)
+	}
+}
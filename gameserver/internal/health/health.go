@@ -0,0 +1,111 @@
+// Package health runs named readiness checks and reports per component
+// status for the health endpoints.
+package health
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Status values reported for a component
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check reports whether a component can serve players, nil means healthy
+type Check func(ctx context.Context) error
+
+// Component is the result of one check
+type Component struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker holds the readiness checks of a server
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []namedCheck
+	timeout time.Duration
+}
+
+// NewChecker creates a checker that fails checks running longer than timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a check, components are reported in the order they were added
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Run runs all checks concurrently and reports whether every one passed
+func (c *Checker) Run(ctx context.Context) ([]Component, bool) {
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	components := make([]Component, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			components[i] = Component{Name: nc.name, Status: StatusOK}
+			if err := run(ctx, nc.check); err != nil {
+				components[i].Status = StatusFail
+				components[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	ok := true
+	for _, component := range components {
+		if component.Status != StatusOK {
+			ok = false
+		}
+	}
+	return components, ok
+}
+
+// run calls check and gives up once ctx is done, so a hanging backend
+// cannot block the health endpoints
+func run(ctx context.Context, check Check) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- check(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+}
+
+// GoroutineLimit fails once the process runs more than max goroutines,
+// a sign of leaked connections or rooms
+func GoroutineLimit(max int) Check {
+	return func(ctx context.Context) error {
+		if n := runtime.NumGoroutine(); n > max {
+			return fmt.Errorf("%d goroutines, limit is %d", n, max)
+		}
+		return nil
+	}
+}
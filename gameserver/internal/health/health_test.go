@@ -0,0 +1,53 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChecker_ReportsEveryComponent(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Add("lobby", func(ctx context.Context) error { return nil })
+	checker.Add("store", func(ctx context.Context) error { return errors.New("connection refused") })
+
+	components, ok := checker.Run(context.Background())
+	if ok {
+		t.Error("Expected the failing store to fail the run")
+	}
+	if len(components) != 2 || components[0].Name != "lobby" || components[1].Name != "store" {
+		t.Fatalf("Expected components in registration order, got %+v", components)
+	}
+	if components[0].Status != StatusOK {
+		t.Errorf("Expected lobby to be ok, got %+v", components[0])
+	}
+	if components[1].Status != StatusFail || components[1].Error != "connection refused" {
+		t.Errorf("Expected store to fail with its error, got %+v", components[1])
+	}
+}
+
+func TestChecker_HangingCheckTimesOut(t *testing.T) {
+	checker := NewChecker(50 * time.Millisecond)
+	checker.Add("store", func(ctx context.Context) error {
+		select {} // a backend that never answers
+	})
+
+	start := time.Now()
+	components, ok := checker.Run(context.Background())
+	if ok || components[0].Status != StatusFail {
+		t.Errorf("Expected the hanging check to fail, got %+v", components)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Run should give up after the timeout, took %v", elapsed)
+	}
+}
+
+func TestGoroutineLimit(t *testing.T) {
+	if err := GoroutineLimit(1 << 20)(context.Background()); err != nil {
+		t.Errorf("Expected to be within a generous limit, got %v", err)
+	}
+	if err := GoroutineLimit(1)(context.Background()); err == nil {
+		t.Error("Expected a limit of one goroutine to be exceeded")
+	}
+}
@@ -0,0 +1,73 @@
+package lobby
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// queue finds a game for a queued client of the size or kind it asked
+// for. ref is the message that queued the client. The caller must hold mu.
+func (l *Lobby) queue(client *types.Client, ref string) error {
+	if _, teams := l.teamPlayers[client.ID]; teams {
+		l.fillTeams(client)
+		return nil
+	}
+	if size := l.roomSizes[client.ID]; size > 2 {
+		l.fillRoom(client, size)
+		return nil
+	}
+	return l.matchOrWait(client, ref)
+}
+
+// fillRoom adds a client to the players waiting for a free-for-all of
+// size and starts it once it is full. Free-for-all rooms are only filled
+// with players of this instance. The caller must hold mu.
+func (l *Lobby) fillRoom(client *types.Client, size int) {
+	l.waitingPlayers[client.ID] = client
+	group := append(l.groups[size], client)
+	if len(group) < size {
+		l.groups[size] = group
+		l.sendPlayerWaiting(client)
+		log.Printf("Client %s (%s) is waiting for a room of %d, %d there", client.ID, client.GetName(), size, len(group))
+		return
+	}
+	delete(l.groups, size)
+	l.startFreeForAll(group)
+}
+
+// startFreeForAll starts a game between all players and returns its room
+// ID, the caller must hold mu
+func (l *Lobby) startFreeForAll(players []*types.Client) string {
+	for _, player := range players {
+		delete(l.waitingPlayers, player.ID)
+	}
+
+	l.gameRoomCounter++
+	gameRoomID := fmt.Sprintf("room-%d", l.gameRoomCounter)
+	room := gameroom.NewFreeForAll(gameRoomID, players, l.rules, l.onGameEnd, l.roomOptions...)
+	l.freeForAlls[gameRoomID] = room
+	if l.onFreeForAllStarted != nil {
+		l.onFreeForAllStarted(room)
+	}
+
+	var names []string
+	for _, player := range players {
+		names = append(names, player.GetName())
+	}
+	for i, player := range players {
+		opponents := append(names[:i:i], names[i+1:]...)
+		protocol.Send(player, types.GameStartingMessage{
+			OpponentName: strings.Join(opponents, ", "),
+			Opponents:    opponents,
+		})
+	}
+
+	room.StartFirstRound()
+	log.Printf("Free-for-all starting between %s in room %s", strings.Join(names, ", "), gameRoomID)
+	return gameRoomID
+}
@@ -2,42 +2,129 @@ package lobby
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
+	"github.com/4hel/paper/gameserver/internal/broker"
 	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/snapshot"
 	"github.com/4hel/paper/gameserver/internal/types"
 )
 
+// ErrDraining is returned for requests that would start a game while the
+// lobby is draining
+var ErrDraining = errors.New("lobby is draining")
+
 // Lobby manages player matchmaking and game rooms
 type Lobby struct {
-	clients        map[string]*types.Client
-	waitingPlayers map[string]*types.Client
-	gameRooms      map[string]*gameroom.GameRoom
+	clients         map[string]*types.Client
+	waitingPlayers  map[string]*types.Client
+	gameRooms       map[string]*gameroom.GameRoom
 	gameRoomCounter int
-	mu             sync.RWMutex
-	ctx            context.Context
-	cancel         context.CancelFunc
+	games           map[string]string                // client ID -> game type asked for, unless the default
+	matchEnds       map[string]func(gameroom.Result) // room ID -> callback of a game started by StartMatch
+	onRoomStarted   func(room *gameroom.GameRoom)
+	onRemoteGame    func(clientID string, room Room)
+	broker          broker.Broker
+	ownBroker       bool // broker was created by the lobby and is closed with it
+	instance        string
+	proxies         map[string]*types.Client // room ID -> stand-in for a player on another instance
+	remoteGames     map[string]string        // client ID -> instance hosting the client's game
+	pairing         map[string]*types.Client // client ID -> client the broker is pairing, dropped if another pairing took and gave up its ticket
+	staleTickets    []queuedTicket           // broker tickets to remove once mu is released
+	roomOptions     []gameroom.Option
+	draining        bool
+	maintenance     *Maintenance  // nil unless new games are paused
+	drained         chan struct{} // closed once draining and no game is left
+	mu              sync.RWMutex
+	ctx             context.Context
+	cancel          context.CancelFunc
+
+	// Free-for-all games
+	freeForAlls         map[string]*gameroom.FreeForAll
+	roomSizes           map[string]int          // client ID -> players per game asked for, if more than two
+	groups              map[int][]*types.Client // room size -> players waiting for a free-for-all, oldest first
+	rules               gameroom.Rules          // of free-for-all games
+	onFreeForAllStarted func(room *gameroom.FreeForAll)
+
+	// Team games
+	teamRooms         map[string]*gameroom.TeamRoom
+	teamPlayers       map[string]string // client ID -> teammate asked for, empty for anyone, of clients who want a team game
+	teamQueue         []*types.Client   // players waiting for a team game, oldest first
+	tieBreak          gameroom.TieBreak // of team games
+	onTeamRoomStarted func(room *gameroom.TeamRoom)
+
+	// Turn-based games
+	turnRooms         map[string]*gameroom.TurnRoom
+	onTurnRoomStarted func(room *gameroom.TurnRoom)
+
+	// Snapshots
+	store            snapshot.Store // nil unless games are snapshotted
+	snapshotInterval time.Duration
+	restoreGrace     time.Duration
+	restored         map[string]*restoredRoom // snapshot room ID -> game waiting for its players
+	saveMu           sync.Mutex               // serializes snapshot saves
+	snapshotsStopped bool                     // set by SaveFinalSnapshot
 }
 
 // NewLobby creates a new lobby instance
-func NewLobby() *Lobby {
+func NewLobby(opts ...Option) *Lobby {
 	ctx, cancel := context.WithCancel(context.Background())
-	return &Lobby{
+	l := &Lobby{
 		clients:        make(map[string]*types.Client),
 		waitingPlayers: make(map[string]*types.Client),
 		gameRooms:      make(map[string]*gameroom.GameRoom),
+		freeForAlls:    make(map[string]*gameroom.FreeForAll),
+		roomSizes:      make(map[string]int),
+		groups:         make(map[int][]*types.Client),
+		teamRooms:      make(map[string]*gameroom.TeamRoom),
+		teamPlayers:    make(map[string]string),
+		games:          make(map[string]string),
+		turnRooms:      make(map[string]*gameroom.TurnRoom),
+		matchEnds:      make(map[string]func(gameroom.Result)),
+		proxies:        make(map[string]*types.Client),
+		remoteGames:    make(map[string]string),
+		pairing:        make(map[string]*types.Client),
+		restoreGrace:   2 * time.Minute,
+		restored:       make(map[string]*restoredRoom),
+		drained:        make(chan struct{}),
 		ctx:            ctx,
 		cancel:         cancel,
 	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.broker == nil {
+		l.broker = broker.NewMemoryBroker()
+		l.ownBroker = true
+	}
+	if l.instance == "" {
+		l.instance = newInstanceID()
+	}
+	if l.store != nil {
+		l.restore()
+		if l.snapshotInterval > 0 {
+			go l.snapshotLoop()
+		}
+	}
+
+	messages, err := l.broker.Subscribe(ctx, broker.InstanceTopic(l.instance))
+	if err != nil {
+		log.Printf("Lobby: cannot receive messages from other instances: %v", err)
+	}
+	go l.subscribe(messages)
+	return l
 }
 
 // AddClient adds a client to the lobby
 func (l *Lobby) AddClient(client *types.Client) {
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	defer l.unlock()
 	l.clients[client.ID] = client
 	log.Printf("Client %s added to lobby", client.ID)
 }
@@ -45,11 +132,22 @@ func (l *Lobby) AddClient(client *types.Client) {
 // RemoveClient removes a client from the lobby
 func (l *Lobby) RemoveClient(clientID string) {
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	defer l.unlock()
 	
 	if _, exists := l.clients[clientID]; exists {
 		delete(l.clients, clientID)
-		delete(l.waitingPlayers, clientID)
+		if _, waiting := l.waitingPlayers[clientID]; waiting {
+			delete(l.waitingPlayers, clientID)
+			l.removeTicket(clientID)
+		}
+		delete(l.roomSizes, clientID)
+		delete(l.teamPlayers, clientID)
+		delete(l.games, clientID)
+		l.leaveRestored(clientID)
+		if _, remote := l.remoteGames[clientID]; remote {
+			delete(l.remoteGames, clientID)
+			l.checkDrained()
+		}
 		log.Printf("Client %s removed from lobby", clientID)
 	}
 }
@@ -57,51 +155,85 @@ func (l *Lobby) RemoveClient(clientID string) {
 // JoinLobby processes a client joining the lobby with their name
 func (l *Lobby) JoinLobby(clientID string, joinMsg types.JoinLobbyMessage) error {
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	defer l.unlock()
 
 	client, exists := l.clients[clientID]
 	if !exists {
 		return fmt.Errorf("client %s not found", clientID)
 	}
 
+	if err := l.checkNewGame(client, protocol.TypeJoinLobby); err != nil {
+		return err
+	}
+
+	// Only clients without a name, or back from a game, may (re)join
+	from := client.State()
+	if from != types.StateNamed && !types.CanTransition(from, types.StateNamed) {
+		l.sendStateError(client, protocol.TypeJoinLobby, "join the lobby", from)
+		return &types.TransitionError{ClientID: clientID, From: from, To: types.StateNamed}
+	}
+
 	// Validate name
 	if joinMsg.Name == "" {
-		l.sendError(client, "Name cannot be empty")
+		l.sendError(client, protocol.ErrorCodeNameInvalid, protocol.TypeJoinLobby, "Name cannot be empty")
 		return fmt.Errorf("empty name for client %s", clientID)
 	}
 
 	// Check if name is already taken
-	for _, waitingClient := range l.waitingPlayers {
-		if waitingClient.GetName() == joinMsg.Name {
-			l.sendError(client, "Name already taken")
-			return fmt.Errorf("name %s already taken", joinMsg.Name)
-		}
+	if l.nameTaken(clientID, joinMsg.Name) {
+		l.sendError(client, protocol.ErrorCodeNameTaken, protocol.TypeJoinLobby, "Name already taken",
+			types.ErrorDetail{Key: "name", Value: joinMsg.Name})
+		return fmt.Errorf("%w: %s", ErrNameTaken, joinMsg.Name)
 	}
 
-	// Set client name and add to lobby
-	client.SetName(joinMsg.Name)
-	client.InLobby = true
-	
-	// Check if there's another player waiting
-	if len(l.waitingPlayers) > 0 {
-		// Match with first waiting player
-		for _, waitingClient := range l.waitingPlayers {
-			// Start game between client and waitingClient
-			l.startGame(client, waitingClient)
-			return nil
+	// Check the game, free-for-all and team games are Rock Paper Scissors
+	gameType, ok := gameroom.LookupGameType(joinMsg.Game)
+	if !ok {
+		l.sendError(client, protocol.ErrorCodeUnknownGame, protocol.TypeJoinLobby, "Unknown game",
+			types.ErrorDetail{Key: "game", Value: joinMsg.Game})
+		return fmt.Errorf("unknown game %s", joinMsg.Game)
+	}
+	if gameType.Name != gameroom.DefaultGame && (joinMsg.Teams || joinMsg.RoomSize > 2) {
+		l.sendError(client, protocol.ErrorCodeInvalidMessage, protocol.TypeJoinLobby, "Free-for-all and team games can only play "+gameroom.DefaultGame,
+			types.ErrorDetail{Key: "game", Value: joinMsg.Game})
+		return fmt.Errorf("game %s cannot be played in teams or by more than two", joinMsg.Game)
+	}
+
+	// Set client name and queue for a match
+	if from != types.StateNamed {
+		if err := client.Transition(types.StateNamed); err != nil {
+			return err
 		}
-	} else {
-		// No one waiting, add to waiting list
-		l.waitingPlayers[clientID] = client
-		l.sendPlayerWaiting(client)
-		log.Printf("Client %s (%s) is waiting for opponent", clientID, joinMsg.Name)
+	}
+	client.SetName(joinMsg.Name)
+	if err := client.Transition(types.StateQueued); err != nil {
+		return err
+	}
+	delete(l.roomSizes, clientID)
+	delete(l.teamPlayers, clientID)
+	delete(l.games, clientID)
+	if gameType.Name != gameroom.DefaultGame {
+		l.games[clientID] = gameType.Name
+	}
+	switch {
+	case joinMsg.Teams:
+		l.teamPlayers[clientID] = joinMsg.Teammate
+	case joinMsg.RoomSize > 2:
+		l.roomSizes[clientID] = joinMsg.RoomSize
 	}
 
-	return nil
+	// A player of a game saved before a restart continues it
+	if l.claimRestored(client) {
+		return nil
+	}
+
+	// Match with the longest waiting player, here or on another instance
+	return l.queue(client, protocol.TypeJoinLobby)
 }
 
-// startGame initiates a game between two players
-func (l *Lobby) startGame(player1, player2 *types.Client) {
+// startGame initiates a game of the given type between two players and
+// returns its room ID
+func (l *Lobby) startGame(player1, player2 *types.Client, game string) string {
 	// Remove both players from waiting list
 	delete(l.waitingPlayers, player1.ID)
 	delete(l.waitingPlayers, player2.ID)
@@ -111,12 +243,20 @@ func (l *Lobby) startGame(player1, player2 *types.Client) {
 	gameRoomID := fmt.Sprintf("room-%d", l.gameRoomCounter)
 
 	// Create game room
-	gameRoom := gameroom.NewGameRoom(gameRoomID, player1, player2, l.onGameEnd)
+	gameType, _ := gameroom.LookupGameType(game)
+	if gameType.TurnBased() {
+		return l.startTurnGame(gameRoomID, player1, player2, gameType)
+	}
+	opts := append(l.roomOptions[:len(l.roomOptions):len(l.roomOptions)], gameroom.WithGame(gameType))
+	gameRoom := gameroom.NewGameRoom(gameRoomID, player1, player2, l.onGameEnd, opts...)
 	l.gameRooms[gameRoomID] = gameRoom
+	if l.onRoomStarted != nil {
+		l.onRoomStarted(gameRoom)
+	}
 
 	// Send game starting messages first (before round_start)
-	l.sendGameStarting(player1, player2.GetName())
-	l.sendGameStarting(player2, player1.GetName())
+	l.sendGameStarting(player1, player2.GetName(), gameType, 0)
+	l.sendGameStarting(player2, player1.GetName(), gameType, 1)
 
 	// Now start the first round after game_starting messages are sent
 	gameRoom.StartFirstRound()
@@ -125,77 +265,121 @@ func (l *Lobby) startGame(player1, player2 *types.Client) {
 		player1.ID, player1.GetName(), 
 		player2.ID, player2.GetName(),
 		gameRoomID)
+	return gameRoomID
 }
 
 // sendPlayerWaiting sends player_waiting message to client
 func (l *Lobby) sendPlayerWaiting(client *types.Client) {
-	data, _ := json.Marshal(types.PlayerWaitingMessage{})
-	event := types.BaseGameEvent{
-		Type: "player_waiting",
-		Data: data,
-	}
-	
-	select {
-	case client.Send <- event:
-	default:
-		log.Printf("Failed to send player_waiting to client %s", client.ID)
-	}
+	protocol.Send(client, types.PlayerWaitingMessage{})
 }
 
-// sendGameStarting sends game_starting message to client
-func (l *Lobby) sendGameStarting(client *types.Client, opponentName string) {
-	data, _ := json.Marshal(types.GameStartingMessage{
+// sendGameStarting sends game_starting message to the client in seat 0 or
+// 1 of a game of gameType
+func (l *Lobby) sendGameStarting(client *types.Client, opponentName string, gameType gameroom.GameType, seat int) {
+	protocol.Send(client, types.GameStartingMessage{
 		OpponentName: opponentName,
+		Game:         gameType.Name,
+		Role:         gameType.Roles[seat],
+		Actions:      actionNames(gameType, seat),
 	})
-	event := types.BaseGameEvent{
-		Type: "game_starting",
-		Data: data,
+}
+
+// actionNames lists the actions of the player in seat 0 or 1 of a new game
+// of gameType, none for a turn-based game
+func actionNames(gameType gameroom.GameType, seat int) []string {
+	if gameType.TurnBased() {
+		return nil
 	}
-	
-	select {
-	case client.Send <- event:
-	default:
-		log.Printf("Failed to send game_starting to client %s", client.ID)
+	var actions []string
+	for _, action := range gameType.New().Actions(seat) {
+		actions = append(actions, string(action))
 	}
+	return actions
 }
 
-// sendError sends error message to client
-func (l *Lobby) sendError(client *types.Client, message string) {
-	data, _ := json.Marshal(types.ErrorMessage{
+// sendError sends error message to client in answer to a message of type ref
+func (l *Lobby) sendError(client *types.Client, code, ref, message string, details ...types.ErrorDetail) {
+	protocol.Send(client, types.ErrorMessage{
+		Code:    code,
 		Message: message,
+		Ref:     ref,
+		Details: details,
 	})
-	event := types.BaseGameEvent{
-		Type: "error",
-		Data: data,
+}
+
+// sendStateError tells the client its message is not allowed in its current state
+func (l *Lobby) sendStateError(client *types.Client, ref, action string, state types.ClientState) {
+	l.sendError(client, protocol.ErrorCodeInvalidState, ref, fmt.Sprintf("Cannot %s while %s", action, state),
+		types.ErrorDetail{Key: "state", Value: state.String()})
+}
+
+// checkNewGame rejects a request that would start a new game while the
+// lobby is draining or in maintenance, the caller must hold mu
+func (l *Lobby) checkNewGame(client *types.Client, ref string) error {
+	if l.draining {
+		l.sendError(client, protocol.ErrorCodeShuttingDown, ref, "The server is shutting down, no new games can be started")
+		return ErrDraining
 	}
-	
-	select {
-	case client.Send <- event:
-	default:
-		log.Printf("Failed to send error to client %s", client.ID)
+	if l.maintenance != nil {
+		var details []types.ErrorDetail
+		if !l.maintenance.ETA.IsZero() {
+			details = append(details, types.ErrorDetail{Key: "eta", Value: l.maintenance.ETA.UTC().Format(time.RFC3339)})
+		}
+		l.sendError(client, protocol.ErrorCodeMaintenance, ref, l.maintenance.Message, details...)
+		return ErrMaintenance
 	}
+	return nil
 }
 
-// MakeChoice forwards a player's choice to their game room
-func (l *Lobby) MakeChoice(clientID string, choice string) error {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
+// Spectate lets a client watch the game the named player is in
+func (l *Lobby) Spectate(clientID string, playerName string) error {
+	l.mu.Lock()
+	defer l.unlock()
 
 	client, exists := l.clients[clientID]
 	if !exists {
 		return fmt.Errorf("client %s not found", clientID)
 	}
 
-	if client.GameRoomID == "" {
-		return fmt.Errorf("client %s not in a game room", clientID)
+	var room *gameroom.GameRoom
+	for _, gameRoom := range l.gameRooms {
+		if gameRoom.Player1.GetName() == playerName || gameRoom.Player2.GetName() == playerName {
+			room = gameRoom
+			break
+		}
+	}
+	if room == nil {
+		l.sendError(client, protocol.ErrorCodeNotInGame, protocol.TypeSpectate, fmt.Sprintf("%s is not in a game", playerName),
+			types.ErrorDetail{Key: "player_name", Value: playerName})
+		return fmt.Errorf("no game with player %s", playerName)
 	}
 
-	gameRoom, exists := l.gameRooms[client.GameRoomID]
-	if !exists {
-		return fmt.Errorf("game room %s not found", client.GameRoomID)
+	// Leave the queue first, a queued client cannot spectate directly
+	if client.State() == types.StateQueued {
+		delete(l.waitingPlayers, clientID)
+		l.removeTicket(clientID)
+		l.leaveRestored(clientID)
+		if err := client.Transition(types.StateNamed); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Transition(types.StateSpectating); err != nil {
+		var transitionErr *types.TransitionError
+		if errors.As(err, &transitionErr) {
+			l.sendStateError(client, protocol.TypeSpectate, "spectate", transitionErr.From)
+		}
+		return err
 	}
 
-	return gameRoom.MakeChoice(clientID, gameroom.Choice(choice))
+	if err := room.Spectate(client); err != nil {
+		// The game ended in the meantime
+		client.Transition(types.StateNamed)
+		l.sendError(client, protocol.ErrorCodeNotInGame, protocol.TypeSpectate, fmt.Sprintf("%s is not in a game", playerName),
+			types.ErrorDetail{Key: "player_name", Value: playerName})
+		return err
+	}
+	return nil
 }
 
 // PlayAgain handles when a player wants to play another game
@@ -203,7 +387,7 @@ func (l *Lobby) PlayAgain(clientID string) error {
 	log.Printf("PlayAgain: ENTRY - called for client %s", clientID)
 	
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	defer l.unlock()
 
 	log.Printf("PlayAgain: MUTEX ACQUIRED for client %s", clientID)
 
@@ -213,21 +397,28 @@ func (l *Lobby) PlayAgain(clientID string) error {
 		return fmt.Errorf("client %s not found", clientID)
 	}
 
+	if err := l.checkNewGame(client, protocol.TypePlayAgain); err != nil {
+		return err
+	}
+
 	log.Printf("PlayAgain: Client %s (%s) wants to play again", clientID, client.GetName())
-	log.Printf("PlayAgain: Current client state - InGame: %v, InLobby: %v, GameRoomID: %s", 
-		client.InGame, client.InLobby, client.GameRoomID)
+	log.Printf("PlayAgain: Current client state - %s", client.State())
 
-	// Reset client state
-	client.InGame = false
-	client.InLobby = true
-	client.GameRoomID = ""
+	// Queue the client again, only allowed once its game has ended
+	if err := client.Transition(types.StateQueued); err != nil {
+		var transitionErr *types.TransitionError
+		if errors.As(err, &transitionErr) {
+			l.sendStateError(client, protocol.TypePlayAgain, "play again", transitionErr.From)
+		}
+		log.Printf("PlayAgain: ERROR - %v", err)
+		return err
+	}
 
-	log.Printf("PlayAgain: Reset client state - InGame: %v, InLobby: %v, GameRoomID: %s", 
-		client.InGame, client.InLobby, client.GameRoomID)
+	log.Printf("PlayAgain: Reset client state - %s", client.State())
 	log.Printf("PlayAgain: Current waiting players count: %d", len(l.waitingPlayers))
 
 	// Re-join the lobby for matchmaking
-	err := l.joinLobbyInternal(clientID, types.JoinLobbyMessage{Name: client.GetName()})
+	err := l.joinLobbyInternal(clientID, protocol.TypePlayAgain)
 	if err != nil {
 		log.Printf("PlayAgain: ERROR - joinLobbyInternal failed for client %s: %v", clientID, err)
 	} else {
@@ -239,60 +430,90 @@ func (l *Lobby) PlayAgain(clientID string) error {
 }
 
 // joinLobbyInternal is the internal version without locking (already locked)
-func (l *Lobby) joinLobbyInternal(clientID string, joinMsg types.JoinLobbyMessage) error {
-	log.Printf("joinLobbyInternal: Processing client %s with name '%s'", clientID, joinMsg.Name)
-	
+func (l *Lobby) joinLobbyInternal(clientID string, ref string) error {
 	client, exists := l.clients[clientID]
 	if !exists {
 		log.Printf("joinLobbyInternal: Client %s not found in clients map", clientID)
 		return fmt.Errorf("client %s not found", clientID)
 	}
 
-	// Check if there's another player waiting
-	log.Printf("joinLobbyInternal: Current waiting players count: %d", len(l.waitingPlayers))
-	if len(l.waitingPlayers) > 0 {
-		log.Printf("joinLobbyInternal: Found waiting players, attempting to match...")
-		// Match with first waiting player
-		for _, waitingClient := range l.waitingPlayers {
-			log.Printf("joinLobbyInternal: Matching %s (%s) with waiting client %s (%s)", 
-				clientID, joinMsg.Name, waitingClient.ID, waitingClient.GetName())
-			// Start game between client and waitingClient
-			l.startGame(client, waitingClient)
-			return nil
-		}
-	} else {
-		// No one waiting, add to waiting list
-		log.Printf("joinLobbyInternal: No waiting players, adding %s (%s) to waiting list", clientID, joinMsg.Name)
-		l.waitingPlayers[clientID] = client
-		l.sendPlayerWaiting(client)
-		log.Printf("Client %s (%s) is waiting for opponent", clientID, joinMsg.Name)
-	}
-
-	return nil
+	log.Printf("joinLobbyInternal: Matching %s (%s), %d players waiting here", clientID, client.GetName(), len(l.waitingPlayers))
+	return l.queue(client, ref)
 }
 
-// onGameEnd is called when a game room finishes
+// onGameEnd is called from a game room's goroutine once its game is over
 func (l *Lobby) onGameEnd(gameRoomID string) {
-	log.Printf("onGameEnd: ENTRY - called for game room %s", gameRoomID)
-	
-	// Run asynchronously to avoid deadlock with the lobby mutex
-	go func() {
-		log.Printf("onGameEnd: ASYNC GOROUTINE - starting cleanup for game room %s", gameRoomID)
-		
-		l.mu.Lock()
-		log.Printf("onGameEnd: MUTEX ACQUIRED for game room %s", gameRoomID)
-		defer l.mu.Unlock()
-
-		if gameRoom, exists := l.gameRooms[gameRoomID]; exists {
-			gameRoom.Close()
-			delete(l.gameRooms, gameRoomID)
-			log.Printf("Game room %s destroyed", gameRoomID)
+	l.mu.Lock()
+
+	var result gameroom.Result
+	if gameRoom, exists := l.gameRooms[gameRoomID]; exists {
+		gameRoom.Close()
+		result = gameRoom.Result()
+		delete(l.gameRooms, gameRoomID)
+		log.Printf("Game room %s destroyed", gameRoomID)
+	}
+	if room, exists := l.freeForAlls[gameRoomID]; exists {
+		room.Close()
+		delete(l.freeForAlls, gameRoomID)
+		log.Printf("Free-for-all room %s destroyed", gameRoomID)
+	}
+	if room, exists := l.teamRooms[gameRoomID]; exists {
+		room.Close()
+		delete(l.teamRooms, gameRoomID)
+		log.Printf("Team room %s destroyed", gameRoomID)
+	}
+	if room, exists := l.turnRooms[gameRoomID]; exists {
+		room.Close()
+		result = room.Result()
+		delete(l.turnRooms, gameRoomID)
+		log.Printf("Turn-based room %s destroyed", gameRoomID)
+	}
+	if proxy, exists := l.proxies[gameRoomID]; exists {
+		// The relay still forwards the final events
+		proxy.Close()
+		delete(l.proxies, gameRoomID)
+	}
+	onEnd := l.matchEnds[gameRoomID]
+	delete(l.matchEnds, gameRoomID)
+	l.checkDrained()
+	l.unlock()
+
+	// The callback may start the next match
+	if onEnd != nil {
+		onEnd(result)
+	}
+}
+
+// Drain stops matchmaking: join_lobby and play_again are rejected from now
+// on while running games continue. The returned channel is closed once
+// the last game has ended.
+func (l *Lobby) Drain() <-chan struct{} {
+	l.mu.Lock()
+	defer l.unlock()
+
+	if !l.draining {
+		l.draining = true
+		log.Printf("Lobby draining, waiting for %d games to finish", len(l.gameRooms)+len(l.freeForAlls)+len(l.teamRooms)+len(l.turnRooms)+len(l.remoteGames))
+		// Other instances must not pair with players waiting here
+		for clientID := range l.waitingPlayers {
+			l.removeTicket(clientID)
 		}
-		
-		log.Printf("onGameEnd: EXIT - completed for game room %s", gameRoomID)
-	}()
-	
-	log.Printf("onGameEnd: SCHEDULED ASYNC CLEANUP for game room %s", gameRoomID)
+		l.checkDrained()
+	}
+	return l.drained
+}
+
+// checkDrained closes drained once draining and no game is left, the caller
+// must hold mu
+func (l *Lobby) checkDrained() {
+	if !l.draining || len(l.gameRooms) > 0 || len(l.freeForAlls) > 0 || len(l.teamRooms) > 0 || len(l.turnRooms) > 0 || len(l.remoteGames) > 0 {
+		return
+	}
+	select {
+	case <-l.drained:
+	default:
+		close(l.drained)
+	}
 }
 
 // Close shuts down the lobby
@@ -300,14 +521,32 @@ func (l *Lobby) Close() {
 	l.cancel()
 	
 	l.mu.Lock()
-	defer l.mu.Unlock()
+	defer l.unlock()
 	
 	// Close all game rooms
 	for _, gameRoom := range l.gameRooms {
 		gameRoom.Close()
 	}
+	for _, room := range l.freeForAlls {
+		room.Close()
+	}
+	for _, room := range l.teamRooms {
+		room.Close()
+	}
+	for _, room := range l.turnRooms {
+		room.Close()
+	}
+	for _, proxy := range l.proxies {
+		proxy.Close()
+	}
+	for _, r := range l.restored {
+		r.expiry.Stop()
+	}
 	
 	for _, client := range l.clients {
 		client.Close()
 	}
+	if l.ownBroker {
+		l.broker.Close()
+	}
 }
\ No newline at end of file
@@ -1,13 +1,22 @@
 package lobby
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/4hel/paper/gameserver/internal/broker"
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/snapshot"
 	"github.com/4hel/paper/gameserver/internal/types"
 )
 
@@ -55,6 +64,19 @@ func TestLobby_DuplicateNames(t *testing.T) {
 	if err2 == nil {
 		t.Error("Second client with duplicate name should fail")
 	}
+
+	// The error carries a code the client can branch on
+	event := <-client2.Send
+	errorMsg, err := protocol.Decode[types.ErrorMessage](event)
+	if err != nil {
+		t.Fatalf("Expected error message, got %s: %v", event.Type, err)
+	}
+	if errorMsg.Code != protocol.ErrorCodeNameTaken || errorMsg.Ref != protocol.TypeJoinLobby {
+		t.Errorf("Expected NAME_TAKEN for join_lobby, got %+v", errorMsg)
+	}
+	if len(errorMsg.Details) != 1 || errorMsg.Details[0].Value != "Alice" {
+		t.Errorf("Expected the taken name in the details, got %+v", errorMsg.Details)
+	}
 }
 
 func TestLobby_RemoveClientMultipleTimes(t *testing.T) {
@@ -130,8 +152,8 @@ func TestLobby_PlayerMatching(t *testing.T) {
 		t.Errorf("First player should join successfully: %v", err1)
 	}
 
-	if !client1.InLobby {
-		t.Error("First client should be in lobby")
+	if client1.State() != types.StateQueued {
+		t.Errorf("First client should be queued, got %s", client1.State())
 	}
 
 	// Second player joins - should start game
@@ -141,11 +163,11 @@ func TestLobby_PlayerMatching(t *testing.T) {
 	}
 
 	// Both should be in game now
-	if client1.InLobby || !client1.InGame {
-		t.Error("Client1 should be in game, not in lobby")
+	if client1.State() != types.StateInGame {
+		t.Errorf("Client1 should be in game, got %s", client1.State())
 	}
-	if client2.InLobby || !client2.InGame {
-		t.Error("Client2 should be in game, not in lobby")
+	if client2.State() != types.StateInGame {
+		t.Errorf("Client2 should be in game, got %s", client2.State())
 	}
 }
 
@@ -172,4 +194,655 @@ func TestLobby_NonExistentClient(t *testing.T) {
 	if err == nil {
 		t.Error("Non-existent client should fail")
 	}
-}
\ No newline at end of file
+}
+func TestLobby_SpectateQueuedPlayer(t *testing.T) {
+	lobby := NewLobby()
+	defer lobby.Close()
+
+	alice := createMockClient(t, "alice")
+	bob := createMockClient(t, "bob")
+	carol := createMockClient(t, "carol")
+	for _, client := range []*types.Client{alice, bob, carol} {
+		lobby.AddClient(client)
+	}
+
+	// Alice and Bob are matched, Carol waits in the queue
+	lobby.JoinLobby("alice", types.JoinLobbyMessage{Name: "Alice"})
+	lobby.JoinLobby("bob", types.JoinLobbyMessage{Name: "Bob"})
+	lobby.JoinLobby("carol", types.JoinLobbyMessage{Name: "Carol"})
+
+	if err := lobby.Spectate("carol", "Nobody"); err == nil {
+		t.Error("Expected an error for a player that is not in a game")
+	}
+
+	if err := lobby.Spectate("carol", "Alice"); err != nil {
+		t.Fatalf("Spectate failed: %v", err)
+	}
+	if carol.State() != types.StateSpectating {
+		t.Errorf("Carol should be spectating, got %s", carol.State())
+	}
+
+	// Carol left the queue, so a new player waits instead of matching her
+	dave := createMockClient(t, "dave")
+	lobby.AddClient(dave)
+	lobby.JoinLobby("dave", types.JoinLobbyMessage{Name: "Dave"})
+	if dave.State() != types.StateQueued {
+		t.Errorf("Dave should be waiting, got %s", dave.State())
+	}
+}
+
+func TestLobby_DrainWaitsForRunningGames(t *testing.T) {
+	lobby := NewLobby()
+	defer lobby.Close()
+
+	alice := createMockClient(t, "alice")
+	bob := createMockClient(t, "bob")
+	carol := createMockClient(t, "carol")
+	for _, client := range []*types.Client{alice, bob, carol} {
+		lobby.AddClient(client)
+	}
+	lobby.JoinLobby("alice", types.JoinLobbyMessage{Name: "Alice"})
+	lobby.JoinLobby("bob", types.JoinLobbyMessage{Name: "Bob"})
+
+	drained := lobby.Drain()
+
+	if err := lobby.JoinLobby("carol", types.JoinLobbyMessage{Name: "Carol"}); err != ErrDraining {
+		t.Errorf("Expected ErrDraining for a new player, got %v", err)
+	}
+	select {
+	case <-drained:
+		t.Fatal("Drain should wait for the running game")
+	default:
+	}
+
+	// Alice wins two rounds, which ends the game
+	lobby.mu.RLock()
+	var room *gameroom.GameRoom
+	for _, r := range lobby.gameRooms {
+		room = r
+	}
+	lobby.mu.RUnlock()
+	for round := 0; round < 2; round++ {
+		room.MakeChoice("alice", gameroom.Rock)
+		room.MakeChoice("bob", gameroom.Scissors)
+	}
+
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("Drain did not finish after the last game ended")
+	}
+	if err := lobby.PlayAgain("alice"); err != ErrDraining {
+		t.Errorf("Expected ErrDraining for play_again, got %v", err)
+	}
+}
+
+func TestLobby_MaintenanceBlocksNewGames(t *testing.T) {
+	lobby := NewLobby()
+	defer lobby.Close()
+
+	alice := createMockClient(t, "alice")
+	bob := createMockClient(t, "bob")
+	carol := createMockClient(t, "carol")
+	for _, client := range []*types.Client{alice, bob, carol} {
+		lobby.AddClient(client)
+	}
+	lobby.JoinLobby("alice", types.JoinLobbyMessage{Name: "Alice"})
+	lobby.JoinLobby("bob", types.JoinLobbyMessage{Name: "Bob"})
+
+	eta := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	lobby.SetMaintenance(Maintenance{Message: "Database upgrade", ETA: eta})
+
+	if err := lobby.JoinLobby("carol", types.JoinLobbyMessage{Name: "Carol"}); err != ErrMaintenance {
+		t.Fatalf("Expected ErrMaintenance for a new player, got %v", err)
+	}
+	errorMsg, err := protocol.Decode[types.ErrorMessage](<-carol.Send)
+	if err != nil {
+		t.Fatal("Expected error message:", err)
+	}
+	if errorMsg.Code != protocol.ErrorCodeMaintenance || errorMsg.Message != "Database upgrade" {
+		t.Errorf("Expected maintenance error with the custom message, got %+v", errorMsg)
+	}
+	if len(errorMsg.Details) != 1 || errorMsg.Details[0].Key != "eta" || errorMsg.Details[0].Value != "2030-01-01T12:00:00Z" {
+		t.Errorf("Expected the eta in the details, got %+v", errorMsg.Details)
+	}
+
+	// The running game is played to the end
+	if alice.State() != types.StateInGame {
+		t.Fatalf("Alice should still be in her game, got %s", alice.State())
+	}
+	lobby.mu.RLock()
+	var room *gameroom.GameRoom
+	for _, r := range lobby.gameRooms {
+		room = r
+	}
+	lobby.mu.RUnlock()
+	for round := 0; round < 2; round++ {
+		room.MakeChoice("alice", gameroom.Rock)
+		room.MakeChoice("bob", gameroom.Scissors)
+	}
+	if alice.State() != types.StatePostGame {
+		t.Fatalf("Expected the game to end, Alice is %s", alice.State())
+	}
+
+	if err := lobby.PlayAgain("alice"); err != ErrMaintenance {
+		t.Errorf("Expected ErrMaintenance for play_again, got %v", err)
+	}
+
+	lobby.EndMaintenance()
+	if err := lobby.JoinLobby("carol", types.JoinLobbyMessage{Name: "Carol"}); err != nil {
+		t.Errorf("Expected join to work after maintenance, got %v", err)
+	}
+}
+
+func TestLobby_RemovedPlayerIsNotPairedAcrossInstances(t *testing.T) {
+	shared := broker.NewMemoryBroker()
+	defer shared.Close()
+	lobbyA := NewLobby(WithBroker(shared, "a"))
+	defer lobbyA.Close()
+	lobbyB := NewLobby(WithBroker(shared, "b"))
+	defer lobbyB.Close()
+
+	alice := createMockClient(t, "alice")
+	bob := createMockClient(t, "bob")
+	lobbyA.AddClient(alice)
+	lobbyB.AddClient(bob)
+
+	lobbyA.JoinLobby("alice", types.JoinLobbyMessage{Name: "Alice"})
+	lobbyA.RemoveClient("alice")
+
+	// Bob must wait instead of getting a game with Alice's stale ticket
+	lobbyB.JoinLobby("bob", types.JoinLobbyMessage{Name: "Bob"})
+	if bob.State() != types.StateQueued {
+		t.Errorf("Expected Bob to be queued, got %s", bob.State())
+	}
+	lobbyB.mu.RLock()
+	rooms := len(lobbyB.gameRooms)
+	lobbyB.mu.RUnlock()
+	if rooms != 0 {
+		t.Errorf("Expected no game, got %d", rooms)
+	}
+}
+
+// stallingBroker holds the Pair call of one player, after the broker
+// paired or queued them, until release is closed
+type stallingBroker struct {
+	broker.Broker
+	player  string
+	stalled chan struct{}
+	release chan struct{}
+}
+
+func (b *stallingBroker) Pair(ctx context.Context, queue string, t broker.Ticket) (*broker.Ticket, error) {
+	opponent, err := b.Broker.Pair(ctx, queue, t)
+	if t.PlayerID == b.player {
+		close(b.stalled)
+		<-b.release
+	}
+	return opponent, err
+}
+
+func TestLobby_PairsWithoutHoldingTheLock(t *testing.T) {
+	b := &stallingBroker{
+		Broker:  broker.NewMemoryBroker(),
+		player:  "alice",
+		stalled: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	defer b.Close()
+	l := NewLobby(WithBroker(b, "a"))
+	defer l.Close()
+
+	alice := createMockClient(t, "alice")
+	bob := createMockClient(t, "bob")
+	l.AddClient(alice)
+	l.AddClient(bob)
+
+	aliceJoined := make(chan error, 1)
+	go func() { aliceJoined <- l.JoinLobby("alice", types.JoinLobbyMessage{Name: "Alice"}) }()
+	<-b.stalled
+
+	// Bob takes Alice's ticket while the broker still holds Alice's call
+	bobJoined := make(chan error, 1)
+	go func() { bobJoined <- l.JoinLobby("bob", types.JoinLobbyMessage{Name: "Bob"}) }()
+	select {
+	case err := <-bobJoined:
+		if err != nil {
+			t.Fatal("Bob failed to join:", err)
+		}
+	case <-time.After(time.Second):
+		close(b.release)
+		t.Fatal("Lobby was locked while the broker paired Alice")
+	}
+
+	close(b.release)
+	if err := <-aliceJoined; err != nil {
+		t.Fatal("Alice failed to join:", err)
+	}
+	expectEvent(t, alice, protocol.TypeGameStarting)
+	expectEvent(t, bob, protocol.TypeGameStarting)
+	if alice.State() != types.StateInGame || bob.State() != types.StateInGame {
+		t.Errorf("Expected both in game, got Alice %s and Bob %s", alice.State(), bob.State())
+	}
+}
+
+// droppingBroker lets a test end the lobby's subscription as if the
+// connection to the broker dropped
+type droppingBroker struct {
+	broker.Broker
+	mu         sync.Mutex
+	drop       context.CancelFunc
+	subscribed chan struct{}
+}
+
+func (b *droppingBroker) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	messages, err := b.Broker.Subscribe(ctx, topic)
+	b.mu.Lock()
+	b.drop = cancel
+	b.mu.Unlock()
+	b.subscribed <- struct{}{}
+	return messages, err
+}
+
+func TestLobby_SubscribesAgainAfterDrop(t *testing.T) {
+	b := &droppingBroker{Broker: broker.NewMemoryBroker(), subscribed: make(chan struct{}, 2)}
+	defer b.Close()
+	l := NewLobby(WithBroker(b, "a"))
+	defer l.Close()
+	<-b.subscribed
+
+	b.mu.Lock()
+	b.drop()
+	b.mu.Unlock()
+
+	select {
+	case <-b.subscribed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Lobby did not subscribe again after its subscription ended")
+	}
+}
+
+// expectEvent reads the client's next queued event and checks its type
+func expectEvent(t *testing.T, client *types.Client, eventType string) types.BaseGameEvent {
+	t.Helper()
+	select {
+	case event := <-client.Send:
+		if event.Type != eventType {
+			t.Fatalf("Expected %s for %s, got %s", eventType, client.ID, event.Type)
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for %s for %s", eventType, client.ID)
+	}
+	return types.BaseGameEvent{}
+}
+
+// returningClient connects a player who asks to resume a session from
+// before a restart
+func returningClient(t *testing.T, id, oldSessionID string) *types.Client {
+	client := createMockClient(t, id)
+	session := types.NewSession(id+"-session", 0)
+	session.Succeed(oldSessionID)
+	client.AttachSession(session)
+	return client
+}
+
+// fingerprint returns the fingerprint snapshots keep of a session ID
+func fingerprint(sessionID string) string {
+	return types.NewSession(sessionID, 0).Fingerprint()
+}
+
+func TestLobby_RestoredGameResumesWhenBothPlayersReturn(t *testing.T) {
+	store := snapshot.NewMemoryStore()
+	store.Save(context.Background(), snapshot.State{Rooms: []gameroom.Snapshot{
+		{ID: "room-7", Player1: "Alice", Player2: "Bob", Player1Session: fingerprint("alice-old"), Player2Session: fingerprint("bob-old"),
+			Player2Wins: 1, CurrentRound: 2, BestOf: 3},
+	}})
+
+	lobby := NewLobby(WithSnapshots(store, 0))
+	defer lobby.Close()
+
+	alice := returningClient(t, "alice", "alice-old")
+	bob := returningClient(t, "bob", "bob-old")
+	carol := createMockClient(t, "carol")
+	impostor := returningClient(t, "impostor", "guessed")
+	for _, client := range []*types.Client{alice, bob, carol, impostor} {
+		lobby.AddClient(client)
+	}
+
+	lobby.JoinLobby("bob", types.JoinLobbyMessage{Name: "Bob"})
+	expectEvent(t, bob, "player_waiting")
+
+	// Someone else calling themselves Alice does not get Alice's seat and
+	// Carol is not paired with Bob, who waits for Alice
+	lobby.JoinLobby("impostor", types.JoinLobbyMessage{Name: "Alice"})
+	expectEvent(t, impostor, "player_waiting")
+	lobby.JoinLobby("carol", types.JoinLobbyMessage{Name: "Carol"})
+	expectEvent(t, carol, "game_starting")
+	expectEvent(t, impostor, "game_starting")
+	lobby.RemoveClient("impostor")
+
+	lobby.JoinLobby("alice", types.JoinLobbyMessage{Name: "Alice"})
+	resumed, _ := protocol.Decode[types.GameResumedMessage](expectEvent(t, alice, "game_resumed"))
+	expected := types.GameResumedMessage{OpponentName: "Bob", RoundNumber: 2, YourWins: 0, OpponentWins: 1,
+		Game: "rps", Actions: []string{"rock", "paper", "scissors"}}
+	if !reflect.DeepEqual(resumed, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, resumed)
+	}
+	resumed, _ = protocol.Decode[types.GameResumedMessage](expectEvent(t, bob, "game_resumed"))
+	if resumed.OpponentName != "Alice" || resumed.YourWins != 1 {
+		t.Errorf("Expected Bob to be 1-0 up against Alice, got %+v", resumed)
+	}
+	start, _ := protocol.Decode[types.RoundStartMessage](expectEvent(t, alice, "round_start"))
+	if start.RoundNumber != 2 {
+		t.Errorf("Expected round 2, got %d", start.RoundNumber)
+	}
+
+	// The resumed game is saved again until it ends, under the sessions
+	// the players have now
+	var saved []gameroom.Snapshot
+	for _, snap := range lobby.Snapshot().Rooms {
+		if snap.Player1Session == alice.Session().Fingerprint() && snap.Player2Session == bob.Session().Fingerprint() {
+			saved = append(saved, snap)
+		}
+	}
+	if len(saved) != 1 || saved[0].Player2Wins != 1 {
+		t.Errorf("Expected the resumed game in the snapshot, got %+v", saved)
+	}
+}
+
+func TestLobby_RestoredGameNeedsTheSameGame(t *testing.T) {
+	store := snapshot.NewMemoryStore()
+	store.Save(context.Background(), snapshot.State{Rooms: []gameroom.Snapshot{
+		{ID: "room-7", Player1: "Alice", Player2: "Bob", Player1Session: fingerprint("alice-old"), Player2Session: fingerprint("bob-old"),
+			CurrentRound: 1, BestOf: 3},
+	}})
+
+	lobby := NewLobby(WithSnapshots(store, 0))
+	defer lobby.Close()
+
+	alice := returningClient(t, "alice", "alice-old")
+	lobby.AddClient(alice)
+	lobby.JoinLobby("alice", types.JoinLobbyMessage{Name: "Alice", Game: "matching_pennies"})
+	expectEvent(t, alice, "player_waiting")
+
+	lobby.mu.RLock()
+	defer lobby.mu.RUnlock()
+	if r := lobby.restored["room-7"]; r == nil || r.players[0] != nil {
+		t.Errorf("Expected Alice not to be seated in a Rock Paper Scissors game when asking for Matching Pennies")
+	}
+}
+
+func TestLobby_RestoredGameExpires(t *testing.T) {
+	store := snapshot.NewMemoryStore()
+	store.Save(context.Background(), snapshot.State{Rooms: []gameroom.Snapshot{
+		{ID: "room-7", Player1: "Alice", Player2: "Bob", Player1Session: fingerprint("alice-old"), Player2Session: fingerprint("bob-old"),
+			CurrentRound: 1, BestOf: 3},
+	}})
+
+	lobby := NewLobby(WithSnapshots(store, 0), WithRestoreGrace(50*time.Millisecond))
+	defer lobby.Close()
+
+	alice := returningClient(t, "alice", "alice-old")
+	lobby.AddClient(alice)
+	lobby.JoinLobby("alice", types.JoinLobbyMessage{Name: "Alice"})
+	expectEvent(t, alice, "player_waiting")
+
+	// Bob never comes back, so Alice wins
+	result, _ := protocol.Decode[types.GameEndedMessage](expectEvent(t, alice, "game_ended"))
+	if result.Result != "win" {
+		t.Errorf("Expected Alice to win by forfeit, got %q", result.Result)
+	}
+	if alice.State() != types.StatePostGame {
+		t.Errorf("Expected Alice to be post game, got %s", alice.State())
+	}
+	if state := lobby.Snapshot(); len(state.Rooms) != 0 {
+		t.Errorf("Expected the expired game to be gone, got %+v", state.Rooms)
+	}
+}
+
+func TestLobby_FinalSnapshotIsKept(t *testing.T) {
+	store := snapshot.NewMemoryStore()
+	lobby := NewLobby(WithSnapshots(store, 0))
+	defer lobby.Close()
+
+	alice := createMockClient(t, "alice")
+	bob := createMockClient(t, "bob")
+	lobby.AddClient(alice)
+	lobby.AddClient(bob)
+	lobby.JoinLobby("alice", types.JoinLobbyMessage{Name: "Alice"})
+	lobby.JoinLobby("bob", types.JoinLobbyMessage{Name: "Bob"})
+
+	if err := lobby.SaveFinalSnapshot(context.Background()); err != nil {
+		t.Fatal("SaveFinalSnapshot failed:", err)
+	}
+
+	// Later saves must not overwrite the game with an empty state
+	lobby.mu.RLock()
+	for _, room := range lobby.gameRooms {
+		room.Leave("alice")
+	}
+	lobby.mu.RUnlock()
+	lobby.SaveSnapshot(context.Background())
+
+	state, _ := store.Load(context.Background())
+	if len(state.Rooms) != 1 {
+		t.Errorf("Expected the running game in the final snapshot, got %+v", state.Rooms)
+	}
+}
+
+func TestLobby_StartMatch(t *testing.T) {
+	lobby := NewLobby()
+	defer lobby.Close()
+
+	alice := createMockClient(t, "alice")
+	bob := createMockClient(t, "bob")
+	lobby.AddClient(alice)
+	lobby.AddClient(bob)
+
+	// Bob is away, so the match cannot start
+	lobby.SetName("alice", "Alice")
+	err := lobby.StartMatch("alice", "bob", nil)
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) || len(unavailable.ClientIDs) != 1 || unavailable.ClientIDs[0] != "bob" {
+		t.Fatalf("Expected Bob to be unavailable, got %v", err)
+	}
+
+	// Another client cannot take Bob's name while Bob is connected
+	lobby.SetName("bob", "Bob")
+	impostor := createMockClient(t, "impostor")
+	lobby.AddClient(impostor)
+	if err := lobby.SetName("impostor", "Bob"); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("Expected ErrNameTaken for a second Bob, got %v", err)
+	}
+
+	// A queued player is taken out of matchmaking for the match
+	if err := lobby.JoinLobby("bob", types.JoinLobbyMessage{Name: "Bob"}); err != nil {
+		t.Fatal("Bob failed to queue:", err)
+	}
+	expectEvent(t, bob, protocol.TypePlayerWaiting)
+
+	results := make(chan gameroom.Result, 1)
+	if err := lobby.StartMatch("alice", "bob", func(result gameroom.Result) { results <- result }); err != nil {
+		t.Fatal("Failed to start the match:", err)
+	}
+	expectEvent(t, alice, protocol.TypeGameStarting)
+	expectEvent(t, bob, protocol.TypeGameStarting)
+
+	lobby.mu.RLock()
+	waiting := len(lobby.waitingPlayers)
+	var room *gameroom.GameRoom
+	for _, gameRoom := range lobby.gameRooms {
+		room = gameRoom
+	}
+	lobby.mu.RUnlock()
+	if waiting != 0 {
+		t.Errorf("Expected Bob to leave the queue, %d players waiting", waiting)
+	}
+
+	room.Leave("bob")
+	select {
+	case result := <-results:
+		if result.Winner != "Alice" || !result.Forfeit {
+			t.Errorf("Expected Alice to win by forfeit, got %+v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the match result")
+	}
+
+	// One client cannot fill both slots
+	if err := lobby.StartMatch("alice", "alice", nil); !errors.Is(err, ErrPlayerUnavailable) {
+		t.Errorf("Expected Alice to be unavailable as the opponent too, got %v", err)
+	}
+}
+
+func TestLobby_ListsUnsavedRooms(t *testing.T) {
+	lobby := NewLobby(WithSnapshots(snapshot.NewMemoryStore(), 0))
+	defer lobby.Close()
+
+	for i, name := range []string{"Alice", "Bob", "Carol", "Dave", "Erin"} {
+		client := createMockClient(t, "client"+string(rune('1'+i)))
+		lobby.AddClient(client)
+		msg := types.JoinLobbyMessage{Name: name, RoomSize: 3}
+		if i >= 3 {
+			msg = types.JoinLobbyMessage{Name: name}
+		}
+		if err := lobby.JoinLobby(client.ID, msg); err != nil {
+			t.Fatalf("Failed to join %s: %v", name, err)
+		}
+	}
+
+	// The two-player game is saved, the free-for-all is not
+	if state := lobby.Snapshot(); len(state.Rooms) != 1 {
+		t.Errorf("Expected the two-player game in the snapshot, got %+v", state.Rooms)
+	}
+	if unsaved := lobby.unsavedRooms(); !reflect.DeepEqual(unsaved, []string{"room-1 (free-for-all)"}) {
+		t.Errorf("Expected the free-for-all to be listed as unsaved, got %v", unsaved)
+	}
+}
+
+func TestLobby_FillsFreeForAllRooms(t *testing.T) {
+	lobby := NewLobby()
+	defer lobby.Close()
+
+	var players []*types.Client
+	for i, name := range []string{"Alice", "Bob", "Carol"} {
+		client := createMockClient(t, "client"+string(rune('1'+i)))
+		lobby.AddClient(client)
+		players = append(players, client)
+		if err := lobby.JoinLobby(client.ID, types.JoinLobbyMessage{Name: name, RoomSize: 3}); err != nil {
+			t.Fatalf("Failed to join %s: %v", name, err)
+		}
+	}
+
+	// A player asking for a regular game is not pulled into the room
+	dave := createMockClient(t, "client4")
+	lobby.AddClient(dave)
+	lobby.JoinLobby(dave.ID, types.JoinLobbyMessage{Name: "Dave"})
+	if dave.State() != types.StateQueued {
+		t.Errorf("Dave should still be queued, got %s", dave.State())
+	}
+
+	expectEvent(t, players[0], protocol.TypePlayerWaiting)
+	expectEvent(t, players[1], protocol.TypePlayerWaiting)
+	event := expectEvent(t, players[2], protocol.TypeGameStarting)
+	starting, _ := protocol.Decode[types.GameStartingMessage](event)
+	if want := []string{"Alice", "Bob"}; !slices.Equal(starting.Opponents, want) || starting.OpponentName != "Alice, Bob" {
+		t.Errorf("Expected Carol to play Alice and Bob, got %+v", starting)
+	}
+	for _, player := range players {
+		if player.State() != types.StateInGame {
+			t.Errorf("%s should be in game, got %s", player.GetName(), player.State())
+		}
+	}
+}
+
+func TestLobby_FormsTeams(t *testing.T) {
+	lobby := NewLobby()
+	defer lobby.Close()
+
+	// Carol and Dave asked for each other, Alice and Bob team up with
+	// whoever comes
+	joins := []types.JoinLobbyMessage{
+		{Name: "Alice", Teams: true},
+		{Name: "Carol", Teams: true, Teammate: "Dave"},
+		{Name: "Bob", Teams: true},
+		{Name: "Eve", Teams: true, Teammate: "Mallory"},
+		{Name: "Dave", Teams: true, Teammate: "Carol"},
+	}
+	var players []*types.Client
+	for i, join := range joins {
+		client := createMockClient(t, "client"+string(rune('1'+i)))
+		lobby.AddClient(client)
+		players = append(players, client)
+		if err := lobby.JoinLobby(client.ID, join); err != nil {
+			t.Fatalf("Failed to join %s: %v", join.Name, err)
+		}
+	}
+
+	expected := map[string]types.GameStartingMessage{
+		"Alice": {Teammate: "Bob", Captain: "Alice", Opponents: []string{"Carol", "Dave"}},
+		"Bob":   {Teammate: "Alice", Captain: "Alice", Opponents: []string{"Carol", "Dave"}},
+		"Carol": {Teammate: "Dave", Captain: "Carol", Opponents: []string{"Alice", "Bob"}},
+		"Dave":  {Teammate: "Carol", Captain: "Carol", Opponents: []string{"Alice", "Bob"}},
+	}
+	for _, player := range players {
+		want, playing := expected[player.GetName()]
+		if !playing {
+			if player.State() != types.StateQueued {
+				t.Errorf("%s should wait for their teammate, got %s", player.GetName(), player.State())
+			}
+			continue
+		}
+		if player.GetName() != "Dave" {
+			// Everyone else had to wait for the fourth player
+			expectEvent(t, player, protocol.TypePlayerWaiting)
+		}
+		got, _ := protocol.Decode[types.GameStartingMessage](expectEvent(t, player, protocol.TypeGameStarting))
+		if got.Teammate != want.Teammate || got.Captain != want.Captain || !slices.Equal(got.Opponents, want.Opponents) {
+			t.Errorf("Expected %s to start with %+v, got %+v", player.GetName(), want, got)
+		}
+	}
+}
+
+func TestLobby_QueuesPerGameType(t *testing.T) {
+	lobby := NewLobby()
+	defer lobby.Close()
+
+	joins := []types.JoinLobbyMessage{
+		{Name: "Alice", Game: "matching_pennies"},
+		{Name: "Bob"},
+		{Name: "Carol", Game: "matching_pennies"},
+	}
+	var players []*types.Client
+	for i, join := range joins {
+		client := createMockClient(t, "client"+string(rune('1'+i)))
+		lobby.AddClient(client)
+		players = append(players, client)
+		if err := lobby.JoinLobby(client.ID, join); err != nil {
+			t.Fatalf("Failed to join %s: %v", join.Name, err)
+		}
+	}
+
+	// Bob plays Rock Paper Scissors and keeps waiting
+	if players[1].State() != types.StateQueued {
+		t.Errorf("Bob should still be queued, got %s", players[1].State())
+	}
+	expectEvent(t, players[0], protocol.TypePlayerWaiting)
+	for i, role := range map[int]string{2: "matcher", 0: "mismatcher"} {
+		starting, _ := protocol.Decode[types.GameStartingMessage](expectEvent(t, players[i], protocol.TypeGameStarting))
+		if starting.Game != "matching_pennies" || starting.Role != role || !slices.Equal(starting.Actions, []string{"heads", "tails"}) {
+			t.Errorf("Expected %s to play matching pennies as %s, got %+v", players[i].GetName(), role, starting)
+		}
+	}
+
+	dave := createMockClient(t, "client4")
+	lobby.AddClient(dave)
+	if err := lobby.JoinLobby(dave.ID, types.JoinLobbyMessage{Name: "Dave", Game: "chess"}); err == nil {
+		t.Error("Expected an error for an unknown game")
+	}
+	if msg, _ := protocol.Decode[types.ErrorMessage](expectEvent(t, dave, protocol.TypeError)); msg.Code != protocol.ErrorCodeUnknownGame {
+		t.Errorf("Expected UNKNOWN_GAME, got %s", msg.Code)
+	}
+}
@@ -0,0 +1,53 @@
+package lobby
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrMaintenance is returned for requests that would start a game while
+// the lobby is in maintenance mode
+var ErrMaintenance = errors.New("lobby is in maintenance mode")
+
+// Maintenance describes why new games are paused
+type Maintenance struct {
+	Message string    `json:"message"`      // shown to players who try to play
+	ETA     time.Time `json:"eta,omitzero"` // when games resume, zero if unknown
+}
+
+// SetMaintenance pauses new games: join_lobby and play_again get a
+// maintenance error until EndMaintenance. Running games are not affected.
+func (l *Lobby) SetMaintenance(m Maintenance) {
+	l.mu.Lock()
+	defer l.unlock()
+
+	if m.Message == "" {
+		m.Message = "The server is under maintenance, please try again later"
+	}
+	l.maintenance = &m
+	log.Printf("Lobby in maintenance mode until %v: %s", m.ETA, m.Message)
+}
+
+// EndMaintenance lets players start games again
+func (l *Lobby) EndMaintenance() {
+	l.mu.Lock()
+	defer l.unlock()
+
+	if l.maintenance != nil {
+		l.maintenance = nil
+		log.Printf("Lobby maintenance mode ended")
+	}
+}
+
+// Maintenance returns the current maintenance settings and whether the
+// lobby is in maintenance mode
+func (l *Lobby) Maintenance() (Maintenance, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.maintenance == nil {
+		return Maintenance{}, false
+	}
+	return *l.maintenance, true
+}
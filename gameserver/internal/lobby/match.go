@@ -0,0 +1,154 @@
+package lobby
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// ErrPlayerUnavailable is wrapped by every UnavailableError
+var ErrPlayerUnavailable = errors.New("player unavailable")
+
+// ErrNameTaken is returned for a name another client of this instance
+// already uses
+var ErrNameTaken = errors.New("name already taken")
+
+// UnavailableError reports the players of a scheduled match who are not
+// connected to this instance or are busy in another game
+type UnavailableError struct {
+	ClientIDs []string
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrPlayerUnavailable, strings.Join(e.ClientIDs, ", "))
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return ErrPlayerUnavailable
+}
+
+// SetName names a client without queueing it for matchmaking, e.g. for
+// players who only wait for their tournament games. It returns
+// ErrNameTaken if another client uses the name.
+func (l *Lobby) SetName(clientID, name string) error {
+	l.mu.Lock()
+	defer l.unlock()
+
+	client, exists := l.clients[clientID]
+	if !exists {
+		return fmt.Errorf("client %s not found", clientID)
+	}
+	if l.nameTaken(clientID, name) {
+		return fmt.Errorf("%w: %s", ErrNameTaken, name)
+	}
+	if err := client.Transition(types.StateNamed); err != nil {
+		return err
+	}
+	client.SetName(name)
+	log.Printf("Client %s is named %s", clientID, name)
+	return nil
+}
+
+// nameTaken reports whether a client other than clientID goes by name.
+// Names are unique among the clients of this instance, so the players of a
+// game can tell each other apart. The caller must hold mu.
+func (l *Lobby) nameTaken(clientID, name string) bool {
+	for id, client := range l.clients {
+		if id != clientID && client.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// StartMatch starts a game between two clients of this instance chosen by
+// the caller, e.g. a tournament pairing, instead of matchmaking. Clients
+// that are gone or playing are unavailable. onEnd is called with the
+// result once the game is over, from the room's goroutine and without
+// holding any lobby lock.
+func (l *Lobby) StartMatch(player1, player2 string, onEnd func(gameroom.Result)) error {
+	l.mu.Lock()
+	defer l.unlock()
+
+	if l.draining {
+		return ErrDraining
+	}
+	if l.maintenance != nil {
+		return ErrMaintenance
+	}
+
+	client1, client2 := l.idleClient(player1), l.idleClient(player2)
+	if client1 == nil || client2 == nil || client1 == client2 {
+		err := &UnavailableError{}
+		if client1 == nil {
+			err.ClientIDs = append(err.ClientIDs, player1)
+		}
+		if client2 == nil || client2 == client1 {
+			err.ClientIDs = append(err.ClientIDs, player2)
+		}
+		return err
+	}
+
+	for _, client := range []*types.Client{client1, client2} {
+		if client.State() == types.StateQueued {
+			// Called away from matchmaking
+			delete(l.waitingPlayers, client.ID)
+			l.removeTicket(client.ID)
+			continue
+		}
+		if client.State() == types.StateSpectating {
+			if err := client.Transition(types.StateNamed); err != nil {
+				return err
+			}
+		}
+		if err := client.Transition(types.StateQueued); err != nil {
+			return err
+		}
+	}
+
+	roomID := l.startGame(client1, client2, gameroom.DefaultGame)
+	if onEnd != nil {
+		l.matchEnds[roomID] = onEnd
+	}
+	return nil
+}
+
+// idleClient returns the client with the given ID if it can be called into
+// a game: named, back from a game, spectating or waiting for an opponent.
+// The caller must hold mu.
+func (l *Lobby) idleClient(clientID string) *types.Client {
+	client, ok := l.clients[clientID]
+	if !ok || client.GetName() == "" {
+		return nil
+	}
+	switch client.State() {
+	case types.StateNamed, types.StatePostGame, types.StateSpectating:
+		return client
+	case types.StateQueued:
+		// Players waiting for a restored game are spoken for
+		if _, waiting := l.waitingPlayers[client.ID]; waiting {
+			return client
+		}
+	}
+	return nil
+}
+
+// SendTo delivers an encoded event to the client with the given ID if it
+// is connected to this instance, and reports whether it got it
+func (l *Lobby) SendTo(clientID string, event types.BaseGameEvent) bool {
+	l.mu.RLock()
+	client, ok := l.clients[clientID]
+	l.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	if err := client.Deliver(event); err != nil {
+		log.Printf("Lobby: sending %s to %s failed: %v", event.Type, client.ID, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package lobby
+
+import (
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/broker"
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/snapshot"
+)
+
+// Option configures a Lobby
+type Option func(*Lobby)
+
+// WithRoomStarted registers fn to be called with every new game room,
+// after the players entered it and before the first round starts
+func WithRoomStarted(fn func(room *gameroom.GameRoom)) Option {
+	return func(l *Lobby) {
+		l.onRoomStarted = fn
+	}
+}
+
+// WithFreeForAllStarted registers fn to be called with every new
+// free-for-all room, after the players entered it and before the first
+// round starts
+func WithFreeForAllStarted(fn func(room *gameroom.FreeForAll)) Option {
+	return func(l *Lobby) {
+		l.onFreeForAllStarted = fn
+	}
+}
+
+// WithFreeForAllRules sets the rules of the games players who ask for
+// rooms of three or more play
+func WithFreeForAllRules(rules gameroom.Rules) Option {
+	return func(l *Lobby) {
+		l.rules = rules
+	}
+}
+
+// WithTeamRoomStarted registers fn to be called with every new team room,
+// after the players entered it and before the first round starts
+func WithTeamRoomStarted(fn func(room *gameroom.TeamRoom)) Option {
+	return func(l *Lobby) {
+		l.onTeamRoomStarted = fn
+	}
+}
+
+// WithTurnRoomStarted registers fn to be called with every new room of a
+// turn-based game, after the players entered it and before the board is
+// shown
+func WithTurnRoomStarted(fn func(room *gameroom.TurnRoom)) Option {
+	return func(l *Lobby) {
+		l.onTurnRoomStarted = fn
+	}
+}
+
+// WithTeamTieBreak sets how teams whose players vote for different moves
+// decide, TieBreakCaptain by default
+func WithTeamTieBreak(tieBreak gameroom.TieBreak) Option {
+	return func(l *Lobby) {
+		l.tieBreak = tieBreak
+	}
+}
+
+// WithRoundTimeout limits how long each round of a game waits for choices
+func WithRoundTimeout(timeout time.Duration) Option {
+	return func(l *Lobby) {
+		l.roomOptions = append(l.roomOptions, gameroom.WithRoundTimeout(timeout))
+	}
+}
+
+// WithSuddenDeath plays two-player games that end tied on with
+// sudden-death rounds
+func WithSuddenDeath(suddenDeath gameroom.SuddenDeath) Option {
+	return func(l *Lobby) {
+		l.roomOptions = append(l.roomOptions, gameroom.WithSuddenDeath(suddenDeath))
+	}
+}
+
+// WithBroker shares matchmaking with the other instances using b. instance
+// must be unique among them, it is the topic this lobby receives on. The
+// caller owns b and closes it after the lobby.
+func WithBroker(b broker.Broker, instance string) Option {
+	return func(l *Lobby) {
+		l.broker = b
+		l.instance = instance
+	}
+}
+
+// WithRemoteGame registers fn to be called when a local client enters a
+// game hosted by another instance, with the Room its moves go to
+func WithRemoteGame(fn func(clientID string, room Room)) Option {
+	return func(l *Lobby) {
+		l.onRemoteGame = fn
+	}
+}
+
+// WithSnapshots restores the games saved in store when the lobby starts
+// and saves running games every interval. Zero disables the periodic
+// saves, SaveSnapshot and SaveFinalSnapshot still work.
+func WithSnapshots(store snapshot.Store, interval time.Duration) Option {
+	return func(l *Lobby) {
+		l.store = store
+		l.snapshotInterval = interval
+	}
+}
+
+// WithRestoreGrace sets how long restored games wait for their players to
+// reconnect. A player who is back alone after that wins by forfeit.
+func WithRestoreGrace(grace time.Duration) Option {
+	return func(l *Lobby) {
+		l.restoreGrace = grace
+	}
+}
@@ -0,0 +1,435 @@
+package lobby
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/broker"
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// matchQueue is the broker queue players wait in for an opponent in the
+// default game, every other game type has its own
+const matchQueue = "lobby"
+
+// brokerTimeout bounds every call into the broker
+const brokerTimeout = 5 * time.Second
+
+// An ended subscription to other instances is renewed after
+// resubscribeMin, doubling up to resubscribeMax while that fails
+const (
+	resubscribeMin = 100 * time.Millisecond
+	resubscribeMax = 5 * time.Second
+)
+
+// Room is the game a player's in-game messages go to. A *gameroom.GameRoom
+// is a Room for games hosted by this instance, games hosted by another
+// instance are reached through the broker.
+type Room interface {
+	MakeChoice(clientID string, choice gameroom.Choice) error
+	Leave(clientID string)
+}
+
+// MoveRoom is a Room of a turn-based game, which takes make_move. A
+// *gameroom.TurnRoom is a MoveRoom.
+type MoveRoom interface {
+	Room
+	MakeMove(clientID string, move gameroom.Move) error
+}
+
+// Kinds of messages exchanged between instances
+const (
+	relayEvent  = "event"  // host to player's instance: deliver Event to PlayerID
+	relayChoice = "choice" // player's instance to host: PlayerID made Choice
+	relayMove   = "move"   // player's instance to host: PlayerID made Move
+	relayLeave  = "leave"  // player's instance to host: PlayerID is gone
+)
+
+// relayMessage is published to an instance's topic for a game that spans
+// two instances
+type relayMessage struct {
+	Kind     string               `json:"kind"`
+	From     string               `json:"from"` // instance that sent the message
+	RoomID   string               `json:"room_id"`
+	PlayerID string               `json:"player_id"`
+	Event    *types.BaseGameEvent `json:"event,omitempty"`
+	Choice   gameroom.Choice      `json:"choice,omitempty"`
+	Move     *gameroom.Move       `json:"move,omitempty"`
+}
+
+// remoteRoom forwards a local player's messages to the instance hosting
+// their game
+type remoteRoom struct {
+	lobby  *Lobby
+	host   string
+	roomID string
+}
+
+// MakeChoice sends the choice to the host, errors come back as events
+func (r *remoteRoom) MakeChoice(clientID string, choice gameroom.Choice) error {
+	return r.lobby.publish(r.host, relayMessage{Kind: relayChoice, RoomID: r.roomID, PlayerID: clientID, Choice: choice})
+}
+
+// Leave tells the host the player is gone, so the opponent wins
+func (r *remoteRoom) Leave(clientID string) {
+	if err := r.lobby.publish(r.host, relayMessage{Kind: relayLeave, RoomID: r.roomID, PlayerID: clientID}); err != nil {
+		log.Printf("Lobby: telling %s that %s left failed: %v", r.host, clientID, err)
+	}
+}
+
+// remoteTurnRoom forwards a local player's messages to the instance
+// hosting their turn-based game
+type remoteTurnRoom struct {
+	remoteRoom
+}
+
+// MakeMove sends the move to the host, errors come back as events
+func (r *remoteTurnRoom) MakeMove(clientID string, move gameroom.Move) error {
+	return r.lobby.publish(r.host, relayMessage{Kind: relayMove, RoomID: r.roomID, PlayerID: clientID, Move: &move})
+}
+
+// newInstanceID returns a random instance name for lobbies without one
+func newInstanceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// queuedTicket is a ticket in one of the broker's queues
+type queuedTicket struct {
+	queue    string
+	playerID string
+}
+
+// unlock releases mu, then takes the tickets removeTicket collected out
+// of the broker queue
+func (l *Lobby) unlock() {
+	stale := l.staleTickets
+	l.staleTickets = nil
+	l.mu.Unlock()
+
+	for _, t := range stale {
+		ctx, cancel := context.WithTimeout(l.ctx, brokerTimeout)
+		if err := l.broker.Remove(ctx, t.queue, t.playerID); err != nil && l.ctx.Err() == nil {
+			log.Printf("Lobby: removing %s from the queue failed: %v", t.playerID, err)
+		}
+		cancel()
+	}
+}
+
+// matchOrWait pairs a queued client with the oldest ticket in the broker
+// queue, or queues it. ref is the message that queued the client. The
+// caller must hold mu, which is released while the broker pairs.
+func (l *Lobby) matchOrWait(client *types.Client, ref string) error {
+	ticket := broker.Ticket{PlayerID: client.ID, Name: client.GetName(), Instance: l.instance}
+	queue := l.gameQueue(client.ID)
+	for {
+		l.pairing[client.ID] = client
+		opponent, err := l.pair(queue, ticket)
+		_, ticketed := l.pairing[client.ID]
+		delete(l.pairing, client.ID)
+		queued := l.clients[client.ID] == client && client.State() == types.StateQueued
+
+		switch {
+		case err != nil:
+			if queued {
+				client.Transition(types.StateNamed)
+				l.sendError(client, protocol.ErrorCodeMatchmakingUnavailable, ref, "Matchmaking is unavailable, please try again")
+			}
+			return err
+		case !queued:
+			// Left, or was paired by someone else, while the broker paired
+			l.abandonPairing(client, queue, opponent, ref)
+			return nil
+		case opponent == nil && !ticketed:
+			// Another pairing took the ticket and gave it up
+			continue
+		case opponent == nil:
+			l.waitingPlayers[client.ID] = client
+			l.sendPlayerWaiting(client)
+			log.Printf("Client %s (%s) is waiting for opponent", client.ID, client.GetName())
+			return nil
+		case opponent.Instance == l.instance:
+			// The ticket may be left over from a client that is gone
+			if waitingClient := l.waitingClient(opponent.PlayerID); waitingClient != nil {
+				l.startGame(client, waitingClient, l.games[client.ID])
+				return nil
+			}
+		default:
+			l.startRemoteGame(client, opponent)
+			return nil
+		}
+	}
+}
+
+// pair asks the broker for an opponent for t without holding mu, the
+// caller must hold mu
+func (l *Lobby) pair(queue string, t broker.Ticket) (*broker.Ticket, error) {
+	l.unlock()
+	defer l.mu.Lock()
+
+	ctx, cancel := context.WithTimeout(l.ctx, brokerTimeout)
+	defer cancel()
+	return l.broker.Pair(ctx, queue, t)
+}
+
+// waitingClient returns the local client a ticket stands for if it is
+// waiting for an opponent or being paired. The caller must hold mu.
+func (l *Lobby) waitingClient(playerID string) *types.Client {
+	if client, ok := l.waitingPlayers[playerID]; ok {
+		return client
+	}
+	if client, ok := l.pairing[playerID]; ok && l.clients[playerID] == client && client.State() == types.StateQueued {
+		return client
+	}
+	return nil
+}
+
+// abandonPairing cleans up after a client that left the queue while the
+// broker paired it. The caller must hold mu.
+func (l *Lobby) abandonPairing(client *types.Client, queue string, opponent *broker.Ticket, ref string) {
+	switch {
+	case opponent == nil:
+		// Other instances must not pair with the client
+		l.staleTickets = append(l.staleTickets, queuedTicket{queue: queue, playerID: client.ID})
+	case opponent.Instance == l.instance:
+		// The opponent's ticket is gone, so they queue again
+		if waitingClient, ok := l.waitingPlayers[opponent.PlayerID]; ok {
+			delete(l.waitingPlayers, opponent.PlayerID)
+			l.matchOrWait(waitingClient, ref)
+		} else {
+			delete(l.pairing, opponent.PlayerID)
+		}
+	default:
+		// The ticket cannot be put back, so the opponent wins the game
+		// the client left
+		roomID := l.startRemoteGame(client, opponent)
+		if room := l.roomByID(roomID); room != nil {
+			go room.Leave(client.ID)
+		}
+	}
+	log.Printf("Client %s left the queue while being paired", client.ID)
+}
+
+// roomByID returns the room of a two-player game hosted here, or nil. The
+// caller must hold mu.
+func (l *Lobby) roomByID(roomID string) Room {
+	if room, ok := l.gameRooms[roomID]; ok {
+		return room
+	}
+	if room, ok := l.turnRooms[roomID]; ok {
+		return room
+	}
+	return nil
+}
+
+// gameQueue returns the broker queue of the game a client asked for, the
+// caller must hold mu
+func (l *Lobby) gameQueue(clientID string) string {
+	if game, ok := l.games[clientID]; ok {
+		return matchQueue + ":" + game
+	}
+	return matchQueue
+}
+
+// removeTicket takes a client out of the broker queue, or the queue for
+// the free-for-all or team game it waits for. Broker tickets are removed
+// once unlock releases mu. The caller must hold mu.
+func (l *Lobby) removeTicket(clientID string) {
+	if _, teams := l.teamPlayers[clientID]; teams {
+		l.teamQueue = slices.DeleteFunc(l.teamQueue, func(c *types.Client) bool { return c.ID == clientID })
+		return
+	}
+	if size := l.roomSizes[clientID]; size > 2 {
+		l.groups[size] = slices.DeleteFunc(l.groups[size], func(c *types.Client) bool { return c.ID == clientID })
+		return
+	}
+	l.staleTickets = append(l.staleTickets, queuedTicket{queue: l.gameQueue(clientID), playerID: clientID})
+}
+
+// startRemoteGame hosts a game between a local client and a player queued
+// on another instance. The remote player is represented by a proxy client
+// whose events are relayed to their instance. It returns the room ID, the
+// caller must hold mu.
+func (l *Lobby) startRemoteGame(client *types.Client, opponent *broker.Ticket) string {
+	proxy := types.NewClient(opponent.PlayerID, nil)
+	proxy.SetName(opponent.Name)
+	proxy.Transition(types.StateNamed)
+	proxy.Transition(types.StateQueued)
+
+	roomID := l.startGame(client, proxy, l.games[client.ID])
+	l.proxies[roomID] = proxy
+	go l.relay(proxy, roomID, opponent.Instance)
+	log.Printf("Game %s is hosted for %s on instance %s", roomID, opponent.Name, opponent.Instance)
+	return roomID
+}
+
+// relay publishes a proxy's events to the instance of the player it
+// stands for, until the proxy is closed and its buffer is empty
+func (l *Lobby) relay(proxy *types.Client, roomID, instance string) {
+	forward := func(event types.BaseGameEvent) {
+		msg := relayMessage{Kind: relayEvent, RoomID: roomID, PlayerID: proxy.ID, Event: &event}
+		if err := l.publish(instance, msg); err != nil {
+			log.Printf("Lobby: relaying %s to %s failed: %v", event.Type, proxy.ID, err)
+		}
+	}
+
+	for {
+		select {
+		case event := <-proxy.Send:
+			forward(event)
+		case <-proxy.Ctx.Done():
+			for {
+				select {
+				case event := <-proxy.Send:
+					forward(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// publish sends msg to another instance
+func (l *Lobby) publish(instance string, msg relayMessage) error {
+	msg.From = l.instance
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(l.ctx, brokerTimeout)
+	defer cancel()
+	return l.broker.Publish(ctx, broker.InstanceTopic(instance), payload)
+}
+
+// subscribe handles messages from other instances until the lobby is
+// closed. If the subscription ends, or messages is nil because it could
+// not be opened, it subscribes again with backoff.
+func (l *Lobby) subscribe(messages <-chan []byte) {
+	topic := broker.InstanceTopic(l.instance)
+	for {
+		if messages != nil {
+			for payload := range messages {
+				l.handleRelay(payload)
+			}
+		}
+
+		retry := resubscribeMin
+		for {
+			if l.ctx.Err() != nil {
+				return
+			}
+			log.Printf("Lobby: not subscribed to other instances, subscribing again in %v", retry)
+			select {
+			case <-l.ctx.Done():
+				return
+			case <-time.After(retry):
+			}
+
+			var err error
+			if messages, err = l.broker.Subscribe(l.ctx, topic); err == nil {
+				break
+			}
+			if errors.Is(err, broker.ErrClosed) {
+				log.Printf("Lobby: broker closed, no longer receiving messages from other instances")
+				return
+			}
+			log.Printf("Lobby: subscribing to other instances failed: %v", err)
+			retry = min(2*retry, resubscribeMax)
+		}
+		log.Printf("Lobby: subscribed to other instances again")
+	}
+}
+
+// handleRelay handles one message from another instance
+func (l *Lobby) handleRelay(payload []byte) {
+	var msg relayMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		log.Printf("Lobby: dropping malformed relay message: %v", err)
+		return
+	}
+
+	switch msg.Kind {
+	case relayEvent:
+		if msg.Event != nil {
+			l.deliverRemote(msg)
+		}
+	case relayChoice, relayMove, relayLeave:
+		room := l.hostedRoom(msg.RoomID)
+		if room == nil {
+			return // The game is over
+		}
+		switch msg.Kind {
+		case relayChoice:
+			room.MakeChoice(msg.PlayerID, msg.Choice)
+		case relayMove:
+			if moveRoom, ok := room.(MoveRoom); ok && msg.Move != nil {
+				moveRoom.MakeMove(msg.PlayerID, *msg.Move)
+			}
+		default:
+			room.Leave(msg.PlayerID)
+		}
+	}
+}
+
+// hostedRoom returns the room of a two-player game hosted here, or nil
+func (l *Lobby) hostedRoom(roomID string) Room {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.roomByID(roomID)
+}
+
+// deliverRemote passes an event from a game hosted elsewhere to the local
+// player, moving them in and out of the game like a local room would
+func (l *Lobby) deliverRemote(msg relayMessage) {
+	l.mu.Lock()
+	client, ok := l.clients[msg.PlayerID]
+	switch {
+	case !ok:
+	case msg.Event.Type == protocol.TypeGameStarting:
+		delete(l.waitingPlayers, client.ID)
+		if err := client.EnterGame(msg.RoomID); err != nil {
+			// The player left the queue while the host paired them
+			log.Printf("Lobby: %v", err)
+			ok = false
+			break
+		}
+		l.remoteGames[client.ID] = msg.From
+		if l.onRemoteGame != nil {
+			room := remoteRoom{lobby: l, host: msg.From, roomID: msg.RoomID}
+			if gameType, _ := gameroom.LookupGameType(l.games[client.ID]); gameType.TurnBased() {
+				l.onRemoteGame(client.ID, &remoteTurnRoom{room})
+			} else {
+				l.onRemoteGame(client.ID, &room)
+			}
+		}
+	case client.GameRoomID() != msg.RoomID || l.remoteGames[client.ID] != msg.From:
+		// The player is no longer in this game
+		ok = false
+	case msg.Event.Type == protocol.TypeGameEnded:
+		delete(l.remoteGames, client.ID)
+		if err := client.Transition(types.StatePostGame); err != nil {
+			log.Printf("Lobby: %v", err)
+		}
+		l.checkDrained()
+	}
+	l.unlock()
+
+	if !ok {
+		// Nobody here to play, let the host end the game
+		if msg.Event.Type != protocol.TypeGameEnded {
+			l.publish(msg.From, relayMessage{Kind: relayLeave, RoomID: msg.RoomID, PlayerID: msg.PlayerID})
+		}
+		return
+	}
+	client.Deliver(*msg.Event)
+}
@@ -0,0 +1,291 @@
+package lobby
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/snapshot"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// storeTimeout bounds every call into the snapshot store
+const storeTimeout = 5 * time.Second
+
+// restoredRoom is a game from a snapshot waiting for its players to
+// reconnect. players holds the clients that are back, in snapshot order.
+type restoredRoom struct {
+	snap    gameroom.Snapshot
+	players [2]*types.Client
+	expiry  *time.Timer
+}
+
+// restore loads the last snapshot and waits restoreGrace for the players
+// of each game to come back
+func (l *Lobby) restore() {
+	ctx, cancel := context.WithTimeout(l.ctx, storeTimeout)
+	defer cancel()
+
+	state, err := l.store.Load(ctx)
+	if err != nil {
+		log.Printf("Lobby: cannot load snapshot: %v", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.unlock()
+	for _, snap := range state.Rooms {
+		if err := snap.Validate(); err != nil {
+			log.Printf("Lobby: skipping %v", err)
+			continue
+		}
+		id := snap.ID
+		l.restored[id] = &restoredRoom{
+			snap:   snap,
+			expiry: time.AfterFunc(l.restoreGrace, func() { l.expireRestored(id) }),
+		}
+	}
+	if len(l.restored) > 0 {
+		log.Printf("Lobby: restored %d games saved at %s, players have %v to reconnect",
+			len(l.restored), state.SavedAt.Format(time.RFC3339), l.restoreGrace)
+	}
+}
+
+// claimRestored seats a queued client in the restored game they played
+// under the same session and reports whether there was one. The client
+// must have asked for that game, two-player and without teams. The game
+// resumes once both players are back. The caller must hold mu.
+func (l *Lobby) claimRestored(client *types.Client) bool {
+	if !l.wantsRestoredGame(client.ID) {
+		return false
+	}
+	session := client.Session()
+	requested, _ := gameroom.LookupGameType(l.games[client.ID])
+	for _, r := range l.restored {
+		if game, _ := gameroom.LookupGameType(r.snap.Game); game.Name != requested.Name {
+			continue
+		}
+		for i, fingerprint := range []*string{&r.snap.Player1Session, &r.snap.Player2Session} {
+			if r.players[i] != nil || !session.Claims(*fingerprint) {
+				continue
+			}
+			r.players[i] = client
+			// The player presents the new session after another restart
+			*fingerprint = session.Fingerprint()
+			if r.players[0] != nil && r.players[1] != nil {
+				l.resumeRestored(r)
+			} else {
+				l.sendPlayerWaiting(client)
+				log.Printf("Client %s (%s) is back for game %s, waiting for the opponent", client.ID, client.GetName(), r.snap.ID)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// wantsRestoredGame reports whether a client asked for a game a restored
+// two-player room can continue. The caller must hold mu.
+func (l *Lobby) wantsRestoredGame(clientID string) bool {
+	if _, teams := l.teamPlayers[clientID]; teams {
+		return false
+	}
+	return l.roomSizes[clientID] <= 2
+}
+
+// resumeRestored continues a restored game whose players are both back,
+// the caller must hold mu
+func (l *Lobby) resumeRestored(r *restoredRoom) {
+	r.expiry.Stop()
+	delete(l.restored, r.snap.ID)
+
+	l.gameRoomCounter++
+	gameRoomID := fmt.Sprintf("room-%d", l.gameRoomCounter)
+	player1, player2 := r.players[0], r.players[1]
+
+	gameRoom, err := gameroom.RestoreGameRoom(gameRoomID, r.snap, player1, player2, l.onGameEnd, l.roomOptions...)
+	if err != nil {
+		// Validated on load, so this is a bug. Let the players start over.
+		log.Printf("Lobby: cannot resume game %s: %v", r.snap.ID, err)
+		l.matchOrWait(player1, protocol.TypeJoinLobby)
+		l.matchOrWait(player2, protocol.TypeJoinLobby)
+		return
+	}
+	l.gameRooms[gameRoomID] = gameRoom
+	if l.onRoomStarted != nil {
+		l.onRoomStarted(gameRoom)
+	}
+
+	gameType, _ := gameroom.LookupGameType(r.snap.Game)
+	protocol.Send(player1, types.GameResumedMessage{
+		OpponentName: player2.GetName(),
+		RoundNumber:  r.snap.CurrentRound,
+		YourWins:     r.snap.Player1Wins,
+		OpponentWins: r.snap.Player2Wins,
+		Game:         gameType.Name,
+		Role:         gameType.Roles[0],
+		Actions:      actionNames(gameType, 0),
+	})
+	protocol.Send(player2, types.GameResumedMessage{
+		OpponentName: player1.GetName(),
+		RoundNumber:  r.snap.CurrentRound,
+		YourWins:     r.snap.Player2Wins,
+		OpponentWins: r.snap.Player1Wins,
+		Game:         gameType.Name,
+		Role:         gameType.Roles[1],
+		Actions:      actionNames(gameType, 1),
+	})
+	gameRoom.StartFirstRound()
+
+	log.Printf("Game %s between %s and %s resumed in room %s at round %d (%d-%d)",
+		r.snap.ID, player1.GetName(), player2.GetName(), gameRoomID,
+		r.snap.CurrentRound, r.snap.Player1Wins, r.snap.Player2Wins)
+}
+
+// expireRestored ends a restored game whose grace period passed. A player
+// who came back wins, the game is dropped if nobody did.
+func (l *Lobby) expireRestored(id string) {
+	l.mu.Lock()
+	defer l.unlock()
+
+	r, ok := l.restored[id]
+	if !ok {
+		return // Resumed in the meantime
+	}
+	delete(l.restored, id)
+
+	for _, player := range r.players {
+		if player == nil {
+			continue
+		}
+		if err := player.EnterGame(id); err != nil {
+			log.Printf("Lobby: %v", err)
+			continue
+		}
+		if err := player.Transition(types.StatePostGame); err != nil {
+			log.Printf("Lobby: %v", err)
+		}
+		protocol.Send(player, types.GameEndedMessage{Result: "win"})
+	}
+	log.Printf("Restored game %s expired before both players reconnected", id)
+}
+
+// leaveRestored gives up a client's seat in a restored game, so another
+// connection of the player can claim it. The caller must hold mu.
+func (l *Lobby) leaveRestored(clientID string) {
+	for _, r := range l.restored {
+		for i, player := range r.players {
+			if player != nil && player.ID == clientID {
+				r.players[i] = nil
+			}
+		}
+	}
+}
+
+// Snapshot returns the state of every game hosted by this lobby, including
+// restored games still waiting for their players
+func (l *Lobby) Snapshot() snapshot.State {
+	state := snapshot.State{SavedAt: time.Now().UTC()}
+
+	l.mu.RLock()
+	rooms := make([]*gameroom.GameRoom, 0, len(l.gameRooms))
+	for _, gameRoom := range l.gameRooms {
+		rooms = append(rooms, gameRoom)
+	}
+	for _, r := range l.restored {
+		state.Rooms = append(state.Rooms, r.snap)
+	}
+	l.mu.RUnlock()
+
+	// Rooms answer on their own goroutine, which may need mu to end a game
+	for _, gameRoom := range rooms {
+		if snap, err := gameRoom.Snapshot(); err == nil {
+			state.Rooms = append(state.Rooms, snap)
+		}
+	}
+	return state
+}
+
+// SaveSnapshot writes the current state to the snapshot store, if the
+// lobby has one
+func (l *Lobby) SaveSnapshot(ctx context.Context) error {
+	return l.saveSnapshot(ctx, false)
+}
+
+// SaveFinalSnapshot writes the state one last time before shutdown. Later
+// saves are skipped, so games forfeited by the closing connections are
+// still restored by the next server.
+func (l *Lobby) SaveFinalSnapshot(ctx context.Context) error {
+	return l.saveSnapshot(ctx, true)
+}
+
+func (l *Lobby) saveSnapshot(ctx context.Context, final bool) error {
+	if l.store == nil {
+		return nil
+	}
+
+	l.saveMu.Lock()
+	defer l.saveMu.Unlock()
+	if l.snapshotsStopped {
+		return nil
+	}
+	l.snapshotsStopped = final
+
+	state := l.Snapshot()
+	if err := l.store.Save(ctx, state); err != nil {
+		return err
+	}
+	if final {
+		log.Printf("Lobby: saved %d games for the next start", len(state.Rooms))
+		if unsaved := l.unsavedRooms(); len(unsaved) > 0 {
+			log.Printf("Lobby: %d games are not saved and end with this server: %s",
+				len(unsaved), strings.Join(unsaved, ", "))
+		}
+	}
+	return nil
+}
+
+// unsavedRooms lists the running games snapshots do not cover, sorted by
+// room ID. Only two-player games are saved.
+func (l *Lobby) unsavedRooms() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var rooms []string
+	for id := range l.freeForAlls {
+		rooms = append(rooms, id+" (free-for-all)")
+	}
+	for id := range l.teamRooms {
+		rooms = append(rooms, id+" (team game)")
+	}
+	for id := range l.turnRooms {
+		rooms = append(rooms, id+" (turn-based)")
+	}
+	slices.Sort(rooms)
+	return rooms
+}
+
+// snapshotLoop saves a snapshot every snapshotInterval until the lobby is
+// closed
+func (l *Lobby) snapshotLoop() {
+	ticker := time.NewTicker(l.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(l.ctx, storeTimeout)
+			if err := l.SaveSnapshot(ctx); err != nil {
+				log.Printf("Lobby: saving snapshot failed: %v", err)
+			}
+			cancel()
+		}
+	}
+}
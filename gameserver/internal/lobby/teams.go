@@ -0,0 +1,109 @@
+package lobby
+
+import (
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// fillTeams adds a client to the players waiting for a team game and
+// starts one once two teams can be formed. Like free-for-alls, team games
+// are only filled with players of this instance. The caller must hold mu.
+func (l *Lobby) fillTeams(client *types.Client) {
+	l.waitingPlayers[client.ID] = client
+	l.teamQueue = append(l.teamQueue, client)
+
+	teams := l.formTeams()
+	if len(teams) < 2 {
+		l.sendPlayerWaiting(client)
+		log.Printf("Client %s (%s) is waiting for a team game, %d there", client.ID, client.GetName(), len(l.teamQueue))
+		return
+	}
+	l.teamQueue = slices.DeleteFunc(l.teamQueue, func(c *types.Client) bool {
+		return slices.Contains(teams[0], c) || slices.Contains(teams[1], c)
+	})
+	l.startTeamGame([2][]*types.Client{teams[0], teams[1]})
+}
+
+// formTeams returns the first two teams that can be formed from the team
+// queue, or fewer if there are not enough players. Players who asked for
+// each other as teammates team up, everybody else with the longest waiting
+// player who did not ask for anyone. A team's captain is its player who
+// waited longer. The caller must hold mu.
+func (l *Lobby) formTeams() [][]*types.Client {
+	var teams [][]*types.Client
+	var single *types.Client
+	taken := make(map[string]bool)
+	for i, client := range l.teamQueue {
+		if taken[client.ID] {
+			continue
+		}
+		teammate := l.teamPlayers[client.ID]
+		if teammate == "" {
+			if single == nil {
+				single = client
+				continue
+			}
+			teams = append(teams, []*types.Client{single, client})
+			single = nil
+		} else {
+			j := slices.IndexFunc(l.teamQueue[i+1:], func(c *types.Client) bool {
+				return c.GetName() == teammate && l.teamPlayers[c.ID] == client.GetName()
+			})
+			if j < 0 {
+				// Waits for the teammate
+				continue
+			}
+			other := l.teamQueue[i+1+j]
+			taken[other.ID] = true
+			teams = append(teams, []*types.Client{client, other})
+		}
+		if len(teams) == 2 {
+			break
+		}
+	}
+	return teams
+}
+
+// startTeamGame starts a game between two teams and returns its room ID,
+// the caller must hold mu
+func (l *Lobby) startTeamGame(teams [2][]*types.Client) string {
+	for _, team := range teams {
+		for _, player := range team {
+			delete(l.waitingPlayers, player.ID)
+		}
+	}
+
+	l.gameRoomCounter++
+	gameRoomID := fmt.Sprintf("room-%d", l.gameRoomCounter)
+	room := gameroom.NewTeamRoom(gameRoomID, teams, l.tieBreak, l.onGameEnd, l.roomOptions...)
+	l.teamRooms[gameRoomID] = room
+	if l.onTeamRoomStarted != nil {
+		l.onTeamRoomStarted(room)
+	}
+
+	for i, team := range teams {
+		opponents := []string{teams[1-i][0].GetName(), teams[1-i][1].GetName()}
+		captain := ""
+		if l.tieBreak == "" || l.tieBreak == gameroom.TieBreakCaptain {
+			captain = team[0].GetName()
+		}
+		for j, player := range team {
+			protocol.Send(player, types.GameStartingMessage{
+				OpponentName: opponents[0] + ", " + opponents[1],
+				Opponents:    opponents,
+				Teammate:     team[1-j].GetName(),
+				Captain:      captain,
+			})
+		}
+	}
+
+	room.StartFirstRound()
+	log.Printf("Team game starting between %s and %s against %s and %s in room %s",
+		teams[0][0].GetName(), teams[0][1].GetName(), teams[1][0].GetName(), teams[1][1].GetName(), gameRoomID)
+	return gameRoomID
+}
@@ -0,0 +1,26 @@
+package lobby
+
+import (
+	"log"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// startTurnGame starts a turn-based game in the room with the given ID and
+// returns the ID. player1 moves first. The caller must hold mu.
+func (l *Lobby) startTurnGame(gameRoomID string, player1, player2 *types.Client, gameType gameroom.GameType) string {
+	room := gameroom.NewTurnRoom(gameRoomID, player1, player2, gameType, l.onGameEnd, l.roomOptions...)
+	l.turnRooms[gameRoomID] = room
+	if l.onTurnRoomStarted != nil {
+		l.onTurnRoomStarted(room)
+	}
+
+	l.sendGameStarting(player1, player2.GetName(), gameType, 0)
+	l.sendGameStarting(player2, player1.GetName(), gameType, 1)
+
+	// The empty board goes out after game_starting
+	room.StartFirstRound()
+	log.Printf("%s starting between %s and %s in room %s", gameType.Name, player1.GetName(), player2.GetName(), gameRoomID)
+	return gameRoomID
+}
@@ -0,0 +1,387 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// ErrTruncated is returned when the input ends in the middle of a value
+var ErrTruncated = errors.New("msgpack: truncated input")
+
+// ErrTooDeep is returned for arrays and maps nested deeper than MaxDepth
+var ErrTooDeep = errors.New("msgpack: nested too deeply")
+
+// MaxDepth is how deeply Unmarshal lets arrays and maps nest, so a small
+// hostile input cannot exhaust the stack
+const MaxDepth = 64
+
+// Marshal encodes a generic value tree into MessagePack. Supported values
+// are nil, bool, string, []byte, json.Number, the Go integer and float
+// types, []any and map[string]any - everything encoding/json produces when
+// decoding into an interface with UseNumber.
+func Marshal(v any) ([]byte, error) {
+	var buf []byte
+	return appendValue(buf, v)
+}
+
+func appendValue(buf []byte, v any) ([]byte, error) {
+	switch val := v.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if val {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case string:
+		return appendString(buf, val), nil
+	case []byte:
+		return appendBinary(buf, val), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(val), 10, 64); err == nil {
+			return appendInt(buf, i), nil
+		}
+		if u, err := strconv.ParseUint(string(val), 10, 64); err == nil {
+			return appendUint(buf, u), nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("msgpack: invalid number %q", val)
+		}
+		return appendFloat(buf, f), nil
+	case int:
+		return appendInt(buf, int64(val)), nil
+	case int8:
+		return appendInt(buf, int64(val)), nil
+	case int16:
+		return appendInt(buf, int64(val)), nil
+	case int32:
+		return appendInt(buf, int64(val)), nil
+	case int64:
+		return appendInt(buf, val), nil
+	case uint:
+		return appendUint(buf, uint64(val)), nil
+	case uint8:
+		return appendUint(buf, uint64(val)), nil
+	case uint16:
+		return appendUint(buf, uint64(val)), nil
+	case uint32:
+		return appendUint(buf, uint64(val)), nil
+	case uint64:
+		return appendUint(buf, val), nil
+	case float32:
+		return appendFloat(buf, float64(val)), nil
+	case float64:
+		return appendFloat(buf, val), nil
+	case []any:
+		buf = appendLength(buf, len(val), 0x90, 0xdc, 0xdd)
+		var err error
+		for _, item := range val {
+			if buf, err = appendValue(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]any:
+		// Sort keys so the same value always encodes to the same bytes
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		buf = appendLength(buf, len(val), 0x80, 0xde, 0xdf)
+		var err error
+		for _, key := range keys {
+			buf = appendString(buf, key)
+			if buf, err = appendValue(buf, val[key]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+}
+
+// appendLength writes an array or map header. fix is the fixarray/fixmap
+// prefix, short and long the 16 and 32 bit variants.
+func appendLength(buf []byte, n int, fix, short, long byte) []byte {
+	switch {
+	case n < 16:
+		return append(buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, short), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(buf, long), uint32(n))
+	}
+}
+
+func appendString(buf []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(n))
+	}
+	return append(buf, s...)
+}
+
+func appendBinary(buf []byte, b []byte) []byte {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xc5), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xc6), uint32(n))
+	}
+	return append(buf, b...)
+}
+
+func appendInt(buf []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendUint(buf, uint64(i))
+	case i >= -32:
+		return append(buf, byte(i))
+	case i >= math.MinInt8:
+		return append(buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(i))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(i))
+	}
+}
+
+func appendUint(buf []byte, u uint64) []byte {
+	switch {
+	case u <= 0x7f:
+		return append(buf, byte(u))
+	case u <= math.MaxUint8:
+		return append(buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xce), uint32(u))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 0xcf), u)
+	}
+}
+
+func appendFloat(buf []byte, f float64) []byte {
+	return binary.BigEndian.AppendUint64(append(buf, 0xcb), math.Float64bits(f))
+}
+
+// Unmarshal decodes a single MessagePack value. Maps decode to
+// map[string]any, arrays to []any, integers to int64 or uint64, floats to
+// float64 and binary data to []byte.
+func Unmarshal(data []byte) (any, error) {
+	d := decoder{data: data}
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("msgpack: %d trailing bytes", len(d.data)-d.pos)
+	}
+	return v, nil
+}
+
+type decoder struct {
+	data  []byte
+	pos   int
+	depth int // arrays and maps entered
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, ErrTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *decoder) value() (any, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	tag := b[0]
+
+	switch {
+	case tag <= 0x7f:
+		return int64(tag), nil
+	case tag >= 0xe0:
+		return int64(int8(tag)), nil
+	case tag&0xe0 == 0xa0:
+		return d.str(int(tag & 0x1f))
+	case tag&0xf0 == 0x90:
+		return d.array(int(tag & 0x0f))
+	case tag&0xf0 == 0x80:
+		return d.mapping(int(tag & 0x0f))
+	}
+
+	switch tag {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (tag - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 0xca:
+		u, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (tag - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if u <= math.MaxInt64 {
+			return int64(u), nil
+		}
+		return u, nil
+	case 0xd0:
+		u, err := d.uint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.uint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.uint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.uint(8)
+		return int64(u), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (tag - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (tag - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (tag - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapping(int(n))
+	default:
+		return nil, fmt.Errorf("msgpack: unsupported type byte 0x%02x", tag)
+	}
+}
+
+func (d *decoder) str(n int) (string, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (d *decoder) array(n int) ([]any, error) {
+	// Every element takes at least one byte, which bounds the allocation
+	if n > len(d.data)-d.pos {
+		return nil, ErrTruncated
+	}
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	items := make([]any, 0, n)
+	for i := 0; i < n; i++ {
+		item, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (d *decoder) mapping(n int) (map[string]any, error) {
+	if n > len(d.data)-d.pos {
+		return nil, ErrTruncated
+	}
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	m := make(map[string]any, n)
+	for i := 0; i < n; i++ {
+		key, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack: map key must be a string, got %T", key)
+		}
+		if m[name], err = d.value(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// enter counts an array or map being decoded, failing beyond MaxDepth
+func (d *decoder) enter() error {
+	if d.depth >= MaxDepth {
+		return ErrTooDeep
+	}
+	d.depth++
+	return nil
+}
+
+func (d *decoder) leave() {
+	d.depth--
+}
@@ -0,0 +1,130 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMsgpack_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want any
+	}{
+		{"nil", nil, nil},
+		{"true", true, true},
+		{"false", false, false},
+		{"fixint", 7, int64(7)},
+		{"uint8", 200, int64(200)},
+		{"uint16", 60000, int64(60000)},
+		{"uint32", int64(4000000000), int64(4000000000)},
+		{"uint64", uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{"negative fixint", -5, int64(-5)},
+		{"int8", -100, int64(-100)},
+		{"int16", -30000, int64(-30000)},
+		{"int32", -2000000000, int64(-2000000000)},
+		{"int64", int64(math.MinInt64), int64(math.MinInt64)},
+		{"float", 2.5, 2.5},
+		{"json integer", json.Number("42"), int64(42)},
+		{"json float", json.Number("0.25"), 0.25},
+		{"fixstr", "rock", "rock"},
+		{"str8", strings.Repeat("a", 100), strings.Repeat("a", 100)},
+		{"str16", strings.Repeat("b", 1000), strings.Repeat("b", 1000)},
+		{"binary", []byte{1, 2, 3}, []byte{1, 2, 3}},
+		{"array", []any{"rock", 1, nil}, []any{"rock", int64(1), nil}},
+		{"map", map[string]any{"choice": "paper", "round": 2}, map[string]any{"choice": "paper", "round": int64(2)}},
+		{"nested", map[string]any{"players": []any{map[string]any{"name": "Alice"}}}, map[string]any{"players": []any{map[string]any{"name": "Alice"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Marshal(tt.in)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			got, err := Unmarshal(data)
+			if err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMsgpack_LargeCollections(t *testing.T) {
+	items := make([]any, 70000)
+	fields := make(map[string]any, 20)
+	for i := range items {
+		items[i] = int64(i % 100)
+	}
+	for i := 0; i < 20; i++ {
+		fields[strings.Repeat("k", i+1)] = int64(i)
+	}
+
+	data, err := Marshal(map[string]any{"items": items, "fields": fields})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	m := got.(map[string]any)
+	if len(m["items"].([]any)) != len(items) {
+		t.Errorf("Expected %d items, got %d", len(items), len(m["items"].([]any)))
+	}
+	if !reflect.DeepEqual(m["fields"], fields) {
+		t.Error("Map with more than 15 keys did not round trip")
+	}
+}
+
+func TestMsgpack_DeterministicMapEncoding(t *testing.T) {
+	value := map[string]any{"b": 1, "a": 2, "c": 3}
+	first, _ := Marshal(value)
+	for i := 0; i < 10; i++ {
+		again, _ := Marshal(value)
+		if !bytes.Equal(first, again) {
+			t.Fatal("Map encoding should not depend on iteration order")
+		}
+	}
+}
+
+func TestMsgpack_Errors(t *testing.T) {
+	if _, err := Marshal(struct{}{}); err == nil {
+		t.Error("Expected error for unsupported type")
+	}
+
+	if _, err := Unmarshal([]byte{0xa5, 'r', 'o'}); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected ErrTruncated for short string, got %v", err)
+	}
+	if _, err := Unmarshal([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected ErrTruncated for oversized array header, got %v", err)
+	}
+	if _, err := Unmarshal([]byte{0x81, 0x01, 0x02}); err == nil {
+		t.Error("Expected error for non-string map key")
+	}
+	if _, err := Unmarshal([]byte{0xc0, 0xc0}); err == nil {
+		t.Error("Expected error for trailing bytes")
+	}
+	if _, err := Unmarshal([]byte{0xc1}); err == nil {
+		t.Error("Expected error for reserved type byte")
+	}
+
+	// A megabyte of one-element arrays inside each other
+	deep := append(bytes.Repeat([]byte{0x91}, 1<<20), 0xc0)
+	if _, err := Unmarshal(deep); !errors.Is(err, ErrTooDeep) {
+		t.Errorf("Expected ErrTooDeep for deeply nested arrays, got %v", err)
+	}
+	nested := append(bytes.Repeat([]byte{0x81, 0xa1, 'a'}, MaxDepth), 0xc0)
+	if _, err := Unmarshal(nested); err != nil {
+		t.Errorf("Expected maps nested %d deep to decode, got %v", MaxDepth, err)
+	}
+}
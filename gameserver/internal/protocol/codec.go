@@ -0,0 +1,100 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+var (
+	// ErrUnknownType is returned for message types missing from the registry
+	ErrUnknownType = errors.New("unknown message type")
+	// ErrTypeMismatch is returned when an event is decoded into the wrong struct
+	ErrTypeMismatch = errors.New("message type mismatch")
+	// ErrMalformed is returned when a payload does not unmarshal into its struct
+	ErrMalformed = errors.New("malformed message")
+)
+
+// ValidationError reports a message that decoded but failed its validator.
+// Reason is meant to be shown to the player, Code is sent along with it
+// and defaults to ErrorCodeInvalidMessage.
+type ValidationError struct {
+	Type   string
+	Code   string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s message: %s", e.Type, e.Reason)
+}
+
+// Encode wraps a registered message struct into a BaseGameEvent
+func Encode[T any](msg T) (types.BaseGameEvent, error) {
+	spec, ok := lookupGoType(reflect.TypeFor[T]())
+	if !ok {
+		return types.BaseGameEvent{}, fmt.Errorf("%w: %s", ErrUnknownType, reflect.TypeFor[T]())
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return types.BaseGameEvent{}, fmt.Errorf("marshal %s: %w", spec.Type, err)
+	}
+
+	return types.BaseGameEvent{
+		Type: spec.Type,
+		Data: data,
+	}, nil
+}
+
+// Decode unpacks and validates the payload of a BaseGameEvent into T
+func Decode[T any](event types.BaseGameEvent) (T, error) {
+	var msg T
+
+	spec, ok := Lookup(event.Type)
+	if !ok {
+		return msg, fmt.Errorf("%w: %s", ErrUnknownType, event.Type)
+	}
+	if spec.GoType != reflect.TypeFor[T]() {
+		return msg, fmt.Errorf("%w: %s cannot decode into %s", ErrTypeMismatch, event.Type, reflect.TypeFor[T]())
+	}
+
+	// Empty payloads are allowed for messages without fields
+	if len(event.Data) > 0 && string(event.Data) != "null" {
+		if err := json.Unmarshal(event.Data, &msg); err != nil {
+			return msg, fmt.Errorf("%w: %s: %v", ErrMalformed, event.Type, err)
+		}
+	}
+
+	if err := spec.Validate(msg); err != nil {
+		return msg, err
+	}
+	return msg, nil
+}
+
+// Send encodes msg and hands it to the client's delivery policy.
+// It returns false if the client is closed or had to be disconnected
+// because it stopped reading.
+func Send[T any](client *types.Client, msg T) bool {
+	if client == nil {
+		return false
+	}
+
+	event, err := Encode(msg)
+	if err != nil {
+		log.Printf("Failed to encode message for client %s: %v", client.ID, err)
+		return false
+	}
+
+	if err := client.Deliver(event); err != nil {
+		if errors.Is(err, types.ErrClientClosed) {
+			return false
+		}
+		log.Printf("Failed to send %s to client %s: %v", event.Type, client.ID, err)
+		return false
+	}
+	return true
+}
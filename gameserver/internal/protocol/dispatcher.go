@@ -0,0 +1,52 @@
+package protocol
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// Dispatcher routes decoded client messages to registered handlers
+type Dispatcher struct {
+	handlers map[string]func(client *types.Client, event types.BaseGameEvent) error
+}
+
+// NewDispatcher creates an empty dispatcher
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		handlers: make(map[string]func(*types.Client, types.BaseGameEvent) error),
+	}
+}
+
+// On registers handler for the client message type bound to T.
+// It panics if T is not a registered client message or already has a handler.
+func On[T any](d *Dispatcher, handler func(client *types.Client, msg T) error) {
+	spec, ok := lookupGoType(reflect.TypeFor[T]())
+	if !ok {
+		panic(fmt.Sprintf("protocol: no message type registered for %s", reflect.TypeFor[T]()))
+	}
+	if spec.Direction != ClientToServer {
+		panic(fmt.Sprintf("protocol: %s is not a client message", spec.Type))
+	}
+	if _, exists := d.handlers[spec.Type]; exists {
+		panic(fmt.Sprintf("protocol: handler for %s registered twice", spec.Type))
+	}
+
+	d.handlers[spec.Type] = func(client *types.Client, event types.BaseGameEvent) error {
+		msg, err := Decode[T](event)
+		if err != nil {
+			return err
+		}
+		return handler(client, msg)
+	}
+}
+
+// Dispatch decodes event and calls the handler registered for its type
+func (d *Dispatcher) Dispatch(client *types.Client, event types.BaseGameEvent) error {
+	handler, ok := d.handlers[event.Type]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownType, event.Type)
+	}
+	return handler(client, event)
+}
@@ -0,0 +1,59 @@
+package protocol
+
+// Error codes sent in ErrorMessage.Code. Clients should branch on the code
+// and only show the message to the player.
+const (
+	// ErrorCodeUpgradeRequired means the client protocol is too old
+	ErrorCodeUpgradeRequired = "UPGRADE_REQUIRED"
+	// ErrorCodeInvalidState means the message is not allowed in the
+	// client's current state
+	ErrorCodeInvalidState = "INVALID_STATE"
+	// ErrorCodeInvalidMessage means the payload could not be decoded or
+	// failed validation
+	ErrorCodeInvalidMessage = "INVALID_MESSAGE"
+	// ErrorCodeUnknownMessageType means the message type is not registered
+	ErrorCodeUnknownMessageType = "UNKNOWN_MESSAGE_TYPE"
+	// ErrorCodeFeatureDisabled means the message needs a feature that was
+	// not negotiated in the handshake
+	ErrorCodeFeatureDisabled = "FEATURE_DISABLED"
+	// ErrorCodeNameInvalid means the player name was rejected
+	ErrorCodeNameInvalid = "NAME_INVALID"
+	// ErrorCodeNameTaken means another connected player has the same name,
+	// or registered it for the tournament
+	ErrorCodeNameTaken = "NAME_TAKEN"
+	// ErrorCodeNotInGame means the player, or the player to spectate, is
+	// not in a game
+	ErrorCodeNotInGame = "NOT_IN_GAME"
+	// ErrorCodeInvalidChoice means the choice is not one of the game's
+	// actions
+	ErrorCodeInvalidChoice = "INVALID_CHOICE"
+	// ErrorCodeInvalidMove means the move is off the board or the cell is
+	// taken
+	ErrorCodeInvalidMove = "INVALID_MOVE"
+	// ErrorCodeNotYourTurn means the player moved while it is the
+	// opponent's turn
+	ErrorCodeNotYourTurn = "NOT_YOUR_TURN"
+	// ErrorCodeRateLimited means the client sent messages too fast
+	ErrorCodeRateLimited = "RATE_LIMITED"
+	// ErrorCodeShuttingDown means no new games start because the server is
+	// draining before shutdown
+	ErrorCodeShuttingDown = "SHUTTING_DOWN"
+	// ErrorCodeMaintenance means no new games start during maintenance,
+	// details carry the eta if known
+	ErrorCodeMaintenance = "MAINTENANCE"
+	// ErrorCodeMatchmakingUnavailable means the shared matchmaking queue
+	// could not be reached, the player may try again
+	ErrorCodeMatchmakingUnavailable = "MATCHMAKING_UNAVAILABLE"
+	// ErrorCodeTournamentNotFound means there is no tournament or league
+	// with the requested ID
+	ErrorCodeTournamentNotFound = "TOURNAMENT_NOT_FOUND"
+	// ErrorCodeTournamentClosed means the tournament or league no longer
+	// takes registrations because it has started
+	ErrorCodeTournamentClosed = "TOURNAMENT_CLOSED"
+	// ErrorCodeUnknownGame means the game type asked for in join_lobby
+	// does not exist
+	ErrorCodeUnknownGame = "UNKNOWN_GAME"
+	// ErrorCodeMatchNotFound means the player has no correspondence match
+	// with the requested ID
+	ErrorCodeMatchNotFound = "MATCH_NOT_FOUND"
+)
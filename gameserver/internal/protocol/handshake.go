@@ -0,0 +1,31 @@
+package protocol
+
+// Version is the protocol version spoken by this server
+const Version = 1
+
+// MinSupportedVersion is the oldest client protocol version still accepted
+const MinSupportedVersion = 1
+
+// Optional features a client can announce in its hello message
+const (
+	FeatureResume   = "resume"
+	FeatureSpectate = "spectate"
+	FeatureRulesets = "rulesets"
+)
+
+// NegotiateFeatures returns the features offered by both sides, in the
+// order the server lists them
+func NegotiateFeatures(server, client []string) []string {
+	offered := make(map[string]bool, len(client))
+	for _, feature := range client {
+		offered[feature] = true
+	}
+
+	agreed := []string{}
+	for _, feature := range server {
+		if offered[feature] {
+			agreed = append(agreed, feature)
+		}
+	}
+	return agreed
+}
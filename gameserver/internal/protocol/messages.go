@@ -0,0 +1,140 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// Client to Server message types
+const (
+	TypeHello          = "hello"
+	TypeAck            = "ack"
+	TypeJoinLobby      = "join_lobby"
+	TypeMakeChoice     = "make_choice"
+	TypeMakeMove       = "make_move"
+	TypePlayAgain      = "play_again"
+	TypeSpectate       = "spectate"
+	TypeDisconnect     = "disconnect"
+	TypeJoinTournament = "join_tournament"
+	TypeCreateMatch    = "create_match"
+	TypeListMatches    = "list_matches"
+)
+
+// MaxRoomSize is the largest room_size a client can ask for in join_lobby
+const MaxRoomSize = 8
+
+// Server to Client message types
+const (
+	TypeWelcome          = "welcome"
+	TypePlayerWaiting    = "player_waiting"
+	TypeGameStarting     = "game_starting"
+	TypeRoundResult      = "round_result"
+	TypeRoundStart       = "round_start"
+	TypeGameEnded        = "game_ended"
+	TypeSpectateUpdate   = "spectate_update"
+	TypeShuttingDown     = "server_shutting_down"
+	TypeGameResumed      = "game_resumed"
+	TypeTournamentUpdate = "tournament_update"
+	TypeLeagueUpdate     = "league_update"
+	TypeTeamVote         = "team_vote"
+	TypeBoardUpdate      = "board_update"
+	TypeMatchList        = "match_list"
+	TypeMatchUpdate      = "match_update"
+	TypeYourTurn         = "your_turn"
+	TypeError            = "error"
+)
+
+func init() {
+	Register(TypeHello, ClientToServer, validateHello)
+	Register[types.AckMessage](TypeAck, ClientToServer, nil)
+	Register(TypeJoinLobby, ClientToServer, validateJoinLobby)
+	Register(TypeMakeChoice, ClientToServer, validateMakeChoice)
+	Register(TypeMakeMove, ClientToServer, validateMakeMove)
+	Register[types.PlayAgainMessage](TypePlayAgain, ClientToServer, nil)
+	Register(TypeSpectate, ClientToServer, validateSpectate)
+	Register[types.DisconnectMessage](TypeDisconnect, ClientToServer, nil)
+	Register(TypeJoinTournament, ClientToServer, validateJoinTournament)
+	Register(TypeCreateMatch, ClientToServer, validateCreateMatch)
+	Register[types.ListMatchesMessage](TypeListMatches, ClientToServer, nil)
+
+	Register[types.WelcomeMessage](TypeWelcome, ServerToClient, nil)
+	Register[types.PlayerWaitingMessage](TypePlayerWaiting, ServerToClient, nil)
+	Register[types.GameStartingMessage](TypeGameStarting, ServerToClient, nil)
+	Register[types.RoundResultMessage](TypeRoundResult, ServerToClient, nil)
+	Register[types.RoundStartMessage](TypeRoundStart, ServerToClient, nil)
+	Register[types.GameEndedMessage](TypeGameEnded, ServerToClient, nil)
+	Register[types.SpectateUpdateMessage](TypeSpectateUpdate, ServerToClient, nil)
+	Register[types.ServerShuttingDownMessage](TypeShuttingDown, ServerToClient, nil)
+	Register[types.GameResumedMessage](TypeGameResumed, ServerToClient, nil)
+	Register[types.TournamentUpdateMessage](TypeTournamentUpdate, ServerToClient, nil)
+	Register[types.LeagueUpdateMessage](TypeLeagueUpdate, ServerToClient, nil)
+	Register[types.TeamVoteMessage](TypeTeamVote, ServerToClient, nil)
+	Register[types.BoardUpdateMessage](TypeBoardUpdate, ServerToClient, nil)
+	Register[types.MatchListMessage](TypeMatchList, ServerToClient, nil)
+	Register[types.MatchUpdateMessage](TypeMatchUpdate, ServerToClient, nil)
+	Register[types.YourTurnMessage](TypeYourTurn, ServerToClient, nil)
+	Register[types.ErrorMessage](TypeError, ServerToClient, nil)
+}
+
+func validateHello(msg types.HelloMessage) error {
+	if msg.ProtocolVersion < 0 {
+		return &ValidationError{Type: TypeHello, Reason: "Protocol version cannot be negative"}
+	}
+	return nil
+}
+
+func validateJoinLobby(msg types.JoinLobbyMessage) error {
+	if strings.TrimSpace(msg.Name) == "" {
+		return &ValidationError{Type: TypeJoinLobby, Code: ErrorCodeNameInvalid, Reason: "Name cannot be empty"}
+	}
+	if msg.RoomSize != 0 && (msg.RoomSize < 2 || msg.RoomSize > MaxRoomSize) {
+		return &ValidationError{Type: TypeJoinLobby, Reason: fmt.Sprintf("Room size must be between 2 and %d", MaxRoomSize)}
+	}
+	if msg.Teams && msg.RoomSize != 0 && msg.RoomSize != 4 {
+		return &ValidationError{Type: TypeJoinLobby, Reason: "Team games have 4 players"}
+	}
+	if msg.Teammate != "" && !msg.Teams {
+		return &ValidationError{Type: TypeJoinLobby, Reason: "A teammate needs a team game"}
+	}
+	if msg.Teammate != "" && msg.Teammate == msg.Name {
+		return &ValidationError{Type: TypeJoinLobby, Reason: "Cannot team up with yourself"}
+	}
+	return nil
+}
+
+func validateMakeChoice(msg types.MakeChoiceMessage) error {
+	if msg.Choice == "" {
+		return &ValidationError{Type: TypeMakeChoice, Code: ErrorCodeInvalidChoice, Reason: "Choice cannot be empty"}
+	}
+	return nil
+}
+
+func validateMakeMove(msg types.MakeMoveMessage) error {
+	if msg.Row < 0 || msg.Column < 0 {
+		return &ValidationError{Type: TypeMakeMove, Code: ErrorCodeInvalidMove, Reason: "Row and column cannot be negative"}
+	}
+	return nil
+}
+
+func validateSpectate(msg types.SpectateMessage) error {
+	if strings.TrimSpace(msg.PlayerName) == "" {
+		return &ValidationError{Type: TypeSpectate, Reason: "Player name cannot be empty"}
+	}
+	return nil
+}
+
+func validateJoinTournament(msg types.JoinTournamentMessage) error {
+	if strings.TrimSpace(msg.TournamentID) == "" {
+		return &ValidationError{Type: TypeJoinTournament, Reason: "Tournament ID cannot be empty"}
+	}
+	return nil
+}
+
+func validateCreateMatch(msg types.CreateMatchMessage) error {
+	if msg.Game == "" {
+		return &ValidationError{Type: TypeCreateMatch, Reason: "Game cannot be empty"}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package protocol
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+func TestProtocol_EncodeDecodeRoundTrip(t *testing.T) {
+	event, err := Encode(types.RoundResultMessage{
+		Result:         "win",
+		YourChoice:     "rock",
+		OpponentChoice: "scissors",
+	})
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if event.Type != TypeRoundResult {
+		t.Errorf("Expected type %s, got %s", TypeRoundResult, event.Type)
+	}
+
+	msg, err := Decode[types.RoundResultMessage](event)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if msg.Result != "win" || msg.YourChoice != "rock" || msg.OpponentChoice != "scissors" {
+		t.Errorf("Unexpected decoded message: %+v", msg)
+	}
+}
+
+func TestProtocol_DecodeEmptyPayload(t *testing.T) {
+	if _, err := Decode[types.PlayAgainMessage](types.BaseGameEvent{Type: TypePlayAgain}); err != nil {
+		t.Errorf("Expected empty payload to decode, got %v", err)
+	}
+}
+
+func TestProtocol_DecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		event types.BaseGameEvent
+		check func(error) bool
+	}{
+		{"unknown type", types.BaseGameEvent{Type: "bogus"}, func(err error) bool { return errors.Is(err, ErrUnknownType) }},
+		{"wrong struct", types.BaseGameEvent{Type: TypePlayAgain}, func(err error) bool { return errors.Is(err, ErrTypeMismatch) }},
+		{"bad json", types.BaseGameEvent{Type: TypeJoinLobby, Data: []byte(`{"name":`)}, func(err error) bool { return err != nil }},
+		{"empty name", types.BaseGameEvent{Type: TypeJoinLobby, Data: []byte(`{"name":"  "}`)}, func(err error) bool {
+			var validationErr *ValidationError
+			return errors.As(err, &validationErr) && validationErr.Reason == "Name cannot be empty"
+		}},
+		{"room too large", types.BaseGameEvent{Type: TypeJoinLobby, Data: []byte(`{"name":"Alice","room_size":9}`)}, func(err error) bool {
+			var validationErr *ValidationError
+			return errors.As(err, &validationErr) && validationErr.Type == TypeJoinLobby
+		}},
+		{"teammate without teams", types.BaseGameEvent{Type: TypeJoinLobby, Data: []byte(`{"name":"Alice","teammate":"Bob"}`)}, func(err error) bool {
+			var validationErr *ValidationError
+			return errors.As(err, &validationErr) && validationErr.Reason == "A teammate needs a team game"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode[types.JoinLobbyMessage](tt.event)
+			if !tt.check(err) {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestProtocol_EncodeUnregisteredType(t *testing.T) {
+	type notRegistered struct{}
+	if _, err := Encode(notRegistered{}); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Expected ErrUnknownType, got %v", err)
+	}
+}
+
+func TestProtocol_RegistryDirections(t *testing.T) {
+	clientTypes := map[string]bool{
+		TypeHello:          true,
+		TypeAck:            true,
+		TypeJoinLobby:      true,
+		TypeMakeChoice:     true,
+		TypeMakeMove:       true,
+		TypePlayAgain:      true,
+		TypeSpectate:       true,
+		TypeDisconnect:     true,
+		TypeJoinTournament: true,
+		TypeCreateMatch:    true,
+		TypeListMatches:    true,
+	}
+
+	for _, spec := range Specs() {
+		want := ServerToClient
+		if clientTypes[spec.Type] {
+			want = ClientToServer
+		}
+		if spec.Direction != want {
+			t.Errorf("%s: expected direction %s, got %s", spec.Type, want, spec.Direction)
+		}
+	}
+}
+
+func TestDispatcher_RoutesToHandler(t *testing.T) {
+	d := NewDispatcher()
+
+	var got string
+	On(d, func(client *types.Client, msg types.MakeChoiceMessage) error {
+		got = msg.Choice
+		return nil
+	})
+
+	event, _ := Encode(types.MakeChoiceMessage{Choice: "paper"})
+	if err := d.Dispatch(nil, event); err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+	if got != "paper" {
+		t.Errorf("Expected handler to receive paper, got %q", got)
+	}
+
+	if err := d.Dispatch(nil, types.BaseGameEvent{Type: TypePlayAgain}); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("Expected ErrUnknownType for unhandled type, got %v", err)
+	}
+}
+
+func TestDispatcher_RejectsServerMessages(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic when registering a handler for a server message")
+		}
+	}()
+
+	On(NewDispatcher(), func(client *types.Client, msg types.RoundStartMessage) error {
+		return nil
+	})
+}
+
+func TestProtocol_NegotiateFeatures(t *testing.T) {
+	got := NegotiateFeatures(
+		[]string{FeatureResume, FeatureSpectate, FeatureRulesets},
+		[]string{FeatureRulesets, "telepathy", FeatureResume},
+	)
+	if len(got) != 2 || got[0] != FeatureResume || got[1] != FeatureRulesets {
+		t.Errorf("Expected [resume rulesets], got %v", got)
+	}
+
+	if got := NegotiateFeatures(nil, []string{FeatureResume}); len(got) != 0 {
+		t.Errorf("Expected no features when server offers none, got %v", got)
+	}
+}
+
+func TestWireCodecs_RoundTrip(t *testing.T) {
+	original, _ := Encode(types.HelloMessage{
+		ProtocolVersion: 1,
+		ClientName:      "unity",
+		ClientVersion:   "1.0.0",
+		Features:        []string{FeatureResume, FeatureSpectate},
+	})
+
+	for _, codec := range Codecs {
+		t.Run(codec.Subprotocol(), func(t *testing.T) {
+			data, err := codec.Marshal(original)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+
+			var event types.BaseGameEvent
+			if err := codec.Unmarshal(data, &event); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+
+			hello, err := Decode[types.HelloMessage](event)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if hello.ClientName != "unity" || hello.ProtocolVersion != 1 || len(hello.Features) != 2 {
+				t.Errorf("Unexpected hello after round trip: %+v", hello)
+			}
+		})
+	}
+}
+
+func TestWireCodecs_EmptyPayload(t *testing.T) {
+	for _, codec := range Codecs {
+		data, err := codec.Marshal(types.BaseGameEvent{Type: TypePlayAgain})
+		if err != nil {
+			t.Fatalf("%s: Marshal failed: %v", codec.Subprotocol(), err)
+		}
+
+		var event types.BaseGameEvent
+		if err := codec.Unmarshal(data, &event); err != nil {
+			t.Fatalf("%s: Unmarshal failed: %v", codec.Subprotocol(), err)
+		}
+		if _, err := Decode[types.PlayAgainMessage](event); err != nil {
+			t.Errorf("%s: Decode failed: %v", codec.Subprotocol(), err)
+		}
+	}
+}
+
+func TestWireCodecs_Seq(t *testing.T) {
+	original, _ := Encode(types.RoundStartMessage{RoundNumber: 2})
+	original.Seq = 300
+
+	for _, codec := range Codecs {
+		data, err := codec.Marshal(original)
+		if err != nil {
+			t.Fatalf("%s: Marshal failed: %v", codec.Subprotocol(), err)
+		}
+
+		var event types.BaseGameEvent
+		if err := codec.Unmarshal(data, &event); err != nil {
+			t.Fatalf("%s: Unmarshal failed: %v", codec.Subprotocol(), err)
+		}
+		if event.Seq != 300 {
+			t.Errorf("%s: expected seq 300, got %d", codec.Subprotocol(), event.Seq)
+		}
+	}
+}
+
+func TestWireCodecs_CodecFor(t *testing.T) {
+	if _, ok := CodecFor("").(JSONCodec); !ok {
+		t.Error("Expected JSON codec when no subprotocol was negotiated")
+	}
+	if _, ok := CodecFor(SubprotocolMsgpack).(MsgpackCodec); !ok {
+		t.Error("Expected msgpack codec for paper.msgpack.v1")
+	}
+}
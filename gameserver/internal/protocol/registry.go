@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Direction describes which side of the connection sends a message
+type Direction int
+
+const (
+	ClientToServer Direction = iota + 1
+	ServerToClient
+)
+
+// String returns a readable name for the direction
+func (d Direction) String() string {
+	switch d {
+	case ClientToServer:
+		return "client_to_server"
+	case ServerToClient:
+		return "server_to_client"
+	default:
+		return "unknown"
+	}
+}
+
+// Spec describes a registered message type
+type Spec struct {
+	Type      string
+	Direction Direction
+	GoType    reflect.Type
+	validate  func(any) error
+	order     int
+}
+
+// Validate runs the registered validator against a decoded message
+func (s Spec) Validate(msg any) error {
+	if s.validate == nil {
+		return nil
+	}
+	return s.validate(msg)
+}
+
+var (
+	registryMu sync.RWMutex
+	byType     = make(map[string]Spec)
+	byGoType   = make(map[reflect.Type]Spec)
+)
+
+// Register adds a message type to the registry. It panics if the type name
+// or Go struct is already registered, since that is a programming error.
+func Register[T any](msgType string, dir Direction, validate func(T) error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	goType := reflect.TypeFor[T]()
+	if _, exists := byType[msgType]; exists {
+		panic(fmt.Sprintf("protocol: message type %q registered twice", msgType))
+	}
+	if _, exists := byGoType[goType]; exists {
+		panic(fmt.Sprintf("protocol: Go type %s registered twice", goType))
+	}
+
+	spec := Spec{
+		Type:      msgType,
+		Direction: dir,
+		GoType:    goType,
+		order:     len(byType),
+	}
+	if validate != nil {
+		spec.validate = func(msg any) error {
+			return validate(msg.(T))
+		}
+	}
+
+	byType[msgType] = spec
+	byGoType[goType] = spec
+}
+
+// Lookup returns the spec registered under a message type name
+func Lookup(msgType string) (Spec, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	spec, ok := byType[msgType]
+	return spec, ok
+}
+
+// lookupGoType returns the spec registered for a Go struct type
+func lookupGoType(goType reflect.Type) (Spec, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	spec, ok := byGoType[goType]
+	return spec, ok
+}
+
+// TypeOf returns the message type name registered for T
+func TypeOf[T any]() (string, bool) {
+	spec, ok := lookupGoType(reflect.TypeFor[T]())
+	return spec.Type, ok
+}
+
+// Specs returns every registered message spec in registration order
+func Specs() []Spec {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	specs := make([]Spec, 0, len(byType))
+	for _, spec := range byType {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].order < specs[j].order
+	})
+	return specs
+}
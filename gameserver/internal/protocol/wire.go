@@ -0,0 +1,123 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/4hel/paper/gameserver/internal/msgpack"
+	"github.com/4hel/paper/gameserver/internal/types"
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket subprotocols offered through Sec-WebSocket-Protocol
+const (
+	SubprotocolJSON    = "paper.json.v1"
+	SubprotocolMsgpack = "paper.msgpack.v1"
+)
+
+// WireCodec turns BaseGameEvents into WebSocket frames and back
+type WireCodec interface {
+	// Subprotocol is the Sec-WebSocket-Protocol name of the codec
+	Subprotocol() string
+	// FrameType is websocket.TextMessage or websocket.BinaryMessage
+	FrameType() int
+	Marshal(event types.BaseGameEvent) ([]byte, error)
+	Unmarshal(data []byte, event *types.BaseGameEvent) error
+}
+
+// JSONCodec is the default text codec
+type JSONCodec struct{}
+
+func (JSONCodec) Subprotocol() string { return SubprotocolJSON }
+func (JSONCodec) FrameType() int      { return websocket.TextMessage }
+
+func (JSONCodec) Marshal(event types.BaseGameEvent) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+func (JSONCodec) Unmarshal(data []byte, event *types.BaseGameEvent) error {
+	return json.Unmarshal(data, event)
+}
+
+// MsgpackCodec encodes the whole envelope, payload included, as a single
+// MessagePack map so the payload is not JSON nested inside a string
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Subprotocol() string { return SubprotocolMsgpack }
+func (MsgpackCodec) FrameType() int      { return websocket.BinaryMessage }
+
+func (MsgpackCodec) Marshal(event types.BaseGameEvent) ([]byte, error) {
+	var data any
+	if len(event.Data) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(event.Data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&data); err != nil {
+			return nil, fmt.Errorf("decode %s payload: %w", event.Type, err)
+		}
+	}
+
+	envelope := map[string]any{
+		"type": event.Type,
+		"data": data,
+	}
+	if event.Seq != 0 {
+		envelope["seq"] = event.Seq
+	}
+	return msgpack.Marshal(envelope)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, event *types.BaseGameEvent) error {
+	value, err := msgpack.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+
+	envelope, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("msgpack envelope must be a map, got %T", value)
+	}
+	msgType, ok := envelope["type"].(string)
+	if !ok {
+		return fmt.Errorf("msgpack envelope is missing a string type")
+	}
+
+	event.Type = msgType
+	event.Seq = 0
+	switch seq := envelope["seq"].(type) {
+	case int64:
+		event.Seq = uint64(seq)
+	case uint64:
+		event.Seq = seq
+	}
+	event.Data = nil
+	if payload, ok := envelope["data"]; ok && payload != nil {
+		if event.Data, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("re-encode %s payload: %w", msgType, err)
+		}
+	}
+	return nil
+}
+
+// Codecs lists the supported codecs in order of server preference
+var Codecs = []WireCodec{JSONCodec{}, MsgpackCodec{}}
+
+// Subprotocols returns the subprotocol names of all supported codecs
+func Subprotocols() []string {
+	names := make([]string, 0, len(Codecs))
+	for _, codec := range Codecs {
+		names = append(names, codec.Subprotocol())
+	}
+	return names
+}
+
+// CodecFor returns the codec for a negotiated subprotocol. An empty or
+// unknown subprotocol falls back to JSON.
+func CodecFor(subprotocol string) WireCodec {
+	for _, codec := range Codecs {
+		if codec.Subprotocol() == subprotocol {
+			return codec
+		}
+	}
+	return JSONCodec{}
+}
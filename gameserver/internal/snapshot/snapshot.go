@@ -0,0 +1,137 @@
+// Package snapshot persists the state of running games, so a restarted
+// server can let players finish the matches it was hosting.
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+)
+
+// State is everything saved in one snapshot
+type State struct {
+	SavedAt time.Time           `json:"saved_at"`
+	Rooms   []gameroom.Snapshot `json:"rooms"`
+}
+
+// Store keeps the latest snapshot. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Save replaces the stored snapshot with state
+	Save(ctx context.Context, state State) error
+	// Load returns the stored snapshot, an empty State if there is none
+	Load(ctx context.Context) (State, error)
+}
+
+// MemoryStore keeps the snapshot in memory, it survives a lobby but not
+// the process. Useful for tests.
+type MemoryStore struct {
+	mu    sync.Mutex
+	state State
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Save replaces the stored snapshot
+func (s *MemoryStore) Save(ctx context.Context, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state.Rooms = append([]gameroom.Snapshot(nil), state.Rooms...)
+	s.state = state
+	return nil
+}
+
+// Load returns a copy of the stored snapshot
+func (s *MemoryStore) Load(ctx context.Context) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.state
+	state.Rooms = append([]gameroom.Snapshot(nil), state.Rooms...)
+	return state, nil
+}
+
+// FileStore keeps the snapshot as a JSON file. Saves write a temporary
+// file and rename it, so a crash never leaves a half written snapshot.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStore stores snapshots at path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Save writes state to the file
+func (s *FileStore) Save(ctx context.Context, state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	return nil
+}
+
+// Load reads the file, a missing file is an empty snapshot
+func (s *FileStore) Load(ctx context.Context) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var state State
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("snapshot: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("snapshot: %s: %w", s.path, err)
+	}
+	return state, nil
+}
+
+// Ping checks that the snapshot directory exists, for readiness checks
+func (s *FileStore) Ping(ctx context.Context) error {
+	info, err := os.Stat(filepath.Dir(s.path))
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("snapshot: %s is not a directory", filepath.Dir(s.path))
+	}
+	return nil
+}
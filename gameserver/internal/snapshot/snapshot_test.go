@@ -0,0 +1,66 @@
+package snapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+)
+
+func TestFileStore_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rooms.json")
+	store := NewFileStore(path)
+	ctx := context.Background()
+
+	// Nothing saved yet
+	state, err := store.Load(ctx)
+	if err != nil || len(state.Rooms) != 0 {
+		t.Fatalf("Expected an empty snapshot, got %+v, %v", state, err)
+	}
+
+	saved := State{
+		SavedAt: time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC),
+		Rooms: []gameroom.Snapshot{
+			{ID: "room-1", Player1: "Alice", Player2: "Bob", Player1Wins: 1, CurrentRound: 2, BestOf: 3},
+		},
+	}
+	if err := store.Save(ctx, saved); err != nil {
+		t.Fatal("Save failed:", err)
+	}
+
+	loaded, err := NewFileStore(path).Load(ctx)
+	if err != nil {
+		t.Fatal("Load failed:", err)
+	}
+	if !reflect.DeepEqual(loaded, saved) {
+		t.Errorf("Expected %+v, got %+v", saved, loaded)
+	}
+
+	// No temporary files are left behind
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the snapshot file, got %d entries", len(entries))
+	}
+}
+
+func TestFileStore_Ping(t *testing.T) {
+	if err := NewFileStore(filepath.Join(t.TempDir(), "rooms.json")).Ping(context.Background()); err != nil {
+		t.Error("Expected an existing directory to pass:", err)
+	}
+	if err := NewFileStore(filepath.Join(t.TempDir(), "missing", "rooms.json")).Ping(context.Background()); err == nil {
+		t.Error("Expected a missing directory to fail")
+	}
+}
+
+func TestFileStore_CorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rooms.json")
+	os.WriteFile(path, []byte("{not json"), 0o644)
+
+	if _, err := NewFileStore(path).Load(context.Background()); err == nil {
+		t.Error("Expected a corrupt snapshot to fail loading")
+	}
+}
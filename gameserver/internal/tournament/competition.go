@@ -0,0 +1,213 @@
+package tournament
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/lobby"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// competition is what tournaments and leagues share: registration, seeds
+// and the check-in of due matches. The embedding type sets done and
+// update.
+type competition struct {
+	ID      string
+	Name    string
+	manager *Manager
+	mu      sync.Mutex
+	state   string
+	players []string          // in seed order
+	seeds   map[string]int    // name -> seed, 1 is the best
+	clients map[string]string // name -> ID of the client that registered it
+	matches []*match          // every match that became due, to stop their retries
+	closed  bool
+	done    func(m *match) // called with mu held once a match has its result
+	update  func()         // sends the current state to the players, called with mu held
+}
+
+// match is one pairing of a bracket or league round
+type match struct {
+	round    int
+	index    int
+	players  [2]string // empty for a bye
+	decided  [2]bool   // the player, or the bye, of a slot is known
+	wins     [2]int
+	winner   string // empty for a draw
+	status   string
+	deadline time.Time   // check-in deadline while waiting
+	retry    *time.Timer // next attempt to start the match while waiting
+}
+
+// over reports whether the match has its result
+func (m *match) over() bool {
+	return m.status == statusDone || m.status == statusBye || m.status == statusNoShow
+}
+
+// view returns the match as sent to players
+func (m *match) view() types.TournamentMatch {
+	return types.TournamentMatch{
+		Player1:     m.players[0],
+		Player2:     m.players[1],
+		Player1Wins: m.wins[0],
+		Player2Wins: m.wins[1],
+		Winner:      m.winner,
+		Status:      m.status,
+	}
+}
+
+// Register adds the player of a client before the competition starts.
+// Registering the same name from the same client again is a no-op, from
+// another client it fails with ErrNameTaken. A name registered without a
+// client, e.g. by an admin, goes to the first client that registers it.
+func (c *competition) Register(name, clientID string) error {
+	if strings.TrimSpace(name) == "" {
+		return ErrInvalidName
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != StateRegistering {
+		return ErrStarted
+	}
+	registered, ok := c.clients[name]
+	switch {
+	case ok && registered == "":
+		c.clients[name] = clientID
+	case ok && clientID != "" && registered != clientID:
+		return ErrNameTaken
+	}
+	if _, registered := c.seeds[name]; !registered {
+		c.players = append(c.players, name)
+		c.seeds[name] = len(c.players)
+		c.clients[name] = clientID
+		log.Printf("Tournament %s: %s registered as seed %d", c.ID, name, len(c.players))
+	}
+	c.update()
+	return nil
+}
+
+// checkIn makes a match between two players due. It starts once both are
+// available, a player who is not by the check-in deadline forfeits. The
+// caller must hold mu.
+func (c *competition) checkIn(m *match) {
+	m.status = statusWaiting
+	m.deadline = time.Now().Add(c.manager.checkIn)
+	c.matches = append(c.matches, m)
+	c.tryStart(m)
+}
+
+// tryStart starts the game of a waiting match, or tries again later. The
+// caller must hold mu.
+func (c *competition) tryStart(m *match) {
+	err := c.manager.host.StartMatch(c.clients[m.players[0]], c.clients[m.players[1]], func(result gameroom.Result) {
+		c.finish(m, result)
+	})
+
+	var unavailable *lobby.UnavailableError
+	switch {
+	case err == nil:
+		m.status = statusPlaying
+		log.Printf("Tournament %s: round %d match %s vs %s started", c.ID, m.round+1, m.players[0], m.players[1])
+		return
+	case errors.As(err, &unavailable):
+		if !time.Now().Before(m.deadline) {
+			var missing []string
+			for _, player := range m.players {
+				if slices.Contains(unavailable.ClientIDs, c.clients[player]) {
+					missing = append(missing, player)
+				}
+			}
+			c.noShow(m, missing)
+			return
+		}
+	default:
+		// No game starts while draining or in maintenance, which is not the
+		// players' fault
+		log.Printf("Tournament %s: cannot start match %s vs %s: %v", c.ID, m.players[0], m.players[1], err)
+		m.deadline = time.Now().Add(c.manager.checkIn)
+	}
+	m.retry = time.AfterFunc(min(c.manager.retry, time.Until(m.deadline)), func() { c.retryMatch(m) })
+}
+
+// retryMatch tries to start a match that is still waiting for its players
+func (c *competition) retryMatch(m *match) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || m.status != statusWaiting {
+		return
+	}
+	c.tryStart(m)
+	if m.status != statusWaiting {
+		c.update()
+	}
+}
+
+// noShow ends a match some players did not show up for. A player who did
+// wins, nobody does if both are missing. The caller must hold mu.
+func (c *competition) noShow(m *match, missing []string) {
+	m.status = statusNoShow
+	for _, player := range m.players {
+		if !slices.Contains(missing, player) {
+			m.winner = player
+		}
+	}
+	log.Printf("Tournament %s: %s did not show up for round %d", c.ID, strings.Join(missing, " and "), m.round+1)
+	c.done(m)
+}
+
+// finish records the result of a match's game
+func (c *competition) finish(m *match, result gameroom.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || m.status != statusPlaying {
+		return
+	}
+	m.status = statusDone
+	m.wins = [2]int{result.Player1Wins, result.Player2Wins}
+	// The result names the players as they are called in the lobby, which
+	// may not be their registered names
+	switch {
+	case result.Winner == "":
+		m.winner = ""
+	case result.Winner == result.Player1:
+		m.winner = m.players[0]
+	default:
+		m.winner = m.players[1]
+	}
+	log.Printf("Tournament %s: round %d match %s vs %s ended %d-%d", c.ID, m.round+1, m.players[0], m.players[1], m.wins[0], m.wins[1])
+	c.done(m)
+	c.update()
+}
+
+// close stops the retries of waiting matches, results that come in later
+// are ignored. The caller must hold mu.
+func (c *competition) close() {
+	c.closed = true
+	for _, m := range c.matches {
+		if m.retry != nil {
+			m.retry.Stop()
+		}
+	}
+}
+
+// broadcast sends msg to every registered player, the caller must hold mu
+func broadcast[T any](c *competition, msg T) {
+	event, err := protocol.Encode(msg)
+	if err != nil {
+		log.Printf("Tournament %s: %v", c.ID, err)
+		return
+	}
+	for _, name := range c.players {
+		c.manager.host.SendTo(c.clients[name], event)
+	}
+}
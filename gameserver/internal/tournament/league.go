@@ -0,0 +1,382 @@
+package tournament
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// League formats
+const (
+	FormatRoundRobin = "round_robin" // every player meets every other once
+	FormatSwiss      = "swiss"       // players with similar scores meet, nobody twice
+)
+
+// ErrInvalidLeague is returned for league configurations that cannot be
+// played
+var ErrInvalidLeague = errors.New("invalid league")
+
+// LeagueConfig describes a league
+type LeagueConfig struct {
+	Name          string
+	Format        string        // FormatRoundRobin or FormatSwiss
+	Rounds        int           // Swiss rounds, zero for enough to separate the top player
+	RoundInterval time.Duration // between round starts, zero to start each round once the previous one is over
+}
+
+// League is a round-robin or Swiss league. Each round's pairings are
+// played as scheduled matches with a check-in window, the standings rank
+// players by points (1 per win, ½ per draw), then Buchholz, head-to-head
+// and round differential.
+type League struct {
+	competition
+	format   string
+	rounds   int           // rounds to play, known once the league started
+	interval time.Duration // between round starts
+	schedule [][]*match    // round robin: every round from the start, Swiss: paired round by round
+	round    int           // current round starting at 1, 0 before the start
+	starting bool          // the current round's matches are being checked in
+	started  time.Time
+	nextAt   time.Time   // when the next round opens, zero unless waiting for it
+	next     *time.Timer // opens the next round at nextAt
+}
+
+// newLeague creates a league open for registration
+func newLeague(m *Manager, id string, cfg LeagueConfig) *League {
+	l := &League{
+		competition: competition{
+			ID:      id,
+			Name:    cfg.Name,
+			manager: m,
+			state:   StateRegistering,
+			seeds:   make(map[string]int),
+			clients: make(map[string]string),
+		},
+		format:   cfg.Format,
+		rounds:   cfg.Rounds,
+		interval: cfg.RoundInterval,
+	}
+	l.done = func(*match) { l.checkRound() }
+	l.update = func() { broadcast(&l.competition, l.table()) }
+	return l
+}
+
+// Start closes registration and opens the first round
+func (l *League) Start() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.state != StateRegistering {
+		return ErrStarted
+	}
+	if len(l.players) < 2 {
+		return ErrTooFewPlayers
+	}
+
+	switch l.format {
+	case FormatRoundRobin:
+		l.schedule = roundRobin(l.players)
+		l.rounds = len(l.schedule)
+	case FormatSwiss:
+		if l.rounds == 0 {
+			l.rounds = swissRounds(len(l.players))
+		}
+	}
+	l.state = StateRunning
+	l.started = time.Now()
+	log.Printf("League %s started with %d players in %d %s rounds", l.ID, len(l.players), l.rounds, l.format)
+	l.startRound()
+	l.update()
+	return nil
+}
+
+// Table returns the league as sent to its players: the current round's
+// pairings and the standings
+func (l *League) Table() types.LeagueUpdateMessage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.table()
+}
+
+// roundRobin schedules every pairing of players with the circle method.
+// In an odd field everyone sits out one round with a bye.
+func roundRobin(players []string) [][]*match {
+	field := slices.Clone(players)
+	if len(field)%2 == 1 {
+		field = append(field, "")
+	}
+
+	n := len(field)
+	rounds := make([][]*match, n-1)
+	for r := range rounds {
+		for i := 0; i < n/2; i++ {
+			m := &match{round: r, index: i, players: [2]string{field[i], field[n-1-i]}, status: statusPending}
+			if m.players[0] == "" {
+				m.players = [2]string{m.players[1], ""}
+			}
+			rounds[r] = append(rounds[r], m)
+		}
+		// The first player stays, everyone else moves one seat on
+		field = append([]string{field[0], field[n-1]}, field[1:n-1]...)
+	}
+	return rounds
+}
+
+// swissRounds returns enough Swiss rounds for one player to win them all
+func swissRounds(players int) int {
+	rounds := 1
+	for 1<<rounds < players {
+		rounds++
+	}
+	return rounds
+}
+
+// startRound opens the next round, pairing it first for Swiss. The caller
+// must hold mu.
+func (l *League) startRound() {
+	l.round++
+	l.nextAt = time.Time{}
+	if l.format == FormatSwiss {
+		l.schedule = append(l.schedule, l.pairSwiss())
+	}
+	log.Printf("League %s: round %d of %d opens", l.ID, l.round, l.rounds)
+
+	// Matches that end right away must not close the round before all
+	// of them were checked in
+	l.starting = true
+	for _, m := range l.schedule[l.round-1] {
+		if m.players[1] == "" {
+			m.status = statusBye
+			m.winner = m.players[0]
+			continue
+		}
+		l.checkIn(m)
+	}
+	l.starting = false
+	l.checkRound()
+}
+
+// checkRound moves on once every match of the current round is over: to
+// the next round, right away or at its scheduled time, or to the end of
+// the league. The caller must hold mu.
+func (l *League) checkRound() {
+	if l.starting {
+		return
+	}
+	for _, m := range l.schedule[l.round-1] {
+		if !m.over() {
+			return
+		}
+	}
+
+	if l.round == l.rounds {
+		l.state = StateFinished
+		log.Printf("League %s finished", l.ID)
+		return
+	}
+	if l.interval > 0 {
+		l.nextAt = l.started.Add(time.Duration(l.round) * l.interval)
+		if wait := time.Until(l.nextAt); wait > 0 {
+			l.next = time.AfterFunc(wait, l.openRound)
+			return
+		}
+	}
+	l.startRound()
+}
+
+// openRound starts a round at its scheduled time
+func (l *League) openRound() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return
+	}
+	l.startRound()
+	l.update()
+}
+
+// pairSwiss pairs a round from the top of the standings down, everyone
+// with the best placed player they have not met yet. In an odd field the
+// lowest placed player without a bye so far sits out. The caller must
+// hold mu.
+func (l *League) pairSwiss() []*match {
+	var order []string
+	for _, standing := range l.standings() {
+		order = append(order, standing.Player)
+	}
+
+	bye := ""
+	if len(order)%2 == 1 {
+		bye = order[len(order)-1]
+		for i := len(order) - 1; i >= 0; i-- {
+			if !l.hadBye(order[i]) {
+				bye = order[i]
+				break
+			}
+		}
+		order = slices.DeleteFunc(order, func(player string) bool { return player == bye })
+	}
+
+	round := len(l.schedule)
+	var matches []*match
+	for len(order) > 0 {
+		// A rematch only if everyone left was met already
+		opponent := 1
+		for i := 1; i < len(order); i++ {
+			if !l.met(order[0], order[i]) {
+				opponent = i
+				break
+			}
+		}
+		matches = append(matches, &match{round: round, index: len(matches), players: [2]string{order[0], order[opponent]}, status: statusPending})
+		order = slices.Delete(order, opponent, opponent+1)[1:]
+	}
+	if bye != "" {
+		matches = append(matches, &match{round: round, index: len(matches), players: [2]string{bye, ""}, status: statusPending})
+	}
+	return matches
+}
+
+// met reports whether two players were paired before, the caller must
+// hold mu
+func (l *League) met(a, b string) bool {
+	for _, round := range l.schedule {
+		for _, m := range round {
+			if m.players == [2]string{a, b} || m.players == [2]string{b, a} {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hadBye reports whether a player sat out a round, the caller must hold mu
+func (l *League) hadBye(player string) bool {
+	return l.met(player, "")
+}
+
+// standings ranks the players by points, Buchholz, head-to-head, round
+// differential and finally seed. The caller must hold mu.
+func (l *League) standings() []types.LeagueStanding {
+	rows := make(map[string]*types.LeagueStanding, len(l.players))
+	for _, player := range l.players {
+		rows[player] = &types.LeagueStanding{Player: player}
+	}
+
+	opponents := make(map[string][]string)
+	for _, round := range l.schedule {
+		for _, m := range round {
+			if !m.over() {
+				continue
+			}
+			if m.players[1] == "" {
+				// A bye counts as a win
+				row := rows[m.players[0]]
+				row.Played++
+				row.Wins++
+				row.Points++
+				continue
+			}
+			opponents[m.players[0]] = append(opponents[m.players[0]], m.players[1])
+			opponents[m.players[1]] = append(opponents[m.players[1]], m.players[0])
+			for slot, player := range m.players {
+				row := rows[player]
+				row.Played++
+				row.RoundDifferential += m.wins[slot] - m.wins[1-slot]
+				switch {
+				case m.winner == player:
+					row.Wins++
+					row.Points++
+				case m.winner == "" && m.status == statusDone:
+					row.Draws++
+					row.Points += 0.5
+				default:
+					// Lost, or did not show up
+					row.Losses++
+				}
+			}
+		}
+	}
+
+	for player, row := range rows {
+		for _, opponent := range opponents[player] {
+			row.Buchholz += rows[opponent].Points
+		}
+	}
+
+	// Head-to-head only counts games between players who are level so far
+	for _, round := range l.schedule {
+		for _, m := range round {
+			if !m.over() || m.players[1] == "" {
+				continue
+			}
+			a, b := rows[m.players[0]], rows[m.players[1]]
+			if a.Points != b.Points || a.Buchholz != b.Buchholz {
+				continue
+			}
+			switch {
+			case m.winner == a.Player:
+				a.HeadToHead++
+			case m.winner == b.Player:
+				b.HeadToHead++
+			case m.status == statusDone:
+				a.HeadToHead += 0.5
+				b.HeadToHead += 0.5
+			}
+		}
+	}
+
+	table := make([]types.LeagueStanding, 0, len(l.players))
+	for _, player := range l.players {
+		table = append(table, *rows[player])
+	}
+	// Stable, so the better seed stays ahead when everything is level
+	slices.SortStableFunc(table, func(a, b types.LeagueStanding) int {
+		return cmp.Or(
+			cmp.Compare(b.Points, a.Points),
+			cmp.Compare(b.Buchholz, a.Buchholz),
+			cmp.Compare(b.HeadToHead, a.HeadToHead),
+			cmp.Compare(b.RoundDifferential, a.RoundDifferential),
+		)
+	})
+	for i := range table {
+		table[i].Rank = i + 1
+	}
+	return table
+}
+
+// table builds the league_update message, the caller must hold mu
+func (l *League) table() types.LeagueUpdateMessage {
+	msg := types.LeagueUpdateMessage{
+		LeagueID:  l.ID,
+		Name:      l.Name,
+		Format:    l.format,
+		State:     l.state,
+		Round:     l.round,
+		Rounds:    l.rounds,
+		Pairings:  []types.TournamentMatch{},
+		Standings: l.standings(),
+	}
+	if !l.nextAt.IsZero() {
+		msg.NextRoundAt = l.nextAt.Unix()
+	}
+	if l.round > 0 {
+		for _, m := range l.schedule[l.round-1] {
+			msg.Pairings = append(msg.Pairings, m.view())
+		}
+	}
+	return msg
+}
+
+// close stops the league's timers, the caller must hold mu
+func (l *League) close() {
+	l.competition.close()
+	if l.next != nil {
+		l.next.Stop()
+	}
+}
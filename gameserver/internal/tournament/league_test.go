@@ -0,0 +1,246 @@
+package tournament
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+func createLeague(t *testing.T, manager *Manager, cfg LeagueConfig, names ...string) *League {
+	t.Helper()
+	league, err := manager.CreateLeague(cfg)
+	if err != nil {
+		t.Fatal("Failed to create league:", err)
+	}
+	for _, name := range names {
+		if err := league.Register(name, name); err != nil {
+			t.Fatalf("Failed to register %s: %v", name, err)
+		}
+	}
+	return league
+}
+
+// end finishes a started match with the given score
+func (m *fakeMatch) end(player1Wins, player2Wins int) {
+	result := gameroom.Result{Player1: m.player1, Player2: m.player2, Player1Wins: player1Wins, Player2Wins: player2Wins}
+	switch {
+	case player1Wins > player2Wins:
+		result.Winner = m.player1
+	case player2Wins > player1Wins:
+		result.Winner = m.player2
+	}
+	m.onEnd(result)
+}
+
+func pairings(table types.LeagueUpdateMessage) []string {
+	var pairs []string
+	for _, m := range table.Pairings {
+		pairs = append(pairs, m.Player1+"-"+m.Player2+":"+m.Status)
+	}
+	return pairs
+}
+
+func ranking(table types.LeagueUpdateMessage) []string {
+	var players []string
+	for _, standing := range table.Standings {
+		players = append(players, standing.Player)
+	}
+	return players
+}
+
+func TestRoundRobin(t *testing.T) {
+	players := []string{"A", "B", "C", "D", "E"}
+	rounds := roundRobin(players)
+	if len(rounds) != 5 {
+		t.Fatalf("Expected 5 rounds, got %d", len(rounds))
+	}
+
+	pairs := map[[2]string]int{}
+	byes := map[string]int{}
+	for _, round := range rounds {
+		for _, m := range round {
+			if m.players[1] == "" {
+				byes[m.players[0]]++
+				continue
+			}
+			pair := m.players
+			if pair[0] > pair[1] {
+				pair[0], pair[1] = pair[1], pair[0]
+			}
+			pairs[pair]++
+		}
+	}
+	if len(pairs) != 10 {
+		t.Errorf("Expected every one of the 10 pairings, got %v", pairs)
+	}
+	for pair, count := range pairs {
+		if count != 1 {
+			t.Errorf("Expected %v to meet once, got %d", pair, count)
+		}
+	}
+	for _, player := range players {
+		if byes[player] != 1 {
+			t.Errorf("Expected %s to have one bye, got %d", player, byes[player])
+		}
+	}
+}
+
+func TestLeague_RoundRobin(t *testing.T) {
+	host := newFakeHost()
+	league := createLeague(t, NewManager(host), LeagueConfig{Name: "Office", Format: FormatRoundRobin}, "Alice", "Bob", "Carol")
+	if err := league.Start(); err != nil {
+		t.Fatal("Failed to start:", err)
+	}
+
+	expected := []string{"Alice-:bye", "Bob-Carol:playing"}
+	if got := pairings(league.Table()); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	// Everyone wins once and has a bye, the round differential decides
+	host.match(t, 0).end(2, 1) // Bob beats Carol
+	host.match(t, 1).end(0, 2) // Carol beats Alice
+	host.match(t, 2).end(2, 0) // Alice beats Bob
+
+	final := lastEvent[types.LeagueUpdateMessage](host, "Bob")
+	if final.State != StateFinished || final.Round != 3 || final.Rounds != 3 {
+		t.Errorf("Expected the league to finish after 3 rounds, got %s round %d of %d", final.State, final.Round, final.Rounds)
+	}
+	if got := ranking(final); !reflect.DeepEqual(got, []string{"Carol", "Alice", "Bob"}) {
+		t.Errorf("Expected Carol, Alice, Bob, got %v", got)
+	}
+	if top := final.Standings[0]; top.Rank != 1 || top.Points != 2 || top.Wins != 2 || top.Losses != 1 || top.RoundDifferential != 1 {
+		t.Errorf("Unexpected standing for Carol: %+v", top)
+	}
+}
+
+func TestLeague_Tiebreakers(t *testing.T) {
+	done := func(player1, player2 string, wins1, wins2 int) *match {
+		m := &match{players: [2]string{player1, player2}, wins: [2]int{wins1, wins2}, status: statusDone}
+		if wins1 > wins2 {
+			m.winner = player1
+		} else {
+			m.winner = player2
+		}
+		return m
+	}
+	// Bob is seeded ahead of Alice and has the better round differential
+	league := &League{competition: competition{players: []string{"Bob", "Alice", "Carol", "Dave", "Eve"}}}
+	league.schedule = [][]*match{{
+		done("Alice", "Bob", 2, 1),
+		done("Bob", "Carol", 2, 0),
+		done("Dave", "Alice", 2, 0),
+		done("Carol", "Eve", 2, 1),
+	}}
+
+	// Four players have a point. Alice and Bob played stronger opponents,
+	// Alice beat Bob. Carol and Dave are level on Buchholz too and never
+	// met, Dave won their rounds more clearly.
+	standings := league.standings()
+	if got := ranking(types.LeagueUpdateMessage{Standings: standings}); !reflect.DeepEqual(got, []string{"Alice", "Bob", "Dave", "Carol", "Eve"}) {
+		t.Errorf("Expected Alice, Bob, Dave, Carol, Eve, got %v", got)
+	}
+	if alice := standings[0]; alice.Buchholz != 2 || alice.HeadToHead != 1 || alice.RoundDifferential != -1 {
+		t.Errorf("Unexpected tiebreakers for Alice: %+v", alice)
+	}
+}
+
+func TestLeague_SwissAvoidsRematches(t *testing.T) {
+	host := newFakeHost()
+	league := createLeague(t, NewManager(host), LeagueConfig{Format: FormatSwiss, Rounds: 3}, "Alice", "Bob", "Carol", "Dave")
+	league.Start()
+
+	host.match(t, 0).win("Alice")
+	host.match(t, 1).win("Carol")
+	expected := []string{"Alice-Carol:playing", "Bob-Dave:playing"}
+	if got := pairings(league.Table()); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected the winners to meet, got %v", got)
+	}
+
+	// Carol is second on Buchholz, but already played Alice
+	host.match(t, 2).win("Alice")
+	host.match(t, 3).win("Dave")
+	expected = []string{"Alice-Dave:playing", "Carol-Bob:playing"}
+	if got := pairings(league.Table()); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestLeague_SwissByes(t *testing.T) {
+	host := newFakeHost()
+	league := createLeague(t, NewManager(host), LeagueConfig{Format: FormatSwiss}, "Alice", "Bob", "Carol")
+	league.Start()
+
+	// The lowest placed player sits out, but nobody twice
+	expected := []string{"Alice-Bob:playing", "Carol-:bye"}
+	if got := pairings(league.Table()); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+	host.match(t, 0).win("Alice")
+	expected = []string{"Alice-Carol:playing", "Bob-:bye"}
+	if got := pairings(league.Table()); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected %v, got %v", expected, got)
+	}
+
+	host.match(t, 1).win("Carol")
+	if table := league.Table(); table.State != StateFinished || table.Rounds != 2 {
+		t.Errorf("Expected the league to finish after 2 rounds, got %s after %d", table.State, table.Rounds)
+	}
+}
+
+func TestLeague_RoundInterval(t *testing.T) {
+	host := newFakeHost()
+	manager := NewManager(host)
+	defer manager.Close()
+	league := createLeague(t, manager, LeagueConfig{Format: FormatRoundRobin, RoundInterval: 100 * time.Millisecond}, "Alice", "Bob", "Carol")
+	started := time.Now()
+	league.Start()
+
+	host.match(t, 0).win("Bob")
+	if table := league.Table(); table.Round != 1 || table.NextRoundAt == 0 {
+		t.Errorf("Expected round 2 to be scheduled, got round %d opening at %d", table.Round, table.NextRoundAt)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for league.Table().Round == 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected round 2 to open")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if elapsed := time.Since(started); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected round 2 to open after the interval, got %v", elapsed)
+	}
+	if table := lastEvent[types.LeagueUpdateMessage](host, "Alice"); table.Round != 2 || table.NextRoundAt != 0 {
+		t.Errorf("Expected players to see round 2 open, got %+v", table)
+	}
+}
+
+func TestManager_Leagues(t *testing.T) {
+	manager := NewManager(newFakeHost())
+
+	if _, err := manager.CreateLeague(LeagueConfig{Format: "ladder"}); !errors.Is(err, ErrInvalidLeague) {
+		t.Errorf("Expected ErrInvalidLeague for an unknown format, got %v", err)
+	}
+	if _, err := manager.CreateLeague(LeagueConfig{Format: FormatRoundRobin, Rounds: 2}); !errors.Is(err, ErrInvalidLeague) {
+		t.Errorf("Expected ErrInvalidLeague for round robin rounds, got %v", err)
+	}
+
+	league := createLeague(t, manager, LeagueConfig{Format: FormatSwiss})
+	if league.Name != "League 1" || league.ID != "league-1" {
+		t.Errorf("Expected league-1 named League 1, got %s named %q", league.ID, league.Name)
+	}
+	if _, err := manager.Register(league.ID, "Alice", "Alice"); err != nil {
+		t.Errorf("Failed to register for the league: %v", err)
+	}
+	if _, err := manager.Register("league-2", "Alice", "Alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if got := ranking(league.Table()); !reflect.DeepEqual(got, []string{"Alice"}) {
+		t.Errorf("Expected Alice in the standings, got %v", got)
+	}
+}
@@ -0,0 +1,160 @@
+package tournament
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Manager creates tournaments and leagues and keeps them for lookup by ID
+type Manager struct {
+	host          Host
+	checkIn       time.Duration // how long a due match waits for its players
+	retry         time.Duration // how often a waiting match is tried
+	mu            sync.Mutex
+	tournaments   []*Tournament // in creation order
+	counter       int
+	leagues       []*League // in creation order
+	leagueCounter int
+}
+
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithCheckIn sets how long the players of a due match have to become
+// available, e.g. by reconnecting or finishing another game. A player who
+// is not available by then loses by no-show.
+func WithCheckIn(checkIn time.Duration) Option {
+	return func(m *Manager) {
+		m.checkIn = checkIn
+	}
+}
+
+// WithRetryInterval sets how often a match that waits for its players is
+// tried again
+func WithRetryInterval(retry time.Duration) Option {
+	return func(m *Manager) {
+		m.retry = retry
+	}
+}
+
+// NewManager creates a manager whose tournaments play their games on host
+func NewManager(host Host, opts ...Option) *Manager {
+	m := &Manager{
+		host:    host,
+		checkIn: 2 * time.Minute,
+		retry:   5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Create opens a new tournament for registration
+func (m *Manager) Create(name string) *Tournament {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.counter++
+	if name == "" {
+		name = fmt.Sprintf("Tournament %d", m.counter)
+	}
+	t := newTournament(m, fmt.Sprintf("tournament-%d", m.counter), name)
+	m.tournaments = append(m.tournaments, t)
+	log.Printf("Tournament %s (%s) created", t.ID, t.Name)
+	return t
+}
+
+// Get returns the tournament with the given ID
+func (m *Manager) Get(id string) (*Tournament, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.tournaments {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// List returns all tournaments in creation order
+func (m *Manager) List() []*Tournament {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Tournament(nil), m.tournaments...)
+}
+
+// CreateLeague opens a new league for registration
+func (m *Manager) CreateLeague(cfg LeagueConfig) (*League, error) {
+	if cfg.Format != FormatRoundRobin && cfg.Format != FormatSwiss {
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidLeague, cfg.Format)
+	}
+	if cfg.Rounds < 0 || cfg.RoundInterval < 0 {
+		return nil, fmt.Errorf("%w: rounds and round interval cannot be negative", ErrInvalidLeague)
+	}
+	if cfg.Format == FormatRoundRobin && cfg.Rounds != 0 {
+		return nil, fmt.Errorf("%w: a round robin has one round per opponent", ErrInvalidLeague)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.leagueCounter++
+	if cfg.Name == "" {
+		cfg.Name = fmt.Sprintf("League %d", m.leagueCounter)
+	}
+	l := newLeague(m, fmt.Sprintf("league-%d", m.leagueCounter), cfg)
+	m.leagues = append(m.leagues, l)
+	log.Printf("League %s (%s, %s) created", l.ID, l.Name, l.format)
+	return l, nil
+}
+
+// GetLeague returns the league with the given ID
+func (m *Manager) GetLeague(id string) (*League, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, l := range m.leagues {
+		if l.ID == id {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// Leagues returns all leagues in creation order
+func (m *Manager) Leagues() []*League {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*League(nil), m.leagues...)
+}
+
+// Register adds a player to the tournament or league with the given ID
+// and returns its name
+func (m *Manager) Register(id, name, clientID string) (string, error) {
+	if t, ok := m.Get(id); ok {
+		return t.Name, t.Register(name, clientID)
+	}
+	if l, ok := m.GetLeague(id); ok {
+		return l.Name, l.Register(name, clientID)
+	}
+	return "", ErrNotFound
+}
+
+// Close stops all tournaments and leagues, games that end afterwards are
+// not recorded
+func (m *Manager) Close() {
+	for _, t := range m.List() {
+		t.mu.Lock()
+		t.close()
+		t.mu.Unlock()
+	}
+	for _, l := range m.Leagues() {
+		l.mu.Lock()
+		l.close()
+		l.mu.Unlock()
+	}
+}
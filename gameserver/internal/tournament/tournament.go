@@ -0,0 +1,203 @@
+// Package tournament runs single-elimination tournaments and round-robin
+// or Swiss leagues on top of the lobby. Players register a name from their
+// connection, seeds follow registration order and each pairing is played
+// as a regular game once both players are available.
+package tournament
+
+import (
+	"errors"
+	"log"
+	"slices"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// Tournament states as sent in tournament_update
+const (
+	StateRegistering = "registering"
+	StateRunning     = "running"
+	StateFinished    = "finished"
+)
+
+// Match statuses as sent in tournament_update
+const (
+	statusPending = "pending" // waiting for the previous round
+	statusWaiting = "waiting" // due, waiting for both players to be available
+	statusPlaying = "playing"
+	statusDone    = "done"
+	statusBye     = "bye"     // at most one player, who advances without playing
+	statusNoShow  = "no_show" // a player did not show up before the check-in deadline
+)
+
+var (
+	// ErrStarted is returned for changes to a tournament that has started
+	ErrStarted = errors.New("tournament already started")
+	// ErrTooFewPlayers is returned when starting a tournament with less
+	// than two players
+	ErrTooFewPlayers = errors.New("a tournament needs at least two players")
+	// ErrInvalidName is returned when registering an empty name
+	ErrInvalidName = errors.New("player name cannot be empty")
+	// ErrNameTaken is returned when registering a name another client
+	// registered before
+	ErrNameTaken = errors.New("name registered by another player")
+	// ErrNotFound is returned for unknown tournament and league IDs
+	ErrNotFound = errors.New("tournament not found")
+)
+
+// Host starts the games of a tournament and reaches its players by client
+// ID, *lobby.Lobby is a Host
+type Host interface {
+	StartMatch(player1, player2 string, onEnd func(gameroom.Result)) error
+	SendTo(clientID string, event types.BaseGameEvent) bool
+}
+
+// Tournament is a single-elimination bracket. Players are identified by
+// the client they registered from, a player who resumes their session
+// keeps it between matches.
+type Tournament struct {
+	competition
+	rounds   [][]*match // first round first, the last one is the final
+	champion string
+}
+
+// newTournament creates a tournament open for registration
+func newTournament(m *Manager, id, name string) *Tournament {
+	t := &Tournament{competition: competition{
+		ID:      id,
+		Name:    name,
+		manager: m,
+		state:   StateRegistering,
+		seeds:   make(map[string]int),
+		clients: make(map[string]string),
+	}}
+	t.done = t.advance
+	t.update = func() { broadcast(&t.competition, t.bracket()) }
+	return t
+}
+
+// Start closes registration, seeds the bracket and starts the first round.
+// Missing players are padded with byes for the best seeds.
+func (t *Tournament) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.state != StateRegistering {
+		return ErrStarted
+	}
+	if len(t.players) < 2 {
+		return ErrTooFewPlayers
+	}
+
+	size := 2
+	for size < len(t.players) {
+		size *= 2
+	}
+	for matches, round := size/2, 0; matches >= 1; matches, round = matches/2, round+1 {
+		t.rounds = append(t.rounds, make([]*match, matches))
+		for i := range t.rounds[round] {
+			t.rounds[round][i] = &match{round: round, index: i, status: statusPending}
+		}
+	}
+
+	order := seedOrder(size)
+	for i, m := range t.rounds[0] {
+		for slot, seed := range order[2*i : 2*i+2] {
+			if seed <= len(t.players) {
+				m.players[slot] = t.players[seed-1]
+			}
+			m.decided[slot] = true
+		}
+	}
+
+	t.state = StateRunning
+	log.Printf("Tournament %s started with %d players in %d rounds", t.ID, len(t.players), len(t.rounds))
+	for _, m := range t.rounds[0] {
+		t.ready(m)
+	}
+	t.update()
+	return nil
+}
+
+// Bracket returns the tournament as sent to its players
+func (t *Tournament) Bracket() types.TournamentUpdateMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.bracket()
+}
+
+// seedOrder returns the seeds of a bracket with size slots in bracket
+// order, pairing the best with the worst so the top two seeds can only
+// meet in the final
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, seed := range order {
+			next = append(next, seed, 2*len(order)+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// ready is called once both slots of m are decided. Byes advance right
+// away, real pairings wait for their players. The caller must hold mu.
+func (t *Tournament) ready(m *match) {
+	if m.players[0] == "" || m.players[1] == "" {
+		// Nobody to play against, an empty match sends nobody through
+		m.status = statusBye
+		m.winner = m.players[0] + m.players[1]
+		t.advance(m)
+		return
+	}
+	t.checkIn(m)
+}
+
+// advance moves the winner of m into the next round, or ends the
+// tournament after the final. A draw sends the better seed through. The
+// caller must hold mu.
+func (t *Tournament) advance(m *match) {
+	if m.status == statusDone && m.winner == "" {
+		m.winner = m.players[0]
+		if t.seeds[m.players[1]] < t.seeds[m.winner] {
+			m.winner = m.players[1]
+		}
+	}
+
+	if m.round == len(t.rounds)-1 {
+		t.state = StateFinished
+		t.champion = m.winner
+		log.Printf("Tournament %s finished, champion: %q", t.ID, t.champion)
+		return
+	}
+
+	next := t.rounds[m.round+1][m.index/2]
+	next.players[m.index%2] = m.winner
+	next.decided[m.index%2] = true
+	if next.decided[0] && next.decided[1] {
+		t.ready(next)
+	}
+}
+
+// bracket builds the tournament_update message, the caller must hold mu
+func (t *Tournament) bracket() types.TournamentUpdateMessage {
+	msg := types.TournamentUpdateMessage{
+		TournamentID: t.ID,
+		Name:         t.Name,
+		State:        t.state,
+		Players:      slices.Clone(t.players),
+		Rounds:       make([]types.TournamentRound, len(t.rounds)),
+		Champion:     t.champion,
+	}
+	if msg.Players == nil {
+		msg.Players = []string{}
+	}
+	for i, round := range t.rounds {
+		msg.Rounds[i].Matches = make([]types.TournamentMatch, len(round))
+		for j, m := range round {
+			msg.Rounds[i].Matches[j] = m.view()
+		}
+	}
+	return msg
+}
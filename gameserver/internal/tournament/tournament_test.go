@@ -0,0 +1,264 @@
+package tournament
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4hel/paper/gameserver/internal/gameroom"
+	"github.com/4hel/paper/gameserver/internal/lobby"
+	"github.com/4hel/paper/gameserver/internal/protocol"
+	"github.com/4hel/paper/gameserver/internal/types"
+)
+
+// fakeHost records the matches a tournament starts and the updates it sends
+type fakeHost struct {
+	mu      sync.Mutex
+	away    map[string]bool // client IDs StartMatch reports as unavailable
+	matches []*fakeMatch
+	updates map[string][]types.BaseGameEvent
+}
+
+type fakeMatch struct {
+	player1, player2 string
+	onEnd            func(gameroom.Result)
+}
+
+func newFakeHost() *fakeHost {
+	return &fakeHost{away: make(map[string]bool), updates: make(map[string][]types.BaseGameEvent)}
+}
+
+func (h *fakeHost) StartMatch(player1, player2 string, onEnd func(gameroom.Result)) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	err := &lobby.UnavailableError{}
+	for _, id := range []string{player1, player2} {
+		if h.away[id] {
+			err.ClientIDs = append(err.ClientIDs, id)
+		}
+	}
+	if len(err.ClientIDs) > 0 {
+		return err
+	}
+	h.matches = append(h.matches, &fakeMatch{player1: player1, player2: player2, onEnd: onEnd})
+	return nil
+}
+
+func (h *fakeHost) SendTo(clientID string, event types.BaseGameEvent) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.updates[clientID] = append(h.updates[clientID], event)
+	return true
+}
+
+// match returns the i-th started match, failing the test if there is none
+func (h *fakeHost) match(t *testing.T, i int) *fakeMatch {
+	t.Helper()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.matches) <= i {
+		t.Fatalf("Expected match %d to be started, got %d matches", i, len(h.matches))
+	}
+	return h.matches[i]
+}
+
+// lastUpdate returns the last bracket sent to name
+func (h *fakeHost) lastUpdate(name string) types.TournamentUpdateMessage {
+	return lastEvent[types.TournamentUpdateMessage](h, name)
+}
+
+// lastEvent decodes the last event sent to name
+func lastEvent[T any](h *fakeHost, name string) T {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var msg T
+	if updates := h.updates[name]; len(updates) > 0 {
+		var err error
+		if msg, err = protocol.Decode[T](updates[len(updates)-1]); err != nil {
+			panic(err)
+		}
+	}
+	return msg
+}
+
+// win ends a started match with winner taking two rounds
+func (m *fakeMatch) win(winner string) {
+	result := gameroom.Result{Player1: m.player1, Player2: m.player2, Winner: winner}
+	if winner == m.player1 {
+		result.Player1Wins = 2
+	} else {
+		result.Player2Wins = 2
+	}
+	m.onEnd(result)
+}
+
+func register(t *testing.T, tournament *Tournament, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := tournament.Register(name, name); err != nil {
+			t.Fatalf("Failed to register %s: %v", name, err)
+		}
+	}
+}
+
+func statuses(bracket types.TournamentUpdateMessage) [][]string {
+	var rounds [][]string
+	for _, round := range bracket.Rounds {
+		var matches []string
+		for _, m := range round.Matches {
+			matches = append(matches, m.Player1+"-"+m.Player2+":"+m.Status+":"+m.Winner)
+		}
+		rounds = append(rounds, matches)
+	}
+	return rounds
+}
+
+func TestSeedOrder(t *testing.T) {
+	expected := []int{1, 8, 4, 5, 2, 7, 3, 6}
+	if order := seedOrder(8); !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected %v, got %v", expected, order)
+	}
+}
+
+func TestTournament_ByesAndAdvance(t *testing.T) {
+	host := newFakeHost()
+	tournament := NewManager(host).Create("Office")
+	register(t, tournament, "Alice", "Bob", "Carol")
+
+	if err := tournament.Start(); err != nil {
+		t.Fatal("Failed to start:", err)
+	}
+
+	// Alice is the top seed and gets the bye
+	expected := [][]string{
+		{"Alice-:bye:Alice", "Bob-Carol:playing:"},
+		{"Alice-:pending:"},
+	}
+	if got := statuses(tournament.Bracket()); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	host.match(t, 0).win("Carol")
+	final := host.match(t, 1)
+	if final.player1 != "Alice" || final.player2 != "Carol" {
+		t.Fatalf("Expected Alice vs Carol in the final, got %s vs %s", final.player1, final.player2)
+	}
+	final.win("Alice")
+
+	bracket := host.lastUpdate("Bob")
+	if bracket.State != StateFinished || bracket.Champion != "Alice" {
+		t.Errorf("Expected eliminated players to see Alice win, got %s %q", bracket.State, bracket.Champion)
+	}
+	if m := bracket.Rounds[1].Matches[0]; m.Status != statusDone || m.Player1Wins != 2 || m.Player2Wins != 0 {
+		t.Errorf("Expected the final score in the bracket, got %+v", m)
+	}
+}
+
+func TestTournament_DrawAdvancesBetterSeed(t *testing.T) {
+	host := newFakeHost()
+	tournament := NewManager(host).Create("Office")
+	register(t, tournament, "Alice", "Bob")
+	tournament.Start()
+
+	host.match(t, 0).onEnd(gameroom.Result{Player1: "Alice", Player2: "Bob"})
+
+	if bracket := tournament.Bracket(); bracket.Champion != "Alice" {
+		t.Errorf("Expected the top seed to win a draw, got %q", bracket.Champion)
+	}
+}
+
+func TestTournament_NoShow(t *testing.T) {
+	host := newFakeHost()
+	host.away["Bob"] = true
+	manager := NewManager(host, WithCheckIn(50*time.Millisecond), WithRetryInterval(10*time.Millisecond))
+	defer manager.Close()
+
+	tournament := manager.Create("Office")
+	register(t, tournament, "Alice", "Bob", "Carol", "Dave")
+	tournament.Start()
+
+	// Bob misses the match against Carol, Dave is on time
+	host.match(t, 0).win("Alice")
+	deadline := time.Now().Add(time.Second)
+	for tournament.Bracket().Rounds[0].Matches[1].Status == statusWaiting {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the check-in to expire")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	expected := [][]string{
+		{"Alice-Dave:done:Alice", "Bob-Carol:no_show:Carol"},
+		{"Alice-Carol:playing:"},
+	}
+	if got := statuses(tournament.Bracket()); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestTournament_Registration(t *testing.T) {
+	host := newFakeHost()
+	tournament := NewManager(host).Create("")
+
+	if tournament.Name != "Tournament 1" {
+		t.Errorf("Expected a default name, got %q", tournament.Name)
+	}
+	if err := tournament.Register(" ", "c1"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Expected ErrInvalidName, got %v", err)
+	}
+	register(t, tournament, "Alice", "Alice")
+	if err := tournament.Start(); !errors.Is(err, ErrTooFewPlayers) {
+		t.Errorf("Expected ErrTooFewPlayers, got %v", err)
+	}
+	if update := host.lastUpdate("Alice"); update.State != StateRegistering || len(update.Players) != 1 {
+		t.Errorf("Expected Alice to be registered once, got %+v", update)
+	}
+	// The name belongs to the client that registered it
+	if err := tournament.Register("Alice", "impostor"); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("Expected ErrNameTaken for another client, got %v", err)
+	}
+
+	// A name an admin registered goes to the first client that claims it
+	tournament.Register("Dave", "")
+	if err := tournament.Register("Dave", "client-d"); err != nil {
+		t.Errorf("Expected Dave's client to claim the name, got %v", err)
+	}
+	if err := tournament.Register("Dave", "impostor"); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("Expected ErrNameTaken once Dave claimed the name, got %v", err)
+	}
+
+	register(t, tournament, "Bob")
+	tournament.Start()
+	if err := tournament.Register("Carol", "Carol"); !errors.Is(err, ErrStarted) {
+		t.Errorf("Expected ErrStarted, got %v", err)
+	}
+	if err := tournament.Start(); !errors.Is(err, ErrStarted) {
+		t.Errorf("Expected ErrStarted, got %v", err)
+	}
+}
+
+func TestTournament_PlaysRegisteredClients(t *testing.T) {
+	host := newFakeHost()
+	tournament := NewManager(host).Create("")
+	tournament.Register("Alice", "client-a")
+	tournament.Register("Bob", "client-b")
+	tournament.Start()
+
+	// Games start between the registered clients, who get the bracket
+	m := host.match(t, 0)
+	if m.player1 != "client-a" || m.player2 != "client-b" {
+		t.Fatalf("Expected a match between client-a and client-b, got %s and %s", m.player1, m.player2)
+	}
+	if update := host.lastUpdate("client-b"); update.State != StateRunning {
+		t.Errorf("Expected the running bracket for Bob's client, got %+v", update)
+	}
+
+	// Alice plays under another name in the lobby and still wins
+	m.onEnd(gameroom.Result{Player1: "Alicia", Player2: "Bob", Player1Wins: 2, Winner: "Alicia"})
+	if bracket := tournament.Bracket(); bracket.Champion != "Alice" {
+		t.Errorf("Expected Alice to be champion, got %+v", bracket)
+	}
+}
@@ -7,35 +7,145 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// Capabilities holds what a client announced in its hello handshake
+// together with the features both sides agreed on
+type Capabilities struct {
+	ProtocolVersion int
+	ClientName      string
+	ClientVersion   string
+	Features        []string
+}
+
 // Client represents a connected WebSocket client
 type Client struct {
-	ID         string
-	Name       string
-	Conn       *websocket.Conn
-	Send       chan BaseGameEvent
-	InLobby    bool
-	InGame     bool
-	GameRoomID string
-	mu         sync.RWMutex
-	Ctx        context.Context
-	cancel     context.CancelFunc
-	closed     bool
+	ID           string
+	Name         string
+	Conn         *websocket.Conn
+	Send         chan BaseGameEvent
+	closing      chan struct{}
+	sendMu       sync.Mutex
+	policy       DeliveryPolicy
+	stats        DeliveryStats
+	session      *Session
+	relay        *Client // newer connection that resumed the session, gets the client's events
+	state        ClientState
+	gameRoomID   string
+	observers    []TransitionObserver
+	mu           sync.RWMutex
+	Ctx          context.Context
+	cancel       context.CancelFunc
+	closed       bool
+	handshaken   bool
+	capabilities Capabilities
+	closeCode    int
+	closeReason  string
 }
 
 // NewClient creates a new client instance
 func NewClient(id string, conn *websocket.Conn) *Client {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Client{
-		ID:      id,
-		Conn:    conn,
+		ID:     id,
+		Conn:   conn,
 		Send:    make(chan BaseGameEvent, 256),
-		InLobby: false,
-		InGame:  false,
+		closing: make(chan struct{}),
+		policy:  DefaultDeliveryPolicy,
+		state:   StateConnected,
 		Ctx:     ctx,
 		cancel:  cancel,
 	}
 }
 
+// State returns the client's current lifecycle state
+func (c *Client) State() ClientState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.state
+}
+
+// GameRoomID returns the ID of the room the client plays in, if any
+func (c *Client) GameRoomID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.gameRoomID
+}
+
+// Observe registers fn to be called after every state transition
+func (c *Client) Observe(fn TransitionObserver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.observers = append(c.observers, fn)
+}
+
+// Transition moves the client to a new state if the state table allows it.
+// Leaving StateInGame clears the game room ID.
+func (c *Client) Transition(to ClientState) error {
+	return c.transition(to, "")
+}
+
+// EnterGame moves a queued client into the given game room
+func (c *Client) EnterGame(gameRoomID string) error {
+	return c.transition(StateInGame, gameRoomID)
+}
+
+func (c *Client) transition(to ClientState, gameRoomID string) error {
+	c.mu.Lock()
+	from := c.state
+	if !CanTransition(from, to) {
+		c.mu.Unlock()
+		return &TransitionError{ClientID: c.ID, From: from, To: to}
+	}
+	c.state = to
+	c.gameRoomID = gameRoomID
+	observers := c.observers
+	c.mu.Unlock()
+
+	// Notify outside the lock so observers may call back into the client
+	for _, observer := range observers {
+		observer(c, from, to)
+	}
+	return nil
+}
+
+// AttachSession makes the client number and record its events in session
+func (c *Client) AttachSession(session *Session) {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = session
+}
+
+// DetachSession stops recording events, e.g. because a new connection
+// resumed the session
+func (c *Client) DetachSession() {
+	c.AttachSession(nil)
+}
+
+// RelayTo sends the client's events to conn from now on, because conn
+// resumed the client's session. catchUp runs first while no event can be
+// sent to the client, RelayTo gives up if it returns false. A nil conn
+// stops relaying.
+func (c *Client) RelayTo(conn *Client, catchUp func() bool) bool {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if catchUp != nil && !catchUp() {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.relay = conn
+	return true
+}
+
+// Session returns the client's session, or nil before the handshake
+func (c *Client) Session() *Session {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.session
+}
+
 // SetName sets the client's name safely
 func (c *Client) SetName(name string) {
 	c.mu.Lock()
@@ -50,19 +160,76 @@ func (c *Client) GetName() string {
 	return c.Name
 }
 
-// Close closes the client connection and cancels context
+// SetCapabilities records the result of the hello handshake
+func (c *Client) SetCapabilities(caps Capabilities) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capabilities = caps
+	c.handshaken = true
+}
+
+// Capabilities returns the negotiated capabilities and whether the
+// client has completed the hello handshake
+func (c *Client) Capabilities() (Capabilities, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.capabilities, c.handshaken
+}
+
+// HasFeature reports whether a feature was negotiated for this client
+func (c *Client) HasFeature(feature string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, f := range c.capabilities.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the client connection and cancels context. The Send
+// channel is never closed, so late senders cannot panic.
 func (c *Client) Close() {
+	c.cancel()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	
+
+	c.closed = true
+	if c.Conn != nil {
+		c.Conn.Close()
+	}
+}
+
+// CloseGracefully stops accepting new messages but lets the write pump
+// flush what is already queued, then close the connection with the given
+// WebSocket close code and reason
+func (c *Client) CloseGracefully(code int, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.closed {
 		return // Already closed
 	}
-	
+
 	c.closed = true
-	c.cancel()
-	close(c.Send)
-	c.Conn.Close()
+	c.closeCode = code
+	c.closeReason = reason
+	close(c.closing)
+}
+
+// Closing is closed when CloseGracefully was called, telling the write
+// pump to flush the Send buffer and close the connection
+func (c *Client) Closing() <-chan struct{} {
+	return c.closing
+}
+
+// CloseReason returns the close code and reason set by CloseGracefully
+func (c *Client) CloseReason() (int, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.closeCode, c.closeReason
 }
 
 // IsClosed returns true if the client has been closed
@@ -136,20 +136,10 @@ func TestClient_SendChannelAfterClose(t *testing.T) {
 	}
 
 	// Trying to send should not panic but should fail gracefully
-	func() {
-		defer func() {
-			if r := recover(); r != nil {
-				// This is currently expected behavior - we're testing that the 
-				// double close is fixed, not that sending to closed channels is safe
-				t.Logf("Expected panic when sending to closed channel: %v", r)
-			}
-		}()
-
-		select {
-		case client.Send <- BaseGameEvent{Type: "test", Data: []byte("{}")}:
-			t.Error("Should not be able to send to closed channel")
-		default:
-			// Expected - channel is closed or send would block
-		}
-	}()
+	if err := client.Deliver(BaseGameEvent{Type: "test", Data: []byte("{}")}); err != ErrClientClosed {
+		t.Errorf("Expected ErrClientClosed, got %v", err)
+	}
+	if len(client.Send) != 0 {
+		t.Error("Should not be able to queue messages on a closed client")
+	}
 }
\ No newline at end of file
@@ -0,0 +1,237 @@
+package types
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DeliveryMode decides what happens when a client's send buffer is full
+type DeliveryMode int
+
+const (
+	// DeliverBlock waits up to the policy timeout for buffer space, then
+	// disconnects the client
+	DeliverBlock DeliveryMode = iota
+	// DeliverCoalesce drops queued messages of the same type as the new
+	// one if that type is a state snapshot the newer message supersedes,
+	// see coalescable. Otherwise, or if there are none, the client is
+	// disconnected.
+	DeliverCoalesce
+	// DeliverDisconnect disconnects the client as soon as its buffer is full
+	DeliverDisconnect
+)
+
+// String returns a readable name for the mode
+func (m DeliveryMode) String() string {
+	switch m {
+	case DeliverBlock:
+		return "block"
+	case DeliverCoalesce:
+		return "coalesce"
+	case DeliverDisconnect:
+		return "disconnect"
+	default:
+		return "unknown"
+	}
+}
+
+// CloseReasonSlowConsumer is the close reason sent to clients that are
+// disconnected because they do not read their messages
+const CloseReasonSlowConsumer = "slow_consumer"
+
+var (
+	// ErrClientClosed is returned when delivering to a closed client
+	ErrClientClosed = errors.New("client closed")
+	// ErrSlowConsumer is returned when a message could not be queued and
+	// the client was disconnected
+	ErrSlowConsumer = errors.New("slow consumer disconnected")
+)
+
+// coalescable are the event types that carry a complete state, so only the
+// newest one queued matters. Other events such as round_result each carry
+// something the client must see.
+var coalescable = map[string]bool{
+	"spectate_update":   true,
+	"tournament_update": true,
+	"league_update":     true,
+	"match_update":      true,
+}
+
+// DeliveryPolicy configures how messages are queued for a client
+type DeliveryPolicy struct {
+	Mode DeliveryMode
+	// Timeout is how long DeliverBlock waits for buffer space
+	Timeout time.Duration
+	// Stats, if set, is shared by many clients to count totals
+	Stats *DeliveryStats
+}
+
+// DefaultDeliveryPolicy blocks briefly before giving up on a client
+var DefaultDeliveryPolicy = DeliveryPolicy{Mode: DeliverBlock, Timeout: 2 * time.Second}
+
+// DeliveryStats counts delivery outcomes. It is safe for concurrent use.
+type DeliveryStats struct {
+	delivered    atomic.Uint64
+	coalesced    atomic.Uint64
+	dropped      atomic.Uint64
+	disconnected atomic.Uint64
+}
+
+// DeliveryCounts is a snapshot of DeliveryStats
+type DeliveryCounts struct {
+	Delivered    uint64 `json:"delivered"`
+	Coalesced    uint64 `json:"coalesced"`
+	Dropped      uint64 `json:"dropped"`
+	Disconnected uint64 `json:"disconnected"`
+}
+
+// Snapshot returns the current counts
+func (s *DeliveryStats) Snapshot() DeliveryCounts {
+	return DeliveryCounts{
+		Delivered:    s.delivered.Load(),
+		Coalesced:    s.coalesced.Load(),
+		Dropped:      s.dropped.Load(),
+		Disconnected: s.disconnected.Load(),
+	}
+}
+
+// SetDeliveryPolicy changes how messages are queued for this client
+func (c *Client) SetDeliveryPolicy(policy DeliveryPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policy = policy
+}
+
+// DeliveryStats returns this client's delivery counts
+func (c *Client) DeliveryStats() DeliveryCounts {
+	return c.stats.Snapshot()
+}
+
+// Deliver queues an event for the write pump according to the client's
+// delivery policy. Messages are never dropped silently: if one cannot be
+// queued the client is disconnected with CloseReasonSlowConsumer and
+// ErrSlowConsumer is returned.
+func (c *Client) Deliver(event BaseGameEvent) error {
+	// One sender at a time, so coalescing sees a stable buffer and
+	// messages from concurrent senders keep their order
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.mu.RLock()
+	closed, policy, session, relay := c.closed, c.policy, c.session, c.relay
+	c.mu.RUnlock()
+
+	// Number the event even if the connection is gone, so it can still be
+	// replayed when the player reconnects
+	if session != nil {
+		event = session.Stamp(event)
+	}
+	if relay != nil {
+		return relay.Replay([]BaseGameEvent{event})
+	}
+	if closed {
+		return ErrClientClosed
+	}
+	return c.enqueue(event, policy)
+}
+
+// Replay queues events that already carry their sequence numbers, in
+// order and under the client's delivery policy
+func (c *Client) Replay(events []BaseGameEvent) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.mu.RLock()
+	closed, policy := c.closed, c.policy
+	c.mu.RUnlock()
+	if closed {
+		return ErrClientClosed
+	}
+
+	for _, event := range events {
+		if err := c.enqueue(event, policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueue puts one event on the Send buffer. The caller must hold sendMu.
+func (c *Client) enqueue(event BaseGameEvent, policy DeliveryPolicy) error {
+	select {
+	case c.Send <- event:
+		c.count(policy, func(s *DeliveryStats) { s.delivered.Add(1) })
+		return nil
+	default:
+	}
+
+	switch policy.Mode {
+	case DeliverBlock:
+		timer := time.NewTimer(policy.Timeout)
+		defer timer.Stop()
+		select {
+		case c.Send <- event:
+			c.count(policy, func(s *DeliveryStats) { s.delivered.Add(1) })
+			return nil
+		case <-c.Ctx.Done():
+			return ErrClientClosed
+		case <-timer.C:
+		}
+	case DeliverCoalesce:
+		if !coalescable[event.Type] {
+			break
+		}
+		if removed := c.coalesce(event.Type); removed > 0 {
+			c.Send <- event
+			c.count(policy, func(s *DeliveryStats) {
+				s.coalesced.Add(uint64(removed))
+				s.delivered.Add(1)
+			})
+			return nil
+		}
+	}
+
+	c.count(policy, func(s *DeliveryStats) {
+		s.dropped.Add(1)
+		s.disconnected.Add(1)
+	})
+	c.CloseGracefully(websocket.ClosePolicyViolation, CloseReasonSlowConsumer)
+	return ErrSlowConsumer
+}
+
+// coalesce removes queued events of the given type and returns how many
+// were removed. The caller must hold sendMu.
+func (c *Client) coalesce(eventType string) int {
+	var pending []BaseGameEvent
+	removed := 0
+drain:
+	for {
+		select {
+		case queued := <-c.Send:
+			if queued.Type == eventType {
+				removed++
+			} else {
+				pending = append(pending, queued)
+			}
+		default:
+			break drain
+		}
+	}
+
+	// Only this goroutine sends, so the kept events fit back in order
+	for _, queued := range pending {
+		c.Send <- queued
+	}
+	return removed
+}
+
+// count applies fn to the client's own stats and the policy's shared stats
+func (c *Client) count(policy DeliveryPolicy, fn func(s *DeliveryStats)) {
+	fn(&c.stats)
+	if policy.Stats != nil {
+		fn(policy.Stats)
+	}
+}
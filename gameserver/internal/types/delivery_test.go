@@ -0,0 +1,195 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// stalledClient returns a client whose reader never drains its buffer,
+// with the buffer already full of the given event types
+func stalledClient(t *testing.T, policy DeliveryPolicy, fill ...string) *Client {
+	client := NewClient("stalled", nil)
+	client.SetDeliveryPolicy(policy)
+	for i := 0; i < cap(client.Send); i++ {
+		eventType := fill[i%len(fill)]
+		if err := client.Deliver(BaseGameEvent{Type: eventType}); err != nil {
+			t.Fatalf("Filling buffer failed at %d: %v", i, err)
+		}
+	}
+	return client
+}
+
+func TestDeliver_BlockWaitsForReader(t *testing.T) {
+	var stats DeliveryStats
+	client := stalledClient(t, DeliveryPolicy{Mode: DeliverBlock, Timeout: time.Second, Stats: &stats}, "round_start")
+
+	// The reader wakes up after a short stall
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-client.Send
+	}()
+
+	if err := client.Deliver(BaseGameEvent{Type: "round_result"}); err != nil {
+		t.Fatalf("Expected delivery once the reader caught up, got %v", err)
+	}
+	if client.IsClosed() {
+		t.Error("Client should stay connected")
+	}
+	if counts := stats.Snapshot(); counts.Delivered != uint64(cap(client.Send))+1 || counts.Dropped != 0 {
+		t.Errorf("Unexpected shared counts %+v", counts)
+	}
+}
+
+func TestDeliver_BlockTimesOut(t *testing.T) {
+	client := stalledClient(t, DeliveryPolicy{Mode: DeliverBlock, Timeout: 20 * time.Millisecond}, "round_start")
+
+	start := time.Now()
+	err := client.Deliver(BaseGameEvent{Type: "round_result"})
+	if !errors.Is(err, ErrSlowConsumer) {
+		t.Fatalf("Expected ErrSlowConsumer, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Deliver should wait for the timeout, returned after %v", elapsed)
+	}
+	assertSlowConsumerClosed(t, client)
+}
+
+func TestDeliver_Disconnect(t *testing.T) {
+	client := stalledClient(t, DeliveryPolicy{Mode: DeliverDisconnect}, "round_start")
+
+	if err := client.Deliver(BaseGameEvent{Type: "round_result"}); !errors.Is(err, ErrSlowConsumer) {
+		t.Fatalf("Expected ErrSlowConsumer, got %v", err)
+	}
+	assertSlowConsumerClosed(t, client)
+
+	// Everything queued before the disconnect is still there to be flushed
+	if len(client.Send) != cap(client.Send) {
+		t.Errorf("Expected a full buffer to flush, got %d messages", len(client.Send))
+	}
+}
+
+func TestDeliver_Coalesce(t *testing.T) {
+	client := stalledClient(t, DeliveryPolicy{Mode: DeliverCoalesce}, "round_result", "spectate_update")
+
+	// The new update supersedes every queued one
+	if err := client.Deliver(BaseGameEvent{Type: "spectate_update", Data: []byte(`{"round_number":9}`)}); err != nil {
+		t.Fatalf("Expected coalesced delivery, got %v", err)
+	}
+
+	counts := client.DeliveryStats()
+	if counts.Coalesced != uint64(cap(client.Send)/2) {
+		t.Errorf("Expected %d coalesced messages, got %+v", cap(client.Send)/2, counts)
+	}
+
+	// Order of the remaining messages is kept and the update comes last
+	var got []BaseGameEvent
+	for len(client.Send) > 0 {
+		got = append(got, <-client.Send)
+	}
+	for i, event := range got[:len(got)-1] {
+		if event.Type != "round_result" {
+			t.Fatalf("Message %d: expected round_result, got %s", i, event.Type)
+		}
+	}
+	if last := got[len(got)-1]; last.Type != "spectate_update" || string(last.Data) != `{"round_number":9}` {
+		t.Errorf("Expected the newest update last, got %s %s", last.Type, last.Data)
+	}
+}
+
+func TestDeliver_CoalesceWithoutMatchDisconnects(t *testing.T) {
+	client := stalledClient(t, DeliveryPolicy{Mode: DeliverCoalesce}, "round_result")
+
+	// Nothing queued can be replaced by a game_ended, so it cannot be queued
+	if err := client.Deliver(BaseGameEvent{Type: "game_ended"}); !errors.Is(err, ErrSlowConsumer) {
+		t.Fatalf("Expected ErrSlowConsumer, got %v", err)
+	}
+	assertSlowConsumerClosed(t, client)
+}
+
+func TestDeliver_CoalesceKeepsRoundResults(t *testing.T) {
+	client := stalledClient(t, DeliveryPolicy{Mode: DeliverCoalesce}, "round_result")
+
+	// Every round's result matters, so a new one cannot replace the queued ones
+	if err := client.Deliver(BaseGameEvent{Type: "round_result"}); !errors.Is(err, ErrSlowConsumer) {
+		t.Fatalf("Expected ErrSlowConsumer, got %v", err)
+	}
+	assertSlowConsumerClosed(t, client)
+
+	if counts := client.DeliveryStats(); counts.Coalesced != 0 {
+		t.Errorf("Expected nothing to be coalesced, got %+v", counts)
+	}
+	if len(client.Send) != cap(client.Send) {
+		t.Errorf("Expected every queued round_result to be kept, got %d messages", len(client.Send))
+	}
+}
+
+func TestDeliver_ConcurrentWithClose(t *testing.T) {
+	// Senders racing with Close must never panic
+	for i := 0; i < 50; i++ {
+		client := NewClient(fmt.Sprintf("race-%d", i), nil)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for j := 0; j < 100; j++ {
+				client.Deliver(BaseGameEvent{Type: "round_start"})
+			}
+		}()
+		client.Close()
+		<-done
+	}
+}
+
+func assertSlowConsumerClosed(t *testing.T, client *Client) {
+	t.Helper()
+	if !client.IsClosed() {
+		t.Fatal("Slow consumer should be closed")
+	}
+	select {
+	case <-client.Closing():
+	default:
+		t.Error("Closing should signal the write pump")
+	}
+	if code, reason := client.CloseReason(); code != websocket.ClosePolicyViolation || reason != CloseReasonSlowConsumer {
+		t.Errorf("Expected close %d %s, got %d %s", websocket.ClosePolicyViolation, CloseReasonSlowConsumer, code, reason)
+	}
+	if counts := client.DeliveryStats(); counts.Dropped != 1 || counts.Disconnected != 1 {
+		t.Errorf("Expected one dropped message and one disconnect, got %+v", counts)
+	}
+}
+
+func TestDeliver_RelaysToResumingConnection(t *testing.T) {
+	session := NewSession("session", 8)
+	seat := NewClient("seat", nil)
+	seat.AttachSession(session)
+	seat.Close()
+
+	// Events for a disconnected player are only recorded
+	if err := seat.Deliver(BaseGameEvent{Type: "round_start"}); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("Expected ErrClientClosed, got %v", err)
+	}
+
+	conn := NewClient("conn", nil)
+	seat.RelayTo(conn, func() bool {
+		missed, _ := session.After(0)
+		return conn.Replay(missed) == nil
+	})
+	if err := seat.Deliver(BaseGameEvent{Type: "round_result"}); err != nil {
+		t.Fatalf("Expected the event to be relayed, got %v", err)
+	}
+
+	for _, want := range []BaseGameEvent{{Type: "round_start", Seq: 1}, {Type: "round_result", Seq: 2}} {
+		got := <-conn.Send
+		if got.Type != want.Type || got.Seq != want.Seq {
+			t.Errorf("Expected %s with seq %d, got %s with seq %d", want.Type, want.Seq, got.Type, got.Seq)
+		}
+	}
+
+	seat.RelayTo(nil, nil)
+	if err := seat.Deliver(BaseGameEvent{Type: "game_ended"}); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed once relaying stopped, got %v", err)
+	}
+}
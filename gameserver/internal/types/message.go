@@ -6,42 +6,275 @@ import "encoding/json"
 type BaseGameEvent struct {
 	Type string          `json:"type"`
 	Data json.RawMessage `json:"data"`
+	Seq  uint64          `json:"seq,omitempty"` // set on server events, increases per session
 }
 
 // Client to Server Messages
+type HelloMessage struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	ClientName      string   `json:"client_name"`
+	ClientVersion   string   `json:"client_version"`
+	Features        []string `json:"features"` // "resume", "spectate", "rulesets"
+	SessionID       string   `json:"session_id,omitempty"` // session to resume after a reconnect, or of games saved before a restart
+	LastSeq         uint64   `json:"last_seq,omitempty"`   // last seq received in that session
+}
+
+type AckMessage struct {
+	Seq uint64 `json:"seq"` // every event up to and including seq was received
+}
+
 type JoinLobbyMessage struct {
-	Name string `json:"name"`
+	Name     string `json:"name"`
+	RoomSize int    `json:"room_size,omitempty"` // players per game, more than 2 for a free-for-all
+	Teams    bool   `json:"teams,omitempty"`     // play 2v2 in teams
+	Teammate string `json:"teammate,omitempty"`  // name of the player to team up with, anyone if empty
+	Game     string `json:"game,omitempty"`      // game type, "rps" if empty
 }
 
 type MakeChoiceMessage struct {
 	Choice string `json:"choice"` // "rock", "paper", "scissors"
 }
 
+// MakeMoveMessage places a piece in a turn-based game. Cells count from 0,
+// row 0 is the top row.
+type MakeMoveMessage struct {
+	Row     int    `json:"row"` // ignored where pieces drop, as in connect_four
+	Column  int    `json:"column"`
+	MatchID string `json:"match_id,omitempty"` // a correspondence match to move in instead of the running game
+}
+
 type PlayAgainMessage struct{}
 
+type SpectateMessage struct {
+	PlayerName string `json:"player_name"` // name of a player in the game to watch
+}
+
 type DisconnectMessage struct{}
 
+type JoinTournamentMessage struct {
+	TournamentID string `json:"tournament_id"`  // a tournament or league ID
+	Name         string `json:"name,omitempty"` // player name, for clients that have not joined the lobby
+}
+
+// CreateMatchMessage asks for a correspondence match against the longest
+// waiting player of the same game
+type CreateMatchMessage struct {
+	Game      string `json:"game"`                 // a turn-based game type
+	Name      string `json:"name,omitempty"`       // player name, for clients that have not joined the lobby
+	PlayerKey string `json:"player_key,omitempty"` // from an earlier match_list, empty to be issued a new one
+}
+
+type ListMatchesMessage struct {
+	Name      string `json:"name,omitempty"`       // player name, for clients that have not joined the lobby
+	PlayerKey string `json:"player_key,omitempty"` // from an earlier match_list, empty to be issued a new one
+}
+
 // Server to Client Messages
+type WelcomeMessage struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	ServerVersion   string   `json:"server_version"`
+	Features        []string `json:"features"` // features both sides support
+	SessionID       string   `json:"session_id"`
+	Resumed         bool     `json:"resumed"` // missed events follow, replayed with their original seq
+}
+
 type PlayerWaitingMessage struct{}
 
 type GameStartingMessage struct {
-	OpponentName string `json:"opponent_name"`
+	OpponentName string   `json:"opponent_name"`       // in a free-for-all or team game every opponent, comma separated
+	Opponents    []string `json:"opponents,omitempty"` // every opponent of a free-for-all or team game
+	Teammate     string   `json:"teammate,omitempty"`  // in a team game
+	Captain      string   `json:"captain,omitempty"`   // whose vote counts when the team disagrees, if the captain decides
+	Game         string   `json:"game,omitempty"`      // game type of a two-player game
+	Role         string   `json:"role,omitempty"`      // what the player plays as, e.g. "matcher", in games where the players differ
+	Actions      []string `json:"actions,omitempty"`   // choices make_choice accepts
+}
+
+// BoardUpdateMessage shows the board of a turn-based game after every move,
+// and once before the first
+type BoardUpdateMessage struct {
+	Board      []string   `json:"board"`               // rows top first, a character per cell, "." if empty
+	MoveNumber int        `json:"move_number"`         // moves made so far
+	Turn       string     `json:"turn,omitempty"`      // player to move next, empty once the game is over
+	YourTurn   bool       `json:"your_turn"`
+	LastMove   *BoardMove `json:"last_move,omitempty"` // the move that led to this board
+}
+
+// BoardMove is a move of a turn-based game
+type BoardMove struct {
+	Player string `json:"player"`
+	Row    int    `json:"row"` // where the piece landed
+	Column int    `json:"column"`
 }
 
 type RoundResultMessage struct {
-	Result       string `json:"result"`        // "win", "lose", "draw"
-	YourChoice   string `json:"your_choice"`   // "rock", "paper", "scissors"
-	OpponentChoice string `json:"opponent_choice"` // "rock", "paper", "scissors"
+	Result              string         `json:"result"`                          // "win", "lose", "draw"
+	YourChoice          string         `json:"your_choice"`                     // "rock", "paper", "scissors"
+	OpponentChoice      string         `json:"opponent_choice"`                 // "rock", "paper", "scissors"
+	Points              int            `json:"points,omitempty"`                // earned this round, a won round is 1 unless the game pays more
+	OpponentPoints      int            `json:"opponent_points,omitempty"`       // earned this round
+	TotalPoints         int            `json:"total_points,omitempty"`          // earned in the game so far
+	OpponentTotalPoints int            `json:"opponent_total_points,omitempty"` // earned in the game so far
+	Players             []PlayerResult `json:"players,omitempty"`               // every player of a free-for-all or team game
+}
+
+// PlayerResult is one player's round of a free-for-all or team game. In a
+// team game the choice is the player's vote, result and points are the
+// team's.
+type PlayerResult struct {
+	Name       string `json:"name"`
+	Choice     string `json:"choice,omitempty"` // empty if the player ran out of time or is out
+	Result     string `json:"result,omitempty"` // "win", "lose", "draw", empty if the player is out
+	Points     int    `json:"points"`
+	Eliminated bool   `json:"eliminated,omitempty"` // knocked out or left, in this round or before
+}
+
+// TeamVoteMessage tells a player what their teammate voted for. Votes
+// can change until the round locks.
+type TeamVoteMessage struct {
+	Player string `json:"player"`
+	Choice string `json:"choice"` // "rock", "paper", "scissors"
 }
 
 type RoundStartMessage struct {
-	RoundNumber int `json:"round_number"`
+	RoundNumber  int         `json:"round_number"`
+	YourHand     []HandCount `json:"your_hand,omitempty"`     // actions left in a limited hand game
+	OpponentHand []HandCount `json:"opponent_hand,omitempty"` // actions left in a limited hand game
+	SuddenDeath  bool        `json:"sudden_death,omitempty"`  // the game was tied, the first round won decides it
+}
+
+// HandCount is how often a player can still play an action
+type HandCount struct {
+	Action string `json:"action"`
+	Count  int    `json:"count"`
 }
 
 type GameEndedMessage struct {
-	Result string `json:"result"` // "win", "lose", "draw"
+	Result         string `json:"result"`                    // "win", "lose", "draw"
+	Points         int    `json:"points,omitempty"`          // final score of a two-player game
+	OpponentPoints int    `json:"opponent_points,omitempty"` // final score of a two-player game
+}
+
+type SpectateUpdateMessage struct {
+	RoundNumber   int    `json:"round_number"`
+	Player1       string `json:"player1"`
+	Player2       string `json:"player2"`
+	Player1Wins   int    `json:"player1_wins"`
+	Player2Wins   int    `json:"player2_wins"`
+	Player1Choice string `json:"player1_choice,omitempty"` // set once the round is played
+	Player2Choice string `json:"player2_choice,omitempty"` // set once the round is played
+	GameOver      bool   `json:"game_over"`
+}
+
+type ServerShuttingDownMessage struct {
+	Deadline int64  `json:"deadline"` // unix time in seconds when remaining connections are closed
+	Message  string `json:"message"`
+}
+
+type GameResumedMessage struct {
+	OpponentName string   `json:"opponent_name"`
+	RoundNumber  int      `json:"round_number"` // round the game continues with
+	YourWins     int      `json:"your_wins"`
+	OpponentWins int      `json:"opponent_wins"`
+	Game         string   `json:"game,omitempty"`    // game type
+	Role         string   `json:"role,omitempty"`    // what the player plays as, in games where the players differ
+	Actions      []string `json:"actions,omitempty"` // choices make_choice accepts
+}
+
+type TournamentUpdateMessage struct {
+	TournamentID string            `json:"tournament_id"`
+	Name         string            `json:"name"`
+	State        string            `json:"state"`   // "registering", "running", "finished"
+	Players      []string          `json:"players"` // in seed order
+	Rounds       []TournamentRound `json:"rounds"`  // first round first, empty while registering
+	Champion     string            `json:"champion,omitempty"`
+}
+
+// TournamentRound is one round of a tournament bracket
+type TournamentRound struct {
+	Matches []TournamentMatch `json:"matches"`
+}
+
+// TournamentMatch is one pairing of a tournament bracket
+type TournamentMatch struct {
+	Player1     string `json:"player1,omitempty"` // empty until decided, or for a bye
+	Player2     string `json:"player2,omitempty"`
+	Player1Wins int    `json:"player1_wins"`
+	Player2Wins int    `json:"player2_wins"`
+	Winner      string `json:"winner,omitempty"`
+	Status      string `json:"status"` // "pending", "waiting", "playing", "done", "bye", "no_show"
+}
+
+type LeagueUpdateMessage struct {
+	LeagueID    string            `json:"league_id"`
+	Name        string            `json:"name"`
+	Format      string            `json:"format"`                  // "round_robin", "swiss"
+	State       string            `json:"state"`                   // "registering", "running", "finished"
+	Round       int               `json:"round"`                   // current round starting at 1, 0 while registering
+	Rounds      int               `json:"rounds"`                  // rounds to play, 0 for a round robin that has not started
+	NextRoundAt int64             `json:"next_round_at,omitempty"` // unix time in seconds the next round opens, while waiting for it
+	Pairings    []TournamentMatch `json:"pairings"`                // of the current round
+	Standings   []LeagueStanding  `json:"standings"`               // best first
+}
+
+// LeagueStanding is one player's row of a league table. Players are ranked
+// by points, then Buchholz, head-to-head and round differential.
+type LeagueStanding struct {
+	Rank              int     `json:"rank"`
+	Player            string  `json:"player"`
+	Played            int     `json:"played"`
+	Wins              int     `json:"wins"` // including byes
+	Draws             int     `json:"draws"`
+	Losses            int     `json:"losses"`             // including no-shows
+	Points            float64 `json:"points"`             // 1 per win, 0.5 per draw
+	Buchholz          float64 `json:"buchholz"`           // sum of the opponents' points
+	HeadToHead        float64 `json:"head_to_head"`       // points against players level on points and Buchholz
+	RoundDifferential int     `json:"round_differential"` // rounds won minus rounds lost
+}
+
+type MatchListMessage struct {
+	Matches   []MatchInfo `json:"matches"`              // the player's correspondence matches, oldest first
+	PlayerKey string      `json:"player_key,omitempty"` // a newly issued key, keep it secret and send it with later create_match and list_matches
+}
+
+// MatchUpdateMessage is sent to both players whenever a correspondence
+// match starts, a move is made or it ends
+type MatchUpdateMessage struct {
+	Match MatchInfo `json:"match"`
+}
+
+// YourTurnMessage tells a player that their opponent in a correspondence
+// match has moved, or that the match started with them to move
+type YourTurnMessage struct {
+	MatchID  string `json:"match_id"`
+	Opponent string `json:"opponent"`
+	Deadline int64  `json:"deadline"` // unix time in seconds the player forfeits if they have not moved
+}
+
+// MatchInfo is a correspondence match as seen by one of its players
+type MatchInfo struct {
+	MatchID    string     `json:"match_id"`
+	Game       string     `json:"game"`
+	State      string     `json:"state"`              // "waiting" for an opponent, "active", "finished"
+	Opponent   string     `json:"opponent,omitempty"` // empty while waiting
+	Role       string     `json:"role,omitempty"`     // what the player plays as, e.g. "X"
+	Board      []string   `json:"board,omitempty"`    // as in board_update
+	MoveNumber int        `json:"move_number"`
+	YourTurn   bool       `json:"your_turn"`
+	Deadline   int64      `json:"deadline,omitempty"` // unix time in seconds the player to move forfeits
+	LastMove   *BoardMove `json:"last_move,omitempty"`
+	Result     string     `json:"result,omitempty"` // "win", "lose", "draw" once finished
 }
 
 type ErrorMessage struct {
-	Message string `json:"message"`
+	Code    string        `json:"code,omitempty"`    // e.g. "NAME_TAKEN", branch on this instead of message
+	Message string        `json:"message"`           // for display only
+	Ref     string        `json:"ref,omitempty"`     // type of the client message that caused the error
+	Details []ErrorDetail `json:"details,omitempty"` // e.g. the name that was taken
+}
+
+// ErrorDetail is one key/value pair of extra information about an error
+type ErrorDetail struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
 }
\ No newline at end of file
@@ -0,0 +1,116 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+)
+
+// Session numbers the events sent to one player and keeps the most recent
+// ones, so a client that reconnects can ask for everything it missed. A
+// session outlives the connection it was created for.
+type Session struct {
+	ID       string
+	mu       sync.Mutex
+	seq      uint64
+	events   []BaseGameEvent // unacknowledged events, oldest first
+	capacity int
+	// previous is the ID of a session the player asked to resume but that
+	// was gone, e.g. because the server restarted
+	previous string
+}
+
+// NewSession creates a session that replays at most capacity events
+func NewSession(id string, capacity int) *Session {
+	return &Session{ID: id, capacity: capacity}
+}
+
+// Stamp gives the event the next sequence number and records it for replay.
+// When the buffer is full the oldest event is forgotten.
+func (s *Session) Stamp(event BaseGameEvent) BaseGameEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	event.Seq = s.seq
+	if s.capacity > 0 {
+		if len(s.events) == s.capacity {
+			s.events = append(s.events[:0], s.events[1:]...)
+		}
+		s.events = append(s.events, event)
+	}
+	return event
+}
+
+// Ack forgets every event up to and including seq
+func (s *Session) Ack(seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	drop := 0
+	for drop < len(s.events) && s.events[drop].Seq <= seq {
+		drop++
+	}
+	s.events = append(s.events[:0], s.events[drop:]...)
+}
+
+// After returns the events sent after seq. It reports false if some of
+// them are no longer buffered, or if seq was never sent.
+func (s *Session) After(seq uint64) ([]BaseGameEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if seq > s.seq {
+		return nil, false
+	}
+	if seq == s.seq {
+		return nil, true
+	}
+	if len(s.events) == 0 || s.events[0].Seq > seq+1 {
+		return nil, false
+	}
+
+	start := int(seq + 1 - s.events[0].Seq)
+	return append([]BaseGameEvent(nil), s.events[start:]...), true
+}
+
+// LastSeq returns the sequence number of the newest event
+func (s *Session) LastSeq() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seq
+}
+
+// Succeed records the ID of a session the player presented but could not
+// resume, so games saved under it still recognise them
+func (s *Session) Succeed(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.previous = id
+}
+
+// Fingerprint identifies the session without revealing its ID, which lets
+// anyone who knows it play as the player. It is empty for a nil session.
+func (s *Session) Fingerprint() string {
+	if s == nil {
+		return ""
+	}
+	return fingerprint(s.ID)
+}
+
+// Claims reports whether the player presented the session with the given
+// fingerprint, as this session's ID or as the one it succeeded
+func (s *Session) Claims(fp string) bool {
+	if s == nil || fp == "" {
+		return false
+	}
+	s.mu.Lock()
+	previous := s.previous
+	s.mu.Unlock()
+	return fp == fingerprint(s.ID) || (previous != "" && fp == fingerprint(previous))
+}
+
+func fingerprint(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,77 @@
+package types
+
+import "testing"
+
+// stampN stamps n events on the session
+func stampN(s *Session, n int) {
+	for i := 0; i < n; i++ {
+		s.Stamp(BaseGameEvent{Type: "round_start"})
+	}
+}
+
+func TestSession_StampNumbersEvents(t *testing.T) {
+	s := NewSession("s", 8)
+
+	for want := uint64(1); want <= 3; want++ {
+		if event := s.Stamp(BaseGameEvent{Type: "round_start"}); event.Seq != want {
+			t.Errorf("Expected seq %d, got %d", want, event.Seq)
+		}
+	}
+	if s.LastSeq() != 3 {
+		t.Errorf("Expected last seq 3, got %d", s.LastSeq())
+	}
+}
+
+func TestSession_AfterReturnsMissedEvents(t *testing.T) {
+	s := NewSession("s", 8)
+	stampN(s, 5)
+
+	missed, ok := s.After(2)
+	if !ok {
+		t.Fatal("Expected events after seq 2 to be available")
+	}
+	if len(missed) != 3 || missed[0].Seq != 3 || missed[2].Seq != 5 {
+		t.Errorf("Expected seq 3 to 5, got %+v", missed)
+	}
+
+	if missed, ok := s.After(5); !ok || len(missed) != 0 {
+		t.Errorf("Expected nothing missed after the last seq, got %v %v", missed, ok)
+	}
+	if _, ok := s.After(6); ok {
+		t.Error("A seq that was never sent cannot be resumed")
+	}
+}
+
+func TestSession_FullBufferForgetsOldest(t *testing.T) {
+	s := NewSession("s", 3)
+	stampN(s, 5)
+
+	if _, ok := s.After(1); ok {
+		t.Error("Seq 2 was dropped, resuming after seq 1 should fail")
+	}
+	missed, ok := s.After(2)
+	if !ok || len(missed) != 3 || missed[0].Seq != 3 {
+		t.Errorf("Expected seq 3 to 5 to be kept, got %+v %v", missed, ok)
+	}
+}
+
+func TestSession_AckTrimsBuffer(t *testing.T) {
+	s := NewSession("s", 8)
+	stampN(s, 5)
+
+	s.Ack(3)
+
+	if _, ok := s.After(2); ok {
+		t.Error("Acked events should no longer be replayed")
+	}
+	missed, ok := s.After(3)
+	if !ok || len(missed) != 2 || missed[0].Seq != 4 {
+		t.Errorf("Expected seq 4 and 5 after ack, got %+v %v", missed, ok)
+	}
+
+	// Acking everything still allows resuming from the last seq
+	s.Ack(5)
+	if missed, ok := s.After(5); !ok || len(missed) != 0 {
+		t.Errorf("Expected an empty replay after acking everything, got %v %v", missed, ok)
+	}
+}
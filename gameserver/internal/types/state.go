@@ -0,0 +1,85 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ClientState is where a client is in its session lifecycle
+type ClientState int
+
+const (
+	// StateConnected is a fresh connection that has not picked a name
+	StateConnected ClientState = iota
+	// StateNamed has a name but is not queued for a match
+	StateNamed
+	// StateQueued is waiting in the lobby for an opponent
+	StateQueued
+	// StateInGame is playing in a game room
+	StateInGame
+	// StatePostGame has finished a game and may play again
+	StatePostGame
+	// StateSpectating is watching a game room without playing
+	StateSpectating
+)
+
+// String returns the wire name of the state
+func (s ClientState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateNamed:
+		return "named"
+	case StateQueued:
+		return "queued"
+	case StateInGame:
+		return "in_game"
+	case StatePostGame:
+		return "post_game"
+	case StateSpectating:
+		return "spectating"
+	default:
+		return fmt.Sprintf("state(%d)", int(s))
+	}
+}
+
+// transitions lists the states reachable from each state
+var transitions = map[ClientState][]ClientState{
+	StateConnected:  {StateNamed},
+	StateNamed:      {StateQueued, StateSpectating},
+	StateQueued:     {StateInGame, StateNamed},
+	StateInGame:     {StatePostGame},
+	StatePostGame:   {StateQueued, StateNamed, StateSpectating},
+	StateSpectating: {StateNamed, StateQueued},
+}
+
+// CanTransition reports whether the state table allows from -> to
+func CanTransition(from, to ClientState) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrInvalidTransition is wrapped by every TransitionError
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// TransitionError reports a transition the state table does not allow
+type TransitionError struct {
+	ClientID string
+	From     ClientState
+	To       ClientState
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("client %s cannot go from %s to %s", e.ClientID, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// TransitionObserver is called after every successful state transition
+type TransitionObserver func(client *Client, from, to ClientState)
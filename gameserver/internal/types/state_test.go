@@ -0,0 +1,92 @@
+package types
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClientState_Transitions(t *testing.T) {
+	tests := []struct {
+		from    ClientState
+		to      ClientState
+		allowed bool
+	}{
+		{StateConnected, StateNamed, true},
+		{StateConnected, StateQueued, false},
+		{StateConnected, StateInGame, false},
+		{StateNamed, StateQueued, true},
+		{StateNamed, StateSpectating, true},
+		{StateNamed, StateInGame, false},
+		{StateQueued, StateInGame, true},
+		{StateQueued, StateNamed, true},
+		{StateQueued, StatePostGame, false},
+		{StateInGame, StatePostGame, true},
+		{StateInGame, StateQueued, false},
+		{StatePostGame, StateQueued, true},
+		{StatePostGame, StateNamed, true},
+		{StatePostGame, StateInGame, false},
+		{StateSpectating, StateQueued, true},
+		{StateSpectating, StateInGame, false},
+	}
+
+	for _, test := range tests {
+		if got := CanTransition(test.from, test.to); got != test.allowed {
+			t.Errorf("CanTransition(%s, %s) = %v, expected %v", test.from, test.to, got, test.allowed)
+		}
+	}
+}
+
+func TestClient_InvalidTransition(t *testing.T) {
+	client := NewClient("test-789", nil)
+
+	err := client.Transition(StateInGame)
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("Expected ErrInvalidTransition, got %v", err)
+	}
+
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) || transitionErr.From != StateConnected || transitionErr.To != StateInGame {
+		t.Errorf("Unexpected transition error: %v", err)
+	}
+	if client.State() != StateConnected {
+		t.Errorf("State should be unchanged, got %s", client.State())
+	}
+}
+
+func TestClient_EnterGameAndObserve(t *testing.T) {
+	client := NewClient("test-790", nil)
+
+	var seen []ClientState
+	client.Observe(func(c *Client, from, to ClientState) {
+		seen = append(seen, to)
+	})
+
+	for _, state := range []ClientState{StateNamed, StateQueued} {
+		if err := client.Transition(state); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.EnterGame("room-1"); err != nil {
+		t.Fatal(err)
+	}
+	if client.GameRoomID() != "room-1" {
+		t.Errorf("Expected game room room-1, got %q", client.GameRoomID())
+	}
+
+	if err := client.Transition(StatePostGame); err != nil {
+		t.Fatal(err)
+	}
+	if client.GameRoomID() != "" {
+		t.Errorf("Game room should be cleared after the game, got %q", client.GameRoomID())
+	}
+
+	expected := []ClientState{StateNamed, StateQueued, StateInGame, StatePostGame}
+	if len(seen) != len(expected) {
+		t.Fatalf("Expected %d notifications, got %v", len(expected), seen)
+	}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Errorf("Notification %d: expected %s, got %s", i, expected[i], seen[i])
+		}
+	}
+}
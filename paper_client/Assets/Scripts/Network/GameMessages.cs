@@ -1,3 +1,6 @@
+// Code generated by cmd/protogen from internal/types/message.go. DO NOT EDIT.
+// Run `make protogen` in gameserver/ after changing the Go message definitions.
+
 using System;
 
 namespace Scripts.Network
@@ -7,42 +10,145 @@ namespace Scripts.Network
     {
         public string type;
         public T data;
+        public ulong seq;
+    }
+
+    public static class MessageTypes
+    {
+        public const string Hello = "hello";
+        public const string Ack = "ack";
+        public const string JoinLobby = "join_lobby";
+        public const string MakeChoice = "make_choice";
+        public const string MakeMove = "make_move";
+        public const string PlayAgain = "play_again";
+        public const string Spectate = "spectate";
+        public const string Disconnect = "disconnect";
+        public const string JoinTournament = "join_tournament";
+        public const string CreateMatch = "create_match";
+        public const string ListMatches = "list_matches";
+        public const string Welcome = "welcome";
+        public const string PlayerWaiting = "player_waiting";
+        public const string GameStarting = "game_starting";
+        public const string RoundResult = "round_result";
+        public const string RoundStart = "round_start";
+        public const string GameEnded = "game_ended";
+        public const string SpectateUpdate = "spectate_update";
+        public const string ServerShuttingDown = "server_shutting_down";
+        public const string GameResumed = "game_resumed";
+        public const string TournamentUpdate = "tournament_update";
+        public const string LeagueUpdate = "league_update";
+        public const string TeamVote = "team_vote";
+        public const string BoardUpdate = "board_update";
+        public const string MatchList = "match_list";
+        public const string MatchUpdate = "match_update";
+        public const string YourTurn = "your_turn";
+        public const string Error = "error";
     }
 
     // For sending messages with typed data
+    [Serializable]
+    public class HelloEvent
+    {
+        public string type = MessageTypes.Hello;
+        public HelloMessage data;
+    }
+
+    [Serializable]
+    public class AckEvent
+    {
+        public string type = MessageTypes.Ack;
+        public AckMessage data;
+    }
+
     [Serializable]
     public class JoinLobbyEvent
     {
-        public string type = "join_lobby";
+        public string type = MessageTypes.JoinLobby;
         public JoinLobbyMessage data;
     }
 
     [Serializable]
     public class MakeChoiceEvent
     {
-        public string type = "make_choice";
+        public string type = MessageTypes.MakeChoice;
         public MakeChoiceMessage data;
     }
 
+    [Serializable]
+    public class MakeMoveEvent
+    {
+        public string type = MessageTypes.MakeMove;
+        public MakeMoveMessage data;
+    }
+
     [Serializable]
     public class PlayAgainEvent
     {
-        public string type = "play_again";
+        public string type = MessageTypes.PlayAgain;
         public PlayAgainMessage data;
     }
 
+    [Serializable]
+    public class SpectateEvent
+    {
+        public string type = MessageTypes.Spectate;
+        public SpectateMessage data;
+    }
+
     [Serializable]
     public class DisconnectEvent
     {
-        public string type = "disconnect";
+        public string type = MessageTypes.Disconnect;
         public DisconnectMessage data;
     }
 
+    [Serializable]
+    public class JoinTournamentEvent
+    {
+        public string type = MessageTypes.JoinTournament;
+        public JoinTournamentMessage data;
+    }
+
+    [Serializable]
+    public class CreateMatchEvent
+    {
+        public string type = MessageTypes.CreateMatch;
+        public CreateMatchMessage data;
+    }
+
+    [Serializable]
+    public class ListMatchesEvent
+    {
+        public string type = MessageTypes.ListMatches;
+        public ListMatchesMessage data;
+    }
+
     // Client to Server Messages
+    [Serializable]
+    public class HelloMessage
+    {
+        public int protocol_version;
+        public string client_name;
+        public string client_version;
+        public string[] features; // "resume", "spectate", "rulesets"
+        public string session_id; // session to resume after a reconnect, or of games saved before a restart
+        public ulong last_seq; // last seq received in that session
+    }
+
+    [Serializable]
+    public class AckMessage
+    {
+        public ulong seq; // every event up to and including seq was received
+    }
+
     [Serializable]
     public class JoinLobbyMessage
     {
         public string name;
+        public int room_size; // players per game, more than 2 for a free-for-all
+        public bool teams; // play 2v2 in teams
+        public string teammate; // name of the player to team up with, anyone if empty
+        public string game; // game type, "rps" if empty
     }
 
     [Serializable]
@@ -51,19 +157,67 @@ namespace Scripts.Network
         public string choice; // "rock", "paper", "scissors"
     }
 
+    // MakeMoveMessage places a piece in a turn-based game. Cells count from 0, row 0 is the top row.
+    [Serializable]
+    public class MakeMoveMessage
+    {
+        public int row; // ignored where pieces drop, as in connect_four
+        public int column;
+        public string match_id; // a correspondence match to move in instead of the running game
+    }
+
     [Serializable]
     public class PlayAgainMessage
     {
         // Empty message
     }
 
+    [Serializable]
+    public class SpectateMessage
+    {
+        public string player_name; // name of a player in the game to watch
+    }
+
     [Serializable]
     public class DisconnectMessage
     {
         // Empty message
     }
 
+    [Serializable]
+    public class JoinTournamentMessage
+    {
+        public string tournament_id; // a tournament or league ID
+        public string name; // player name, for clients that have not joined the lobby
+    }
+
+    // CreateMatchMessage asks for a correspondence match against the longest waiting player of the same game
+    [Serializable]
+    public class CreateMatchMessage
+    {
+        public string game; // a turn-based game type
+        public string name; // player name, for clients that have not joined the lobby
+        public string player_key; // from an earlier match_list, empty to be issued a new one
+    }
+
+    [Serializable]
+    public class ListMatchesMessage
+    {
+        public string name; // player name, for clients that have not joined the lobby
+        public string player_key; // from an earlier match_list, empty to be issued a new one
+    }
+
     // Server to Client Messages
+    [Serializable]
+    public class WelcomeMessage
+    {
+        public int protocol_version;
+        public string server_version;
+        public string[] features; // features both sides support
+        public string session_id;
+        public bool resumed; // missed events follow, replayed with their original seq
+    }
+
     [Serializable]
     public class PlayerWaitingMessage
     {
@@ -73,44 +227,287 @@ namespace Scripts.Network
     [Serializable]
     public class GameStartingMessage
     {
-        public string opponent_name;
+        public string opponent_name; // in a free-for-all or team game every opponent, comma separated
+        public string[] opponents; // every opponent of a free-for-all or team game
+        public string teammate; // in a team game
+        public string captain; // whose vote counts when the team disagrees, if the captain decides
+        public string game; // game type of a two-player game
+        public string role; // what the player plays as, e.g. "matcher", in games where the players differ
+        public string[] actions; // choices make_choice accepts
     }
 
     [Serializable]
     public class RoundResultMessage
     {
-        public string result;        // "win", "lose", "draw"
-        public string your_choice;   // "rock", "paper", "scissors"
+        public string result; // "win", "lose", "draw"
+        public string your_choice; // "rock", "paper", "scissors"
         public string opponent_choice; // "rock", "paper", "scissors"
+        public int points; // earned this round, a won round is 1 unless the game pays more
+        public int opponent_points; // earned this round
+        public int total_points; // earned in the game so far
+        public int opponent_total_points; // earned in the game so far
+        public PlayerResult[] players; // every player of a free-for-all or team game
     }
 
     [Serializable]
     public class RoundStartMessage
     {
         public int round_number;
+        public HandCount[] your_hand; // actions left in a limited hand game
+        public HandCount[] opponent_hand; // actions left in a limited hand game
+        public bool sudden_death; // the game was tied, the first round won decides it
     }
 
     [Serializable]
     public class GameEndedMessage
     {
         public string result; // "win", "lose", "draw"
+        public int points; // final score of a two-player game
+        public int opponent_points; // final score of a two-player game
     }
 
     [Serializable]
-    public class ErrorMessage
+    public class SpectateUpdateMessage
+    {
+        public int round_number;
+        public string player1;
+        public string player2;
+        public int player1_wins;
+        public int player2_wins;
+        public string player1_choice; // set once the round is played
+        public string player2_choice; // set once the round is played
+        public bool game_over;
+    }
+
+    [Serializable]
+    public class ServerShuttingDownMessage
     {
+        public long deadline; // unix time in seconds when remaining connections are closed
         public string message;
     }
 
+    [Serializable]
+    public class GameResumedMessage
+    {
+        public string opponent_name;
+        public int round_number; // round the game continues with
+        public int your_wins;
+        public int opponent_wins;
+        public string game; // game type
+        public string role; // what the player plays as, in games where the players differ
+        public string[] actions; // choices make_choice accepts
+    }
+
+    [Serializable]
+    public class TournamentUpdateMessage
+    {
+        public string tournament_id;
+        public string name;
+        public string state; // "registering", "running", "finished"
+        public string[] players; // in seed order
+        public TournamentRound[] rounds; // first round first, empty while registering
+        public string champion;
+    }
+
+    [Serializable]
+    public class LeagueUpdateMessage
+    {
+        public string league_id;
+        public string name;
+        public string format; // "round_robin", "swiss"
+        public string state; // "registering", "running", "finished"
+        public int round; // current round starting at 1, 0 while registering
+        public int rounds; // rounds to play, 0 for a round robin that has not started
+        public long next_round_at; // unix time in seconds the next round opens, while waiting for it
+        public TournamentMatch[] pairings; // of the current round
+        public LeagueStanding[] standings; // best first
+    }
+
+    // TeamVoteMessage tells a player what their teammate voted for. Votes can change until the round locks.
+    [Serializable]
+    public class TeamVoteMessage
+    {
+        public string player;
+        public string choice; // "rock", "paper", "scissors"
+    }
+
+    // BoardUpdateMessage shows the board of a turn-based game after every move, and once before the first
+    [Serializable]
+    public class BoardUpdateMessage
+    {
+        public string[] board; // rows top first, a character per cell, "." if empty
+        public int move_number; // moves made so far
+        public string turn; // player to move next, empty once the game is over
+        public bool your_turn;
+        public BoardMove last_move; // the move that led to this board
+    }
+
+    [Serializable]
+    public class MatchListMessage
+    {
+        public MatchInfo[] matches; // the player's correspondence matches, oldest first
+        public string player_key; // a newly issued key, keep it secret and send it with later create_match and list_matches
+    }
+
+    // MatchUpdateMessage is sent to both players whenever a correspondence match starts, a move is made or it ends
+    [Serializable]
+    public class MatchUpdateMessage
+    {
+        public MatchInfo match;
+    }
+
+    // YourTurnMessage tells a player that their opponent in a correspondence match has moved, or that the match started with them to move
+    [Serializable]
+    public class YourTurnMessage
+    {
+        public string match_id;
+        public string opponent;
+        public long deadline; // unix time in seconds the player forfeits if they have not moved
+    }
+
+    [Serializable]
+    public class ErrorMessage
+    {
+        public string code; // e.g. "NAME_TAKEN", branch on this instead of message
+        public string message; // for display only
+        public string @ref; // type of the client message that caused the error
+        public ErrorDetail[] details; // e.g. the name that was taken
+    }
+
+    // Nested types
+    // PlayerResult is one player's round of a free-for-all or team game. In a team game the choice is the player's vote, result and points are the team's.
+    [Serializable]
+    public class PlayerResult
+    {
+        public string name;
+        public string choice; // empty if the player ran out of time or is out
+        public string result; // "win", "lose", "draw", empty if the player is out
+        public int points;
+        public bool eliminated; // knocked out or left, in this round or before
+    }
+
+    // HandCount is how often a player can still play an action
+    [Serializable]
+    public class HandCount
+    {
+        public string action;
+        public int count;
+    }
+
+    // TournamentRound is one round of a tournament bracket
+    [Serializable]
+    public class TournamentRound
+    {
+        public TournamentMatch[] matches;
+    }
+
+    // TournamentMatch is one pairing of a tournament bracket
+    [Serializable]
+    public class TournamentMatch
+    {
+        public string player1; // empty until decided, or for a bye
+        public string player2;
+        public int player1_wins;
+        public int player2_wins;
+        public string winner;
+        public string status; // "pending", "waiting", "playing", "done", "bye", "no_show"
+    }
+
+    // LeagueStanding is one player's row of a league table. Players are ranked by points, then Buchholz, head-to-head and round differential.
+    [Serializable]
+    public class LeagueStanding
+    {
+        public int rank;
+        public string player;
+        public int played;
+        public int wins; // including byes
+        public int draws;
+        public int losses; // including no-shows
+        public double points; // 1 per win, 0.5 per draw
+        public double buchholz; // sum of the opponents' points
+        public double head_to_head; // points against players level on points and Buchholz
+        public int round_differential; // rounds won minus rounds lost
+    }
+
+    // BoardMove is a move of a turn-based game
+    [Serializable]
+    public class BoardMove
+    {
+        public string player;
+        public int row; // where the piece landed
+        public int column;
+    }
+
+    // MatchInfo is a correspondence match as seen by one of its players
+    [Serializable]
+    public class MatchInfo
+    {
+        public string match_id;
+        public string game;
+        public string state; // "waiting" for an opponent, "active", "finished"
+        public string opponent; // empty while waiting
+        public string role; // what the player plays as, e.g. "X"
+        public string[] board; // as in board_update
+        public int move_number;
+        public bool your_turn;
+        public long deadline; // unix time in seconds the player to move forfeits
+        public BoardMove last_move;
+        public string result; // "win", "lose", "draw" once finished
+    }
+
+    // ErrorDetail is one key/value pair of extra information about an error
+    [Serializable]
+    public class ErrorDetail
+    {
+        public string key;
+        public string value;
+    }
+
     // Utility class for message handling
     public static class GameMessageHelper
     {
+        // Protocol version spoken by this client
+        public const int ProtocolVersion = 1;
+        
         // Send message helpers - convert C# objects to JSON
-        public static string CreateJoinLobby(string playerName)
+        public static string CreateHello(int protocolVersion, string clientName, string clientVersion, string[] features, string sessionId, ulong lastSeq)
+        {
+            var envelope = new HelloEvent
+            {
+                data = new HelloMessage
+                {
+                    protocol_version = protocolVersion,
+                    client_name = clientName,
+                    client_version = clientVersion,
+                    features = features,
+                    session_id = sessionId,
+                    last_seq = lastSeq
+                }
+            };
+            return UnityEngine.JsonUtility.ToJson(envelope);
+        }
+        
+        public static string CreateAck(ulong seq)
         {
-            var envelope = new JoinLobbyEvent 
-            { 
-                data = new JoinLobbyMessage { name = playerName }
+            var envelope = new AckEvent
+            {
+                data = new AckMessage { seq = seq }
+            };
+            return UnityEngine.JsonUtility.ToJson(envelope);
+        }
+        
+        public static string CreateJoinLobby(string name, int roomSize, bool teams, string teammate, string game)
+        {
+            var envelope = new JoinLobbyEvent
+            {
+                data = new JoinLobbyMessage
+                {
+                    name = name,
+                    room_size = roomSize,
+                    teams = teams,
+                    teammate = teammate,
+                    game = game
+                }
             };
             return UnityEngine.JsonUtility.ToJson(envelope);
         }
@@ -124,6 +521,20 @@ namespace Scripts.Network
             return UnityEngine.JsonUtility.ToJson(envelope);
         }
         
+        public static string CreateMakeMove(int row, int column, string matchId)
+        {
+            var envelope = new MakeMoveEvent
+            {
+                data = new MakeMoveMessage
+                {
+                    row = row,
+                    column = column,
+                    match_id = matchId
+                }
+            };
+            return UnityEngine.JsonUtility.ToJson(envelope);
+        }
+        
         public static string CreatePlayAgain()
         {
             var envelope = new PlayAgainEvent
@@ -133,6 +544,15 @@ namespace Scripts.Network
             return UnityEngine.JsonUtility.ToJson(envelope);
         }
         
+        public static string CreateSpectate(string playerName)
+        {
+            var envelope = new SpectateEvent
+            {
+                data = new SpectateMessage { player_name = playerName }
+            };
+            return UnityEngine.JsonUtility.ToJson(envelope);
+        }
+        
         public static string CreateDisconnect()
         {
             var envelope = new DisconnectEvent
@@ -142,20 +562,61 @@ namespace Scripts.Network
             return UnityEngine.JsonUtility.ToJson(envelope);
         }
         
+        public static string CreateJoinTournament(string tournamentId, string name)
+        {
+            var envelope = new JoinTournamentEvent
+            {
+                data = new JoinTournamentMessage
+                {
+                    tournament_id = tournamentId,
+                    name = name
+                }
+            };
+            return UnityEngine.JsonUtility.ToJson(envelope);
+        }
+        
+        public static string CreateCreateMatch(string game, string name, string playerKey)
+        {
+            var envelope = new CreateMatchEvent
+            {
+                data = new CreateMatchMessage
+                {
+                    game = game,
+                    name = name,
+                    player_key = playerKey
+                }
+            };
+            return UnityEngine.JsonUtility.ToJson(envelope);
+        }
+        
+        public static string CreateListMatches(string name, string playerKey)
+        {
+            var envelope = new ListMatchesEvent
+            {
+                data = new ListMatchesMessage
+                {
+                    name = name,
+                    player_key = playerKey
+                }
+            };
+            return UnityEngine.JsonUtility.ToJson(envelope);
+        }
+        
         // Receive message helpers - parse JSON to C# objects
         public static T ParseMessage<T>(string jsonData)
         {
             return UnityEngine.JsonUtility.FromJson<T>(jsonData);
         }
-        
+
         // Simple BaseGameEvent for parsing incoming messages
         [System.Serializable]
         public class IncomingGameEvent
         {
             public string type;
             public string data;
+            public ulong seq; // 0 for messages outside a session, e.g. welcome
         }
-        
+
         public static IncomingGameEvent ParseBaseEvent(string json)
         {
             try
@@ -164,22 +625,23 @@ namespace Scripts.Network
                 int typeStart = json.IndexOf("\"type\":\"") + 8;
                 int typeEnd = json.IndexOf("\"", typeStart);
                 string messageType = json.Substring(typeStart, typeEnd - typeStart);
-                
+                ulong seq = ParseSeq(json);
+
                 // Extract data part
                 int dataKeyIndex = json.IndexOf("\"data\":");
                 if (dataKeyIndex == -1)
                 {
-                    return new IncomingGameEvent { type = messageType, data = "{}" };
+                    return new IncomingGameEvent { type = messageType, data = "{}", seq = seq };
                 }
-                
+
                 // Find the start of data value (after the colon)
                 int colonIndex = json.IndexOf(":", dataKeyIndex);
                 int dataStart = colonIndex + 1;
-                
+
                 // Skip whitespace
                 while (dataStart < json.Length && char.IsWhiteSpace(json[dataStart]))
                     dataStart++;
-                
+
                 // Find the end of the data object
                 int dataEnd;
                 if (json[dataStart] == '{')
@@ -200,10 +662,10 @@ namespace Scripts.Network
                     // Handle other data types (shouldn't happen in our protocol)
                     dataEnd = json.LastIndexOf('}') - 1;
                 }
-                
+
                 string dataJson = json.Substring(dataStart, dataEnd - dataStart + 1);
-                
-                return new IncomingGameEvent { type = messageType, data = dataJson };
+
+                return new IncomingGameEvent { type = messageType, data = dataJson, seq = seq };
             }
             catch (System.Exception e)
             {
@@ -211,8 +673,32 @@ namespace Scripts.Network
                 return new IncomingGameEvent { type = "error", data = "{}" };
             }
         }
+
+        // ParseSeq reads the top level seq field. The server writes it after
+        // data, so search from the end to skip any seq inside the payload.
+        static ulong ParseSeq(string json)
+        {
+            int seqIndex = json.LastIndexOf("\"seq\":");
+            if (seqIndex == -1)
+            {
+                return 0;
+            }
+
+            int start = seqIndex + 6;
+            int end = start;
+            while (end < json.Length && char.IsDigit(json[end]))
+                end++;
+
+            ulong seq;
+            return ulong.TryParse(json.Substring(start, end - start), out seq) ? seq : 0;
+        }
         
         // Type-safe message parsing
+        public static WelcomeMessage ParseWelcome(string dataJson)
+        {
+            return ParseMessage<WelcomeMessage>(dataJson);
+        }
+        
         public static PlayerWaitingMessage ParsePlayerWaiting(string dataJson)
         {
             return ParseMessage<PlayerWaitingMessage>(dataJson);
@@ -238,9 +724,59 @@ namespace Scripts.Network
             return ParseMessage<GameEndedMessage>(dataJson);
         }
         
+        public static SpectateUpdateMessage ParseSpectateUpdate(string dataJson)
+        {
+            return ParseMessage<SpectateUpdateMessage>(dataJson);
+        }
+        
+        public static ServerShuttingDownMessage ParseServerShuttingDown(string dataJson)
+        {
+            return ParseMessage<ServerShuttingDownMessage>(dataJson);
+        }
+        
+        public static GameResumedMessage ParseGameResumed(string dataJson)
+        {
+            return ParseMessage<GameResumedMessage>(dataJson);
+        }
+        
+        public static TournamentUpdateMessage ParseTournamentUpdate(string dataJson)
+        {
+            return ParseMessage<TournamentUpdateMessage>(dataJson);
+        }
+        
+        public static LeagueUpdateMessage ParseLeagueUpdate(string dataJson)
+        {
+            return ParseMessage<LeagueUpdateMessage>(dataJson);
+        }
+        
+        public static TeamVoteMessage ParseTeamVote(string dataJson)
+        {
+            return ParseMessage<TeamVoteMessage>(dataJson);
+        }
+        
+        public static BoardUpdateMessage ParseBoardUpdate(string dataJson)
+        {
+            return ParseMessage<BoardUpdateMessage>(dataJson);
+        }
+        
+        public static MatchListMessage ParseMatchList(string dataJson)
+        {
+            return ParseMessage<MatchListMessage>(dataJson);
+        }
+        
+        public static MatchUpdateMessage ParseMatchUpdate(string dataJson)
+        {
+            return ParseMessage<MatchUpdateMessage>(dataJson);
+        }
+        
+        public static YourTurnMessage ParseYourTurn(string dataJson)
+        {
+            return ParseMessage<YourTurnMessage>(dataJson);
+        }
+        
         public static ErrorMessage ParseError(string dataJson)
         {
             return ParseMessage<ErrorMessage>(dataJson);
         }
     }
-}
\ No newline at end of file
+}
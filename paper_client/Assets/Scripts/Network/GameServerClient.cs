@@ -11,6 +11,12 @@ namespace Scripts.Network
         
         private WebSocket webSocket;
         
+        // Session state survives reconnects so missed events are replayed
+        private const int AckInterval = 16;
+        private string sessionId;
+        private ulong lastSeq;
+        private ulong lastAckedSeq;
+        
         public event Action<string, string> OnMessageReceived; // (messageType, dataJson)
         public event Action OnConnected;
         public event Action<WebSocketCloseCode> OnDisconnected;
@@ -41,6 +47,7 @@ namespace Scripts.Network
                 webSocket.OnOpen += () =>
                 {
                     Debug.Log("Connected to game server");
+                    SendHello();
                     OnConnected?.Invoke();
                 };
                 
@@ -50,7 +57,7 @@ namespace Scripts.Network
                     try
                     {
                         GameMessageHelper.IncomingGameEvent gameEvent = GameMessageHelper.ParseBaseEvent(jsonMessage);
-                        if (gameEvent != null)
+                        if (gameEvent != null && TrackSession(gameEvent))
                         {
                             OnMessageReceived?.Invoke(gameEvent.type, gameEvent.data);
                         }
@@ -96,6 +103,44 @@ namespace Scripts.Network
         }
         
         // Typed message methods
+        public void SendHello()
+        {
+            string[] features = { "resume" };
+            string message = GameMessageHelper.CreateHello(GameMessageHelper.ProtocolVersion, "unity", Application.version, features, sessionId, lastSeq);
+            SendMessage(message);
+        }
+        
+        // TrackSession records the session from welcome and the seq of every
+        // event, acking now and then. Returns false for events already seen.
+        private bool TrackSession(GameMessageHelper.IncomingGameEvent gameEvent)
+        {
+            if (gameEvent.type == MessageTypes.Welcome)
+            {
+                WelcomeMessage welcome = GameMessageHelper.ParseWelcome(gameEvent.data);
+                if (welcome.session_id != sessionId || !welcome.resumed)
+                {
+                    // New session, numbering starts over
+                    lastSeq = 0;
+                    lastAckedSeq = 0;
+                }
+                sessionId = welcome.session_id;
+                return true;
+            }
+            
+            if (gameEvent.seq == 0)
+                return true;
+            if (gameEvent.seq <= lastSeq)
+                return false;
+            
+            lastSeq = gameEvent.seq;
+            if (lastSeq - lastAckedSeq >= AckInterval)
+            {
+                lastAckedSeq = lastSeq;
+                SendMessage(GameMessageHelper.CreateAck(lastSeq));
+            }
+            return true;
+        }
+        
         public void JoinLobby(string playerName)
         {
             string message = GameMessageHelper.CreateJoinLobby(playerName);
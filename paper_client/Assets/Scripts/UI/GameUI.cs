@@ -111,6 +111,11 @@ namespace Scripts.UI
             
             switch (messageType)
             {
+                case "welcome":
+                    var welcomeMsg = GameMessageHelper.ParseWelcome(dataJson);
+                    Debug.Log($"Server {welcomeMsg.server_version} speaks protocol {welcomeMsg.protocol_version}");
+                    break;
+                    
                 case "player_waiting":
                     var waitingMsg = GameMessageHelper.ParsePlayerWaiting(dataJson);
                     // If we're already in game view, show waiting state
@@ -130,12 +135,57 @@ namespace Scripts.UI
                     gamePanel.ShowChoiceButtons(); // Make sure choice buttons are visible
                     break;
                     
+                case "game_resumed":
+                    var resumedMsg = GameMessageHelper.ParseGameResumed(dataJson);
+                    SwitchToGameView(resumedMsg.opponent_name);
+                    gamePanel.UpdateResultText($"Game resumed - You {resumedMsg.your_wins} : {resumedMsg.opponent_wins} Opponent");
+                    break;
+                    
+                case "tournament_update":
+                    var tournamentMsg = GameMessageHelper.ParseTournamentUpdate(dataJson);
+                    string tournamentStatus = tournamentMsg.state == "finished"
+                        ? $"{tournamentMsg.name}: {tournamentMsg.champion} wins the tournament!"
+                        : $"{tournamentMsg.name}: {tournamentMsg.state} with {tournamentMsg.players.Length} players";
+                    if (gamePanel.gameObject.activeInHierarchy)
+                    {
+                        gamePanel.UpdateResultText(tournamentStatus);
+                    }
+                    else
+                    {
+                        loginPanel.UpdateStatus(tournamentStatus);
+                    }
+                    break;
+                    
+                case "league_update":
+                    var leagueMsg = GameMessageHelper.ParseLeagueUpdate(dataJson);
+                    string leagueStatus = leagueMsg.state == "registering"
+                        ? $"{leagueMsg.name}: registering with {leagueMsg.standings.Length} players"
+                        : $"{leagueMsg.name}: round {leagueMsg.round} of {leagueMsg.rounds}, {leagueMsg.standings[0].player} leads";
+                    if (leagueMsg.state == "finished")
+                    {
+                        leagueStatus = $"{leagueMsg.name}: {leagueMsg.standings[0].player} wins the league!";
+                    }
+                    if (gamePanel.gameObject.activeInHierarchy)
+                    {
+                        gamePanel.UpdateResultText(leagueStatus);
+                    }
+                    else
+                    {
+                        loginPanel.UpdateStatus(leagueStatus);
+                    }
+                    break;
+                    
                 case "round_start":
                     var roundStartMsg = GameMessageHelper.ParseRoundStart(dataJson);
                     gamePanel.UpdateGameStatus($"Round {roundStartMsg.round_number} - Make your choice!");
                     gamePanel.ShowChoiceButtons(); // Show choice buttons for new round
                     break;
                     
+                case "team_vote":
+                    var voteMsg = GameMessageHelper.ParseTeamVote(dataJson);
+                    gamePanel.UpdateResultText($"{voteMsg.player} votes {voteMsg.choice}");
+                    break;
+                    
                 case "round_result":
                     var resultMsg = GameMessageHelper.ParseRoundResult(dataJson);
                     gamePanel.UpdateGameStatus($"You {resultMsg.result}!");
@@ -149,10 +199,34 @@ namespace Scripts.UI
                     gamePanel.ShowEndGameButtons(); // Show Play Again and Disconnect buttons
                     break;
                     
+                case "server_shutting_down":
+                    var shutdownMsg = GameMessageHelper.ParseServerShuttingDown(dataJson);
+                    long secondsLeft = System.Math.Max(0, shutdownMsg.deadline - System.DateTimeOffset.UtcNow.ToUnixTimeSeconds());
+                    loginPanel.UpdateStatus($"{shutdownMsg.message} ({secondsLeft}s left)");
+                    loginPanel.SetJoinButtonEnabled(false);
+                    gamePanel.UpdateResultText($"Server restarts in {secondsLeft}s");
+                    break;
+                    
                 case "error":
                     var errorMsg = GameMessageHelper.ParseError(dataJson);
-                    loginPanel.UpdateStatus($"Error: {errorMsg.message}");
-                    loginPanel.SetJoinButtonEnabled(true);
+                    switch (errorMsg.code)
+                    {
+                        case "NAME_TAKEN":
+                            loginPanel.UpdateStatus("That name is already taken, please pick another one");
+                            break;
+                        case "NAME_INVALID":
+                            loginPanel.UpdateStatus("Please enter a valid name!");
+                            break;
+                        case "MAINTENANCE":
+                            string eta = FindDetail(errorMsg, "eta");
+                            loginPanel.UpdateStatus(eta != null ? $"{errorMsg.message} (back at {eta})" : errorMsg.message);
+                            break;
+                        default:
+                            loginPanel.UpdateStatus($"Error: {errorMsg.message}");
+                            break;
+                    }
+                    // An outdated client cannot recover by retrying
+                    loginPanel.SetJoinButtonEnabled(errorMsg.code != "UPGRADE_REQUIRED");
                     break;
                     
                 default:
@@ -161,6 +235,19 @@ namespace Scripts.UI
             }
         }
         
+        // FindDetail returns the value of an error detail, or null if missing
+        static string FindDetail(ErrorMessage errorMsg, string key)
+        {
+            if (errorMsg.details == null)
+                return null;
+            foreach (var detail in errorMsg.details)
+            {
+                if (detail.key == key)
+                    return detail.value;
+            }
+            return null;
+        }
+        
         void OnError(string error)
         {
             loginPanel.UpdateStatus($"Error: {error}");